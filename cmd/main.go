@@ -1,23 +1,69 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 
 	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/diagnosis"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
 	"github.com/fyerfyer/fan-atpg/pkg/utils"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "equiv" {
+		runEquivalence(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "remove-redundancy" {
+		runRedundancyRemoval(os.Args[2:])
+		return
+	}
+
 	// Parse command-line arguments
-	circuitFile := flag.String("circuit", "", "Circuit file in BENCH format")
-	faultStr := flag.String("fault", "", "Fault to test (e.g., 'net42/1' for net42 stuck-at-1)")
+	circuitFile := flag.String("circuit", "", "Circuit file in BENCH or Verilog (.v) format")
+	faultStr := flag.String("fault", "", "Fault to test (e.g., 'net42/1' for net42 stuck-at-1, 'n12->g7/0' for the n12 branch into g7)")
 	allFaults := flag.Bool("all", false, "Generate tests for all faults")
-	outputFile := flag.String("output", "tests.txt", "Output file for test vectors")
-	compactTests := flag.Bool("compact", true, "Compact test vectors")
+	outputFile := flag.String("output", "tests.txt", "Output file for test vectors, in STIL format if it ends in .stil")
+	compactTests := flag.Bool("compact", true, "Compact the finished test vectors")
+	dynamicCompact := flag.Bool("dynamic-compact", false, "Target further faults with the inputs each new test leaves unassigned")
+	merge := flag.Bool("merge", true, "Merge compacted tests whose specified inputs do not conflict")
+	setCover := flag.Bool("set-cover", false, "Compact the finished tests with a greedy set cover instead of reverse-order fault simulation")
+	maxSecondary := flag.Int("max-secondary", algorithm.DefaultCompactionOptions().MaxSecondary, "Secondary faults targeted per test by dynamic compaction (0 for every undetected fault)")
+	dominance := flag.Bool("dominance", false, "Collapse the fault list by dominance as well as equivalence")
+	defaults := algorithm.DefaultOptions()
+	maxBacktracks := flag.Int("max-backtracks", defaults.MaxBacktracks, "Backtracks per fault before it is aborted (0 for no limit)")
+	maxDecisions := flag.Int("max-decisions", defaults.MaxDecisions, "Decisions per fault before it is aborted (0 for no limit)")
+	maxImplications := flag.Int("max-implications", defaults.MaxImplications, "Iterations of an implication pass before the fault is aborted (0 for no limit)")
+	timeout := flag.Duration("timeout", 0, "Time budget per fault, e.g. 500ms (0 for no limit)")
+	jobs := flag.Int("jobs", 1, "Number of faults targeted in parallel with -all")
+	model := flag.String("model", "stuck-at", "Fault model: stuck-at, transition (launch-on-capture two-pattern tests) or bridge")
+	bridgeFile := flag.String("bridges", "", "File of line pairs to target with -model bridge, one 'a b [wand|wor|dom]' per line")
+	bridgeModel := flag.String("bridge-model", circuit.WiredAND.String(), "Model of bridges listed without one: wand, wor or dom")
+	randomDefaults := algorithm.DefaultRandomOptions()
+	randomPatterns := flag.Int("random", 0, "Random patterns applied before deterministic ATPG with -all (0 disables the random phase)")
+	randomBlock := flag.Int("random-block", randomDefaults.BlockSize, "Random patterns fault-simulated between two coverage checks")
+	randomGain := flag.Float64("random-min-gain", randomDefaults.MinGain, "End the random phase when a block detects less than this fraction of the faults")
+	randomWeights := flag.String("random-weights", "", "Probability of a 1 per input for weighted-random patterns, e.g. 'G0=0.9,G3=0.25'")
+	verify := flag.Bool("verify", true, "Verify the final tests by independent good/faulty machine simulation before writing them")
+	fill := flag.String("fill", circuit.FillNone.String(), "Fill of the inputs a test leaves unassigned: none, zero, one, random (seeded by -seed) or adjacent")
+	seed := flag.Int64("seed", randomDefaults.Seed, "Seed of the random pattern generator")
+	failLog := flag.String("diagnose", "", "Tester fail log to diagnose, one 'pattern output...' per line; needs -patterns")
+	patternFile := flag.String("patterns", "", "Pattern file the fail log of -diagnose refers to, as written by -output")
+	diagnosisMode := flag.String("diagnosis", diagnosis.FullResponse.String(), "Diagnosis mode: pass-fail, full-response (dictionaries) or effect-cause")
+	maxCandidates := flag.Int("candidates", 10, "Candidate faults reported by -diagnose (0 for all)")
+	heuristic := flag.String("heuristic", defaults.Heuristic.String(), "Backtrace and D-frontier guidance: scoap or structural")
+	learn := flag.Bool("learn", false, "Learn indirect implications of the circuit first and apply them during implication")
+	writeFile := flag.String("write", "", "Write the parsed circuit to a BENCH or Verilog (.v) file, e.g. to convert between the formats")
+	validate := flag.String("validate", "", "Severity of BENCH netlist problems, e.g. 'unused=error,loop=warning' (problems: all, syntax, unknown-gate, arity, undriven, multiple-drivers, loop, unused; severities: error, warning, ignore)")
+	libFile := flag.String("lib", "", "Cell library (JSON) of the cells the netlist instantiates")
 	verbose := flag.Bool("verbose", false, "Verbose output")
 	logFile := flag.String("log", "", "Log file (default: stdout)")
 	flag.Parse()
@@ -48,77 +94,156 @@ func main() {
 		os.Exit(1)
 	}
 
-	if !*allFaults && *faultStr == "" {
+	if *failLog != "" {
+		if *patternFile == "" {
+			fmt.Println("Error: -diagnose needs the -patterns file the fail log refers to")
+			flag.Usage()
+			os.Exit(1)
+		}
+	} else if *model == "bridge" {
+		if *bridgeFile == "" {
+			fmt.Println("Error: -model bridge needs a -bridges file")
+			flag.Usage()
+			os.Exit(1)
+		}
+	} else if !*allFaults && *faultStr == "" && *writeFile == "" {
 		fmt.Println("Error: Either specify a fault or use -all flag")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	guidance, err := algorithm.ParseHeuristic(*heuristic)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	if *model != "stuck-at" && *model != "transition" && *model != "bridge" {
+		logger.Error("unknown fault model %q (expected stuck-at, transition or bridge)", *model)
+		os.Exit(1)
+	}
+	defaultBridge, err := circuit.ParseBridgeModel(*bridgeModel)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	fillPolicy, err := circuit.ParseFillPolicy(*fill)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	mode, err := diagnosis.ParseMode(*diagnosisMode)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	policy, err := utils.ParseValidationPolicy(*validate)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+
+	library, err := loadLibrary(*libFile, logger)
+	if err != nil {
+		logger.Error("Failed to load cell library: %v", err)
+		os.Exit(1)
+	}
+
 	// Parse circuit file
 	logger.Info("Parsing circuit from %s", *circuitFile)
-	c, err := utils.ParseBenchFile(*circuitFile)
+	c, err := parseNetlist(*circuitFile, policy, library, logger)
 	if err != nil {
 		logger.Error("Failed to parse circuit: %v", err)
 		os.Exit(1)
 	}
 
+	if *writeFile != "" {
+		logger.Info("Writing circuit to %s", *writeFile)
+		if err := utils.WriteNetlistFile(*writeFile, c); err != nil {
+			logger.Error("Failed to write circuit: %v", err)
+			os.Exit(1)
+		}
+		if *failLog == "" && !*allFaults && *faultStr == "" {
+			return
+		}
+	}
+
+	if *failLog != "" {
+		runDiagnosis(c, logger, *failLog, *patternFile, mode, *maxCandidates)
+		return
+	}
+
 	// Create FAN algorithm instance
 	fan := algorithm.NewFan(c, logger)
+	fan.DominanceCollapsing = *dominance
+	fan.Options.MaxBacktracks = *maxBacktracks
+	fan.Options.MaxDecisions = *maxDecisions
+	fan.Options.MaxImplications = *maxImplications
+	fan.Options.Timeout = *timeout
+	fan.Jobs = *jobs
+	fan.Options.Heuristic = guidance
+	fan.Options.Learning = *learn
+	fan.Compaction.Enabled = *dynamicCompact
+	fan.Compaction.MaxSecondary = *maxSecondary
+	fan.Compaction.Merge = *merge
+	fan.Compaction.SetCover = *setCover
+	fan.Random.MaxPatterns = *randomPatterns
+	fan.Random.BlockSize = *randomBlock
+	fan.Random.MinGain = *randomGain
+	fan.Random.Seed = *seed
+	fan.Random.Weights, err = utils.ParseInputWeights(*randomWeights, c)
+	if err != nil {
+		logger.Error("Invalid random weights: %v", err)
+		os.Exit(1)
+	}
+
+	// Interrupting the run stops test generation but keeps the tests found so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *model == "transition" {
+		runTransitionATPG(ctx, c, fan.Options, logger, *faultStr, *allFaults, *outputFile, fillPolicy, *seed, *verify)
+		return
+	}
+	if *model == "bridge" {
+		runBridgeATPG(ctx, fan, logger, *bridgeFile, defaultBridge, *outputFile, fillPolicy, *verify)
+		return
+	}
 
 	var testVectors map[string]map[string]circuit.LogicValue
+	var fault circuit.Fault
 
 	if *allFaults {
 		// Generate tests for all faults
 		logger.Info("Generating tests for all faults")
-		testVectors, err = fan.GenerateTestsForAllFaults()
-		if err != nil {
-			logger.Error("Error generating tests: %v", err)
-			os.Exit(1)
-		}
+		testVectors, err = fan.GenerateTestsForAllFaultsContext(ctx)
+		checkGenerationError(ctx, logger, err, len(testVectors))
 	} else {
 		// Generate test for specific fault
 		logger.Info("Generating test for fault: %s", *faultStr)
 
-		lineName, faultTypeStr, found := strings.Cut(*faultStr, "/")
-		if !found {
-			logger.Error("Invalid fault format: %s (expected: net/value)", *faultStr)
-			os.Exit(1)
-		}
-
-		// Find line by name
-		var faultLine *circuit.Line
-		for _, line := range c.Lines {
-			if line.Name == lineName {
-				faultLine = line
-				break
-			}
-		}
-
-		if faultLine == nil {
-			logger.Error("Line not found: %s", lineName)
-			os.Exit(1)
-		}
-
-		// Parse fault type
-		var faultType circuit.LogicValue
-		if faultTypeStr == "0" {
-			faultType = circuit.Zero
-		} else if faultTypeStr == "1" {
-			faultType = circuit.One
-		} else {
-			logger.Error("Invalid fault type: %s (expected: 0 or 1)", faultTypeStr)
+		fault, err = utils.ParseFault(*faultStr, c)
+		if err != nil {
+			logger.Error("Invalid fault %s: %v (expected: net/value or stem->gate/value)", *faultStr, err)
 			os.Exit(1)
 		}
 
 		// Generate test
-		test, err := fan.FindTest(faultLine, faultType)
-		if err != nil {
-			logger.Error("Failed to find test: %v", err)
-			os.Exit(1)
+		result := fan.GenerateTestContext(ctx, fault)
+		switch result.Status {
+		case algorithm.Redundant:
+			logger.Info("Fault %s is redundant: %s", fault, result.Reason)
+			os.Exit(2)
+		case algorithm.Aborted:
+			if result.Mismatch != nil {
+				logger.Error("%v", result.Err())
+				os.Exit(1)
+			}
+			logger.Error("Test generation for %s aborted: %s", fault, result.Reason)
+			os.Exit(3)
 		}
 
 		testVectors = make(map[string]map[string]circuit.LogicValue)
-		testVectors[*faultStr] = test
+		testVectors[*faultStr] = result.Test
 	}
 
 	// Compact tests if requested
@@ -134,10 +259,24 @@ func main() {
 		}
 	}
 
+	// Fill and verify the tests that are written
+	finalTests = c.FillTests(finalTests, fillPolicy, fan.Random.Seed)
+	if *verify {
+		if *allFaults {
+			err = fan.VerifyTests(finalTests)
+		} else {
+			err = fan.Verifier.Verify(finalTests[0], fault)
+		}
+		if err != nil {
+			logger.Error("Verification of the final tests failed: %v", err)
+			os.Exit(1)
+		}
+		logger.Info("Final tests verified by independent simulation")
+	}
+
 	// Write output file
 	logger.Info("Writing %d test vectors to %s", len(finalTests), *outputFile)
-	err = utils.WriteTestVectors(*outputFile, finalTests)
-	if err != nil {
+	if err := writeTests(*outputFile, fan, finalTests, fillPolicy); err != nil {
 		logger.Error("Error writing test vectors: %v", err)
 		os.Exit(1)
 	}
@@ -147,7 +286,395 @@ func main() {
 	logger.Info("Circuit: %s", c.Name)
 	logger.Info("Gates: %d", len(c.Gates))
 	logger.Info("Lines: %d", len(c.Lines))
-	logger.Info("Primary inputs: %d", len(c.Inputs))
-	logger.Info("Primary outputs: %d", len(c.Outputs))
+	logger.Info("Primary inputs: %d", len(c.PrimaryInputs()))
+	logger.Info("Primary outputs: %d", len(c.PrimaryOutputs()))
+	if len(c.ScanCells) > 0 {
+		logger.Info("Scan cells: %d", len(c.ScanCells))
+	}
 	logger.Info("Tests generated: %d", len(finalTests))
+	if *allFaults {
+		if fan.Stats.RandomPatterns > 0 {
+			logger.Info("Random patterns: %d (seed %d), detecting %d faults",
+				fan.Stats.RandomPatterns, fan.Random.Seed, fan.Stats.RandomDetected)
+		}
+		logger.Info("Redundant faults: %d", fan.Stats.RedundantFaults)
+		logger.Info("Aborted faults: %d", fan.Stats.AbortedFaults)
+		logger.Info("Fault coverage: %.2f%%", fan.Stats.FaultCoverage()*100)
+		logger.Info("Test efficiency: %.2f%%", fan.Stats.TestEfficiency()*100)
+	}
+}
+
+// checkGenerationError exits on an error of a full test generation run. An
+// interrupted run keeps the tests found so far, unless a test failed verification.
+func checkGenerationError(ctx context.Context, logger *utils.Logger, err error, found int) {
+	if err == nil {
+		return
+	}
+	if ctx.Err() == nil || errors.Is(err, simulation.ErrNotDetected) {
+		logger.Error("Error generating tests: %v", err)
+		os.Exit(1)
+	}
+	logger.Warning("Test generation interrupted, keeping %d tests found so far", found)
+}
+
+// writeTests writes filled test vectors in STIL if the file name ends in .stil,
+// and in the text format of the circuit otherwise. fill is recorded in the file.
+func writeTests(outputFile string, fan *algorithm.Fan, tests []map[string]circuit.LogicValue, fill circuit.FillPolicy) error {
+	c := fan.Circuit
+	switch {
+	case strings.EqualFold(filepath.Ext(outputFile), ".stil"):
+		return utils.WriteSTIL(outputFile, c, tests, fan.Simulator.Responses(tests), fill)
+	case len(c.ScanCells) > 0:
+		return utils.WriteScanTestVectors(outputFile, c, tests, fill)
+	default:
+		return utils.WriteTestVectors(outputFile, tests, fill)
+	}
+}
+
+// loadLibrary reads the cell library of -lib, nil if no file is given
+func loadLibrary(filename string, logger *utils.Logger) (*utils.CellLibrary, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	logger.Info("Loading cell library from %s", filename)
+	library, err := utils.LoadCellLibrary(filename)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Cell library %s: %d cells", library.Name, len(library.Cells))
+	return library, nil
+}
+
+// parseNetlist reads a netlist, logging every problem its validation reports.
+// The problems of Error severity are logged one per line and summed up by the
+// returned error. library may be nil.
+func parseNetlist(filename string, policy utils.ValidationPolicy, library *utils.CellLibrary, logger *utils.Logger) (*circuit.Circuit, error) {
+	c, diagnostics, err := utils.ParseNetlistFileWithLibrary(filename, policy, library)
+	for _, d := range diagnostics {
+		switch d.Severity {
+		case utils.Error:
+			logger.Error("%v", d)
+		case utils.Warning:
+			logger.Warning("%v", d)
+		}
+	}
+	var invalid *utils.ValidationError
+	if errors.As(err, &invalid) {
+		return nil, errors.New(invalid.Summary(filepath.Base(filename)))
+	}
+	return c, err
+}
+
+// runEquivalence checks two circuits for equivalence with FAN on their miter.
+// It exits with status 0 if they are equivalent, 2 with a counterexample if they
+// are not, 3 if the search stopped at a limit and 1 on an error.
+func runEquivalence(args []string) {
+	flags := flag.NewFlagSet("equiv", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s equiv [flags] a.bench b.bench\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	defaults := algorithm.DefaultOptions()
+	maxBacktracks := flags.Int("max-backtracks", 0, "Backtracks before the check is abandoned (0 for no limit)")
+	maxDecisions := flags.Int("max-decisions", 0, "Decisions before the check is abandoned (0 for no limit)")
+	maxImplications := flags.Int("max-implications", 0, "Iterations of an implication pass before the check is abandoned (0 for no limit)")
+	timeout := flags.Duration("timeout", 0, "Time budget of the check, e.g. 10s (0 for no limit)")
+	heuristic := flags.String("heuristic", defaults.Heuristic.String(), "Backtrace and D-frontier guidance: scoap or structural")
+	learn := flags.Bool("learn", false, "Learn indirect implications of the circuit first and apply them during implication")
+	miterFile := flags.String("miter", "", "Write the miter to a BENCH or Verilog (.v) file")
+	libFile := flags.String("lib", "", "Cell library (JSON) of the cells the netlists instantiate")
+	verbose := flags.Bool("verbose", false, "Verbose output")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	logLevel := utils.InfoLevel
+	if *verbose {
+		logLevel = utils.DebugLevel
+	}
+	logger := utils.NewLogger(logLevel)
+	guidance, err := algorithm.ParseHeuristic(*heuristic)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+
+	library, err := loadLibrary(*libFile, logger)
+	if err != nil {
+		logger.Error("Failed to load cell library: %v", err)
+		os.Exit(1)
+	}
+
+	var circuits [2]*circuit.Circuit
+	for i, file := range flags.Args() {
+		logger.Info("Parsing circuit from %s", file)
+		if circuits[i], err = parseNetlist(file, utils.DefaultValidationPolicy(), library, logger); err != nil {
+			logger.Error("Failed to parse circuit: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	checker, err := algorithm.NewEquivalenceChecker(circuits[0], circuits[1], logger)
+	if err != nil {
+		logger.Error("Cannot compare the circuits: %v", err)
+		os.Exit(1)
+	}
+	checker.Fan.Options.MaxBacktracks = *maxBacktracks
+	checker.Fan.Options.MaxDecisions = *maxDecisions
+	checker.Fan.Options.MaxImplications = *maxImplications
+	checker.Fan.Options.Timeout = *timeout
+	checker.Fan.Options.Heuristic = guidance
+	checker.Fan.Options.Learning = *learn
+	if *miterFile != "" {
+		logger.Info("Writing miter to %s", *miterFile)
+		if err := utils.WriteNetlistFile(*miterFile, checker.Miter.Circuit); err != nil {
+			logger.Error("Failed to write miter: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result := checker.CheckContext(ctx)
+
+	switch result.Status {
+	case algorithm.Equivalent:
+		logger.Info("Circuits %s and %s are equivalent", circuits[0].Name, circuits[1].Name)
+	case algorithm.NotEquivalent:
+		logger.Info("Circuits %s and %s are not equivalent", circuits[0].Name, circuits[1].Name)
+		logger.Info("Differing outputs: %s", strings.Join(result.Outputs, " "))
+		for _, input := range checker.Miter.Circuit.Inputs {
+			logger.Info("  %s = %v", input.Name, result.Counterexample[input.Name])
+		}
+		os.Exit(2)
+	default:
+		logger.Error("Equivalence check undecided: %s", result.Reason)
+		os.Exit(3)
+	}
+}
+
+// runRedundancyRemoval removes the redundant logic of a circuit and writes the
+// simplified netlist in BENCH or Verilog format. It exits with status 0 once no
+// redundancy is left, 3 if it was interrupted and 1 on an error.
+func runRedundancyRemoval(args []string) {
+	flags := flag.NewFlagSet("remove-redundancy", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s remove-redundancy [flags] in.bench out.bench\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	defaults := algorithm.DefaultOptions()
+	maxBacktracks := flags.Int("max-backtracks", defaults.MaxBacktracks, "Backtracks per fault before it is aborted and kept (0 for no limit)")
+	maxDecisions := flags.Int("max-decisions", defaults.MaxDecisions, "Decisions per fault before it is aborted and kept (0 for no limit)")
+	maxImplications := flags.Int("max-implications", defaults.MaxImplications, "Iterations of an implication pass before the fault is aborted and kept (0 for no limit)")
+	timeout := flags.Duration("timeout", 0, "Time budget of the whole removal, e.g. 1m (0 for no limit)")
+	heuristic := flags.String("heuristic", defaults.Heuristic.String(), "Backtrace and D-frontier guidance: scoap or structural")
+	learn := flags.Bool("learn", false, "Learn indirect implications of the circuit first and apply them during implication")
+	libFile := flags.String("lib", "", "Cell library (JSON) of the cells the netlist instantiates")
+	verbose := flags.Bool("verbose", false, "Verbose output")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	logLevel := utils.InfoLevel
+	if *verbose {
+		logLevel = utils.DebugLevel
+	}
+	logger := utils.NewLogger(logLevel)
+	guidance, err := algorithm.ParseHeuristic(*heuristic)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+
+	library, err := loadLibrary(*libFile, logger)
+	if err != nil {
+		logger.Error("Failed to load cell library: %v", err)
+		os.Exit(1)
+	}
+
+	logger.Info("Parsing circuit from %s", flags.Arg(0))
+	c, err := parseNetlist(flags.Arg(0), utils.DefaultValidationPolicy(), library, logger)
+	if err != nil {
+		logger.Error("Failed to parse circuit: %v", err)
+		os.Exit(1)
+	}
+	gates := len(c.Gates)
+
+	remover := algorithm.NewRedundancyRemover(c, logger)
+	remover.Options.MaxBacktracks = *maxBacktracks
+	remover.Options.MaxDecisions = *maxDecisions
+	remover.Options.MaxImplications = *maxImplications
+	remover.Options.Heuristic = guidance
+	remover.Options.Learning = *learn
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	simplified, err := remover.RunContext(ctx)
+
+	logger.Info("Writing simplified circuit to %s: %d gates of %d", flags.Arg(1), len(simplified.Gates), gates)
+	if err := utils.WriteNetlistFile(flags.Arg(1), simplified); err != nil {
+		logger.Error("Failed to write circuit: %v", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Error("Redundancy removal interrupted, the circuit may still be redundant: %v", err)
+		os.Exit(3)
+	}
+}
+
+// runDiagnosis ranks the stuck-at faults that explain the failures of a tester
+// fail log for the patterns of patternFile
+func runDiagnosis(c *circuit.Circuit, logger *utils.Logger, failLog, patternFile string, mode diagnosis.Mode, maxCandidates int) {
+	patterns, err := utils.ReadTestVectors(patternFile, c)
+	if err != nil {
+		logger.Error("Failed to read patterns: %v", err)
+		os.Exit(1)
+	}
+	failures, err := diagnosis.ParseFailLog(failLog, c)
+	if err != nil {
+		logger.Error("Failed to parse fail log: %v", err)
+		os.Exit(1)
+	}
+	if len(failures) == 0 {
+		logger.Error("Fail log %s records no failures", failLog)
+		os.Exit(1)
+	}
+
+	logger.Info("Diagnosing %d failures of %d patterns (%v)", len(failures), len(patterns), mode)
+	candidates, err := diagnosis.NewDiagnoser(c, patterns, logger).Diagnose(failures, mode)
+	if err != nil {
+		logger.Error("Diagnosis failed: %v", err)
+		os.Exit(1)
+	}
+	if len(candidates) == 0 {
+		logger.Info("No stuck-at fault explains any of the failures")
+		os.Exit(2)
+	}
+
+	if maxCandidates > 0 && len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	for i, candidate := range candidates {
+		logger.Info("%2d. %v", i+1, candidate)
+	}
+}
+
+// runBridgeATPG generates tests for the bridging faults listed in bridgeFile,
+// fills and verifies them and writes them to outputFile
+func runBridgeATPG(ctx context.Context, fan *algorithm.Fan, logger *utils.Logger,
+	bridgeFile string, defaultModel circuit.BridgeModel, outputFile string, fill circuit.FillPolicy, verify bool) {
+	bridges, err := utils.ParseBridgeFile(bridgeFile, fan.Circuit, defaultModel)
+	if err != nil {
+		logger.Error("Failed to parse bridges: %v", err)
+		os.Exit(1)
+	}
+
+	atpg := algorithm.NewBridgeATPG(fan.Circuit, logger)
+	atpg.Fan = fan
+	tests, err := atpg.GenerateTestsForAllFaultsContext(ctx, bridges)
+	checkGenerationError(ctx, logger, err, len(tests))
+
+	tests = fan.Circuit.FillTests(tests, fill, fan.Random.Seed)
+	if verify {
+		if err := atpg.VerifyTests(bridges, tests); err != nil {
+			logger.Error("Verification of the final tests failed: %v", err)
+			os.Exit(1)
+		}
+		logger.Info("Final tests verified by independent simulation")
+	}
+
+	logger.Info("Writing %d test vectors to %s", len(tests), outputFile)
+	if err := writeTests(outputFile, fan, tests, fill); err != nil {
+		logger.Error("Error writing test vectors: %v", err)
+		os.Exit(1)
+	}
+
+	logger.Info("ATPG complete")
+	logger.Info("Circuit: %s", fan.Circuit.Name)
+	logger.Info("Bridges: %d", len(bridges))
+	logger.Info("Tests generated: %d", len(tests))
+	logger.Info("Redundant bridges: %d", atpg.Stats.RedundantFaults)
+	logger.Info("Aborted bridges: %d", atpg.Stats.AbortedFaults)
+	logger.Info("Bridging fault coverage: %.2f%%", atpg.Stats.FaultCoverage()*100)
+	logger.Info("Test efficiency: %.2f%%", atpg.Stats.TestEfficiency()*100)
+}
+
+// runTransitionATPG generates launch-on-capture two-pattern tests for one
+// transition fault or for all of them, fills and verifies them and writes them
+// to outputFile
+func runTransitionATPG(ctx context.Context, c *circuit.Circuit, options algorithm.Options,
+	logger *utils.Logger, faultStr string, allFaults bool, outputFile string, fill circuit.FillPolicy, seed int64, verify bool) {
+	atpg := algorithm.NewTransitionATPG(c, logger)
+	atpg.Fan.Options = options
+
+	var tests []circuit.TwoPatternTest
+	var fault circuit.TransitionFault
+	var err error
+	if allFaults {
+		logger.Info("Generating tests for all transition faults")
+		tests, err = atpg.GenerateTestsForAllFaultsContext(ctx)
+		checkGenerationError(ctx, logger, err, len(tests))
+	} else {
+		logger.Info("Generating test for transition fault: %s", faultStr)
+		fault, err = utils.ParseTransitionFault(faultStr, c)
+		if err != nil {
+			logger.Error("Invalid fault %s: %v (expected: net/STR, net/STF or stem->gate/STR)", faultStr, err)
+			os.Exit(1)
+		}
+
+		result := atpg.GenerateTestContext(ctx, fault)
+		switch result.Status {
+		case algorithm.Redundant:
+			logger.Info("Fault %s is untestable: %s", fault, result.Reason)
+			os.Exit(2)
+		case algorithm.Aborted:
+			if result.Mismatch != nil {
+				logger.Error("%v", result.Err())
+				os.Exit(1)
+			}
+			logger.Error("Test generation for %s aborted: %s", fault, result.Reason)
+			os.Exit(3)
+		}
+		tests = append(tests, *result.Test)
+	}
+
+	tests = c.FillTwoPatternTests(tests, fill, seed)
+	if verify {
+		if allFaults {
+			err = atpg.VerifyTests(tests)
+		} else {
+			target, initial := atpg.Frames.Target(fault)
+			err = atpg.Fan.Verifier.Verify(atpg.Frames.ExpandedTest(tests[0]), target, initial)
+		}
+		if err != nil {
+			logger.Error("Verification of the final tests failed: %v", err)
+			os.Exit(1)
+		}
+		logger.Info("Final tests verified by independent simulation")
+	}
+
+	logger.Info("Writing %d two-pattern tests to %s", len(tests), outputFile)
+	if err := utils.WriteTwoPatternTests(outputFile, c, tests, fill); err != nil {
+		logger.Error("Error writing test vectors: %v", err)
+		os.Exit(1)
+	}
+
+	logger.Info("ATPG complete")
+	logger.Info("Circuit: %s", c.Name)
+	logger.Info("Tests generated: %d", len(tests))
+	if allFaults {
+		logger.Info("Untestable faults: %d", atpg.Stats.RedundantFaults)
+		logger.Info("Aborted faults: %d", atpg.Stats.AbortedFaults)
+		logger.Info("Transition fault coverage: %.2f%%", atpg.Stats.FaultCoverage()*100)
+		logger.Info("Test efficiency: %.2f%%", atpg.Stats.TestEfficiency()*100)
+	}
 }
@@ -128,8 +128,10 @@ func (b *Backtrace) DirectBacktrace(targetLine *circuit.Line, targetValue circui
 	return line, value
 }
 
-// GetNextObjective determines what to target next in the FAN algorithm
-// Returns the line to assign, value to try, and boolean indicating if the algorithm should continue
+// GetNextObjective determines what to target next in the FAN algorithm.
+// Returns the line to assign, value to try, and boolean indicating if the algorithm should continue.
+// Decisions are made on head lines whose fanout-free cone is still unassigned,
+// since any value on them can be justified later, and on primary inputs.
 func (b *Backtrace) GetNextObjective() (*circuit.Line, circuit.LogicValue, bool) {
 	// First, check if we need to activate the fault (fault excitation)
 	if b.Circuit.FaultSite != nil && !b.Circuit.FaultSite.IsAssigned() {
@@ -142,80 +144,249 @@ func (b *Backtrace) GetNextObjective() (*circuit.Line, circuit.LogicValue, bool)
 		b.Logger.Algorithm("Need to activate fault at %s (stuck-at-%v) with value %v",
 			b.Circuit.FaultSite.Name, b.Circuit.FaultType, targetValue)
 
-		// If fault site is a head line or PI, assign it directly
-		if b.Circuit.FaultSite.IsHeadLine || b.Circuit.FaultSite.Type == circuit.PrimaryInput {
-			b.Logger.Algorithm("Fault site is head line or PI, assigning directly")
+		// If fault site is a PI, assign it directly
+		if b.Circuit.FaultSite.Type == circuit.PrimaryInput {
+			b.Logger.Algorithm("Fault site is a PI, assigning directly")
 			return b.Circuit.FaultSite, targetValue, true
 		}
 
-		// Important fix: Check if there's already a decision made for an input that would control the fault site
-		// If we've already decided on all inputs to the gate driving the fault site, we shouldn't try again
-		if b.Circuit.FaultSite.InputGate != nil {
-			allInputsAssigned := true
-			for _, input := range b.Circuit.FaultSite.InputGate.Inputs {
-				if !input.IsAssigned() {
-					allInputsAssigned = false
-					break
-				}
-			}
-
-			if allInputsAssigned {
-				b.Logger.Algorithm("All inputs to fault site gate are already assigned, skipping fault activation")
-				// Skip to next objective (D-frontier or J-frontier)
-				goto checkDFrontier
-			}
-		}
-
 		// Otherwise, backtrace to control it
 		line, value := b.DirectBacktrace(b.Circuit.FaultSite, targetValue)
-		if line == nil {
-			b.Logger.Algorithm("Direct backtrace failed to find an objective")
-			return nil, circuit.X, false
-		}
+		return b.objectiveAtInput(b.Circuit.FaultSite, targetValue, line, value)
+	}
+
+	// A fault site that holds its stuck-at value can no longer be activated
+	if b.Circuit.FaultSite != nil && b.Circuit.FaultSite.Value == b.Circuit.FaultType {
+		b.Logger.Algorithm("Fault site %s is set to its stuck-at value, backtracking needed",
+			b.Circuit.FaultSite.Name)
+		return nil, circuit.X, false
+	}
 
-		// Check if the line we're about to decide on is already assigned
-		if line.IsAssigned() {
-			b.Logger.Algorithm("Line %s is already assigned to %v, skipping", line.Name, line.Value)
-			// Skip to next objective (D-frontier or J-frontier)
-			goto checkDFrontier
+	// The test must also justify the constraints on good values
+	for _, cons := range b.Circuit.Constraints {
+		switch cons.Line.GetGoodValue() {
+		case cons.Value:
+			continue
+		case circuit.X:
+			b.Logger.Algorithm("Need to justify constraint %s = %v", cons.Line.Name, cons.Value)
+			line, value := b.DirectBacktrace(cons.Line, cons.Value)
+			return b.objectiveAtInput(cons.Line, cons.Value, line, value)
+		default:
+			b.Logger.Algorithm("Constraint %s = %v is violated, backtracking needed", cons.Line.Name, cons.Value)
+			return nil, circuit.X, false
 		}
+	}
 
-		b.Logger.Algorithm("Direct backtrace found objective: %s = %v", line.Name, value)
-		return line, value, true
+	// Once the fault effect reaches an output only justification is left
+	if b.Circuit.CheckTestStatus() {
+		goto checkJFrontier
 	}
 
-checkDFrontier:
 	// Check if there's a D-frontier to propagate
 	if len(b.Frontier.DFrontier) > 0 {
 		b.Logger.Algorithm("D-frontier exists with %d gates, finding objective to propagate",
 			len(b.Frontier.DFrontier))
+
+		// The fault effect is blocked if no D-frontier gate reaches an output over X lines
+		if b.Circuit.FaultSite != nil && !b.CheckXPath() {
+			b.Logger.Algorithm("No X-path from the D-frontier to an output, backtracking needed")
+			return nil, circuit.X, false
+		}
+
+		objs := b.Frontier.GetObjectivesFromDFrontier()
 		line, value := b.BacktraceFromDFrontier()
-		if line != nil {
-			return line, value, true
+		if len(objs) > 0 {
+			return b.objectiveAtInput(objs[0].Line, objs[0].Value, line, value)
 		}
+	} else if b.Circuit.FaultSite != nil {
+		// The fault is activated but its effect cannot propagate any further
+		b.Logger.Algorithm("D-frontier is empty, backtracking needed")
+		return nil, circuit.X, false
 	}
 
+checkJFrontier:
 	// Check if there's a J-frontier to justify
 	if len(b.Frontier.JFrontier) > 0 {
 		b.Logger.Algorithm("J-frontier exists with %d gates, finding objective to justify",
 			len(b.Frontier.JFrontier))
+		objs := b.Frontier.GetObjectivesFromJFrontier()
 		line, value := b.BacktraceFromJFrontier()
-		if line != nil {
-			return line, value, true
+		if len(objs) > 0 {
+			return b.objectiveAtInput(objs[0].Line, objs[0].Value, line, value)
 		}
 	}
 
 	// Check if test is already complete
-	if b.Circuit.CheckTestStatus() {
+	if b.Circuit.CheckTestStatus() && len(b.Frontier.JFrontier) == 0 {
 		b.Logger.Algorithm("Test already complete, no more objectives needed")
 		return nil, circuit.X, true
 	}
 
-	// If we get here, we can't make progress
+	// No objective could be traced, but deciding an unassigned input still
+	// keeps the search complete
+	if input := b.unassignedInput(); input != nil {
+		b.Logger.Algorithm("No viable objectives found, deciding on input %s", input.Name)
+		return input, circuit.Zero, true
+	}
 	b.Logger.Algorithm("No viable objectives found, backtracking needed")
 	return nil, circuit.X, false
 }
 
+// unassignedInput returns the first unassigned primary input, or nil if every
+// input has a value
+func (b *Backtrace) unassignedInput() *circuit.Line {
+	for _, input := range b.Circuit.Inputs {
+		if !input.IsAssigned() {
+			return input
+		}
+	}
+	return nil
+}
+
+// objectiveAtInput turns the result of multiple backtrace into a decision on a
+// head line or primary input. If multiple backtrace found no unassigned head
+// line or input, the initial objective is traced directly instead.
+func (b *Backtrace) objectiveAtInput(objLine *circuit.Line, objValue circuit.LogicValue,
+	line *circuit.Line, value circuit.LogicValue) (*circuit.Line, circuit.LogicValue, bool) {
+	if line == nil || line.IsAssigned() {
+		line, value = objLine, objValue
+	}
+
+	line, value = b.TraceToDecision(line, value)
+	if line == nil || line.IsAssigned() {
+		// Deciding any unassigned input keeps the search complete
+		line, value = b.unassignedInput(), circuit.Zero
+		if line == nil {
+			b.Logger.Algorithm("Backtrace failed to reach an unassigned head line or primary input")
+			return nil, circuit.X, false
+		}
+	}
+
+	b.Logger.Algorithm("Next objective: %s = %v", line.Name, value)
+	return line, value, true
+}
+
+// TraceToDecision follows unassigned gate inputs back from a line to a head line
+// that can be decided on or a primary input, adjusting the target value for
+// inverting gates along the way
+func (b *Backtrace) TraceToDecision(line *circuit.Line, value circuit.LogicValue) (*circuit.Line, circuit.LogicValue) {
+	cone := b.Implication.faultCone()
+	for line.Type != circuit.PrimaryInput && !b.isDecisionHead(line, cone) {
+		gate := line.InputGate
+		if gate == nil {
+			return nil, circuit.X // Undriven line
+		}
+
+		// A cell is traced through the easiest cube that gives the value
+		if gate.Type.HasTruthTable() {
+			cube, ok := easiestCube(gate, value, b.MBT.Measures)
+			if !ok {
+				return nil, circuit.X
+			}
+			var next *circuit.Line
+			for i, input := range gate.Inputs {
+				literal := cube.Literal(i)
+				if literal == circuit.X || input.IsAssigned() {
+					continue
+				}
+				if next == nil || b.harderInput(input, literal, next, value) {
+					next, value = input, literal
+				}
+			}
+			if next == nil {
+				return nil, circuit.X
+			}
+			line = next
+			continue
+		}
+
+		if gate.Type == circuit.NAND || gate.Type == circuit.NOR ||
+			gate.Type == circuit.NOT || gate.Type == circuit.XNOR {
+			value = oppositeBinaryValue(value)
+		}
+
+		var next *circuit.Line
+		parity := circuit.Zero
+		for _, input := range gate.Inputs {
+			if input.IsAssigned() {
+				if input.GetGoodValue() == circuit.One {
+					parity = oppositeBinaryValue(parity)
+				}
+				continue
+			}
+			if next == nil || b.preferInput(gate, input, next, value) {
+				next = input
+			}
+		}
+		if next == nil {
+			return nil, circuit.X
+		}
+
+		// XOR-type gates need the input value that gives the target parity
+		if (gate.Type == circuit.XOR || gate.Type == circuit.XNOR) && parity == circuit.One {
+			value = oppositeBinaryValue(value)
+		}
+		line = next
+	}
+	return line, value
+}
+
+// isDecisionHead reports whether a head line can be decided on: it lies outside
+// the fanout of the fault, and the lines of its fanout-free cone are unassigned
+// and driven by primitive gates, so that either value can be justified later
+// without a conflict
+func (b *Backtrace) isDecisionHead(line *circuit.Line, cone map[*circuit.Line]bool) bool {
+	if !line.IsHeadLine || line.IsAssigned() || cone[line] {
+		return false
+	}
+
+	stack := []*circuit.Line{line}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current != line && (current.IsAssigned() || len(current.OutputGates) != 1) {
+			return false
+		}
+		if current.Type == circuit.PrimaryInput {
+			continue
+		}
+		gate := current.InputGate
+		if gate == nil || len(gate.Inputs) == 0 || gate.Type.HasTruthTable() {
+			return false
+		}
+		stack = append(stack, gate.Inputs...)
+	}
+	return true
+}
+
+// preferInput reports whether input is a better choice than current to set to
+// value when tracing back through gate. If one input at the controlling value
+// is enough the easiest input is chosen, if all inputs need the value the
+// hardest one is chosen first. Without measures the first input is kept.
+func (b *Backtrace) preferInput(gate *circuit.Gate, input, current *circuit.Line, value circuit.LogicValue) bool {
+	m := b.MBT.Measures
+	if m == nil {
+		return false
+	}
+
+	cost, currentCost := m.Controllability(input, value), m.Controllability(current, value)
+	if controlling := gate.GetControllingValue(); controlling != circuit.X && value != controlling {
+		return cost > currentCost
+	}
+	return cost < currentCost
+}
+
+// harderInput reports whether input is harder to set to value than current is
+// to set to currentValue. Every input a cube specifies has to be set, so the
+// hardest one is chosen first. Without measures the first input is kept.
+func (b *Backtrace) harderInput(input *circuit.Line, value circuit.LogicValue, current *circuit.Line, currentValue circuit.LogicValue) bool {
+	m := b.MBT.Measures
+	if m == nil {
+		return false
+	}
+	return m.Controllability(input, value) > m.Controllability(current, currentValue)
+}
+
 // CheckXPath checks if there's a potential path to propagate D/D' to outputs
 func (b *Backtrace) CheckXPath() bool {
 	return b.Implication.CheckIfXPathExists()
@@ -0,0 +1,207 @@
+package algorithm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// BridgeResult holds the outcome of test generation for a single bridging fault
+type BridgeResult struct {
+	Fault  circuit.Bridge
+	Status TestStatus
+	Test   map[string]circuit.LogicValue // Test vector, nil unless the bridge is detected
+	Reason string                        // Why the bridge is redundant or aborted
+
+	// Mismatch is set when the search claimed a test that independent
+	// verification rejects; the bridge is then reported aborted
+	Mismatch error
+}
+
+// Err returns an error describing why no test was found, or nil for a detected bridge
+func (r *BridgeResult) Err() error {
+	if r.Status == Detected {
+		return nil
+	}
+	if r.Mismatch != nil {
+		return fmt.Errorf("no test for %s: %w", r.Fault, r.Mismatch)
+	}
+	return fmt.Errorf("no test for %s (%s): %s", r.Fault, strings.ToLower(r.Status.String()), r.Reason)
+}
+
+// BridgeATPG generates tests for bridging faults. Each bridge is reduced to
+// stuck-at faults on one of its lines with a required value on the other line,
+// see Bridge.Targets, which are targeted in turn with the FAN search.
+type BridgeATPG struct {
+	Circuit *circuit.Circuit
+	Logger  *utils.Logger
+	Fan     *Fan // FAN instance whose Options apply to every bridge
+	Stats   Stats
+
+	// Results holds the outcome of every bridge of the last full run
+	Results map[circuit.Bridge]*BridgeResult
+}
+
+// NewBridgeATPG creates a bridging fault test generator for a circuit
+func NewBridgeATPG(c *circuit.Circuit, logger *utils.Logger) *BridgeATPG {
+	return &BridgeATPG{
+		Circuit: c,
+		Logger:  logger,
+		Fan:     NewFan(c, logger),
+	}
+}
+
+// GenerateTest runs test generation for a bridging fault
+func (b *BridgeATPG) GenerateTest(bridge circuit.Bridge) *BridgeResult {
+	return b.GenerateTestContext(context.Background(), bridge)
+}
+
+// GenerateTestContext is GenerateTest with a context. A bridge is redundant
+// only if every one of its targets is; if none is detected and one is aborted,
+// the bridge is aborted.
+func (b *BridgeATPG) GenerateTestContext(ctx context.Context, bridge circuit.Bridge) *BridgeResult {
+	if err := b.Circuit.ValidateBridge(bridge); err != nil {
+		return &BridgeResult{Fault: bridge, Status: Aborted, Reason: err.Error()}
+	}
+	defer func() { b.Circuit.Constraints = nil }()
+
+	result := &BridgeResult{Fault: bridge, Status: Redundant}
+	var reasons []string
+	for _, target := range bridge.Targets() {
+		b.Logger.Info("Targeting %s as %s with %s = %v",
+			bridge, target.Fault, target.Condition.Line.Name, target.Condition.Value)
+		b.Circuit.Constraints = []circuit.Constraint{target.Condition}
+		stuck := b.Fan.GenerateTestContext(ctx, target.Fault)
+
+		switch stuck.Status {
+		case Detected:
+			return &BridgeResult{Fault: bridge, Status: Detected, Test: stuck.Test}
+		case Aborted:
+			result.Status = Aborted
+		}
+		if stuck.Mismatch != nil {
+			result.Mismatch = stuck.Mismatch
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", target.Fault, stuck.Reason))
+	}
+	result.Reason = strings.Join(reasons, "; ")
+	return result
+}
+
+// GenerateTestsForAllFaults generates tests for a list of bridges. Every new
+// test is fault-simulated against the remaining bridges, and the bridges it
+// detects are dropped.
+func (b *BridgeATPG) GenerateTestsForAllFaults(bridges []circuit.Bridge) ([]map[string]circuit.LogicValue, error) {
+	return b.GenerateTestsForAllFaultsContext(context.Background(), bridges)
+}
+
+// GenerateTestsForAllFaultsContext is GenerateTestsForAllFaults with a context.
+// When the context is done the bridges not yet targeted are reported as aborted
+// and the tests found so far are returned with ctx.Err().
+func (b *BridgeATPG) GenerateTestsForAllFaultsContext(ctx context.Context, bridges []circuit.Bridge) ([]map[string]circuit.LogicValue, error) {
+	startTime := time.Now()
+	b.Logger.Info("Starting test generation for %d bridging faults", len(bridges))
+
+	tests := make([]map[string]circuit.LogicValue, 0)
+	b.Results = make(map[circuit.Bridge]*BridgeResult)
+	b.Stats = Stats{}
+	remaining := make([]circuit.Bridge, len(bridges))
+	copy(remaining, bridges)
+	var mismatches []error
+
+	for len(remaining) > 0 {
+		if ctx.Err() != nil {
+			b.Logger.Warning("Test generation cancelled with %d bridges left", len(remaining))
+			for _, bridge := range remaining {
+				b.Results[bridge] = &BridgeResult{Fault: bridge, Status: Aborted, Reason: ctx.Err().Error()}
+			}
+			b.Stats.AbortedFaults += len(remaining)
+			break
+		}
+
+		bridge := remaining[0]
+		remaining = remaining[1:]
+		result := b.GenerateTestContext(ctx, bridge)
+		b.Results[bridge] = result
+		if result.Mismatch != nil {
+			mismatches = append(mismatches, result.Mismatch)
+		}
+		switch result.Status {
+		case Redundant:
+			b.Stats.RedundantFaults++
+			continue
+		case Aborted:
+			b.Stats.AbortedFaults++
+			continue
+		}
+
+		// Only count the test once fault simulation confirms it detects the target
+		patterns := []map[string]circuit.LogicValue{result.Test}
+		if b.Fan.Simulator.DetectBridges(patterns, []circuit.Bridge{bridge})[0] != 0 {
+			b.Logger.Warning("Test %v for %s is not confirmed by fault simulation, discarding it", result.Test, bridge)
+			result.Status = Aborted
+			result.Test = nil
+			result.Reason = "test not confirmed by fault simulation"
+			b.Stats.AbortedFaults++
+			continue
+		}
+		b.Stats.TestsFound++
+		tests = append(tests, result.Test)
+
+		// Drop every remaining bridge the new test also detects
+		kept := remaining[:0]
+		for i, first := range b.Fan.Simulator.DetectBridges(patterns, remaining) {
+			if first < 0 {
+				kept = append(kept, remaining[i])
+				continue
+			}
+			b.Results[remaining[i]] = &BridgeResult{Fault: remaining[i], Status: Detected, Test: result.Test}
+			b.Stats.DroppedFaults++
+		}
+		remaining = kept
+	}
+
+	b.Stats.UndetectedFaults = b.Stats.RedundantFaults + b.Stats.AbortedFaults
+	b.Stats.TotalTime = time.Since(startTime)
+	b.Logger.Info("Bridging test generation completed for %d bridges", len(bridges))
+	b.Logger.Info("Tests found: %d", b.Stats.TestsFound)
+	b.Logger.Info("Bridges dropped by fault simulation: %d", b.Stats.DroppedFaults)
+	b.Logger.Info("Undetected bridges: %d (%d redundant, %d aborted)",
+		b.Stats.UndetectedFaults, b.Stats.RedundantFaults, b.Stats.AbortedFaults)
+
+	return tests, errors.Join(verificationError(mismatches), ctx.Err())
+}
+
+// VerifyTests checks with the independent verifier that every bridge of the last
+// full run reported as detected is detected by one of the tests, that is one of
+// the tests detects one of its targets. It returns an error naming the bridges
+// none of them detects.
+func (b *BridgeATPG) VerifyTests(bridges []circuit.Bridge, tests []map[string]circuit.LogicValue) error {
+	var missed []string
+	for _, bridge := range bridges {
+		if result := b.Results[bridge]; result == nil || result.Status != Detected {
+			continue
+		}
+		detected := false
+		for _, test := range tests {
+			for _, target := range bridge.Targets() {
+				if b.Fan.Verifier.Detects(test, target.Fault, target.Condition) {
+					detected = true
+					break
+				}
+			}
+			if detected {
+				break
+			}
+		}
+		if !detected {
+			missed = append(missed, bridge.String())
+		}
+	}
+	return missedError(missed, "bridges")
+}
@@ -0,0 +1,257 @@
+package algorithm
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// compactTest performs dynamic compaction of a new test: the undetected faults
+// are targeted in turn with the inputs the test assigns held fixed, and every
+// secondary test found becomes the new test. It stops when no input is left
+// unassigned or after f.Compaction.MaxSecondary faults.
+func (f *Fan) compactTest(ctx context.Context, test map[string]circuit.LogicValue, faults []circuit.Fault) map[string]circuit.LogicValue {
+	saved := f.Options
+	f.Options.MaxBacktracks = f.Compaction.MaxBacktracks
+	defer func() {
+		f.Options = saved
+		f.Decision.Fixed = nil
+	}()
+
+	// Only faults the test does not detect yet are worth targeting
+	candidates, _ := f.Simulator.DropDetected([]map[string]circuit.LogicValue{test}, faults)
+	targeted, detected := 0, 0
+	for _, fault := range candidates {
+		if ctx.Err() != nil || (f.Compaction.MaxSecondary > 0 && targeted >= f.Compaction.MaxSecondary) {
+			break
+		}
+
+		f.Decision.Fixed = make(map[*circuit.Line]circuit.LogicValue)
+		for _, input := range f.Circuit.Inputs {
+			if value := test[input.Name]; value == circuit.Zero || value == circuit.One {
+				f.Decision.Fixed[input] = value
+			}
+		}
+		if len(f.Decision.Fixed) == len(f.Circuit.Inputs) {
+			break // Every input is assigned
+		}
+
+		targeted++
+		result := f.GenerateTestContext(ctx, fault)
+		if result.Status == Detected {
+			test = result.Test
+			detected++
+		}
+	}
+
+	f.Logger.Info("Dynamic compaction: detected %d of %d targeted secondary faults", detected, targeted)
+	return test
+}
+
+// CompactTests compacts a finished test set without losing coverage of the
+// faults it detects. Tests shared by several faults are listed once, tests whose
+// specified inputs do not conflict are merged if f.Compaction.Merge is set, and
+// the tests are fault-simulated in reverse order so that every test detecting no
+// new fault is dropped. With f.Compaction.SetCover a greedy set cover of the
+// detection matrix is kept instead.
+func (f *Fan) CompactTests(testVectors map[string]map[string]circuit.LogicValue) []map[string]circuit.LogicValue {
+	f.Logger.Info("Compacting test vectors")
+
+	tests := f.distinctTests(testVectors)
+	distinct := len(tests)
+	if f.Compaction.Merge {
+		tests = f.mergeTests(tests)
+	}
+	merged := len(tests)
+
+	faults := f.compactionFaults()
+	if f.Compaction.SetCover {
+		tests = f.coverTests(tests, faults)
+	} else {
+		tests = f.reverseOrderTests(tests, faults)
+	}
+
+	f.Logger.Info("Compacted %d tests to %d tests (%d distinct, %d after merging)",
+		len(testVectors), len(tests), distinct, merged)
+	return tests
+}
+
+// compactionFaults returns the faults whose detection compaction must preserve:
+// the full fault universe of the circuit
+func (f *Fan) compactionFaults() []circuit.Fault {
+	if f.FaultList != nil {
+		return f.FaultList.All
+	}
+	return circuit.NewFaultList(f.Circuit, false).All
+}
+
+// distinctTests returns every distinct test once, ordered by its input values
+func (f *Fan) distinctTests(testVectors map[string]map[string]circuit.LogicValue) []map[string]circuit.LogicValue {
+	keys := make([]string, 0, len(testVectors))
+	distinct := make(map[string]map[string]circuit.LogicValue)
+	for _, vector := range testVectors {
+		key := testKey(f.Circuit, vector)
+		if _, ok := distinct[key]; !ok {
+			keys = append(keys, key)
+			distinct[key] = vector
+		}
+	}
+	sort.Strings(keys)
+
+	tests := make([]map[string]circuit.LogicValue, 0, len(keys))
+	for _, key := range keys {
+		tests = append(tests, distinct[key])
+	}
+	return tests
+}
+
+// mergeTests merges every test into the first earlier test whose specified
+// inputs do not conflict with it. A merged test specifies every input either
+// test specifies, so it detects every fault both tests detect.
+func (f *Fan) mergeTests(tests []map[string]circuit.LogicValue) []map[string]circuit.LogicValue {
+	merged := make([]map[string]circuit.LogicValue, 0, len(tests))
+	for _, test := range tests {
+		target := -1
+		for i, m := range merged {
+			if f.compatible(m, test) {
+				target = i
+				break
+			}
+		}
+		if target < 0 {
+			copied := make(map[string]circuit.LogicValue, len(test))
+			for name, value := range test {
+				copied[name] = value
+			}
+			merged = append(merged, copied)
+			continue
+		}
+		for _, input := range f.Circuit.Inputs {
+			if value := test[input.Name]; isSpecified(value) {
+				merged[target][input.Name] = value
+			}
+		}
+	}
+	return merged
+}
+
+// compatible reports whether no input is specified with different values in two tests
+func (f *Fan) compatible(a, b map[string]circuit.LogicValue) bool {
+	for _, input := range f.Circuit.Inputs {
+		va, vb := a[input.Name], b[input.Name]
+		if isSpecified(va) && isSpecified(vb) && va != vb {
+			return false
+		}
+	}
+	return true
+}
+
+// reverseOrderTests fault-simulates the tests from last to first and keeps only
+// the tests that detect a fault no later test detects
+func (f *Fan) reverseOrderTests(tests []map[string]circuit.LogicValue, faults []circuit.Fault) []map[string]circuit.LogicValue {
+	reversed := make([]map[string]circuit.LogicValue, len(tests))
+	for i, test := range tests {
+		reversed[len(tests)-1-i] = test
+	}
+
+	needed := make([]bool, len(tests))
+	for _, first := range f.Simulator.Detect(reversed, faults) {
+		if first >= 0 {
+			needed[len(tests)-1-first] = true
+		}
+	}
+
+	kept := make([]map[string]circuit.LogicValue, 0, len(tests))
+	for i, test := range tests {
+		if needed[i] {
+			kept = append(kept, test)
+		}
+	}
+	return kept
+}
+
+// coverTests builds the detection matrix of the tests and keeps a greedy set
+// cover of it: the test detecting the most uncovered faults is picked until
+// every detected fault is covered, then picked tests made unnecessary by later
+// picks are removed again
+func (f *Fan) coverTests(tests []map[string]circuit.LogicValue, faults []circuit.Fault) []map[string]circuit.LogicValue {
+	detects := make([][]int, len(tests)) // Faults detected by each test
+	for i, test := range tests {
+		for j, first := range f.Simulator.Detect([]map[string]circuit.LogicValue{test}, faults) {
+			if first == 0 {
+				detects[i] = append(detects[i], j)
+			}
+		}
+	}
+
+	covered := make([]int, len(faults)) // Number of picked tests detecting each fault
+	picked := make([]bool, len(tests))
+	var order []int
+	for {
+		best, bestGain := -1, 0
+		for i := range tests {
+			if picked[i] {
+				continue
+			}
+			gain := 0
+			for _, j := range detects[i] {
+				if covered[j] == 0 {
+					gain++
+				}
+			}
+			if gain > bestGain {
+				best, bestGain = i, gain
+			}
+		}
+		if best < 0 {
+			break
+		}
+		picked[best] = true
+		order = append(order, best)
+		for _, j := range detects[best] {
+			covered[j]++
+		}
+	}
+
+	// A test picked early may be covered by the tests picked after it
+	for _, i := range order {
+		redundant := true
+		for _, j := range detects[i] {
+			if covered[j] == 1 {
+				redundant = false
+				break
+			}
+		}
+		if redundant {
+			picked[i] = false
+			for _, j := range detects[i] {
+				covered[j]--
+			}
+		}
+	}
+
+	kept := make([]map[string]circuit.LogicValue, 0, len(order))
+	for i, test := range tests {
+		if picked[i] {
+			kept = append(kept, test)
+		}
+	}
+	return kept
+}
+
+// testKey returns the values a test assigns to the circuit inputs, in input order
+func testKey(c *circuit.Circuit, test map[string]circuit.LogicValue) string {
+	var key strings.Builder
+	for _, input := range c.Inputs {
+		key.WriteString(test[input.Name].String())
+		key.WriteByte(' ')
+	}
+	return key.String()
+}
+
+// isSpecified reports whether a test assigns a binary value
+func isSpecified(value circuit.LogicValue) bool {
+	return value == circuit.Zero || value == circuit.One
+}
@@ -1,7 +1,7 @@
 package algorithm
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
 	"github.com/fyerfyer/fan-atpg/pkg/utils"
@@ -17,13 +17,20 @@ type DecisionNode struct {
 
 // Decision manages the decision tree for the FAN algorithm
 type Decision struct {
-	Circuit     *circuit.Circuit
-	Logger      *utils.Logger
-	Topology    *circuit.Topology
-	Frontier    *Frontier
-	Implication *Implication
-	Backtrace   *Backtrace
-	Stack       []*DecisionNode // The decision stack (tree nodes in order)
+	Circuit      *circuit.Circuit
+	Logger       *utils.Logger
+	Topology     *circuit.Topology
+	Frontier     *Frontier
+	Implication  *Implication
+	Backtrace    *Backtrace
+	Stack        []*DecisionNode // The decision stack (tree nodes in order)
+	Backtracks   int             // Number of backtracks since the last reset
+	Implications int             // Number of implication passes since the last reset
+
+	// Fixed holds input values applied before every decision, such as the
+	// assignments of an earlier test during dynamic compaction. The search never
+	// changes them.
+	Fixed map[*circuit.Line]circuit.LogicValue
 }
 
 // NewDecision creates a new Decision manager
@@ -39,7 +46,11 @@ func NewDecision(c *circuit.Circuit, t *circuit.Topology, f *Frontier, i *Implic
 	}
 }
 
-// MakeDecision makes a new decision for the next step in test generation
+// MakeDecision makes a new decision for the next step in test generation.
+// Decisions are made on head lines and primary inputs, and the values of the
+// other lines follow by implication. A decision that leads to a conflict is
+// backtracked at once. It returns false when the decision tree has been
+// exhausted, and an error if an implication pass reaches its limit.
 func (d *Decision) MakeDecision() (bool, error) {
 	d.Logger.Decision("Making a new decision")
 
@@ -52,7 +63,7 @@ func (d *Decision) MakeDecision() (bool, error) {
 
 	// If no line was selected, check if we've found a test or need to backtrack
 	if line == nil {
-		if d.Circuit.CheckTestStatus() {
+		if d.Circuit.CheckTestStatus() && len(d.Frontier.JFrontier) == 0 {
 			d.Logger.Decision("Test found! No further decisions needed")
 			return true, nil
 		}
@@ -67,169 +78,88 @@ func (d *Decision) MakeDecision() (bool, error) {
 		Tried:       false,
 		Alternative: oppositeBinaryValue(value),
 	}
+	d.Stack = append(d.Stack, node)
+	d.Logger.Decision("Decision: %s = %v", line.Name, value)
 
-	// Try the selected value
-	success, err := d.tryValue(line, value)
-	if err != nil {
-		return false, err
-	}
-
-	if success {
-		// Value worked, add the node to the stack
-		d.Stack = append(d.Stack, node)
-		d.Logger.Decision("Decision successful: %s = %v", line.Name, value)
-		return true, nil
-	}
-
-	// First value didn't work, try the alternative immediately
-	d.Logger.Decision("First value %v failed for %s, trying alternative %v",
-		value, line.Name, node.Alternative)
-
-	success, err = d.tryValue(line, node.Alternative)
-	if err != nil {
-		return false, err
-	}
-
-	if success {
-		// Alternative worked, add the node to the stack with tried=true
-		node.Value = node.Alternative
-		node.Tried = true
-		d.Stack = append(d.Stack, node)
-		d.Logger.Decision("Alternative decision successful: %s = %v", line.Name, node.Alternative)
-		return true, nil
-	}
-
-	// Both values failed, need to backtrack
-	d.Logger.Decision("Both values failed for %s, need to backtrack", line.Name)
-	return d.Backtrack()
-}
-
-// tryValue attempts to set a line to a specific value and check if it leads to a conflict
-func (d *Decision) tryValue(line *circuit.Line, value circuit.LogicValue) (bool, error) {
-	// Add special handling for assigning values to fault sites
-	if line == d.Circuit.FaultSite {
-		d.Logger.Decision("Setting value %v on fault site %s", value, line.Name)
-
-		// For stuck-at-0, setting to 1 should create D' (not conflict)
-		// For stuck-at-1, setting to 0 should create D (not conflict)
-		if (d.Circuit.FaultType == circuit.Zero && value == circuit.One) ||
-			(d.Circuit.FaultType == circuit.One && value == circuit.Zero) {
-			line.SetValue(value)
-			d.Frontier.UpdateDFrontier()
-			d.Frontier.UpdateJFrontier()
-			return true, nil
-		}
-
-		// Setting fault site to same value as fault should be valid but won't help testing
-		if value == d.Circuit.FaultType {
-			line.SetValue(value)
-			d.Frontier.UpdateDFrontier()
-			d.Frontier.UpdateJFrontier()
-			// But we should warn that this won't activate the fault
-			d.Logger.Decision("Warning: Setting fault site %s to %v (same as fault type)",
-				line.Name, value)
-			return true, nil
+	if err := d.Simulate(); err != nil {
+		if errors.Is(err, ErrImplicationLimit) {
+			return false, err
 		}
+		d.Logger.Decision("Decision %s = %v leads to a conflict: %v", line.Name, value, err)
+		return d.Backtrack()
 	}
-
-	// Save circuit state before trying
-	savedState := d.saveCircuitState()
-
-	// Try setting the value
-	line.SetValue(value)
-	d.Logger.Trace("Setting %s = %v", line.Name, value)
-
-	// Perform implication
-	ok, err := d.Implication.ImplyValues()
-	if err != nil || !ok {
-		// Restore state and return false
-		d.restoreCircuitState(savedState)
-		d.Logger.Trace("Value %v on %s leads to conflict", value, line.Name)
-		return false, nil
-	}
-
-	// Update frontiers after implication
-	d.Frontier.UpdateDFrontier()
-	d.Frontier.UpdateJFrontier()
-
-	// Check if X-path exists (path from D/D' to output)
-	if len(d.Frontier.DFrontier) > 0 && !d.Backtrace.CheckXPath() {
-		// No path exists, restore state and return false
-		d.restoreCircuitState(savedState)
-		d.Logger.Decision("No path exists from fault to output, decision fails")
-		return false, nil
-	}
-
 	return true, nil
 }
 
-// Backtrack performs backtracking in the decision tree
+// Backtrack performs backtracking in the decision tree. The most recent decision
+// whose alternative has not been tried yet is flipped and every later decision
+// is discarded, until the decisions can be implied without a conflict. It
+// returns false when no untried alternative is left, which means the whole
+// decision tree has been explored, and an error if an implication pass reaches
+// its limit.
 func (d *Decision) Backtrack() (bool, error) {
 	d.Logger.Backtrack("Starting backtracking")
 
-	// If stack is empty, no more backtracking possible
-	if len(d.Stack) == 0 {
-		d.Logger.Backtrack("Decision stack empty, no more backtracking possible")
-		return false, fmt.Errorf("no test possible, decision stack empty")
-	}
-
-	// Pop the last decision
-	lastIdx := len(d.Stack) - 1
-	node := d.Stack[lastIdx]
-	d.Stack = d.Stack[:lastIdx]
+	for {
+		d.Backtracks++
 
-	// If we haven't tried the alternative, try it now
-	if !node.Tried {
-		// Save the fault site and fault type before reset
-		faultSite := d.Circuit.FaultSite
-		faultType := d.Circuit.FaultType
-
-		// Reset circuit state
-		d.Circuit.Reset()
-
-		// Re-inject fault
-		if faultSite != nil {
-			d.Circuit.InjectFault(faultSite, faultType)
-			d.Logger.Backtrack("Re-injected fault at %s stuck-at-%v", faultSite.Name, faultType)
+		// Drop decisions whose alternatives have already been tried
+		for len(d.Stack) > 0 && d.Stack[len(d.Stack)-1].Tried {
+			node := d.Stack[len(d.Stack)-1]
+			d.Stack = d.Stack[:len(d.Stack)-1]
+			d.Logger.Backtrack("Both values tried for %s, removing decision", node.Line.Name)
 		}
 
-		// Reapply all decisions up to but not including the current one
-		for i := 0; i < lastIdx; i++ {
-			prevNode := d.Stack[i]
-			prevNode.Line.SetValue(prevNode.Value)
-			d.Logger.Backtrack("Restored previous decision: %s = %v",
-				prevNode.Line.Name, prevNode.Value)
-		}
-
-		// Perform implication to restore circuit state
-		_, err := d.Implication.ImplyValues()
-		if err != nil {
-			d.Logger.Backtrack("Error during state restoration: %v", err)
+		// If stack is empty, no more backtracking possible
+		if len(d.Stack) == 0 {
+			d.Logger.Backtrack("Decision stack empty, decision tree exhausted")
+			_ = d.Simulate()
+			return false, nil
 		}
 
 		// Now try the alternative value
+		node := d.Stack[len(d.Stack)-1]
 		node.Tried = true
 		node.Value = node.Alternative
-		d.Logger.Backtrack("Trying alternative value %v for %s",
-			node.Value, node.Line.Name)
+		d.Logger.Backtrack("Trying alternative value %v for %s", node.Value, node.Line.Name)
 
-		node.Line.SetValue(node.Value)
-		_, err = d.Implication.ImplyValues()
+		err := d.Simulate()
 		if err == nil {
-			// Alternative worked, add it back to the stack
-			d.Stack = append(d.Stack, node)
-			d.Logger.Backtrack("Alternative value %v for %s successful",
-				node.Value, node.Line.Name)
 			return true, nil
 		}
+		if errors.Is(err, ErrImplicationLimit) {
+			return false, err
+		}
+		d.Logger.Backtrack("Alternative value %v for %s leads to a conflict: %v", node.Value, node.Line.Name, err)
+	}
+}
 
-		d.Logger.Backtrack("Alternative value %v for %s also failed",
-			node.Value, node.Line.Name)
+// Simulate rebuilds the circuit state from the decision stack: all lines are
+// cleared, the fault is re-injected, the fixed inputs and the decisions are
+// applied and an unassigned fault site gets the value that activates the
+// fault, which every test needs. Their consequences are then found by
+// implication, which also updates the frontiers. It returns an error if the
+// values conflict.
+func (d *Decision) Simulate() error {
+	fault := d.Circuit.CurrentFault()
+	d.Circuit.Reset()
+	if fault.Line != nil {
+		d.Circuit.InjectFaultObject(fault)
 	}
 
-	// If we get here, both values failed or we've already tried the alternative
-	// Continue backtracking to previous decision
-	return d.Backtrack()
+	for line, value := range d.Fixed {
+		line.SetValue(value)
+	}
+	for _, node := range d.Stack {
+		node.Line.SetValue(node.Value)
+	}
+	if fault.Line != nil && !fault.Line.IsAssigned() {
+		fault.Line.SetValue(oppositeBinaryValue(fault.Type))
+	}
+
+	d.Implications++
+	_, err := d.Implication.ImplyValues()
+	return err
 }
 
 // GetTestPattern returns the current test pattern (input assignments)
@@ -237,28 +167,6 @@ func (d *Decision) GetTestPattern() map[string]circuit.LogicValue {
 	return d.Circuit.GetCurrentTest()
 }
 
-// saveCircuitState saves the current state of all lines in the circuit
-func (d *Decision) saveCircuitState() map[int]circuit.LogicValue {
-	state := make(map[int]circuit.LogicValue)
-	for id, line := range d.Circuit.Lines {
-		state[id] = line.Value
-	}
-	return state
-}
-
-// restoreCircuitState restores all line values from a saved state
-func (d *Decision) restoreCircuitState(state map[int]circuit.LogicValue) {
-	for id, value := range state {
-		if line, exists := d.Circuit.Lines[id]; exists {
-			line.Value = value
-		}
-	}
-
-	// Update frontiers after restore
-	d.Frontier.UpdateDFrontier()
-	d.Frontier.UpdateJFrontier()
-}
-
 // GetCurrentDecisionDepth returns the current depth in the decision tree
 func (d *Decision) GetCurrentDecisionDepth() int {
 	return len(d.Stack)
@@ -267,6 +175,8 @@ func (d *Decision) GetCurrentDecisionDepth() int {
 // Reset resets the decision tree
 func (d *Decision) Reset() {
 	d.Stack = make([]*DecisionNode, 0)
+	d.Backtracks = 0
+	d.Implications = 0
 }
 
 // IsSatisfiable determines if the current decision state can lead to a solution
@@ -274,7 +184,7 @@ func (d *Decision) IsSatisfiable() bool {
 	// Check if there's a fault site, it must be activated
 	if d.Circuit.FaultSite != nil {
 		if d.Circuit.FaultSite.Value != d.Circuit.FaultType &&
-			!d.Circuit.FaultSite.IsFaulty() {
+			!d.Circuit.FaultSite.IsFaulty() && d.Circuit.FaultBranch == nil {
 			return false
 		}
 	}
@@ -0,0 +1,119 @@
+package algorithm
+
+import (
+	"context"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// EquivalenceStatus classifies the outcome of an equivalence check
+type EquivalenceStatus int
+
+const (
+	Equivalent    EquivalenceStatus = iota // No input vector sets the miter output to 1
+	NotEquivalent                          // A counterexample was found
+	Undecided                              // The search stopped at a limit before reaching a conclusion
+)
+
+// String returns a string representation of the equivalence status
+func (s EquivalenceStatus) String() string {
+	switch s {
+	case Equivalent:
+		return "equivalent"
+	case NotEquivalent:
+		return "not equivalent"
+	case Undecided:
+		return "undecided"
+	default:
+		return "unknown"
+	}
+}
+
+// EquivalenceResult holds the outcome of an equivalence check
+type EquivalenceResult struct {
+	Status         EquivalenceStatus
+	Counterexample map[string]circuit.LogicValue // Input vector on which the circuits differ, nil unless not equivalent
+	Outputs        []string                      // Outputs that differ under the counterexample
+	Reason         string                        // Why the check is undecided
+}
+
+// EquivalenceChecker checks two combinational or full-scan circuits for
+// equivalence. The outputs of their miter can only be set to 1 if they differ,
+// so a test for the miter output stuck-at-0 is a counterexample and a proof that
+// the fault is redundant is a proof of equivalence.
+type EquivalenceChecker struct {
+	Miter  *circuit.Miter
+	Logger *utils.Logger
+	Fan    *Fan // FAN instance on the miter, whose Options apply to the check
+}
+
+// NewEquivalenceChecker builds the miter of two circuits and a FAN instance on it
+func NewEquivalenceChecker(a, b *circuit.Circuit, logger *utils.Logger) (*EquivalenceChecker, error) {
+	miter, err := circuit.BuildMiter(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return &EquivalenceChecker{
+		Miter:  miter,
+		Logger: logger,
+		Fan:    NewFan(miter.Circuit, logger),
+	}, nil
+}
+
+// Check runs the equivalence check
+func (e *EquivalenceChecker) Check() *EquivalenceResult {
+	return e.CheckContext(context.Background())
+}
+
+// CheckContext is Check with a context. Circuits whose outputs all share their
+// logic in the miter are equivalent without a search. The counterexample has its unassigned
+// inputs set to 0 and is confirmed by simulating both original circuits.
+func (e *EquivalenceChecker) CheckContext(ctx context.Context) *EquivalenceResult {
+	e.Logger.Info("Checking %s against %s: %d outputs share their logic, %d are compared",
+		e.Miter.A.Name, e.Miter.B.Name, len(e.Miter.Shared), len(e.Miter.Names))
+	if e.Miter.Output == nil {
+		return &EquivalenceResult{Status: Equivalent}
+	}
+
+	fault := circuit.Fault{Line: e.Miter.Output, Type: circuit.Zero}
+	result := e.Fan.GenerateTestContext(ctx, fault)
+
+	switch result.Status {
+	case Redundant:
+		return &EquivalenceResult{Status: Equivalent}
+	case Aborted:
+		return &EquivalenceResult{Status: Undecided, Reason: result.Err().Error()}
+	}
+
+	counterexample := e.Miter.Circuit.FillTests([]map[string]circuit.LogicValue{result.Test}, circuit.FillZero, 0)[0]
+	outputs := e.differingOutputs(counterexample)
+	if len(outputs) == 0 {
+		e.Logger.Error("Counterexample %v is not confirmed by simulating the circuits", counterexample)
+		return &EquivalenceResult{Status: Undecided, Reason: "counterexample not confirmed by simulating the circuits"}
+	}
+	return &EquivalenceResult{Status: NotEquivalent, Counterexample: counterexample, Outputs: outputs}
+}
+
+// differingOutputs simulates both circuits for an input vector and returns the
+// names of the outputs on which they have opposite binary values
+func (e *EquivalenceChecker) differingOutputs(test map[string]circuit.LogicValue) []string {
+	values := make([]map[*circuit.Line]circuit.LogicValue, 2)
+	outputs := make([]map[string]*circuit.Line, 2)
+	for k, c := range []*circuit.Circuit{e.Miter.A, e.Miter.B} {
+		topo := circuit.NewTopology(c)
+		topo.Analyze()
+		values[k] = simulation.NewFaultSimulator(c, topo, e.Logger).GoodValues([]map[string]circuit.LogicValue{test})[0]
+		outputs[k] = c.ObservedOutputs()
+	}
+
+	var differing []string
+	for _, name := range e.Miter.Names {
+		a, b := values[0][outputs[0][name]], values[1][outputs[1][name]]
+		if a != circuit.X && b != circuit.X && a != b {
+			differing = append(differing, name)
+		}
+	}
+	return differing
+}
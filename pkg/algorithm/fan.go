@@ -1,10 +1,16 @@
 package algorithm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/testability"
 	"github.com/fyerfyer/fan-atpg/pkg/utils"
 )
 
@@ -12,14 +18,40 @@ import (
 type Stats struct {
 	Decisions         int           // Number of decisions made
 	Backtracks        int           // Number of backtracks performed
-	Implications      int           // Number of implications performed
+	Implications      int           // Number of implication passes performed
 	TestsFound        int           // Number of tests found
-	UndetectedFaults  int           // Number of undetected faults
+	UndetectedFaults  int           // Number of undetected faults (redundant or aborted)
+	RedundantFaults   int           // Number of faults proven untestable
+	AbortedFaults     int           // Number of faults given up on at a search limit
+	DroppedFaults     int           // Number of faults dropped by fault simulation
+	RandomDetected    int           // Number of faults detected by the random pattern phase
+	RandomPatterns    int           // Number of random patterns kept as tests
 	TotalTime         time.Duration // Total execution time
 	MaxDecisionDepth  int           // Maximum decision tree depth reached
 	UniqueAssignments int           // Number of unique line assignments
 }
 
+// FaultCoverage returns the fraction of targeted faults that are detected
+func (s Stats) FaultCoverage() float64 {
+	detected := s.TestsFound + s.DroppedFaults + s.RandomDetected
+	total := detected + s.UndetectedFaults
+	if total == 0 {
+		return 0
+	}
+	return float64(detected) / float64(total)
+}
+
+// TestEfficiency returns the fraction of targeted faults that are either detected
+// or proven redundant, so that only aborted faults count against it
+func (s Stats) TestEfficiency() float64 {
+	detected := s.TestsFound + s.DroppedFaults + s.RandomDetected
+	total := detected + s.UndetectedFaults
+	if total == 0 {
+		return 0
+	}
+	return float64(detected+s.RedundantFaults) / float64(total)
+}
+
 // Fan implements the FAN (FAN-Alternative-Node) algorithm for test pattern generation
 type Fan struct {
 	Circuit     *circuit.Circuit
@@ -30,7 +62,25 @@ type Fan struct {
 	Backtrace   *Backtrace
 	Decision    *Decision
 	Sensitize   *Sensitization
+	Simulator   *simulation.FaultSimulator
+	Verifier    *simulation.Verifier // Independent check of every test the search finds
+	FaultList   *circuit.FaultList   // Collapsed fault list of the last full run
 	Stats       Stats
+	Options     Options               // Search limits and heuristic applied to every fault
+	Random      RandomOptions         // Random pattern phase run before deterministic test generation
+	Compaction  CompactionOptions     // Dynamic compaction of every new test
+	Measures    *testability.Measures // SCOAP measures of the circuit
+	Learning    *Learning             // Static learning result, computed once Options.Learning is set
+
+	// Results holds the outcome of every targeted fault of the last full run
+	Results map[circuit.Fault]*TestResult
+
+	// DominanceCollapsing enables dominance collapsing on top of equivalence collapsing
+	DominanceCollapsing bool
+
+	// Jobs is the number of faults GenerateTestsForAllFaults targets in parallel
+	Jobs    int
+	workers []*Fan // Worker instances on clones of the circuit, created on demand
 }
 
 // NewFan creates a new FAN algorithm instance
@@ -43,6 +93,8 @@ func NewFan(c *circuit.Circuit, logger *utils.Logger) *Fan {
 	backtrace := NewBacktrace(c, topo, frontier, implication, logger)
 	decision := NewDecision(c, topo, frontier, implication, backtrace, logger)
 	sensitize := NewSensitization(c, topo, implication, frontier, logger)
+	simulator := simulation.NewFaultSimulator(c, topo, logger)
+	measures := testability.Compute(c, topo)
 
 	return &Fan{
 		Circuit:     c,
@@ -53,267 +105,484 @@ func NewFan(c *circuit.Circuit, logger *utils.Logger) *Fan {
 		Backtrace:   backtrace,
 		Decision:    decision,
 		Sensitize:   sensitize,
+		Simulator:   simulator,
+		Verifier:    simulation.NewVerifier(c),
+		Measures:    measures,
+		Options:     DefaultOptions(),
+		Random:      DefaultRandomOptions(),
+		Compaction:  DefaultCompactionOptions(),
+		Jobs:        1,
 	}
 }
 
 // FindTest generates a test for a specific fault (line stuck at value)
 func (f *Fan) FindTest(faultSite *circuit.Line, faultType circuit.LogicValue) (map[string]circuit.LogicValue, error) {
+	return f.FindTestContext(context.Background(), faultSite, faultType)
+}
+
+// FindTestContext is FindTest with a context that can cancel the search
+func (f *Fan) FindTestContext(ctx context.Context, faultSite *circuit.Line, faultType circuit.LogicValue) (map[string]circuit.LogicValue, error) {
+	result := f.GenerateTestContext(ctx, circuit.Fault{Line: faultSite, Type: faultType})
+	return result.Test, result.Err()
+}
+
+// FindTestForFault generates a test for a stem or fanout-branch fault
+func (f *Fan) FindTestForFault(fault circuit.Fault) (map[string]circuit.LogicValue, error) {
+	result := f.GenerateTest(fault)
+	return result.Test, result.Err()
+}
+
+// GenerateTest runs test generation for a fault and reports whether it was
+// detected, proven redundant or aborted
+func (f *Fan) GenerateTest(fault circuit.Fault) *TestResult {
+	return f.GenerateTestContext(context.Background(), fault)
+}
+
+// GenerateTestContext is GenerateTest with a context. The fault is aborted when
+// the context is done or the per-fault limits of f.Options are reached.
+func (f *Fan) GenerateTestContext(ctx context.Context, fault circuit.Fault) *TestResult {
+	if f.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Options.Timeout)
+		defer cancel()
+	}
+	f.Implication.MaxIterations = f.Options.MaxImplications
+	f.applyHeuristic()
+	f.applyLearning()
+
 	startTime := time.Now()
-	f.Logger.Info("Starting test generation for %s stuck-at-%v", faultSite.Name, faultType)
+	f.Logger.Info("Starting test generation for %s", fault)
 	f.Logger.Indent()
 	defer f.Logger.Outdent()
 
-	// Reset circuit and statistics
+	// Reset circuit, decision tree and statistics
 	f.Circuit.Reset()
+	f.Decision.Reset()
 	f.resetStats()
 
 	// Inject the fault
-	f.Circuit.InjectFault(faultSite, faultType)
-	f.Logger.Info("Injected fault: %s stuck-at-%v", faultSite.Name, faultType)
-
-	// Initial implication
-	_, err := f.Implication.ImplyValues()
-	if err != nil {
-		f.Logger.Error("Initial implication failed: %v", err)
-		return nil, err
+	f.Circuit.InjectFaultObject(fault)
+	f.Logger.Info("Injected fault: %s", fault)
+
+	// Fault activation and its implications, which hold for every test
+	result := &TestResult{Fault: fault}
+	var status TestStatus
+	err := f.Decision.Simulate()
+	f.Stats.Implications = f.Decision.Implications
+	switch {
+	case errors.Is(err, ErrImplicationLimit):
+		status, err = Aborted, f.implicationLimit(err)
+	case err != nil:
+		f.Logger.Algorithm("Activating the fault leads to a conflict: %v", err)
+		status, err = Redundant, fmt.Errorf("fault activation conflicts: %w", err)
+	default:
+		// Main FAN algorithm loop
+		status, err = f.runFanAlgorithm(ctx)
 	}
-	f.Stats.Implications++
 
-	// Update frontiers
-	f.Frontier.UpdateDFrontier()
-	f.Frontier.UpdateJFrontier()
-
-	// Main FAN algorithm loop
-	found, err := f.runFanAlgorithm()
-	if err != nil {
-		f.Logger.Error("FAN algorithm failed: %v", err)
-		return nil, err
+	// Never trust the search alone: the test must pass independent verification
+	if status == Detected {
+		result.Test = f.Circuit.GetCurrentTest()
+		if mismatch := f.Verifier.Verify(result.Test, fault, f.Circuit.Constraints...); mismatch != nil {
+			f.Logger.Error("Search result rejected by verification: %v", mismatch)
+			status, err = Aborted, mismatch
+			result.Test = nil
+			result.Mismatch = mismatch
+		}
 	}
+	result.Status = status
 
 	// Update statistics
 	f.Stats.TotalTime = time.Since(startTime)
-	if found {
+	switch status {
+	case Detected:
 		f.Stats.TestsFound++
 		f.logStats()
-		testVector := f.Circuit.GetCurrentTest()
-		f.Logger.Info("Test found: %v", testVector)
-		return testVector, nil
+		f.Logger.Info("Test found: %v", result.Test)
+	case Redundant:
+		f.Stats.RedundantFaults++
+		f.Stats.UndetectedFaults++
+		f.logStats()
+		result.Reason = err.Error()
+		f.Logger.Info("No test possible for this fault: %v", err)
+	case Aborted:
+		f.Stats.AbortedFaults++
+		f.Stats.UndetectedFaults++
+		f.logStats()
+		result.Reason = err.Error()
+		f.Logger.Warning("Test generation aborted: %v", err)
 	}
-
-	f.Stats.UndetectedFaults++
-	f.logStats()
-	f.Logger.Info("No test possible for this fault")
-	return nil, fmt.Errorf("no test possible for %s stuck-at-%v", faultSite.Name, faultType)
+	return result
 }
 
-// runFanAlgorithm runs the main FAN algorithm loop
-func (f *Fan) runFanAlgorithm() (bool, error) {
-	maxIterations := 10000 // Safety limit to prevent infinite loops
+// runFanAlgorithm runs the main FAN algorithm loop. A fault is only reported
+// redundant once every alternative of the decision tree has been tried. For an
+// undetected fault the returned error gives the reason the search ended.
+func (f *Fan) runFanAlgorithm(ctx context.Context) (TestStatus, error) {
 	iterations := 0
+	defer func() {
+		f.Stats.Backtracks = f.Decision.Backtracks
+		f.Stats.Implications = f.Decision.Implications
+	}()
 
-	for iterations < maxIterations {
+	for {
 		iterations++
 
-		// Enhanced logging for debugging
+		// Abort once a search limit is reached
+		if err := f.checkLimits(ctx); err != nil {
+			f.Logger.Warning("FAN algorithm aborted: %v", err)
+			return Aborted, err
+		}
+
 		if iterations == 1 || iterations%100 == 0 || iterations < 20 {
 			f.Logger.Debug("FAN iteration %d - Circuit state:", iterations)
-			f.Logger.Debug("  Fault: %s stuck-at-%v", f.Circuit.FaultSite.Name, f.Circuit.FaultType)
+			f.Logger.Debug("  Fault: %s", f.Circuit.CurrentFault())
 			for _, input := range f.Circuit.Inputs {
 				f.Logger.Debug("  Input %s = %v", input.Name, input.Value)
 			}
-
-			// Add logging for fault site value
 			f.Logger.Debug("  Fault site %s value = %v", f.Circuit.FaultSite.Name, f.Circuit.FaultSite.Value)
-
-			// Add logging for the w1 line to see if it's correctly propagating
-			for _, line := range f.Circuit.Lines {
-				if line.Name == "w1" {
-					f.Logger.Debug("  Line w1 value = %v", line.Value)
-				}
-			}
-
 			f.Logger.Debug("  D-frontier size: %d, J-frontier size: %d",
 				len(f.Frontier.DFrontier), len(f.Frontier.JFrontier))
 		}
 
-		// Check if we've found a test
-		if f.Circuit.CheckTestStatus() {
+		// Check if we've found a test: the fault effect is at an output and
+		// every assigned line is justified by the inputs
+		if f.Circuit.CheckTestStatus() && len(f.Frontier.JFrontier) == 0 {
 			f.Logger.Algorithm("Test found! D/D' has propagated to at least one output")
-			return true, nil
+			return Detected, nil
 		}
 
-		// Make a decision
+		// Make a decision, backtracking if the current state is a dead end
 		f.Logger.Trace("Making decision...")
 		success, err := f.Decision.MakeDecision()
 		if err != nil {
-			return false, err
+			f.Logger.Warning("FAN algorithm aborted: %v", err)
+			return Aborted, f.implicationLimit(err)
 		}
 		f.Stats.Decisions++
 
+		if !success {
+			f.Logger.Algorithm("Decision tree exhausted, no test exists")
+			return Redundant, fmt.Errorf("decision tree exhausted after %d backtracks", f.Decision.Backtracks)
+		}
+
 		// Track max decision depth
 		currentDepth := f.Decision.GetCurrentDecisionDepth()
 		if currentDepth > f.Stats.MaxDecisionDepth {
 			f.Stats.MaxDecisionDepth = currentDepth
 		}
 
-		if !success {
-			f.Logger.Trace("Decision was unsuccessful, will terminate algorithm")
-			return false, fmt.Errorf("no test possible, decision stack empty")
-		}
-
-		// Force forward simulation after a decision
-		f.Circuit.SimulateForward()
-
 		// Log the decision made and the current state
 		f.Logger.Algorithm("Decision made - stack depth: %d", currentDepth)
 		for _, node := range f.Decision.Stack {
 			f.Logger.Trace("  Decision: %s = %v (tried alternate: %v)",
 				node.Line.Name, node.Value, node.Tried)
 		}
+	}
+}
 
-		// Perform implication
-		ok, err := f.Implication.ImplyValues()
-		if err != nil || !ok {
-			// Conflict detected, need to backtrack immediately
-			f.Logger.Algorithm("Conflict detected during implication, backtracking")
-			f.Stats.Backtracks++
-			success, err := f.Decision.Backtrack()
-			if err != nil {
-				return false, err
-			}
-			if !success {
-				return false, fmt.Errorf("backtracking failed after conflict")
-			}
-			// Continue to next iteration after backtracking
-			continue
-		}
-		f.Stats.Implications++
+// applyHeuristic hands the SCOAP measures to backtrace and D-frontier selection
+// if the SCOAP heuristic is selected
+func (f *Fan) applyHeuristic() {
+	var measures *testability.Measures
+	if f.Options.Heuristic == HeuristicSCOAP {
+		measures = f.Measures
+	}
+	f.Frontier.Measures = measures
+	f.Backtrace.MBT.Measures = measures
+}
 
-		// Update frontiers
-		f.Frontier.UpdateDFrontier()
-		f.Frontier.UpdateJFrontier()
+// applyLearning hands the learned implications to the search if
+// Options.Learning is set, running static learning the first time
+func (f *Fan) applyLearning() {
+	if !f.Options.Learning {
+		f.Implication.Learned = nil
+		return
+	}
+	if f.Learning == nil {
+		f.Learning = f.Implication.Learn()
+		f.Logger.Info("Static learning found %d indirect implications and %d constant lines",
+			f.Learning.Count(), len(f.Learning.Constants))
+	}
+	f.Implication.Learned = f.Learning
+}
 
-		// Apply unique sensitization if D-frontier has a single gate
-		if len(f.Frontier.DFrontier) == 1 {
-			// Rest of the unique sensitization code remains the same
-		}
+// implicationLimit turns an implication pass that reached its limit into the
+// reason the search for the current fault was aborted
+func (f *Fan) implicationLimit(err error) error {
+	if errors.Is(err, ErrImplicationLimit) {
+		return fmt.Errorf("implication limit (%d) reached", f.Options.MaxImplications)
 	}
+	return err
+}
 
-	f.Logger.Warning("FAN algorithm reached iteration limit (%d)", maxIterations)
-	return false, fmt.Errorf("iteration limit reached")
+// checkLimits returns why the search for the current fault has to stop, or nil
+func (f *Fan) checkLimits(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && f.Options.Timeout > 0 {
+			return fmt.Errorf("time budget (%v) exceeded", f.Options.Timeout)
+		}
+		return err
+	}
+	if f.Options.MaxDecisions > 0 && f.Stats.Decisions >= f.Options.MaxDecisions {
+		return fmt.Errorf("decision limit (%d) reached", f.Options.MaxDecisions)
+	}
+	if f.Options.MaxBacktracks > 0 && f.Decision.Backtracks > f.Options.MaxBacktracks {
+		return fmt.Errorf("backtrack limit (%d) reached", f.Options.MaxBacktracks)
+	}
+	return nil
 }
 
-// GenerateTestsForAllFaults generates tests for all possible faults in the circuit
+// GenerateTestsForAllFaults generates tests for all faults of the collapsed fault list.
+// If f.Random enables it, random patterns first detect the easy faults. With
+// f.Compaction enabled, the inputs a new test leaves unassigned are used to target
+// further faults. Every new test is fault-simulated against the remaining faults,
+// and the faults it detects are dropped without running deterministic test
+// generation for them.
 func (f *Fan) GenerateTestsForAllFaults() (map[string]map[string]circuit.LogicValue, error) {
+	return f.GenerateTestsForAllFaultsContext(context.Background())
+}
+
+// GenerateTestsForAllFaultsContext is GenerateTestsForAllFaults with a context.
+// When the context is done the run stops, the faults not yet targeted are
+// reported as aborted and the tests found so far are returned with ctx.Err().
+func (f *Fan) GenerateTestsForAllFaultsContext(ctx context.Context) (map[string]map[string]circuit.LogicValue, error) {
 	startTime := time.Now()
 	f.Logger.Info("Starting test generation for all faults")
 
-	// Reset global stats
-	f.resetStats()
+	f.FaultList = circuit.NewFaultList(f.Circuit, f.DominanceCollapsing)
+	f.Logger.Info("Fault list: %d faults collapsed to %d (%d equivalent, %d dominance)",
+		len(f.FaultList.All), f.FaultList.Size(),
+		f.FaultList.EquivalentCollapsed, f.FaultList.DominanceCollapsed)
 
 	// Map to store test vectors for detected faults
 	testVectors := make(map[string]map[string]circuit.LogicValue)
-	faultCount := 0
+	f.Results = make(map[circuit.Fault]*TestResult)
+	remaining := make([]circuit.Fault, len(f.FaultList.Faults))
+	copy(remaining, f.FaultList.Faults)
+	faultCount := len(remaining)
+	testsFound := 0
+	dropped := 0
+	redundant := 0
+	aborted := 0
+	var mismatches []error
+
+	// Random patterns detect the easy faults, FAN only targets the rest
+	remaining, randomResults, randomPatterns := f.randomPhase(ctx, remaining)
+	for _, result := range randomResults {
+		testVectors[result.Fault.String()] = result.Test
+		f.Results[result.Fault] = result
+	}
 
-	// Process each fault site (excluding primary outputs for simplicity)
-	for _, line := range f.Circuit.Lines {
-		// Skip primary outputs as fault sites for simplicity
-		if line.Type == circuit.PrimaryOutput {
-			continue
+	for len(remaining) > 0 {
+		if ctx.Err() != nil {
+			f.Logger.Warning("Test generation cancelled with %d faults left", len(remaining))
+			for _, fault := range remaining {
+				f.Results[fault] = &TestResult{Fault: fault, Status: Aborted, Reason: ctx.Err().Error()}
+			}
+			aborted += len(remaining)
+			break
 		}
 
-		// Try stuck-at-0
-		faultCount++
-		faultKey := fmt.Sprintf("%s/0", line.Name)
-		test, err := f.FindTest(line, circuit.Zero)
-		if err == nil {
-			testVectors[faultKey] = test
-		}
+		// Every round targets up to Jobs faults at once. The results are merged in
+		// fault list order, so the outcome does not depend on worker scheduling.
+		batch := remaining[:min(max(f.Jobs, 1), len(remaining))]
+		remaining = remaining[len(batch):]
+		results := f.generateBatch(ctx, batch)
 
-		// Try stuck-at-1
-		faultCount++
-		faultKey = fmt.Sprintf("%s/1", line.Name)
-		test, err = f.FindTest(line, circuit.One)
-		if err == nil {
-			testVectors[faultKey] = test
+		for i, fault := range batch {
+			if _, done := f.Results[fault]; done {
+				continue // Dropped by the test of an earlier fault of this batch
+			}
+
+			result := results[i]
+			f.Results[fault] = result
+			if result.Mismatch != nil {
+				mismatches = append(mismatches, result.Mismatch)
+			}
+			switch result.Status {
+			case Redundant:
+				redundant++
+				continue
+			case Aborted:
+				aborted++
+				continue
+			}
+
+			// Only count the test once fault simulation confirms it detects the target
+			test := result.Test
+			patterns := []map[string]circuit.LogicValue{test}
+			if f.Simulator.Detect(patterns, []circuit.Fault{fault})[0] != 0 {
+				f.Logger.Warning("Test %v for %s is not confirmed by fault simulation, discarding it", test, fault)
+				result.Status = Aborted
+				result.Test = nil
+				result.Reason = "test not confirmed by fault simulation"
+				aborted++
+				continue
+			}
+			testsFound++
+			if f.Compaction.Enabled {
+				// The faults of this batch still to be merged come first, as
+				// they would in a sequential run
+				var secondary []circuit.Fault
+				for _, next := range batch[i+1:] {
+					if _, done := f.Results[next]; !done {
+						secondary = append(secondary, next)
+					}
+				}
+				test = f.compactTest(ctx, test, append(secondary, remaining...))
+				patterns = []map[string]circuit.LogicValue{test}
+				result.Test = test
+			}
+			testVectors[fault.String()] = test
+
+			// Drop every remaining fault the new test also detects, including
+			// the faults of this batch that are still to be merged
+			var detected []circuit.Fault
+			remaining, detected = f.Simulator.DropDetected(patterns, remaining)
+			if i+1 < len(batch) {
+				_, inBatch := f.Simulator.DropDetected(patterns, batch[i+1:])
+				detected = append(detected, inBatch...)
+			}
+			for _, d := range detected {
+				if _, done := f.Results[d]; done {
+					continue
+				}
+				testVectors[d.String()] = test
+				f.Results[d] = &TestResult{Fault: d, Status: Detected, Test: test}
+				dropped++
+			}
 		}
 	}
 
 	// Update final stats
+	f.resetStats()
+	f.Stats.TestsFound = testsFound
+	f.Stats.DroppedFaults = dropped
+	f.Stats.RandomDetected = len(randomResults)
+	f.Stats.RandomPatterns = randomPatterns
+	f.Stats.RedundantFaults = redundant
+	f.Stats.AbortedFaults = aborted
+	f.Stats.UndetectedFaults = redundant + aborted
 	f.Stats.TotalTime = time.Since(startTime)
-	f.Logger.Info("Test generation completed for %d faults", faultCount)
+	f.Logger.Info("Test generation completed for %d collapsed faults", faultCount)
+	if f.Stats.RandomPatterns > 0 {
+		f.Logger.Info("Random patterns: %d, detecting %d faults", f.Stats.RandomPatterns, f.Stats.RandomDetected)
+	}
 	f.Logger.Info("Tests found: %d", f.Stats.TestsFound)
-	f.Logger.Info("Undetected faults: %d", f.Stats.UndetectedFaults)
-	f.Logger.Info("Fault coverage: %.2f%%", float64(f.Stats.TestsFound)/float64(faultCount)*100)
+	f.Logger.Info("Faults dropped by fault simulation: %d", f.Stats.DroppedFaults)
+	f.Logger.Info("Undetected faults: %d (%d redundant, %d aborted)",
+		f.Stats.UndetectedFaults, f.Stats.RedundantFaults, f.Stats.AbortedFaults)
+	if faultCount > 0 {
+		f.Logger.Info("Fault coverage: %.2f%% (collapsed), %.2f%% (uncollapsed)",
+			f.Stats.FaultCoverage()*100, f.uncollapsedCoverage(testVectors)*100)
+		f.Logger.Info("Test efficiency: %.2f%%", f.Stats.TestEfficiency()*100)
+	}
 
-	return testVectors, nil
+	return testVectors, errors.Join(verificationError(mismatches), ctx.Err())
 }
 
-// CompactTests removes redundant test vectors
-func (f *Fan) CompactTests(testVectors map[string]map[string]circuit.LogicValue) []map[string]circuit.LogicValue {
-	f.Logger.Info("Compacting test vectors")
+// verificationError summarizes the tests rejected by independent verification, or returns nil
+func verificationError(mismatches []error) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d tests failed independent verification, first: %w", len(mismatches), mismatches[0])
+}
 
-	// Group test vectors by their values for all inputs except in3
-	groups := make(map[string][]map[string]circuit.LogicValue)
+// VerifyTests checks with the independent verifier that every fault of the last
+// full run reported as detected is detected by one of the tests, for example
+// after compaction and fill. It returns an error naming the faults none of the
+// tests detects.
+func (f *Fan) VerifyTests(tests []map[string]circuit.LogicValue) error {
+	if f.FaultList == nil {
+		return nil
+	}
 
-	for _, vector := range testVectors {
-		// Create a key based on all inputs except in3
-		key := ""
-		for input, value := range vector {
-			if input != "in3" {
-				key += input + "=" + value.String() + ";"
+	var missed []string
+	for _, fault := range f.FaultList.Faults {
+		if result := f.Results[fault]; result == nil || result.Status != Detected {
+			continue
+		}
+		detected := false
+		for _, test := range tests {
+			if f.Verifier.Detects(test, fault) {
+				detected = true
+				break
 			}
 		}
+		if !detected {
+			missed = append(missed, fault.String())
+		}
+	}
+	return missedError(missed, "faults")
+}
 
-		// Add this vector to the appropriate group
-		groups[key] = append(groups[key], vector)
+// missedError reports the faults reported detected that no final test detects, or returns nil
+func missedError(missed []string, what string) error {
+	if len(missed) == 0 {
+		return nil
+	}
+	if len(missed) > 10 {
+		missed = append(missed[:10], "...")
 	}
+	return fmt.Errorf("%w: %d %s reported detected are not detected by the tests: %s",
+		simulation.ErrNotDetected, len(missed), what, strings.Join(missed, ", "))
+}
 
-	// Create one merged vector for each group
-	result := make([]map[string]circuit.LogicValue, 0)
+// generateBatch runs test generation for a batch of faults, one fault per worker.
+// Every worker searches on its own clone of the circuit.
+func (f *Fan) generateBatch(ctx context.Context, faults []circuit.Fault) []*TestResult {
+	results := make([]*TestResult, len(faults))
+	if len(faults) == 1 {
+		results[0] = f.GenerateTestContext(ctx, faults[0])
+		return results
+	}
 
-	for _, vectors := range groups {
-		if len(vectors) > 0 {
-			// Take the first vector as a base
-			mergedVector := make(map[string]circuit.LogicValue)
-			for input, value := range vectors[0] {
-				mergedVector[input] = value
-			}
+	for len(f.workers) < len(faults) {
+		logger := f.Logger.Fork(fmt.Sprintf("worker %d", len(f.workers)))
+		f.workers = append(f.workers, NewFan(f.Circuit.Clone(), logger))
+	}
 
-			// For inputs that differ across vectors in this group, use X
-			for _, vector := range vectors[1:] {
-				for input, value := range vector {
-					if mergedVector[input] != value {
-						mergedVector[input] = circuit.X // Mark as don't care
-					}
-				}
+	// Workers share what static learning found on the circuit instead of learning again
+	if f.Options.Learning {
+		f.applyLearning()
+		for _, worker := range f.workers[:len(faults)] {
+			if worker.Learning == nil {
+				worker.Learning = f.Learning.Translate(worker.Circuit)
 			}
-
-			result = append(result, mergedVector)
 		}
 	}
 
-	f.Logger.Info("Compacted %d tests to %d tests", len(testVectors), len(result))
-	return result
+	var wg sync.WaitGroup
+	for i, fault := range faults {
+		wg.Add(1)
+		go func(worker *Fan, i int, fault circuit.Fault) {
+			defer wg.Done()
+			worker.Options = f.Options
+			result := worker.GenerateTestContext(ctx, worker.Circuit.TranslateFault(fault))
+			result.Fault = fault
+			results[i] = result
+		}(f.workers[i], i, fault)
+	}
+	wg.Wait()
+
+	return results
 }
 
-// testMatch is a simplified check to see if two test vectors are similar enough
-// that one could detect faults covered by the other
-func testMatch(test1, test2 map[string]circuit.LogicValue) bool {
-	// This is a simplified version - in reality, we would use fault simulation
-	// Here we just check if the tests are identical
-	if len(test1) != len(test2) {
-		return false
+// uncollapsedCoverage returns the fraction of the full fault universe that the
+// test vectors detect, as confirmed by fault simulation
+func (f *Fan) uncollapsedCoverage(testVectors map[string]map[string]circuit.LogicValue) float64 {
+	if len(f.FaultList.All) == 0 {
+		return 0
 	}
 
-	for key, val1 := range test1 {
-		val2, exists := test2[key]
-		if !exists || val1 != val2 {
-			return false
-		}
+	patterns := make([]map[string]circuit.LogicValue, 0, len(testVectors))
+	for _, test := range testVectors {
+		patterns = append(patterns, test)
 	}
-
-	return true
+	_, detected := f.Simulator.DropDetected(patterns, f.FaultList.All)
+	return float64(len(detected)) / float64(len(f.FaultList.All))
 }
 
 // resetStats resets the statistics counters
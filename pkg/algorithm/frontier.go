@@ -2,6 +2,7 @@ package algorithm
 
 import (
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/testability"
 	"github.com/fyerfyer/fan-atpg/pkg/utils"
 	"sort"
 	"strings"
@@ -13,6 +14,10 @@ type Frontier struct {
 	Logger    *utils.Logger
 	DFrontier []*circuit.Gate // Gates with D/D' inputs and X output
 	JFrontier []*circuit.Gate // Gates with assigned output and some unassigned inputs
+	gates     []*circuit.Gate // Circuit gates ordered by ID, so that frontiers are deterministic
+
+	// Measures guide D-frontier selection when set, nil selects structurally
+	Measures *testability.Measures
 }
 
 // NewFrontier creates a new Frontier manager
@@ -29,7 +34,7 @@ func NewFrontier(c *circuit.Circuit, logger *utils.Logger) *Frontier {
 func (f *Frontier) UpdateDFrontier() {
 	f.DFrontier = make([]*circuit.Gate, 0)
 
-	for _, gate := range f.Circuit.Gates {
+	for _, gate := range f.sortedGates() {
 		if f.isGateInDFrontier(gate) {
 			f.DFrontier = append(f.DFrontier, gate)
 			gate.IsInDFrontier = true
@@ -44,6 +49,15 @@ func (f *Frontier) UpdateDFrontier() {
 	}
 }
 
+// sortedGates returns the circuit gates ordered by ID, refreshing the cached
+// list when gates were added
+func (f *Frontier) sortedGates() []*circuit.Gate {
+	if len(f.gates) != len(f.Circuit.Gates) {
+		f.gates = f.Circuit.SortedGates()
+	}
+	return f.gates
+}
+
 // getInputValuesString returns a string representation of gate input values
 func (f *Frontier) getInputValuesString(gate *circuit.Gate) string {
 	values := make([]string, len(gate.Inputs))
@@ -57,7 +71,7 @@ func (f *Frontier) getInputValuesString(gate *circuit.Gate) string {
 func (f *Frontier) UpdateJFrontier() {
 	f.JFrontier = make([]*circuit.Gate, 0)
 
-	for _, gate := range f.Circuit.Gates {
+	for _, gate := range f.sortedGates() {
 		if f.isGateInJFrontier(gate) {
 			f.JFrontier = append(f.JFrontier, gate)
 		}
@@ -71,17 +85,25 @@ func (f *Frontier) isGateInDFrontier(gate *circuit.Gate) bool {
 	// A gate is in D-frontier if:
 	// 1. At least one input has D or D'
 	// 2. Output is X
-	// 3. The gate is sensitizable (all other inputs have non-controlling values)
+	// 3. No other input has a controlling value that blocks propagation
 
-	hasFaultyInput := false
+	if !gate.HasFaultyInput() || gate.Output.Value != circuit.X {
+		return false
+	}
+
+	// A cell must have values of its unassigned inputs that propagate the effect
+	if gate.Type.HasTruthTable() {
+		_, ok := gate.PropagationCube()
+		return ok
+	}
+
+	controllingValue := gate.GetControllingValue()
 	for _, input := range gate.Inputs {
-		if input.IsFaulty() {
-			hasFaultyInput = true
-			break
+		if controllingValue != circuit.X && !gate.IsFaultyInput(input) && input.Value == controllingValue {
+			return false
 		}
 	}
-
-	return hasFaultyInput && gate.Output.Value == circuit.X && gate.IsSensitizable()
+	return true
 }
 
 // isGateInJFrontier checks if a gate belongs in the J-frontier
@@ -89,6 +111,7 @@ func (f *Frontier) isGateInJFrontier(gate *circuit.Gate) bool {
 	// A gate is in J-frontier if:
 	// 1. Output is assigned (not X)
 	// 2. At least one input is not assigned (is X)
+	// 3. The assigned inputs do not already imply the output value
 
 	if !gate.Output.IsAssigned() {
 		return false
@@ -96,7 +119,7 @@ func (f *Frontier) isGateInJFrontier(gate *circuit.Gate) bool {
 
 	for _, input := range gate.Inputs {
 		if !input.IsAssigned() {
-			return true
+			return gate.Evaluate() == circuit.X
 		}
 	}
 
@@ -110,14 +133,20 @@ func (f *Frontier) GetDFrontierGate() *circuit.Gate {
 		return nil
 	}
 
-	// Find gate with the fewest inputs
+	// Prefer the most observable gate, then the gate with the fewest inputs
 	bestGate := f.DFrontier[0]
-	fewestInputs := len(bestGate.Inputs)
-
-	for _, gate := range f.DFrontier {
-		if len(gate.Inputs) < fewestInputs {
+	for _, gate := range f.DFrontier[1:] {
+		if f.Measures != nil {
+			co, bestCO := f.Measures.Observability(gate.Output), f.Measures.Observability(bestGate.Output)
+			if co != bestCO {
+				if co < bestCO {
+					bestGate = gate
+				}
+				continue
+			}
+		}
+		if len(gate.Inputs) < len(bestGate.Inputs) {
 			bestGate = gate
-			fewestInputs = len(gate.Inputs)
 		}
 	}
 
@@ -169,11 +198,26 @@ func (f *Frontier) GetObjectivesFromDFrontier() []InitialObjective {
 		return objectives
 	}
 
+	// A cell needs the values of its propagation cube
+	if gate.Type.HasTruthTable() {
+		cube, _ := gate.PropagationCube()
+		for i, input := range gate.Inputs {
+			if value := cube.Literal(i); value != circuit.X {
+				objectives = append(objectives, InitialObjective{Line: input, Value: value})
+			}
+		}
+		return objectives
+	}
+
 	// For the chosen gate, we need to set all non-faulty inputs to non-controlling values
 	nonControlValue := gate.GetNonControllingValue()
+	if nonControlValue == circuit.X {
+		// XOR-type gates propagate for any side value, pick 0
+		nonControlValue = circuit.Zero
+	}
 
 	for _, input := range gate.Inputs {
-		if !input.IsFaulty() && !input.IsAssigned() {
+		if !gate.IsFaultyInput(input) && !input.IsAssigned() {
 			// Create an objective to set this input to the non-controlling value
 			obj := InitialObjective{
 				Line:  input,
@@ -201,10 +245,18 @@ func (f *Frontier) GetObjectivesFromJFrontier() []InitialObjective {
 		return objectives
 	}
 
-	// Based on gate type and output value, determine required input values
+	// Based on gate type and output value, determine required input values.
+	// At the fault site only the good value has to be justified.
+	outputVal := gate.Output.GetGoodValue()
+
+	// NAND and NOR are justified like AND and OR with the opposite output value
+	if (gate.Type == circuit.NAND || gate.Type == circuit.NOR) && outputVal != circuit.X {
+		outputVal = oppositeBinaryValue(outputVal)
+	}
+
 	switch gate.Type {
-	case circuit.AND:
-		if gate.Output.Value == circuit.One {
+	case circuit.AND, circuit.NAND:
+		if outputVal == circuit.One {
 			// All inputs must be 1
 			for _, input := range gate.Inputs {
 				if !input.IsAssigned() {
@@ -214,7 +266,7 @@ func (f *Frontier) GetObjectivesFromJFrontier() []InitialObjective {
 					})
 				}
 			}
-		} else if gate.Output.Value == circuit.Zero {
+		} else if outputVal == circuit.Zero {
 			// At least one input must be 0, choose an unassigned one
 			for _, input := range gate.Inputs {
 				if !input.IsAssigned() {
@@ -227,8 +279,8 @@ func (f *Frontier) GetObjectivesFromJFrontier() []InitialObjective {
 			}
 		}
 
-	case circuit.OR:
-		if gate.Output.Value == circuit.Zero {
+	case circuit.OR, circuit.NOR:
+		if outputVal == circuit.Zero {
 			// All inputs must be 0
 			for _, input := range gate.Inputs {
 				if !input.IsAssigned() {
@@ -238,7 +290,7 @@ func (f *Frontier) GetObjectivesFromJFrontier() []InitialObjective {
 					})
 				}
 			}
-		} else if gate.Output.Value == circuit.One {
+		} else if outputVal == circuit.One {
 			// At least one input must be 1, choose an unassigned one
 			for _, input := range gate.Inputs {
 				if !input.IsAssigned() {
@@ -254,7 +306,7 @@ func (f *Frontier) GetObjectivesFromJFrontier() []InitialObjective {
 	case circuit.NOT:
 		if len(gate.Inputs) == 1 && !gate.Inputs[0].IsAssigned() {
 			var inputVal circuit.LogicValue
-			switch gate.Output.Value {
+			switch outputVal {
 			case circuit.Zero:
 				inputVal = circuit.One
 			case circuit.One:
@@ -271,9 +323,54 @@ func (f *Frontier) GetObjectivesFromJFrontier() []InitialObjective {
 			})
 		}
 
-	case circuit.NAND, circuit.NOR, circuit.XOR, circuit.XNOR:
-		// Similar logic for other gate types
-		// (simplified for brevity)
+	case circuit.BUF:
+		if len(gate.Inputs) == 1 && !gate.Inputs[0].IsAssigned() {
+			objectives = append(objectives, InitialObjective{
+				Line:  gate.Inputs[0],
+				Value: outputVal,
+			})
+		}
+
+	case circuit.XOR, circuit.XNOR:
+		// The last unassigned input has to give the output parity, before
+		// that any value of an unassigned input can still be justified
+		parity := outputVal
+		if gate.Type == circuit.XNOR {
+			parity = oppositeBinaryValue(parity)
+		}
+		var next *circuit.Line
+		unassigned := 0
+		for _, input := range gate.Inputs {
+			if !input.IsAssigned() {
+				if next == nil {
+					next = input
+				}
+				unassigned++
+			} else if gate.InputValue(input) == circuit.One {
+				parity = oppositeBinaryValue(parity)
+			}
+		}
+		if next != nil {
+			value := circuit.Zero
+			if unassigned == 1 {
+				value = parity
+			}
+			objectives = append(objectives, InitialObjective{Line: next, Value: value})
+		}
+
+	default:
+		if !gate.Type.HasTruthTable() {
+			break
+		}
+
+		// A cell needs the unassigned inputs of its easiest cube for the value
+		if cube, ok := easiestCube(gate, outputVal, f.Measures); ok {
+			for i, input := range gate.Inputs {
+				if value := cube.Literal(i); value != circuit.X && !input.IsAssigned() {
+					objectives = append(objectives, InitialObjective{Line: input, Value: value})
+				}
+			}
+		}
 	}
 
 	return objectives
@@ -1,32 +1,42 @@
 package algorithm
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
 	"github.com/fyerfyer/fan-atpg/pkg/utils"
 )
 
+// ErrImplicationLimit is returned by ImplyValues when MaxIterations ends an
+// implication pass that still makes changes
+var ErrImplicationLimit = errors.New("implication limit reached")
+
 // Implication manages the implication operations for the FAN algorithm
 type Implication struct {
-	Circuit  *circuit.Circuit
-	Logger   *utils.Logger
-	Topo     *circuit.Topology
-	Frontier *Frontier
+	Circuit       *circuit.Circuit
+	Logger        *utils.Logger
+	Topo          *circuit.Topology
+	Frontier      *Frontier
+	MaxIterations int       // Iteration cap of ImplyValues, 0 for no limit
+	Learned       *Learning // Implications found by static learning, nil if none
 }
 
 // NewImplication creates a new Implication manager
 func NewImplication(c *circuit.Circuit, f *Frontier, t *circuit.Topology, logger *utils.Logger) *Implication {
 	return &Implication{
-		Circuit:  c,
-		Logger:   logger,
-		Topo:     t,
-		Frontier: f,
+		Circuit:       c,
+		Logger:        logger,
+		Topo:          t,
+		Frontier:      f,
+		MaxIterations: DefaultOptions().MaxImplications,
 	}
 }
 
-// ImplyValues performs forward and backward implication until no more changes
+// ImplyValues performs forward and backward implication, together with the
+// learned implications if static learning has been run, until no more changes.
+// It returns an error wrapping ErrImplicationLimit if the changes do not stop
+// within MaxIterations iterations.
 func (i *Implication) ImplyValues() (bool, error) {
 	i.Logger.Implication("Starting implication process")
 	i.Logger.Indent()
@@ -36,7 +46,7 @@ func (i *Implication) ImplyValues() (bool, error) {
 	iterations := 0
 
 	// Continue until no more changes or conflict detected
-	for changed && iterations < 100 { // Limit iterations to prevent infinite loops
+	for changed && (i.MaxIterations == 0 || iterations < i.MaxIterations) {
 		iterations++
 		i.Logger.Trace("Implication iteration %d", iterations)
 
@@ -52,6 +62,12 @@ func (i *Implication) ImplyValues() (bool, error) {
 			return false, err
 		}
 
+		// Indirect implications found by static learning
+		lrnChanged, err := i.ImplyLearned()
+		if err != nil {
+			return false, err
+		}
+
 		// Update frontiers
 		i.Frontier.UpdateDFrontier()
 		i.Frontier.UpdateJFrontier()
@@ -65,9 +81,13 @@ func (i *Implication) ImplyValues() (bool, error) {
 			}
 		}
 
-		changed = fwdChanged || bwdChanged || usChanged
+		changed = fwdChanged || bwdChanged || lrnChanged || usChanged
 	}
 
+	if changed {
+		i.Logger.Implication("Implication stopped after %d iterations", iterations)
+		return false, fmt.Errorf("%w after %d iterations", ErrImplicationLimit, iterations)
+	}
 	i.Logger.Implication("Implication completed after %d iterations", iterations)
 
 	// Check if there's a conflict in the current assignments
@@ -81,8 +101,19 @@ func (i *Implication) ImplyValues() (bool, error) {
 
 // ImplyForward performs forward implication (from inputs toward outputs)
 func (i *Implication) ImplyForward() (bool, error) {
-	// Use the circuit's built-in forward simulation
-	changed := i.Circuit.SimulateForward()
+	changed := false
+
+	// Gates in level order see the values their inputs were just given
+	for _, gate := range i.Topo.LevelizedGates() {
+		if gate.Output.IsAssigned() {
+			continue
+		}
+		// SetValue turns a value opposite to the fault type into D or D' at the fault site
+		if value := gate.Evaluate(); value != circuit.X {
+			gate.Output.SetValue(value)
+			changed = true
+		}
+	}
 
 	if changed {
 		i.Logger.Trace("Forward implication made changes")
@@ -100,85 +131,28 @@ func (i *Implication) ImplyForward() (bool, error) {
 func (i *Implication) ImplyBackward() (bool, error) {
 	changed := false
 
-	// do backward imply for all gates
-	for _, gate := range i.Circuit.Gates {
+	// Gates in reverse level order pass implied inputs on to their drivers
+	gates := i.Topo.LevelizedGates()
+	for idx := len(gates) - 1; idx >= 0; idx-- {
+		gate := gates[idx]
 		if gate.Output.IsAssigned() {
 			outputVal := gate.Output.Value
 
-			switch gate.Type {
-			case circuit.NOT:
-				// NOT gate logic
-				if len(gate.Inputs) == 1 && !gate.Inputs[0].IsAssigned() {
-					var inputVal circuit.LogicValue
-					switch outputVal {
-					case circuit.Zero:
-						inputVal = circuit.One
-					case circuit.One:
-						inputVal = circuit.Zero
-					case circuit.D:
-						inputVal = circuit.Dnot
-					case circuit.Dnot:
-						inputVal = circuit.D
-					}
-
-					if inputVal != circuit.X {
-						gate.Inputs[0].SetValue(inputVal)
-						changed = true
-					}
-				}
-
-			case circuit.BUF:
-				// BUF gate logic
-				if len(gate.Inputs) == 1 && !gate.Inputs[0].IsAssigned() {
-					gate.Inputs[0].SetValue(outputVal)
-					changed = true
-				}
-
-			case circuit.AND, circuit.NAND:
-				isAND := gate.Type == circuit.AND
-				outputIsControl := (isAND && outputVal == circuit.Zero) ||
-					(!isAND && outputVal == circuit.One)
-
-				if outputIsControl {
-					// Can't determine input values when output has controlling value
-					continue
-				}
-
-				// For both AND and NAND, the non-controlling value is 1
-				nonControlVal := circuit.One
-
-				for _, input := range gate.Inputs {
-					if !input.IsAssigned() {
-						input.SetValue(nonControlVal)
-						changed = true
-					} else if input.Value != nonControlVal &&
-						input.Value != circuit.D && input.Value != circuit.Dnot {
-						return changed, fmt.Errorf("conflict in backward implication")
-					}
-				}
-
-			case circuit.OR, circuit.NOR:
-				isOR := gate.Type == circuit.OR
-				outputIsControl := (isOR && outputVal == circuit.One) ||
-					(!isOR && outputVal == circuit.Zero)
-
-				if outputIsControl {
-					// Can't determine input values when output has controlling value
+			// A fault effect at the fault site only needs its good value justified,
+			// any other D or D' was propagated forward from the gate inputs
+			if gate.Output.IsFaulty() {
+				if gate.Output != i.Circuit.FaultSite {
 					continue
 				}
+				outputVal = gate.Output.GetGoodValue()
+			}
 
-				// For both OR and NOR, the non-controlling value is 0
-				nonControlVal := circuit.Zero
-
-				for _, input := range gate.Inputs {
-					if !input.IsAssigned() {
-						input.SetValue(nonControlVal)
-						changed = true
-					} else if input.Value != nonControlVal &&
-						input.Value != circuit.D && input.Value != circuit.Dnot {
-						return changed, fmt.Errorf("conflict in backward implication")
-					}
-				}
+			assigned, err := i.implyGate(gate, outputVal)
+			if len(assigned) > 0 {
+				changed = true
+			}
+			if err != nil {
+				return changed, err
 			}
 		}
 	}
@@ -194,6 +168,106 @@ func (i *Implication) ImplyBackward() (bool, error) {
 	return changed, nil
 }
 
+// implyGate assigns the inputs of a gate that the value of its output implies
+// and returns the lines it assigned
+func (i *Implication) implyGate(gate *circuit.Gate, outputVal circuit.LogicValue) ([]*circuit.Line, error) {
+	var assigned []*circuit.Line
+
+	switch gate.Type {
+	case circuit.NOT:
+		// NOT gate logic
+		if len(gate.Inputs) == 1 && !gate.Inputs[0].IsAssigned() {
+			var inputVal circuit.LogicValue
+			switch outputVal {
+			case circuit.Zero:
+				inputVal = circuit.One
+			case circuit.One:
+				inputVal = circuit.Zero
+			case circuit.D:
+				inputVal = circuit.Dnot
+			case circuit.Dnot:
+				inputVal = circuit.D
+			}
+
+			if inputVal != circuit.X {
+				gate.Inputs[0].SetValue(inputVal)
+				assigned = append(assigned, gate.Inputs[0])
+			}
+		}
+
+	case circuit.BUF:
+		// BUF gate logic
+		if len(gate.Inputs) == 1 && !gate.Inputs[0].IsAssigned() {
+			gate.Inputs[0].SetValue(outputVal)
+			assigned = append(assigned, gate.Inputs[0])
+		}
+
+	case circuit.AND, circuit.NAND:
+		isAND := gate.Type == circuit.AND
+		outputIsControl := (isAND && outputVal == circuit.Zero) ||
+			(!isAND && outputVal == circuit.One)
+
+		if outputIsControl {
+			// Can't determine input values when output has controlling value
+			return nil, nil
+		}
+
+		// For both AND and NAND, the non-controlling value is 1
+		nonControlVal := circuit.One
+
+		for _, input := range gate.Inputs {
+			if !input.IsAssigned() {
+				input.SetValue(nonControlVal)
+				assigned = append(assigned, input)
+			} else if v := gate.InputValue(input); v != nonControlVal && !v.IsFaulty() {
+				return assigned, fmt.Errorf("conflict in backward implication")
+			}
+		}
+
+	case circuit.OR, circuit.NOR:
+		isOR := gate.Type == circuit.OR
+		outputIsControl := (isOR && outputVal == circuit.One) ||
+			(!isOR && outputVal == circuit.Zero)
+
+		if outputIsControl {
+			// Can't determine input values when output has controlling value
+			return nil, nil
+		}
+
+		// For both OR and NOR, the non-controlling value is 0
+		nonControlVal := circuit.Zero
+
+		for _, input := range gate.Inputs {
+			if !input.IsAssigned() {
+				input.SetValue(nonControlVal)
+				assigned = append(assigned, input)
+			} else if v := gate.InputValue(input); v != nonControlVal && !v.IsFaulty() {
+				return assigned, fmt.Errorf("conflict in backward implication")
+			}
+		}
+
+	default:
+		if !gate.Type.HasTruthTable() {
+			return nil, nil
+		}
+
+		// A cell implies the inputs on which every cube of the output
+		// value consistent with the assigned inputs agrees
+		implied, ok := gate.ImpliedInputs(outputVal)
+		if !ok {
+			return assigned, fmt.Errorf("conflict in backward implication")
+		}
+		for idx, input := range gate.Inputs {
+			if implied[idx] != circuit.X {
+				input.SetValue(implied[idx])
+				assigned = append(assigned, input)
+			}
+		}
+	}
+
+	return assigned, nil
+}
+
 // HasConflict checks for logical conflicts in the current circuit state
 func (i *Implication) HasConflict() bool {
 	// Check for conflicts in gate outputs (simulated vs. assigned)
@@ -221,8 +295,15 @@ func (i *Implication) HasConflict() bool {
 				continue
 			}
 
+			// The fault site holds D or D' where the gate computes its good value
+			assigned := gate.Output.Value
+			if gate.Output == i.Circuit.FaultSite && i.Circuit.FaultBranch == nil {
+				assigned = gate.Output.GetGoodValue()
+				simulated = goodValue(simulated)
+			}
+
 			// Check for inconsistency between simulated and assigned
-			if gate.Output.Value != simulated {
+			if assigned != simulated {
 				i.Logger.Implication("Conflict detected: gate %s output is %v but should be %v",
 					gate.Name, gate.Output.Value, simulated)
 				return true
@@ -245,6 +326,11 @@ func (i *Implication) HasConflict() bool {
 			return true
 		}
 
+		// A branch fault keeps the good value on the stem, any other binary value activates it
+		if i.Circuit.FaultBranch != nil {
+			return false
+		}
+
 		// From second implementation: Check for values incompatible with fault type
 		if faultType == circuit.Zero {
 			// For s-a-0, only allowed values are 0 and D'
@@ -268,67 +354,63 @@ func (i *Implication) HasConflict() bool {
 	return false
 }
 
-// ApplyUniqueSensitization implements the unique sensitization strategy from the FAN algorithm
+// goodValue returns the good circuit value of a five-valued logic value
+func goodValue(v circuit.LogicValue) circuit.LogicValue {
+	switch v {
+	case circuit.D:
+		return circuit.Zero
+	case circuit.Dnot:
+		return circuit.One
+	default:
+		return v
+	}
+}
+
+// ApplyUniqueSensitization implements the unique sensitization strategy of
+// FAN. When the D-frontier is a single gate, the fault effect has to pass
+// through every dominator of its output on the way to a primary output, so
+// every side input of a dominator gate that the fault effect cannot reach is
+// set to the non-controlling value. A side input that already holds the
+// controlling value blocks the fault effect, which is a conflict.
 func (i *Implication) ApplyUniqueSensitization(gate *circuit.Gate) (bool, error) {
 	i.Logger.Implication("Attempting unique sensitization for gate %s", gate.Name)
 
-	// Find all unique paths from the gate to primary outputs
-	uniquePaths := i.Topo.FindUniquePathsToOutputs(gate)
-	if len(uniquePaths) == 0 {
-		i.Logger.Trace("No unique paths found for gate %s", gate.Name)
-		return false, nil
+	// Nothing is left to sensitize once the fault effect is at an output
+	for _, output := range i.Circuit.Outputs {
+		if output.IsFaulty() {
+			return false, nil
+		}
 	}
 
-	i.Logger.Trace("Found %d unique paths that must be sensitized", len(uniquePaths))
-
-	// Print paths for debugging
-	for idx, path := range uniquePaths {
-		pathNames := []string{}
-		for _, line := range path {
-			pathNames = append(pathNames, line.Name)
-		}
-		i.Logger.Trace("Path %d: %s", idx+1, strings.Join(pathNames, " → "))
+	dominators := i.Topo.Dominators(gate.Output)
+	if len(dominators) == 0 {
+		i.Logger.Trace("Gate %s reaches no primary output", gate.Name)
+		return false, nil
 	}
 
+	cone := i.faultCone()
 	changed := false
+	for _, line := range dominators {
+		dominator := line.InputGate
+		nonControllingValue := dominator.GetNonControllingValue()
+		if nonControllingValue == circuit.X {
+			continue
+		}
 
-	// Process each path
-	for _, path := range uniquePaths {
-		// For each line in the path
-		for _, line := range path {
-			// Find the gate that produces this line (if any)
-			if line.InputGate == nil {
-				continue
-			}
-
-			// Skip the D-frontier gate itself
-			if line.InputGate.ID == gate.ID {
+		for _, input := range dominator.Inputs {
+			// The stem of a branch fault carries the fault effect into the faulty branch
+			if cone[input] || (input == i.Circuit.FaultSite && dominator == i.Circuit.FaultBranch) {
 				continue
 			}
-
-			inputGate := line.InputGate
-			nonControllingValue := inputGate.GetNonControllingValue()
-
-			// For non-X non-controlling values, set all unassigned side inputs
-			if nonControllingValue != circuit.X {
-				for _, input := range inputGate.Inputs {
-					// Skip inputs that are part of the path we're sensitizing
-					isOnPath := false
-					for _, pathLine := range path {
-						if input.ID == pathLine.ID {
-							isOnPath = true
-							break
-						}
-					}
-
-					// Set unassigned side inputs to non-controlling values
-					if !isOnPath && !input.IsAssigned() {
-						i.Logger.Algorithm("Setting line %s to %v for unique sensitization",
-							input.Name, nonControllingValue)
-						input.SetValue(nonControllingValue)
-						changed = true
-					}
-				}
+			switch input.Value {
+			case nonControllingValue:
+			case circuit.X:
+				i.Logger.Algorithm("Setting line %s to %v for unique sensitization",
+					input.Name, nonControllingValue)
+				input.SetValue(nonControllingValue)
+				changed = true
+			default:
+				return changed, fmt.Errorf("side input %s of %s blocks the fault effect", input.Name, dominator.Name)
 			}
 		}
 	}
@@ -336,6 +418,38 @@ func (i *Implication) ApplyUniqueSensitization(gate *circuit.Gate) (bool, error)
 	return changed, nil
 }
 
+// faultCone returns the lines whose faulty value can differ from their good
+// value: the fault site of a stem fault, the lines holding a fault effect and
+// every line in their fanout
+func (i *Implication) faultCone() map[*circuit.Line]bool {
+	cone := make(map[*circuit.Line]bool)
+
+	var queue []*circuit.Line
+	if branch := i.Circuit.FaultBranch; branch != nil {
+		queue = append(queue, branch.Output)
+	} else if site := i.Circuit.FaultSite; site != nil {
+		queue = append(queue, site)
+	}
+	for _, line := range i.Circuit.Lines {
+		if line.IsFaulty() {
+			queue = append(queue, line)
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if cone[current] {
+			continue
+		}
+		cone[current] = true
+		for _, gate := range current.OutputGates {
+			queue = append(queue, gate.Output)
+		}
+	}
+	return cone
+}
+
 // JustifyLine attempts to justify a line to have a specific value
 // Returns true if successful, false if impossible
 func (i *Implication) JustifyLine(line *circuit.Line, targetValue circuit.LogicValue) (bool, error) {
@@ -405,45 +519,43 @@ func (i *Implication) TryValueOnLine(line *circuit.Line, value circuit.LogicValu
 }
 
 // CheckIfXPathExists checks if a path exists from a D/D' value to a primary output
+// along lines that are still X, so that the fault effect can still be propagated
 func (i *Implication) CheckIfXPathExists() bool {
-	faultyLines := make([]*circuit.Line, 0)
-	for _, line := range i.Circuit.Lines {
+	startLines := make([]*circuit.Line, 0)
+	for _, line := range i.Circuit.Outputs {
 		if line.IsFaulty() {
-			faultyLines = append(faultyLines, line)
+			return true
+		}
+	}
+	for _, gate := range i.Circuit.Gates {
+		if gate.Output.Value == circuit.X && gate.HasFaultyInput() {
+			startLines = append(startLines, gate.Output)
 		}
 	}
 
-	if len(faultyLines) == 0 {
+	if len(startLines) == 0 {
 		return false
 	}
 
-	// for every faulty line, check if there is a path to a primary output
-	// where all gates can be sensitized (no blocking control values)
-	for _, faultyLine := range faultyLines {
-		// bfs search for the path
-		visited := make(map[*circuit.Line]bool)
-		queue := []*circuit.Line{faultyLine}
-
-		for len(queue) > 0 {
-			current := queue[0]
-			queue = queue[1:]
+	// bfs search for a path of X-valued lines to a primary output
+	visited := make(map[*circuit.Line]bool)
+	queue := startLines
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
 
-			if current.Type == circuit.PrimaryOutput {
-				// find the output path
-				return true
-			}
+		if current.Type == circuit.PrimaryOutput {
+			return true
+		}
 
-			if visited[current] {
-				continue
-			}
-			visited[current] = true
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
 
-			// check all gates connected to this line
-			for _, gate := range current.OutputGates {
-				// check if the gate can be sensitized
-				if gate.IsSensitizable() {
-					queue = append(queue, gate.Output)
-				}
+		for _, gate := range current.OutputGates {
+			if gate.Output.Value == circuit.X {
+				queue = append(queue, gate.Output)
 			}
 		}
 	}
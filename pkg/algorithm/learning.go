@@ -0,0 +1,272 @@
+package algorithm
+
+import (
+	"fmt"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// Assignment is a binary value on a line
+type Assignment struct {
+	Line  *circuit.Line
+	Value circuit.LogicValue
+}
+
+// Learning holds the implications of the fault-free circuit that static
+// learning found beyond direct implication. Every assignment in Implications
+// implies the listed assignments; a line in Constants has the same value for
+// every input pattern.
+type Learning struct {
+	Implications map[Assignment][]Assignment
+	Constants    map[*circuit.Line]circuit.LogicValue
+}
+
+// Count returns the number of learned implications
+func (l *Learning) Count() int {
+	count := 0
+	for _, implied := range l.Implications {
+		count += len(implied)
+	}
+	return count
+}
+
+// Translate returns the learned implications on the lines of another circuit
+// with the same structure, such as a clone, matching lines by ID
+func (l *Learning) Translate(c *circuit.Circuit) *Learning {
+	lines := make(map[int]*circuit.Line, len(c.Lines))
+	for _, line := range c.Lines {
+		lines[line.ID] = line
+	}
+	translate := func(a Assignment) Assignment {
+		return Assignment{Line: lines[a.Line.ID], Value: a.Value}
+	}
+
+	learning := &Learning{
+		Implications: make(map[Assignment][]Assignment, len(l.Implications)),
+		Constants:    make(map[*circuit.Line]circuit.LogicValue, len(l.Constants)),
+	}
+	for from, implied := range l.Implications {
+		to := make([]Assignment, len(implied))
+		for idx, a := range implied {
+			to[idx] = translate(a)
+		}
+		learning.Implications[translate(from)] = to
+	}
+	for line, value := range l.Constants {
+		learning.Constants[lines[line.ID]] = value
+	}
+	return learning
+}
+
+// Learn performs SOCRATES-style static learning on the fault-free circuit and
+// sets the result as the learned implications of i. Every line is tentatively
+// assigned each value in turn and its direct implications are computed. For an
+// implied value w on the output m of a gate whose other value does not imply
+// any gate input by itself, the contrapositive is learned: m = !w implies that
+// the line has the opposite of its tentative value. A tentative value that
+// leads to a conflict cannot occur at all, so the line is constant. The circuit
+// is left reset.
+func (i *Implication) Learn() *Learning {
+	i.Logger.Implication("Starting static learning")
+	i.Logger.Indent()
+	defer i.Logger.Outdent()
+
+	learning := &Learning{
+		Implications: make(map[Assignment][]Assignment),
+		Constants:    make(map[*circuit.Line]circuit.LogicValue),
+	}
+	learned := make(map[[2]Assignment]bool)
+
+	// Lines driven by tie cells keep their values under every assignment
+	i.Circuit.Reset()
+	for _, gate := range i.Topo.LevelizedGates() {
+		if !gate.Output.IsAssigned() {
+			gate.Output.SetValue(gate.Evaluate())
+		}
+	}
+
+	for _, line := range i.Circuit.SortedLines() {
+		if line.IsAssigned() {
+			continue
+		}
+		conflicts := 0
+		for _, value := range []circuit.LogicValue{circuit.Zero, circuit.One} {
+			implied, ok := i.implyAssignment(line, value)
+			if !ok {
+				i.Logger.Implication("%s = %v leads to a conflict, the line is constant", line.Name, value)
+				learning.Constants[line] = oppositeBinaryValue(value)
+				conflicts++
+			}
+			for _, m := range implied {
+				if !ok || m == line || !learnable(m) {
+					continue
+				}
+				from := Assignment{Line: m, Value: oppositeBinaryValue(m.Value)}
+				to := Assignment{Line: line, Value: oppositeBinaryValue(value)}
+				if !learned[[2]Assignment{from, to}] {
+					learned[[2]Assignment{from, to}] = true
+					learning.Implications[from] = append(learning.Implications[from], to)
+				}
+			}
+			for _, m := range implied {
+				m.Value = circuit.X
+			}
+		}
+
+		// Both values only conflict in a circuit that no input pattern is consistent with
+		if conflicts == 2 {
+			delete(learning.Constants, line)
+		}
+	}
+	i.Circuit.Reset()
+
+	i.Logger.Implication("Learned %d indirect implications and %d constant lines",
+		learning.Count(), len(learning.Constants))
+	i.Learned = learning
+	return learning
+}
+
+// implyAssignment assigns a value to a line of the fault-free circuit and
+// performs direct implication from it event by event. It returns every line it
+// assigned, starting with the line itself, and false on a conflict.
+func (i *Implication) implyAssignment(line *circuit.Line, value circuit.LogicValue) ([]*circuit.Line, bool) {
+	line.SetValue(value)
+	assigned := []*circuit.Line{line}
+
+	for next := 0; next < len(assigned); next++ {
+		current := assigned[next]
+
+		// Backward: the value of the line implies inputs of its driving gate
+		if gate := current.InputGate; gate != nil {
+			inputs, err := i.implyGate(gate, current.Value)
+			assigned = append(assigned, inputs...)
+			if err != nil {
+				return assigned, false
+			}
+		}
+
+		// Forward: the gates it drives may now have an output value, and an
+		// assigned output may imply further inputs or contradict this one
+		for _, gate := range current.OutputGates {
+			if !gate.Output.IsAssigned() {
+				if v := gate.Evaluate(); v != circuit.X {
+					gate.Output.SetValue(v)
+					assigned = append(assigned, gate.Output)
+				}
+				continue
+			}
+			if v := gate.Evaluate(); v != circuit.X && v != gate.Output.Value {
+				return assigned, false
+			}
+			inputs, err := i.implyGate(gate, gate.Output.Value)
+			assigned = append(assigned, inputs...)
+			if err != nil {
+				return assigned, false
+			}
+		}
+	}
+	return assigned, true
+}
+
+// learnable reports whether the contrapositive of an implied value on a line is
+// worth learning: the value follows forward from the inputs of the driving gate,
+// while the opposite value does not imply any of those inputs directly
+func learnable(line *circuit.Line) bool {
+	gate := line.InputGate
+	if gate == nil || len(gate.Inputs) == 0 {
+		return false
+	}
+	for _, input := range gate.Inputs {
+		if !input.IsAssigned() {
+			return false
+		}
+	}
+	return !impliesInput(gate, oppositeBinaryValue(line.Value))
+}
+
+// impliesInput reports whether an output value of a gate alone implies the
+// value of one of its inputs
+func impliesInput(gate *circuit.Gate, value circuit.LogicValue) bool {
+	switch gate.Type {
+	case circuit.NOT, circuit.BUF:
+		return true
+	case circuit.AND, circuit.NOR:
+		return value == circuit.One || len(gate.Inputs) == 1
+	case circuit.NAND, circuit.OR:
+		return value == circuit.Zero || len(gate.Inputs) == 1
+	case circuit.XOR, circuit.XNOR:
+		return len(gate.Inputs) == 1
+	}
+
+	// A cell implies an input on which all cubes of the value agree
+	cubes := gate.Cubes(value)
+	if len(cubes) == 0 {
+		return true
+	}
+	for idx := range gate.Inputs {
+		literal := cubes[0].Literal(idx)
+		for _, c := range cubes[1:] {
+			if c.Literal(idx) != literal {
+				literal = circuit.X
+				break
+			}
+		}
+		if literal != circuit.X {
+			return true
+		}
+	}
+	return false
+}
+
+// ImplyLearned applies the learned implications of the lines with a binary
+// good value. An implied value is assigned to an unassigned line outside the
+// fanout of the fault, where the faulty machine agrees with the good machine,
+// and to an unassigned stem fault site, which then holds its fault effect or
+// stuck-at value. An implied value that contradicts the good value of a line
+// is a conflict.
+func (i *Implication) ImplyLearned() (bool, error) {
+	if i.Learned == nil {
+		return false, nil
+	}
+	cone := i.faultCone()
+	changed := false
+
+	imply := func(a Assignment) error {
+		switch a.Line.GetGoodValue() {
+		case a.Value:
+			return nil
+		case circuit.X:
+			stemSite := a.Line == i.Circuit.FaultSite && i.Circuit.FaultBranch == nil
+			if stemSite || !cone[a.Line] {
+				a.Line.SetValue(a.Value)
+				changed = true
+			}
+			return nil
+		default:
+			return fmt.Errorf("learned value %v of %s contradicts its value %v",
+				a.Value, a.Line.Name, a.Line.GetGoodValue())
+		}
+	}
+
+	for line, value := range i.Learned.Constants {
+		if err := imply(Assignment{Line: line, Value: value}); err != nil {
+			return changed, err
+		}
+	}
+	for _, line := range i.Circuit.Lines {
+		value := line.GetGoodValue()
+		if value == circuit.X {
+			continue
+		}
+		for _, a := range i.Learned.Implications[Assignment{Line: line, Value: value}] {
+			if err := imply(a); err != nil {
+				return changed, err
+			}
+		}
+	}
+
+	if changed {
+		i.Logger.Trace("Learned implication made changes")
+	}
+	return changed, nil
+}
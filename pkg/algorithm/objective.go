@@ -3,6 +3,7 @@ package algorithm
 import (
 	"fmt"
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/testability"
 	"github.com/fyerfyer/fan-atpg/pkg/utils"
 	"sort"
 )
@@ -46,6 +47,9 @@ type MultipleBacktrace struct {
 	InitialObjs []InitialObjective
 	CurrentObjs []*Objective
 	FinalObjs   []*Objective
+
+	// Measures pick the easiest input to control when set, nil picks the first free input
+	Measures *testability.Measures
 }
 
 // NewMultipleBacktrace creates a new multiple backtrace manager
@@ -111,6 +115,11 @@ func (mb *MultipleBacktrace) PerformBacktrace() {
 			continue
 		}
 
+		// An assigned line either meets the objective already or cannot be changed
+		if line.IsAssigned() {
+			continue
+		}
+
 		// If this is a head line or primary input, add to final objectives
 		if line.IsHeadLine || line.Type == circuit.PrimaryInput {
 			mb.FinalObjs = append(mb.FinalObjs, obj)
@@ -266,19 +275,92 @@ func (mb *MultipleBacktrace) backtraceGate(gate *circuit.Gate, obj *Objective) [
 			}
 			newObjs = append(newObjs, newObj)
 		}
+
+	default:
+		if !gate.Type.HasTruthTable() {
+			break
+		}
+
+		// A cell is justified by one of its cubes: each required value is
+		// passed to the inputs of the easiest cube that gives it
+		for _, target := range []struct {
+			value circuit.LogicValue
+			n     int
+		}{{circuit.Zero, obj.N0}, {circuit.One, obj.N1}} {
+			if target.n == 0 {
+				continue
+			}
+			cube, ok := easiestCube(gate, target.value, mb.Measures)
+			if !ok {
+				continue
+			}
+			for i, input := range gate.Inputs {
+				switch cube.Literal(i) {
+				case circuit.Zero:
+					newObjs = append(newObjs, &Objective{Line: input, N0: target.n})
+				case circuit.One:
+					newObjs = append(newObjs, &Objective{Line: input, N1: target.n})
+				}
+			}
+		}
 	}
 
 	return newObjs
 }
 
+// easiestCube returns the cube that sets the output of a cell to a value at
+// the least effort, among those the assigned inputs do not contradict. The
+// effort is the controllability of the unassigned inputs the cube specifies,
+// or their number without measures. It returns false if no cube is left.
+func easiestCube(gate *circuit.Gate, value circuit.LogicValue, m *testability.Measures) (circuit.Cube, bool) {
+	var best circuit.Cube
+	bestCost := -1
+	for _, cube := range gate.ConsistentCubes(value) {
+		cost := 0
+		for i, input := range gate.Inputs {
+			literal := cube.Literal(i)
+			if literal == circuit.X || input.IsAssigned() {
+				continue
+			}
+			if m != nil {
+				cost += m.Controllability(input, literal)
+			} else {
+				cost++
+			}
+		}
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = cube, cost
+		}
+	}
+	return best, bestCost >= 0
+}
+
 // findEasiestControlInput finds the input that is easiest to control to the target value
 func (mb *MultipleBacktrace) findEasiestControlInput(gate *circuit.Gate, targetValue circuit.LogicValue) *circuit.Line {
-	// This is a simplified implementation
-	// In practice, you might use testability measures or controllability metrics
+	if mb.Measures != nil {
+		// The cached control input is the easiest one to set to the controlling value
+		if gate.ControlID >= 0 && gate.ControlID < len(gate.Inputs) &&
+			!gate.Inputs[gate.ControlID].IsAssigned() && targetValue == gate.GetControllingValue() {
+			return gate.Inputs[gate.ControlID]
+		}
 
-	// If gate has a cached control input ID, use that
-	if gate.ControlID >= 0 && gate.ControlID < len(gate.Inputs) {
-		return gate.Inputs[gate.ControlID]
+		var easiest *circuit.Line
+		for _, input := range gate.Inputs {
+			if !input.IsAssigned() && (easiest == nil ||
+				mb.Measures.Controllability(input, targetValue) < mb.Measures.Controllability(easiest, targetValue)) {
+				easiest = input
+			}
+		}
+		if easiest != nil {
+			return easiest
+		}
+	}
+
+	// Otherwise prefer the first input that is still unassigned
+	for _, input := range gate.Inputs {
+		if !input.IsAssigned() {
+			return input
+		}
 	}
 
 	// Otherwise, default to the first input
@@ -0,0 +1,82 @@
+package algorithm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Heuristic selects how backtrace picks gate inputs and which D-frontier gate
+// the fault effect is propagated through
+type Heuristic int
+
+const (
+	HeuristicStructural Heuristic = iota // First free input, D-frontier gate with fewest inputs
+	HeuristicSCOAP                       // SCOAP controllability and observability
+)
+
+// String returns the name of the heuristic
+func (h Heuristic) String() string {
+	switch h {
+	case HeuristicStructural:
+		return "structural"
+	case HeuristicSCOAP:
+		return "scoap"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseHeuristic returns the heuristic with the given name
+func ParseHeuristic(name string) (Heuristic, error) {
+	switch name {
+	case "structural":
+		return HeuristicStructural, nil
+	case "scoap":
+		return HeuristicSCOAP, nil
+	default:
+		return 0, fmt.Errorf("unknown heuristic %q (expected structural or scoap)", name)
+	}
+}
+
+// Options bounds the effort the FAN algorithm spends on a single fault.
+// A zero limit means no limit.
+type Options struct {
+	MaxBacktracks   int           // Backtracks before a fault is aborted
+	MaxDecisions    int           // Decisions before a fault is aborted
+	Timeout         time.Duration // Wall-clock budget per fault
+	MaxImplications int           // Iterations of a single implication pass before a fault is aborted
+	Heuristic       Heuristic     // Guidance for backtrace and D-frontier selection
+	Learning        bool          // Apply implications learned statically during implication
+}
+
+// DefaultOptions returns the limits used by NewFan
+func DefaultOptions() Options {
+	return Options{
+		MaxBacktracks:   1000,
+		MaxDecisions:    10000,
+		MaxImplications: 100,
+		Heuristic:       HeuristicStructural,
+	}
+}
+
+// CompactionOptions configures test compaction. Dynamic compaction uses the
+// inputs a new test leaves unassigned to target further undetected faults
+// without changing the inputs it already assigns; static compaction works on the
+// finished test set, see Fan.CompactTests.
+type CompactionOptions struct {
+	Enabled       bool // Dynamic compaction of every new test, off by default
+	MaxSecondary  int  // Secondary faults targeted per test, 0 for every undetected fault
+	MaxBacktracks int  // Backtracks per secondary fault before it is given up on
+	Merge         bool // Merge finished tests whose specified inputs do not conflict
+	SetCover      bool // Keep a greedy set cover of the detection matrix instead of reverse-order simulation
+}
+
+// DefaultCompactionOptions returns the compaction settings used by NewFan
+func DefaultCompactionOptions() CompactionOptions {
+	return CompactionOptions{
+		Enabled:       false,
+		MaxSecondary:  0,
+		MaxBacktracks: 10,
+		Merge:         true,
+	}
+}
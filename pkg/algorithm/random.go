@@ -0,0 +1,106 @@
+package algorithm
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// RandomOptions configures the random-pattern phase that GenerateTestsForAllFaults
+// runs before deterministic test generation. The phase is disabled when
+// MaxPatterns is 0.
+type RandomOptions struct {
+	MaxPatterns int                // Random patterns applied at most
+	BlockSize   int                // Patterns fault-simulated between two coverage checks
+	MinGain     float64            // Stop once a block detects less than this fraction of the faults
+	Seed        int64              // Seed of the pattern generator, the same seed gives the same patterns
+	Weights     map[string]float64 // Probability of a 1 per input name, 0.5 for inputs not listed
+}
+
+// DefaultRandomOptions returns the random-pattern settings used by NewFan, with
+// the phase disabled
+func DefaultRandomOptions() RandomOptions {
+	return RandomOptions{
+		BlockSize: 64,
+		MinGain:   0.01,
+		Seed:      1,
+	}
+}
+
+// randomPhase fault-simulates blocks of random patterns against the faults and
+// drops the faults they detect. It stops when MaxPatterns patterns have been
+// applied, when a block detects less than MinGain of the faults or when the
+// context is done. It returns the faults left undetected and a result for every
+// detected fault; only patterns that detect a fault first are used as tests.
+func (f *Fan) randomPhase(ctx context.Context, faults []circuit.Fault) ([]circuit.Fault, []*TestResult, int) {
+	opts := f.Random
+	if opts.MaxPatterns <= 0 || len(faults) == 0 {
+		return faults, nil, 0
+	}
+	blockSize := opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = DefaultRandomOptions().BlockSize
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	f.Logger.Info("Random pattern phase: up to %d patterns in blocks of %d, seed %d",
+		opts.MaxPatterns, blockSize, opts.Seed)
+
+	total := len(faults)
+	remaining := faults
+	var detected []*TestResult
+	applied, kept := 0, 0
+	for applied < opts.MaxPatterns && len(remaining) > 0 && ctx.Err() == nil {
+		block := make([]map[string]circuit.LogicValue, min(blockSize, opts.MaxPatterns-applied))
+		for i := range block {
+			block[i] = f.randomPattern(rng)
+		}
+		applied += len(block)
+
+		used := make([]bool, len(block))
+		undetected := remaining[:0:0]
+		for i, first := range f.Simulator.Detect(block, remaining) {
+			if first < 0 {
+				undetected = append(undetected, remaining[i])
+				continue
+			}
+			used[first] = true
+			detected = append(detected, &TestResult{Fault: remaining[i], Status: Detected, Test: block[first]})
+		}
+		gain := len(remaining) - len(undetected)
+		remaining = undetected
+		for _, u := range used {
+			if u {
+				kept++
+			}
+		}
+
+		f.Logger.Info("Random patterns %d: %d faults detected, %d left", applied, gain, len(remaining))
+		if float64(gain) < opts.MinGain*float64(total) {
+			f.Logger.Info("Coverage gain below %.2f%% per block, ending random pattern phase", opts.MinGain*100)
+			break
+		}
+	}
+
+	f.Logger.Info("Random pattern phase detected %d faults with %d of %d patterns",
+		len(detected), kept, applied)
+	return remaining, detected, kept
+}
+
+// randomPattern returns a fully specified pattern for the inputs of the circuit
+func (f *Fan) randomPattern(rng *rand.Rand) map[string]circuit.LogicValue {
+	pattern := make(map[string]circuit.LogicValue, len(f.Circuit.Inputs))
+	for _, input := range f.Circuit.Inputs {
+		p, ok := f.Random.Weights[input.Name]
+		if !ok {
+			p = 0.5
+		}
+		if rng.Float64() < p {
+			pattern[input.Name] = circuit.One
+		} else {
+			pattern[input.Name] = circuit.Zero
+		}
+	}
+	return pattern
+}
@@ -0,0 +1,155 @@
+package algorithm
+
+import (
+	"context"
+	"time"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// RedundancyRemover removes redundant logic from a circuit. A fault FAN proves
+// untestable can be made permanent without changing the function of the
+// circuit, so its line is tied to the stuck value and the netlist simplified.
+// Removing one redundancy can make other faults testable or redundant, so the
+// faults are targeted again after every removal.
+type RedundancyRemover struct {
+	Circuit *circuit.Circuit // Circuit being simplified, replaced after every removal
+	Logger  *utils.Logger
+	Options Options // Search limits and heuristic applied to every fault
+
+	Removed []string // Redundant faults tied to their stuck value, in removal order
+	Kept    []string // Redundant faults that would make an output constant
+	Aborted int      // Faults aborted by the last full pass, which may hide redundancies
+	Passes  int      // Test generation passes run
+}
+
+// NewRedundancyRemover creates a redundancy remover for a circuit
+func NewRedundancyRemover(c *circuit.Circuit, logger *utils.Logger) *RedundancyRemover {
+	return &RedundancyRemover{
+		Circuit: c,
+		Logger:  logger,
+		Options: DefaultOptions(),
+	}
+}
+
+// Run removes redundancies until none is left and returns the simplified circuit
+func (r *RedundancyRemover) Run() (*circuit.Circuit, error) {
+	return r.RunContext(context.Background())
+}
+
+// RunContext is Run with a context. After a removal, only the faults the
+// previous pass proved redundant are targeted again, and one of them that
+// still is gets removed. Once none is, a full pass over every fault confirms
+// that no redundancy is left. When the context is done the circuit simplified
+// so far is returned with ctx.Err().
+func (r *RedundancyRemover) RunContext(ctx context.Context) (*circuit.Circuit, error) {
+	startTime := time.Now()
+	r.Removed, r.Kept, r.Passes = nil, nil, 0
+	kept := make(map[string]bool)
+
+	var candidates []string // Faults proven redundant by the last pass, by name
+	full := true
+	for ctx.Err() == nil {
+		redundant := r.redundantFaults(ctx, candidates, full)
+		if ctx.Err() != nil {
+			break
+		}
+
+		removed := -1
+		for i, fault := range redundant {
+			if kept[fault.String()] || !r.removable(fault) {
+				continue
+			}
+			simplified, err := r.Circuit.Simplify([]circuit.Fault{fault})
+			if err != nil {
+				r.Logger.Info("Keeping redundant fault %s: %v", fault, err)
+				kept[fault.String()] = true
+				r.Kept = append(r.Kept, fault.String())
+				continue
+			}
+			r.Logger.Info("Removing redundant fault %s: %d gates left of %d",
+				fault, len(simplified.Gates), len(r.Circuit.Gates))
+			r.Circuit = simplified
+			r.Removed = append(r.Removed, fault.String())
+			removed = i
+			break
+		}
+
+		if removed >= 0 {
+			candidates = candidates[:0]
+			for _, fault := range redundant[removed+1:] {
+				candidates = append(candidates, fault.String())
+			}
+			full = false
+			continue
+		}
+		if full {
+			break // A full pass found nothing left to remove
+		}
+		full = true
+	}
+
+	r.Logger.Info("Redundancy removal completed in %v after %d passes", time.Since(startTime), r.Passes)
+	r.Logger.Info("Redundant faults removed: %d, kept: %d", len(r.Removed), len(r.Kept))
+	if r.Aborted > 0 {
+		r.Logger.Warning("%d faults were aborted and may hide further redundancies", r.Aborted)
+	}
+	return r.Circuit, ctx.Err()
+}
+
+// redundantFaults targets every collapsed fault of the circuit, or only the
+// named candidates that still exist, and returns those proven redundant in
+// fault list order
+func (r *RedundancyRemover) redundantFaults(ctx context.Context, candidates []string, full bool) []circuit.Fault {
+	r.Passes++
+	fan := NewFan(r.Circuit, r.Logger.Fork("fan"))
+	fan.Logger.Level = utils.WarningLevel
+	fan.Options = r.Options
+	fan.Compaction.Enabled = false
+
+	var redundant []circuit.Fault
+	if full {
+		r.Logger.Info("Pass %d: targeting every fault of %d gates", r.Passes, len(r.Circuit.Gates))
+		if _, err := fan.GenerateTestsForAllFaultsContext(ctx); err != nil {
+			return nil
+		}
+		r.Aborted = fan.Stats.AbortedFaults
+		for _, fault := range fan.FaultList.Faults {
+			if result := fan.Results[fault]; result != nil && result.Status == Redundant {
+				redundant = append(redundant, fault)
+			}
+		}
+		return redundant
+	}
+
+	r.Logger.Info("Pass %d: targeting %d faults redundant before the last removal", r.Passes, len(candidates))
+	faults := make(map[string]circuit.Fault)
+	for _, fault := range circuit.NewFaultList(r.Circuit, false).All {
+		faults[fault.String()] = fault
+	}
+	for _, name := range candidates {
+		fault, ok := faults[name]
+		if !ok {
+			continue // Removed together with the logic of an earlier fault
+		}
+		if fan.GenerateTestContext(ctx, fault).Status == Redundant {
+			redundant = append(redundant, fault)
+		}
+	}
+	return redundant
+}
+
+// removable reports whether tying a fault changes the circuit: a stem without
+// fanout that is no output feeds nothing, such as an input left unused
+func (r *RedundancyRemover) removable(fault circuit.Fault) bool {
+	if fault.Branch != nil || len(fault.Line.OutputGates) > 0 {
+		return true
+	}
+	for _, output := range r.Circuit.Outputs {
+		if output == fault.Line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package algorithm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// TestStatus classifies the outcome of test generation for a fault
+type TestStatus int
+
+const (
+	Detected  TestStatus = iota // A test was found or the fault was dropped by fault simulation
+	Redundant                   // The search space was exhausted, the fault is untestable
+	Aborted                     // The search stopped at a limit before reaching a conclusion
+)
+
+// String returns a string representation of the test status
+func (s TestStatus) String() string {
+	switch s {
+	case Detected:
+		return "Detected"
+	case Redundant:
+		return "Redundant"
+	case Aborted:
+		return "Aborted"
+	default:
+		return "Unknown"
+	}
+}
+
+// TestResult holds the outcome of test generation for a single fault
+type TestResult struct {
+	Fault  circuit.Fault
+	Status TestStatus
+	Test   map[string]circuit.LogicValue // Test vector, nil unless the fault is detected
+	Reason string                        // Why the fault is redundant or aborted
+
+	// Mismatch is set when the search claimed a test that independent
+	// verification rejects; the fault is then reported aborted
+	Mismatch error
+}
+
+// Err returns an error describing why no test was found, or nil for a detected fault
+func (r *TestResult) Err() error {
+	if r.Status == Detected {
+		return nil
+	}
+	if r.Mismatch != nil {
+		return fmt.Errorf("no test for %s: %w", r.Fault, r.Mismatch)
+	}
+	return fmt.Errorf("no test for %s (%s): %s", r.Fault, strings.ToLower(r.Status.String()), r.Reason)
+}
@@ -151,7 +151,7 @@ func (s *Sensitization) FindCriticalInputs() []InitialObjective {
 		}
 
 		for _, input := range gate.Inputs {
-			if !input.IsFaulty() && !input.IsAssigned() {
+			if !gate.IsFaultyInput(input) && !input.IsAssigned() {
 				objectives = append(objectives, InitialObjective{
 					Line:  input,
 					Value: nonControlVal,
@@ -0,0 +1,213 @@
+package algorithm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TransitionResult holds the outcome of test generation for a single transition fault
+type TransitionResult struct {
+	Fault  circuit.TransitionFault
+	Status TestStatus
+	Test   *circuit.TwoPatternTest // Two-pattern test, nil unless the fault is detected
+	Reason string                  // Why the fault is untestable or aborted
+
+	// Mismatch is set when the search claimed a test that independent
+	// verification rejects; the fault is then reported aborted
+	Mismatch error
+}
+
+// Err returns an error describing why no test was found, or nil for a detected fault
+func (r *TransitionResult) Err() error {
+	if r.Status == Detected {
+		return nil
+	}
+	if r.Mismatch != nil {
+		return fmt.Errorf("no test for %s: %w", r.Fault, r.Mismatch)
+	}
+	return fmt.Errorf("no test for %s (%s): %s", r.Fault, strings.ToLower(r.Status.String()), r.Reason)
+}
+
+// TransitionATPG generates launch-on-capture two-pattern tests for transition
+// faults. Each transition fault becomes a stuck-at fault in the second frame of
+// a two time-frame expansion, with its initial value as a constraint on the
+// first frame, and is targeted with the FAN search.
+type TransitionATPG struct {
+	Circuit *circuit.Circuit
+	Logger  *utils.Logger
+	Frames  *circuit.TimeFrames
+	Fan     *Fan // FAN instance on the expanded circuit, whose Options apply to every fault
+	Stats   Stats
+
+	// Results holds the outcome of every transition fault of the last full run
+	Results map[circuit.TransitionFault]*TransitionResult
+}
+
+// NewTransitionATPG creates a transition fault test generator for a circuit
+func NewTransitionATPG(c *circuit.Circuit, logger *utils.Logger) *TransitionATPG {
+	frames := circuit.ExpandLaunchOnCapture(c)
+	return &TransitionATPG{
+		Circuit: c,
+		Logger:  logger,
+		Frames:  frames,
+		Fan:     NewFan(frames.Circuit, logger),
+	}
+}
+
+// GenerateTest runs test generation for a transition fault
+func (t *TransitionATPG) GenerateTest(fault circuit.TransitionFault) *TransitionResult {
+	return t.GenerateTestContext(context.Background(), fault)
+}
+
+// GenerateTestContext is GenerateTest with a context
+func (t *TransitionATPG) GenerateTestContext(ctx context.Context, fault circuit.TransitionFault) *TransitionResult {
+	result, _ := t.generate(ctx, fault)
+	return result
+}
+
+// generate targets a transition fault and also returns the test of the expanded circuit
+func (t *TransitionATPG) generate(ctx context.Context, fault circuit.TransitionFault) (*TransitionResult, map[string]circuit.LogicValue) {
+	target, initial := t.Frames.Target(fault)
+	t.Fan.Circuit.Constraints = []circuit.Constraint{initial}
+	defer func() { t.Fan.Circuit.Constraints = nil }()
+
+	t.Logger.Info("Targeting %s as %s with %s = %v", fault, target, initial.Line.Name, initial.Value)
+	stuck := t.Fan.GenerateTestContext(ctx, target)
+
+	result := &TransitionResult{Fault: fault, Status: stuck.Status, Reason: stuck.Reason, Mismatch: stuck.Mismatch}
+	if stuck.Status == Detected {
+		test := t.Frames.TwoPatternTest(stuck.Test)
+		result.Test = &test
+	}
+	return result, stuck.Test
+}
+
+// GenerateTestsForAllFaults generates two-pattern tests for every transition fault.
+// Every new test is fault-simulated against the remaining faults, and the faults
+// it detects are dropped.
+func (t *TransitionATPG) GenerateTestsForAllFaults() ([]circuit.TwoPatternTest, error) {
+	return t.GenerateTestsForAllFaultsContext(context.Background())
+}
+
+// GenerateTestsForAllFaultsContext is GenerateTestsForAllFaults with a context.
+// When the context is done the faults not yet targeted are reported as aborted
+// and the tests found so far are returned with ctx.Err().
+func (t *TransitionATPG) GenerateTestsForAllFaultsContext(ctx context.Context) ([]circuit.TwoPatternTest, error) {
+	startTime := time.Now()
+	faults := circuit.NewTransitionFaults(t.Circuit)
+	t.Logger.Info("Starting test generation for %d transition faults", len(faults))
+
+	tests := make([]circuit.TwoPatternTest, 0)
+	t.Results = make(map[circuit.TransitionFault]*TransitionResult)
+	t.Stats = Stats{}
+	remaining := faults
+	var mismatches []error
+
+	for len(remaining) > 0 {
+		if ctx.Err() != nil {
+			t.Logger.Warning("Test generation cancelled with %d faults left", len(remaining))
+			for _, fault := range remaining {
+				t.Results[fault] = &TransitionResult{Fault: fault, Status: Aborted, Reason: ctx.Err().Error()}
+			}
+			t.Stats.AbortedFaults += len(remaining)
+			break
+		}
+
+		fault := remaining[0]
+		remaining = remaining[1:]
+		result, expanded := t.generate(ctx, fault)
+		t.Results[fault] = result
+		if result.Mismatch != nil {
+			mismatches = append(mismatches, result.Mismatch)
+		}
+		switch result.Status {
+		case Redundant:
+			t.Stats.RedundantFaults++
+			continue
+		case Aborted:
+			t.Stats.AbortedFaults++
+			continue
+		}
+
+		// Only count the test once fault simulation confirms it detects the target
+		patterns := []map[string]circuit.LogicValue{expanded}
+		if t.detect(patterns, []circuit.TransitionFault{fault})[0] != 0 {
+			t.Logger.Warning("Test %v for %s is not confirmed by fault simulation, discarding it", *result.Test, fault)
+			result.Status = Aborted
+			result.Test = nil
+			result.Reason = "test not confirmed by fault simulation"
+			t.Stats.AbortedFaults++
+			continue
+		}
+		t.Stats.TestsFound++
+		tests = append(tests, *result.Test)
+
+		// Drop every remaining fault the new test also detects
+		kept := remaining[:0]
+		for i, first := range t.detect(patterns, remaining) {
+			if first < 0 {
+				kept = append(kept, remaining[i])
+				continue
+			}
+			t.Results[remaining[i]] = &TransitionResult{Fault: remaining[i], Status: Detected, Test: result.Test}
+			t.Stats.DroppedFaults++
+		}
+		remaining = kept
+	}
+
+	t.Stats.UndetectedFaults = t.Stats.RedundantFaults + t.Stats.AbortedFaults
+	t.Stats.TotalTime = time.Since(startTime)
+	t.Logger.Info("Transition test generation completed for %d faults", len(faults))
+	t.Logger.Info("Tests found: %d", t.Stats.TestsFound)
+	t.Logger.Info("Faults dropped by fault simulation: %d", t.Stats.DroppedFaults)
+	t.Logger.Info("Undetected faults: %d (%d untestable, %d aborted)",
+		t.Stats.UndetectedFaults, t.Stats.RedundantFaults, t.Stats.AbortedFaults)
+
+	return tests, errors.Join(verificationError(mismatches), ctx.Err())
+}
+
+// VerifyTests checks with the independent verifier, on the expanded circuit, that
+// every transition fault of the last full run reported as detected is detected
+// by one of the tests. It returns an error naming the faults none of them detects.
+func (t *TransitionATPG) VerifyTests(tests []circuit.TwoPatternTest) error {
+	expanded := make([]map[string]circuit.LogicValue, len(tests))
+	for i, test := range tests {
+		expanded[i] = t.Frames.ExpandedTest(test)
+	}
+
+	var missed []string
+	for _, fault := range circuit.NewTransitionFaults(t.Circuit) {
+		if result := t.Results[fault]; result == nil || result.Status != Detected {
+			continue
+		}
+		target, initial := t.Frames.Target(fault)
+		detected := false
+		for _, test := range expanded {
+			if t.Fan.Verifier.Detects(test, target, initial) {
+				detected = true
+				break
+			}
+		}
+		if !detected {
+			missed = append(missed, fault.String())
+		}
+	}
+	return missedError(missed, "transition faults")
+}
+
+// detect fault-simulates tests of the expanded circuit against transition faults
+// and returns, for each fault, the index of the first test that detects it, or -1
+func (t *TransitionATPG) detect(patterns []map[string]circuit.LogicValue, faults []circuit.TransitionFault) []int {
+	targets := make([]circuit.Fault, len(faults))
+	constraints := make([]circuit.Constraint, len(faults))
+	for i, fault := range faults {
+		targets[i], constraints[i] = t.Frames.Target(fault)
+	}
+	return t.Fan.Simulator.DetectConstrained(patterns, targets, constraints)
+}
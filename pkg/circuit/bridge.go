@@ -0,0 +1,117 @@
+package circuit
+
+import "fmt"
+
+// BridgeModel describes how two shorted lines resolve their values
+type BridgeModel int
+
+const (
+	WiredAND BridgeModel = iota // Both lines take the AND of their values
+	WiredOR                     // Both lines take the OR of their values
+	Dominant                    // The victim takes the value of the aggressor
+)
+
+// String returns a string representation of the bridge model
+func (m BridgeModel) String() string {
+	switch m {
+	case WiredAND:
+		return "wand"
+	case WiredOR:
+		return "wor"
+	case Dominant:
+		return "dom"
+	default:
+		return "?"
+	}
+}
+
+// ParseBridgeModel returns the bridge model with the given name
+func ParseBridgeModel(name string) (BridgeModel, error) {
+	for _, m := range []BridgeModel{WiredAND, WiredOR, Dominant} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return WiredAND, fmt.Errorf("unknown bridge model %q (expected wand, wor or dom)", name)
+}
+
+// Bridge is a bridging fault between two lines. For a dominant bridge A is the
+// aggressor and B the victim.
+type Bridge struct {
+	A, B  *Line
+	Model BridgeModel
+}
+
+// String returns the bridge in "model(a, b)" notation
+func (b Bridge) String() string {
+	return fmt.Sprintf("%v(%s, %s)", b.Model, b.A.Name, b.B.Name)
+}
+
+// BridgeTarget is one way of exciting a bridge: the line whose value is
+// overridden behaves as stuck at the other value while the other line holds it
+type BridgeTarget struct {
+	Fault     Fault
+	Condition Constraint
+}
+
+// Targets returns the stuck-at faults, each with the value the other line must
+// hold, whose tests are exactly the tests of the bridge. A wired-AND bridge is
+// detected when the line at 1 is pulled to 0 by the other line at 0 and
+// propagates its error, a wired-OR bridge likewise with the values swapped, and
+// a dominant bridge when the victim differs from the aggressor.
+func (b Bridge) Targets() []BridgeTarget {
+	switch b.Model {
+	case WiredAND:
+		return []BridgeTarget{
+			{Fault{Line: b.A, Type: Zero}, Constraint{Line: b.B, Value: Zero}},
+			{Fault{Line: b.B, Type: Zero}, Constraint{Line: b.A, Value: Zero}},
+		}
+	case WiredOR:
+		return []BridgeTarget{
+			{Fault{Line: b.A, Type: One}, Constraint{Line: b.B, Value: One}},
+			{Fault{Line: b.B, Type: One}, Constraint{Line: b.A, Value: One}},
+		}
+	default:
+		return []BridgeTarget{
+			{Fault{Line: b.B, Type: Zero}, Constraint{Line: b.A, Value: Zero}},
+			{Fault{Line: b.B, Type: One}, Constraint{Line: b.A, Value: One}},
+		}
+	}
+}
+
+// ValidateBridge checks that a bridge joins two different lines of the circuit
+// and is not a feedback bridge. The reduction to stuck-at faults relies on the
+// error on one line never reaching the other.
+func (c *Circuit) ValidateBridge(b Bridge) error {
+	if b.A == nil || b.B == nil || c.Lines[b.A.ID] != b.A || c.Lines[b.B.ID] != b.B {
+		return fmt.Errorf("bridge %v joins lines outside the circuit", b)
+	}
+	if b.A == b.B {
+		return fmt.Errorf("bridge %v joins a line to itself", b)
+	}
+	if reaches(b.A, b.B) || reaches(b.B, b.A) {
+		return fmt.Errorf("bridge %v is a feedback bridge: one line is in the fanout cone of the other", b)
+	}
+	return nil
+}
+
+// reaches reports whether to lies in the fanout cone of from
+func reaches(from, to *Line) bool {
+	visited := make(map[*Line]bool)
+	stack := []*Line{from}
+	for len(stack) > 0 {
+		line := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if line == to {
+			return true
+		}
+		if visited[line] {
+			continue
+		}
+		visited[line] = true
+		for _, gate := range line.OutputGates {
+			stack = append(stack, gate.Output)
+		}
+	}
+	return false
+}
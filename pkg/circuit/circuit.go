@@ -7,16 +7,31 @@ import (
 
 // Circuit represents a digital circuit consisting of gates and lines
 type Circuit struct {
-	Name      string
-	Gates     map[int]*Gate
-	Lines     map[int]*Line
-	Inputs    []*Line
-	Outputs   []*Line
-	FaultSite *Line
-	FaultType LogicValue // Stuck-at-0 (Zero) or stuck-at-1 (One)
-	DFrontier []*Gate
-	JFrontier []*Gate // Justification frontier
-	HeadLines []*Line // Cached list of head lines
+	Name        string
+	Gates       map[int]*Gate
+	Lines       map[int]*Line
+	Inputs      []*Line     // Primary inputs, followed by the Q lines of scan cells
+	Outputs     []*Line     // Primary outputs, followed by the D lines of scan cells
+	ScanCells   []*ScanCell // Flip-flops of a full-scan design
+	FaultSite   *Line
+	FaultType   LogicValue // Stuck-at-0 (Zero) or stuck-at-1 (One)
+	FaultBranch *Gate      // Branch gate of a fanout-branch fault, nil for a stem fault
+	DFrontier   []*Gate
+	JFrontier   []*Gate      // Justification frontier
+	HeadLines   []*Line      // Cached list of head lines
+	Constraints []Constraint // Good values every test must justify as well, kept by Reset
+}
+
+// Constraint requires a line to hold a good-machine value, such as the initial
+// value of a transition fault in the first time frame
+type Constraint struct {
+	Line  *Line
+	Value LogicValue // Zero or One
+}
+
+// Holds reports whether the current good value of the line meets the constraint
+func (cons Constraint) Holds() bool {
+	return cons.Line.GetGoodValue() == cons.Value
 }
 
 // NewCircuit creates a new circuit with the given name
@@ -67,28 +82,53 @@ func (c *Circuit) Reset() {
 	}
 	c.FaultSite = nil
 	c.FaultType = X
+	c.FaultBranch = nil
 	c.DFrontier = make([]*Gate, 0)
 	c.JFrontier = make([]*Gate, 0)
 }
 
-// InjectFault injects a fault into the circuit
+// InjectFault injects a stem fault into the circuit
 func (c *Circuit) InjectFault(faultSite *Line, faultType LogicValue) {
+	c.injectFault(faultSite, nil, faultType)
+}
+
+// InjectBranchFault injects a fault on a single fanout branch of a stem line.
+// Only the connection from the stem to the branch gate is faulty.
+func (c *Circuit) InjectBranchFault(stem *Line, branch *Gate, faultType LogicValue) {
+	c.injectFault(stem, branch, faultType)
+}
+
+// InjectFaultObject injects a stem or fanout-branch fault
+func (c *Circuit) InjectFaultObject(f Fault) {
+	c.injectFault(f.Line, f.Branch, f.Type)
+}
+
+// injectFault records the fault on the circuit and on its site line.
+// A nil branch injects a stem fault.
+func (c *Circuit) injectFault(faultSite *Line, branch *Gate, faultType LogicValue) {
 	c.FaultSite = faultSite
 	c.FaultType = faultType
+	c.FaultBranch = branch
 
 	// Update the line's fault information
 	faultSite.IsFaultSite = true
 	faultSite.FaultType = faultType
+	faultSite.FaultBranch = branch
 
 	// If the fault site already has a value, adjust it
 	if faultSite.Value != X {
 		// Re-apply the current value which will convert to D/D' if needed
-		currentValue := faultSite.Value
+		currentValue := faultSite.GetGoodValue()
 		faultSite.Value = X // Reset to avoid double counting assignments
 		faultSite.SetValue(currentValue)
 	}
 }
 
+// CurrentFault returns the fault currently injected into the circuit
+func (c *Circuit) CurrentFault() Fault {
+	return Fault{Line: c.FaultSite, Type: c.FaultType, Branch: c.FaultBranch}
+}
+
 // SimulateForward performs forward simulation from a specific starting point
 func (c *Circuit) SimulateForward() bool {
 	changed := false
@@ -98,7 +138,8 @@ func (c *Circuit) SimulateForward() bool {
 		if gate.Output.Value == X {
 			newValue := gate.Evaluate()
 			if newValue != X {
-				gate.Output.Value = newValue
+				// SetValue turns a value opposite to the fault type into D or D' at the fault site
+				gate.Output.SetValue(newValue)
 				changed = true
 
 				// Update D-frontier
@@ -184,6 +225,23 @@ func (c *Circuit) SimulateBackward() bool {
 				}
 
 				// XOR and XNOR would need special handling for backward implication
+
+			default:
+				if !gate.Type.HasTruthTable() {
+					continue
+				}
+
+				// The inputs on which every cube of the output value agrees
+				implied, ok := gate.ImpliedInputs(gate.Output.GetGoodValue())
+				if !ok {
+					continue
+				}
+				for i, input := range gate.Inputs {
+					if implied[i] != X {
+						input.Value = implied[i]
+						changed = true
+					}
+				}
 			}
 		}
 	}
@@ -253,6 +311,12 @@ func (c *Circuit) addToDFrontier(gate *Gate) {
 
 // CheckTestStatus checks if current assignments constitute a test
 func (c *Circuit) CheckTestStatus() bool {
+	for _, cons := range c.Constraints {
+		if !cons.Holds() {
+			return false
+		}
+	}
+
 	// A test is found when at least one primary output has D or D'
 	for _, output := range c.Outputs {
 		if output.IsFaulty() {
@@ -260,17 +324,8 @@ func (c *Circuit) CheckTestStatus() bool {
 		}
 	}
 
-	// Added debugging - are there any faulty signals in the circuit?
-	hasFaultySignal := false
-	for _, line := range c.Lines {
-		if line.IsFaulty() {
-			hasFaultySignal = true
-			break
-		}
-	}
-
-	// If there are faulty signals but none at outputs, fault hasn't propagated yet
-	return hasFaultySignal
+	// Faulty signals that have not reached a primary output do not make a test
+	return false
 }
 
 // AnalyzeTopology analyzes the circuit topology to identify free, bound, and head lines
@@ -334,7 +389,8 @@ func (c *Circuit) markReachableLines(startLine *Line) {
 func (c *Circuit) GetCurrentTest() map[string]LogicValue {
 	test := make(map[string]LogicValue)
 	for _, input := range c.Inputs {
-		test[input.Name] = input.Value
+		// A faulty primary input is applied with its good value
+		test[input.Name] = input.GetGoodValue()
 	}
 	return test
 }
@@ -357,10 +413,14 @@ func (c *Circuit) String() string {
 
 	builder.WriteString("\nFault: ")
 	if c.FaultSite != nil {
+		site := c.FaultSite.Name
+		if c.FaultBranch != nil {
+			site = fmt.Sprintf("%s->%s", site, c.FaultBranch.Name)
+		}
 		if c.FaultType == Zero {
-			builder.WriteString(fmt.Sprintf("%s stuck-at-0", c.FaultSite.Name))
+			builder.WriteString(fmt.Sprintf("%s stuck-at-0", site))
 		} else {
-			builder.WriteString(fmt.Sprintf("%s stuck-at-1", c.FaultSite.Name))
+			builder.WriteString(fmt.Sprintf("%s stuck-at-1", site))
 		}
 	} else {
 		builder.WriteString("None")
@@ -0,0 +1,94 @@
+package circuit
+
+import "sort"
+
+// Clone returns a deep copy of the circuit structure. Line values and fault
+// information are reset, topology flags are kept. The copy shares no state
+// with the original, so both can be used by different goroutines.
+func (c *Circuit) Clone() *Circuit {
+	clone := NewCircuit(c.Name)
+	lines := make(map[*Line]*Line, len(c.Lines))
+	gates := make(map[*Gate]*Gate, len(c.Gates))
+
+	for id, line := range c.Lines {
+		l := NewLine(line.ID, line.Name, line.Type)
+		l.IsFree = line.IsFree
+		l.IsBound = line.IsBound
+		l.IsHeadLine = line.IsHeadLine
+		clone.Lines[id] = l
+		lines[line] = l
+	}
+	for id, gate := range c.Gates {
+		g := NewGate(gate.ID, gate.Name, gate.Type)
+		g.Table = gate.Table
+		g.ControlID = gate.ControlID
+		clone.Gates[id] = g
+		gates[gate] = g
+	}
+
+	// Connect the copies in the original order of inputs and fanouts
+	for line, l := range lines {
+		if line.InputGate != nil {
+			l.InputGate = gates[line.InputGate]
+		}
+		for _, gate := range line.OutputGates {
+			l.OutputGates = append(l.OutputGates, gates[gate])
+		}
+	}
+	for gate, g := range gates {
+		for _, input := range gate.Inputs {
+			g.Inputs = append(g.Inputs, lines[input])
+		}
+		if gate.Output != nil {
+			g.Output = lines[gate.Output]
+		}
+	}
+
+	for _, input := range c.Inputs {
+		clone.Inputs = append(clone.Inputs, lines[input])
+	}
+	for _, output := range c.Outputs {
+		clone.Outputs = append(clone.Outputs, lines[output])
+	}
+	for _, cell := range c.ScanCells {
+		clone.ScanCells = append(clone.ScanCells, &ScanCell{Name: cell.Name, Q: lines[cell.Q], D: lines[cell.D], observed: cell.observed})
+	}
+	for _, cons := range c.Constraints {
+		clone.Constraints = append(clone.Constraints, Constraint{Line: lines[cons.Line], Value: cons.Value})
+	}
+	for _, head := range c.HeadLines {
+		clone.HeadLines = append(clone.HeadLines, lines[head])
+	}
+
+	return clone
+}
+
+// TranslateFault returns a fault of another copy of this circuit, made by Clone,
+// in terms of the lines and gates of this circuit
+func (c *Circuit) TranslateFault(f Fault) Fault {
+	clone := Fault{Line: c.Lines[f.Line.ID], Type: f.Type}
+	if f.Branch != nil {
+		clone.Branch = c.Gates[f.Branch.ID]
+	}
+	return clone
+}
+
+// SortedGates returns the gates ordered by ID, for a deterministic traversal
+func (c *Circuit) SortedGates() []*Gate {
+	gates := make([]*Gate, 0, len(c.Gates))
+	for _, gate := range c.Gates {
+		gates = append(gates, gate)
+	}
+	sort.Slice(gates, func(i, j int) bool { return gates[i].ID < gates[j].ID })
+	return gates
+}
+
+// SortedLines returns the lines ordered by ID, for a deterministic traversal
+func (c *Circuit) SortedLines() []*Line {
+	lines := make([]*Line, 0, len(c.Lines))
+	for _, line := range c.Lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+	return lines
+}
@@ -0,0 +1,393 @@
+package circuit
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Fault represents a single stuck-at fault on a stem or on one fanout branch
+type Fault struct {
+	Line   *Line      // Faulty line (the stem for a branch fault)
+	Type   LogicValue // Stuck-at value (Zero or One)
+	Branch *Gate      // Gate fed by the faulty fanout branch, nil for a stem fault
+}
+
+// IsBranch reports whether the fault sits on a single fanout branch
+func (f Fault) IsBranch() bool {
+	return f.Branch != nil
+}
+
+// String returns the fault in "name/value" or "stem->gate/value" notation
+func (f Fault) String() string {
+	if f.Branch != nil {
+		return fmt.Sprintf("%s->%s/%v", f.Line.Name, f.Branch.Name, f.Type)
+	}
+	return fmt.Sprintf("%s/%v", f.Line.Name, f.Type)
+}
+
+// FaultList holds the fault universe of a circuit together with its collapsed form
+type FaultList struct {
+	Circuit *Circuit
+	All     []Fault // Every stuck-at fault of the circuit
+	Faults  []Fault // Collapsed list of representative faults to target
+
+	// Representative maps every fault in All to the fault in Faults whose test also detects it
+	Representative map[Fault]Fault
+
+	EquivalentCollapsed int // Faults merged into an equivalent representative
+	DominanceCollapsed  int // Equivalence classes removed by dominance collapsing
+}
+
+// NewFaultList builds the fault list of a circuit, collapsing structurally
+// equivalent faults and, if requested, faults that dominate other faults
+func NewFaultList(c *Circuit, dominance bool) *FaultList {
+	fl := &FaultList{
+		Circuit:        c,
+		Representative: make(map[Fault]Fault),
+	}
+
+	lines := make([]*Line, 0, len(c.Lines))
+	for _, line := range c.Lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+
+	index := make(map[Fault]int)
+	for _, line := range lines {
+		for _, faultType := range []LogicValue{Zero, One} {
+			f := Fault{Line: line, Type: faultType}
+			index[f] = len(fl.All)
+			fl.All = append(fl.All, f)
+		}
+
+		// A stem with several destinations gets a separate fault site per branch
+		if !isFanoutFree(line) && fanoutCount(line) > 1 {
+			for _, gate := range sortedBranches(line) {
+				for _, faultType := range []LogicValue{Zero, One} {
+					f := Fault{Line: line, Type: faultType, Branch: gate}
+					index[f] = len(fl.All)
+					fl.All = append(fl.All, f)
+				}
+			}
+		}
+	}
+
+	topo := NewTopology(c)
+	topo.ComputeLevels()
+
+	classes := newFaultClasses(fl.All, index, topo.LevelMap)
+	classes.collapseEquivalent(c)
+	fl.EquivalentCollapsed = len(fl.All) - classes.count()
+
+	dropped := make(map[int]int) // Class root -> class root that dominates-collapses it
+	if dominance {
+		dropped = classes.collapseDominance(c)
+		fl.DominanceCollapsed = len(dropped)
+	}
+
+	// Keep one representative per surviving class, in fault universe order
+	for i, f := range fl.All {
+		root := classes.find(i)
+		if _, isDropped := dropped[root]; isDropped {
+			continue
+		}
+		if classes.rep[root] == i {
+			fl.Faults = append(fl.Faults, f)
+		}
+	}
+
+	for i, f := range fl.All {
+		root := classes.find(i)
+		for {
+			target, isDropped := dropped[root]
+			if !isDropped {
+				break
+			}
+			root = classes.find(target)
+		}
+		fl.Representative[f] = fl.All[classes.rep[root]]
+	}
+
+	return fl
+}
+
+// Size returns the number of faults in the collapsed list
+func (fl *FaultList) Size() int {
+	return len(fl.Faults)
+}
+
+// CollapseRatio returns the collapsed fault count relative to the full universe
+func (fl *FaultList) CollapseRatio() float64 {
+	if len(fl.All) == 0 {
+		return 0
+	}
+	return float64(len(fl.Faults)) / float64(len(fl.All))
+}
+
+// RepresentativeOf returns the targeted fault that covers the given fault
+func (fl *FaultList) RepresentativeOf(f Fault) Fault {
+	if rep, ok := fl.Representative[f]; ok {
+		return rep
+	}
+	return f
+}
+
+// Members returns every fault of the universe covered by the given representative
+func (fl *FaultList) Members(rep Fault) []Fault {
+	members := make([]Fault, 0)
+	for _, f := range fl.All {
+		if fl.Representative[f] == rep {
+			members = append(members, f)
+		}
+	}
+	return members
+}
+
+// faultClasses is a union-find structure over the fault universe
+type faultClasses struct {
+	faults []Fault
+	index  map[Fault]int
+	level  map[*Line]int
+	parent []int
+	rep    []int // Representative fault of each root
+	size   []int // Class size of each root
+}
+
+// newFaultClasses creates one singleton class per fault
+func newFaultClasses(faults []Fault, index map[Fault]int, level map[*Line]int) *faultClasses {
+	fc := &faultClasses{
+		faults: faults,
+		index:  index,
+		level:  level,
+		parent: make([]int, len(faults)),
+		rep:    make([]int, len(faults)),
+		size:   make([]int, len(faults)),
+	}
+	for i := range faults {
+		fc.parent[i] = i
+		fc.rep[i] = i
+		fc.size[i] = 1
+	}
+	return fc
+}
+
+// find returns the root of a fault's class
+func (fc *faultClasses) find(i int) int {
+	for fc.parent[i] != i {
+		fc.parent[i] = fc.parent[fc.parent[i]]
+		i = fc.parent[i]
+	}
+	return i
+}
+
+// union merges the classes of two faults, keeping the representative closest to the inputs
+func (fc *faultClasses) union(a, b Fault) {
+	ra, rb := fc.find(fc.index[a]), fc.find(fc.index[b])
+	if ra == rb {
+		return
+	}
+	if fc.size[ra] < fc.size[rb] {
+		ra, rb = rb, ra
+	}
+	fc.parent[rb] = ra
+	fc.size[ra] += fc.size[rb]
+	if fc.closerToInputs(fc.rep[rb], fc.rep[ra]) {
+		fc.rep[ra] = fc.rep[rb]
+	}
+}
+
+// closerToInputs orders faults by line level, then by line ID and fault type
+func (fc *faultClasses) closerToInputs(a, b int) bool {
+	fa, fb := fc.faults[a], fc.faults[b]
+	la, lb := fc.level[fa.Line], fc.level[fb.Line]
+	if la != lb {
+		return la < lb
+	}
+	if fa.Line.ID != fb.Line.ID {
+		return fa.Line.ID < fb.Line.ID
+	}
+	if fa.IsBranch() != fb.IsBranch() {
+		return !fa.IsBranch() // Prefer the stem fault
+	}
+	if fa.IsBranch() && fa.Branch.ID != fb.Branch.ID {
+		return fa.Branch.ID < fb.Branch.ID
+	}
+	return fa.Type < fb.Type
+}
+
+// count returns the number of classes
+func (fc *faultClasses) count() int {
+	n := 0
+	for i := range fc.parent {
+		if fc.find(i) == i {
+			n++
+		}
+	}
+	return n
+}
+
+// collapseEquivalent merges structurally equivalent faults. A fault on a gate input
+// is equivalent to an output fault when the input line feeds that gate alone, or
+// when the fault sits on the fanout branch into that gate. For a cell, the input
+// fault must make the output constant, as a controlling value does.
+func (fc *faultClasses) collapseEquivalent(c *Circuit) {
+	for _, gate := range sortedGates(c) {
+		if gate.Output == nil {
+			continue
+		}
+
+		for i, input := range gate.Inputs {
+			in, ok := fc.inputFault(input, gate)
+			if !ok {
+				continue
+			}
+
+			switch gate.Type {
+			case AND, NAND, OR, NOR:
+				cv := gate.GetControllingValue()
+				out := cv
+				if gate.Type == NAND || gate.Type == NOR {
+					out = invertBinary(cv)
+				}
+				fc.union(in(cv), Fault{Line: gate.Output, Type: out})
+
+			case NOT:
+				fc.union(in(Zero), Fault{Line: gate.Output, Type: One})
+				fc.union(in(One), Fault{Line: gate.Output, Type: Zero})
+
+			case BUF:
+				fc.union(in(Zero), Fault{Line: gate.Output, Type: Zero})
+				fc.union(in(One), Fault{Line: gate.Output, Type: One})
+
+			case MUX, AOI21, AOI22, OAI21, OAI22, LUT:
+				for _, v := range []LogicValue{Zero, One} {
+					if out := gate.cofactor(i, v); out != X {
+						fc.union(in(v), Fault{Line: gate.Output, Type: out})
+					}
+				}
+			}
+		}
+	}
+}
+
+// inputFault returns a constructor for the faults seen by a gate on one of its inputs:
+// the line's own faults when it is fanout-free, otherwise the faults of its branch
+func (fc *faultClasses) inputFault(input *Line, gate *Gate) (func(LogicValue) Fault, bool) {
+	if isFanoutFree(input) {
+		return func(v LogicValue) Fault { return Fault{Line: input, Type: v} }, true
+	}
+	if _, ok := fc.index[Fault{Line: input, Type: Zero, Branch: gate}]; ok {
+		return func(v LogicValue) Fault { return Fault{Line: input, Type: v, Branch: gate} }, true
+	}
+	return nil, false
+}
+
+// collapseDominance drops the classes of output faults that dominate an input fault
+// of the same gate. It returns a map from each dropped class root to the root of
+// the class whose tests also detect it.
+func (fc *faultClasses) collapseDominance(c *Circuit) map[int]int {
+	dropped := make(map[int]int)
+
+	for _, gate := range sortedGates(c) {
+		if gate.Output == nil || len(gate.Inputs) < 2 {
+			continue
+		}
+
+		var outType LogicValue
+		switch gate.Type {
+		case AND, NOR:
+			outType = One
+		case NAND, OR:
+			outType = Zero
+		default:
+			continue
+		}
+		inType := gate.GetNonControllingValue()
+
+		for _, input := range gate.Inputs {
+			in, ok := fc.inputFault(input, gate)
+			if !ok {
+				continue
+			}
+
+			outRoot := fc.find(fc.index[Fault{Line: gate.Output, Type: outType}])
+			inRoot := fc.find(fc.index[in(inType)])
+			if _, done := dropped[outRoot]; done || outRoot == inRoot {
+				break
+			}
+
+			// Avoid cycles: the dominated class must not resolve back to the dropped one
+			target := inRoot
+			cycle := false
+			for {
+				next, isDropped := dropped[target]
+				if !isDropped {
+					break
+				}
+				if next == outRoot {
+					cycle = true
+					break
+				}
+				target = next
+			}
+			if cycle || target == outRoot {
+				continue
+			}
+
+			dropped[outRoot] = inRoot
+			break
+		}
+	}
+
+	return dropped
+}
+
+// isFanoutFree reports whether a line feeds exactly one gate and is not observed directly
+func isFanoutFree(line *Line) bool {
+	return len(line.OutputGates) == 1 && line.Type != PrimaryOutput
+}
+
+// fanoutCount returns the number of destinations of a line, counting a primary output as one
+func fanoutCount(line *Line) int {
+	n := len(line.OutputGates)
+	if line.Type == PrimaryOutput {
+		n++
+	}
+	return n
+}
+
+// sortedBranches returns the distinct gates fed by a line, ordered by ID
+func sortedBranches(line *Line) []*Gate {
+	seen := make(map[*Gate]bool)
+	gates := make([]*Gate, 0, len(line.OutputGates))
+	for _, gate := range line.OutputGates {
+		if !seen[gate] {
+			seen[gate] = true
+			gates = append(gates, gate)
+		}
+	}
+	sort.Slice(gates, func(i, j int) bool { return gates[i].ID < gates[j].ID })
+	return gates
+}
+
+// sortedGates returns the gates of a circuit ordered by ID
+func sortedGates(c *Circuit) []*Gate {
+	gates := make([]*Gate, 0, len(c.Gates))
+	for _, gate := range c.Gates {
+		gates = append(gates, gate)
+	}
+	sort.Slice(gates, func(i, j int) bool { return gates[i].ID < gates[j].ID })
+	return gates
+}
+
+// invertBinary returns the opposite of a binary logic value
+func invertBinary(v LogicValue) LogicValue {
+	switch v {
+	case Zero:
+		return One
+	case One:
+		return Zero
+	default:
+		return v
+	}
+}
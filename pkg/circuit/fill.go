@@ -0,0 +1,127 @@
+package circuit
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// FillPolicy selects the values given to the inputs a test leaves unassigned
+type FillPolicy int
+
+const (
+	FillNone     FillPolicy = iota // Unassigned inputs stay X
+	FillZero                       // Unassigned inputs are set to 0
+	FillOne                        // Unassigned inputs are set to 1
+	FillRandom                     // Unassigned inputs get seeded random values
+	FillAdjacent                   // Unassigned inputs repeat the nearest assigned input before them
+)
+
+// String returns the name of the fill policy
+func (p FillPolicy) String() string {
+	switch p {
+	case FillNone:
+		return "none"
+	case FillZero:
+		return "zero"
+	case FillOne:
+		return "one"
+	case FillRandom:
+		return "random"
+	case FillAdjacent:
+		return "adjacent"
+	default:
+		return "?"
+	}
+}
+
+// ParseFillPolicy returns the fill policy with the given name
+func ParseFillPolicy(name string) (FillPolicy, error) {
+	for _, p := range []FillPolicy{FillNone, FillZero, FillOne, FillRandom, FillAdjacent} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return FillNone, fmt.Errorf("unknown fill policy %q (expected none, zero, one, random or adjacent)", name)
+}
+
+// FillTests returns copies of the tests with every unassigned input of the
+// circuit filled according to the policy. Random fill draws from a generator
+// seeded with seed, so the same seed gives the same values. Adjacent fill, also
+// known as minimum-transition fill, follows the order of c.Inputs, which puts
+// the scan cells in chain order after the primary inputs: an unassigned input
+// repeats the nearest assigned input before it, and leading unassigned inputs
+// the first assigned one, so that few transitions are shifted through the chain.
+func (c *Circuit) FillTests(tests []map[string]LogicValue, policy FillPolicy, seed int64) []map[string]LogicValue {
+	rng := rand.New(rand.NewSource(seed))
+	filled := make([]map[string]LogicValue, len(tests))
+	for i, test := range tests {
+		filled[i] = fillVector(c.Inputs, test, nil, policy, rng)
+	}
+	return filled
+}
+
+// FillTwoPatternTests is FillTests for two-pattern tests. V1 is filled like a
+// single test; with adjacent fill an unassigned input of V2 keeps its value of
+// V1, so that only the transitions the test needs are launched.
+func (c *Circuit) FillTwoPatternTests(tests []TwoPatternTest, policy FillPolicy, seed int64) []TwoPatternTest {
+	rng := rand.New(rand.NewSource(seed))
+	filled := make([]TwoPatternTest, len(tests))
+	for i, test := range tests {
+		v1 := fillVector(c.Inputs, test.V1, nil, policy, rng)
+		filled[i] = TwoPatternTest{V1: v1, V2: fillVector(c.PrimaryInputs(), test.V2, v1, policy, rng)}
+	}
+	return filled
+}
+
+// fillVector fills the unassigned inputs of one vector. previous, if not nil,
+// holds the values adjacent fill repeats for each input.
+func fillVector(inputs []*Line, test, previous map[string]LogicValue, policy FillPolicy, rng *rand.Rand) map[string]LogicValue {
+	filled := make(map[string]LogicValue, len(test))
+	for name, value := range test {
+		filled[name] = value
+	}
+
+	last := X
+	if policy == FillAdjacent {
+		// Leading unassigned inputs take the first assigned value
+		for _, input := range inputs {
+			if value := test[input.Name]; value == Zero || value == One {
+				last = value
+				break
+			}
+		}
+	}
+
+	for _, input := range inputs {
+		value := test[input.Name]
+		if value == Zero || value == One {
+			last = value
+			continue
+		}
+
+		switch policy {
+		case FillNone:
+			continue
+		case FillZero:
+			value = Zero
+		case FillOne:
+			value = One
+		case FillRandom:
+			value = Zero
+			if rng.Intn(2) == 1 {
+				value = One
+			}
+		case FillAdjacent:
+			switch {
+			case previous != nil:
+				value = previous[input.Name]
+			case last != X:
+				value = last
+			default:
+				value = Zero // No input is assigned
+			}
+		}
+		filled[input.Name] = value
+	}
+	return filled
+}
@@ -14,6 +14,16 @@ const (
 	XOR
 	XNOR
 	BUF // Buffer gate
+
+	// Cells, whose function is the truth table of the gate
+	MUX   // 2:1 multiplexer MUX(d0, d1, s), d1 if s is 1 and d0 otherwise
+	TIE0  // Constant 0, without inputs
+	TIE1  // Constant 1, without inputs
+	AOI21 // AND-OR-invert NOT(a&b | c)
+	AOI22 // AND-OR-invert NOT(a&b | c&d)
+	OAI21 // OR-AND-invert NOT((a|b) & c)
+	OAI22 // OR-AND-invert NOT((a|b) & (c|d))
+	LUT   // Look-up table of up to MaxTableInputs inputs, given by its Table
 )
 
 // String returns a string representation of the gate type
@@ -35,6 +45,22 @@ func (gt GateType) String() string {
 		return "XNOR"
 	case BUF:
 		return "BUF"
+	case MUX:
+		return "MUX"
+	case TIE0:
+		return "TIE0"
+	case TIE1:
+		return "TIE1"
+	case AOI21:
+		return "AOI21"
+	case AOI22:
+		return "AOI22"
+	case OAI21:
+		return "OAI21"
+	case OAI22:
+		return "OAI22"
+	case LUT:
+		return "LUT"
 	default:
 		return "UNKNOWN"
 	}
@@ -47,6 +73,7 @@ type Gate struct {
 	Type          GateType // Type of the gate
 	Inputs        []*Line  // Input lines
 	Output        *Line    // Output line
+	Table         uint64   // Truth table of a cell, see HasTruthTable
 	ControlID     int      // ID of the easiest-to-control input (cached for efficiency)
 	IsInDFrontier bool     // Whether the gate is in D-frontier
 }
@@ -57,6 +84,7 @@ func NewGate(id int, name string, gateType GateType) *Gate {
 		ID:            id,
 		Name:          name,
 		Type:          gateType,
+		Table:         gateType.truthTable(),
 		Inputs:        make([]*Line, 0),
 		IsInDFrontier: false,
 	}
@@ -99,6 +127,9 @@ func (g *Gate) Evaluate() LogicValue {
 	case BUF:
 		return g.evaluateBUF()
 	default:
+		if g.Type.HasTruthTable() {
+			return g.evaluateTable()
+		}
 		return X
 	}
 }
@@ -106,19 +137,22 @@ func (g *Gate) Evaluate() LogicValue {
 func (g *Gate) evaluateAND() LogicValue {
 	result := One
 	hasDorDnot := false
+	hasD, hasDnot := false, false
 	dType := X
 
 	for _, input := range g.Inputs {
-		switch input.Value {
+		switch g.InputValue(input) {
 		case Zero:
 			return Zero // Short-circuit for AND gate
 		case X:
 			result = X
 		case D:
 			hasDorDnot = true
+			hasD = true
 			dType = D
 		case Dnot:
 			hasDorDnot = true
+			hasDnot = true
 			dType = Dnot
 		}
 	}
@@ -127,6 +161,11 @@ func (g *Gate) evaluateAND() LogicValue {
 		return Zero
 	}
 
+	// D and D' together produce the controlling value in both machines
+	if hasD && hasDnot {
+		return Zero
+	}
+
 	if hasDorDnot && result != X {
 		return dType
 	}
@@ -137,19 +176,22 @@ func (g *Gate) evaluateAND() LogicValue {
 func (g *Gate) evaluateOR() LogicValue {
 	result := Zero
 	hasDorDnot := false
+	hasD, hasDnot := false, false
 	dType := X
 
 	for _, input := range g.Inputs {
-		switch input.Value {
+		switch g.InputValue(input) {
 		case One:
 			return One // Short-circuit for OR gate
 		case X:
 			result = X
 		case D:
 			hasDorDnot = true
+			hasD = true
 			dType = D
 		case Dnot:
 			hasDorDnot = true
+			hasDnot = true
 			dType = Dnot
 		}
 	}
@@ -158,6 +200,11 @@ func (g *Gate) evaluateOR() LogicValue {
 		return One
 	}
 
+	// D and D' together produce the controlling value in both machines
+	if hasD && hasDnot {
+		return One
+	}
+
 	if hasDorDnot && result != X {
 		return dType
 	}
@@ -170,7 +217,7 @@ func (g *Gate) evaluateNOT() LogicValue {
 		return X // Error case
 	}
 
-	switch g.Inputs[0].Value {
+	switch g.InputValue(g.Inputs[0]) {
 	case Zero:
 		return One
 	case One:
@@ -217,33 +264,31 @@ func (g *Gate) evaluateNOR() LogicValue {
 }
 
 func (g *Gate) evaluateXOR() LogicValue {
-	// Simplified XOR for two inputs
-	if len(g.Inputs) != 2 {
-		return X // Not handling multi-input XOR
-	}
-
-	a := g.Inputs[0].Value
-	b := g.Inputs[1].Value
-
-	if a == X || b == X {
-		return X
+	// The parity is computed separately for the good and the faulty machine
+	good, faulty := false, false
+	for _, input := range g.Inputs {
+		switch g.InputValue(input) {
+		case X:
+			return X
+		case One:
+			good, faulty = !good, !faulty
+		case D:
+			faulty = !faulty
+		case Dnot:
+			good = !good
+		}
 	}
 
-	// Handle normal cases
-	if (a == Zero && b == Zero) || (a == One && b == One) {
-		return Zero
-	}
-	if (a == Zero && b == One) || (a == One && b == Zero) {
+	switch {
+	case good && faulty:
 		return One
+	case good:
+		return Dnot // Good value 1, faulty value 0
+	case faulty:
+		return D // Good value 0, faulty value 1
+	default:
+		return Zero
 	}
-
-	// Handle fault cases (simplified)
-	if a.IsFaulty() || b.IsFaulty() {
-		// For proper handling, we'd need to check the actual good and faulty values
-		return X // Simplified - in real implementation we would calculate D or D'
-	}
-
-	return X
 }
 
 func (g *Gate) evaluateXNOR() LogicValue {
@@ -266,7 +311,33 @@ func (g *Gate) evaluateBUF() LogicValue {
 	if len(g.Inputs) != 1 {
 		return X // Error case
 	}
-	return g.Inputs[0].Value
+	return g.InputValue(g.Inputs[0])
+}
+
+// InputValue returns the value of an input line as seen by this gate. A line carrying
+// a fanout-branch fault on this gate presents its faulty value (D or D') here only,
+// while the stem and its other branches keep the good value.
+func (g *Gate) InputValue(input *Line) LogicValue {
+	if !input.IsFaultSite || input.FaultBranch != g {
+		return input.Value
+	}
+
+	switch input.Value {
+	case Zero:
+		if input.FaultType == One {
+			return D // Good value 0, faulty value 1
+		}
+	case One:
+		if input.FaultType == Zero {
+			return Dnot // Good value 1, faulty value 0
+		}
+	}
+	return input.Value
+}
+
+// IsFaultyInput returns true if the given input carries D or D' into this gate
+func (g *Gate) IsFaultyInput(input *Line) bool {
+	return g.InputValue(input).IsFaulty()
 }
 
 // IsInputsAssigned returns true if all inputs have non-X values
@@ -282,7 +353,7 @@ func (g *Gate) IsInputsAssigned() bool {
 // HasFaultyInput returns true if any input has a faulty value (D or D')
 func (g *Gate) HasFaultyInput() bool {
 	for _, input := range g.Inputs {
-		if input.IsFaulty() {
+		if g.IsFaultyInput(input) {
 			return true
 		}
 	}
@@ -323,7 +394,7 @@ func (g *Gate) IsSensitizable() bool {
 		controllingValue := g.GetControllingValue()
 
 		for _, input := range g.Inputs {
-			if input.IsFaulty() {
+			if g.IsFaultyInput(input) {
 				continue // Skip the faulty input
 			}
 
@@ -340,10 +411,14 @@ func (g *Gate) IsSensitizable() bool {
 	case NOT, BUF:
 		return true // Always sensitizable
 
+	case MUX, TIE0, TIE1, AOI21, AOI22, OAI21, OAI22, LUT:
+		// A cell is sensitized if its inputs carry the fault effect to the output
+		return g.Evaluate().IsFaulty()
+
 	case XOR, XNOR:
 		// For XOR/XNOR, all other inputs must be known
 		for _, input := range g.Inputs {
-			if !input.IsFaulty() && input.Value == X {
+			if !g.IsFaultyInput(input) && input.Value == X {
 				return false
 			}
 		}
@@ -354,15 +429,26 @@ func (g *Gate) IsSensitizable() bool {
 	}
 }
 
-// FindEasiestControlInput determines and caches the easiest-to-control input
-func (g *Gate) FindEasiestControlInput() int {
-	// Simplified implementation - could be enhanced with testability metrics
-	// For now just return the first input
-	if len(g.Inputs) > 0 {
-		g.ControlID = 0
+// FindEasiestControlInput determines and caches the input that is easiest to set
+// to the controlling value of the gate. Without a controllability measure, or
+// for gates without a controlling value, the first input is used.
+func (g *Gate) FindEasiestControlInput(controllability func(*Line, LogicValue) int) int {
+	if len(g.Inputs) == 0 {
+		return -1
+	}
+
+	g.ControlID = 0
+	controllingValue := g.GetControllingValue()
+	if controllability == nil || controllingValue == X {
 		return 0
 	}
-	return -1
+
+	for i, input := range g.Inputs {
+		if controllability(input, controllingValue) < controllability(g.Inputs[g.ControlID], controllingValue) {
+			g.ControlID = i
+		}
+	}
+	return g.ControlID
 }
 
 // IsFaulty returns true if the value is D or D'
@@ -55,6 +55,7 @@ type Line struct {
 	// Fault information
 	IsFaultSite bool       // True if this line is a fault site
 	FaultType   LogicValue // The fault type (Zero or One) if this is a fault site
+	FaultBranch *Gate      // Gate whose input alone is faulty for a fanout-branch fault, nil for a stem fault
 
 	// Topological properties - will be set during preprocessing
 	IsFree     bool // True if not reachable from any fanout point
@@ -81,8 +82,9 @@ func NewLine(id int, name string, lineType LineType) *Line {
 
 // SetValue sets the logic value of the line
 func (l *Line) SetValue(value LogicValue) {
-	// Check if this is a fault site
-	if l.IsFaultSite {
+	// Check if this is a stem fault site; a branch fault leaves the stem value good
+	// and is applied by the branch gate when it reads this line
+	if l.IsFaultSite && l.FaultBranch == nil {
 		if value != X && value != l.FaultType {
 			// We're setting a value opposite to the fault type
 			// This creates D or D' values
@@ -106,6 +108,7 @@ func (l *Line) SetValue(value LogicValue) {
 func (l *Line) Reset() {
 	l.IsFaultSite = false
 	l.FaultType = X
+	l.FaultBranch = nil
 	l.Value = X
 }
 
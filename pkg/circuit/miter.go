@@ -0,0 +1,226 @@
+package circuit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Miter is the circuit that compares two circuits with the same inputs and
+// outputs. Its single output is 1 exactly for the inputs on which some output
+// of the two circuits differs, so the circuits are equivalent if and only if
+// the output cannot be set to 1.
+type Miter struct {
+	A, B    *Circuit
+	Circuit *Circuit
+	Output  *Line    // OR of the comparisons of every output pair, nil if there is none
+	Outputs []*Line  // Comparison XOR of each output pair, in the order of Names
+	Names   []string // Outputs compared by the miter
+	Shared  []string // Outputs both circuits compute with the same gates, which need no comparison
+}
+
+// BuildMiter builds the miter of two circuits, matching their inputs and outputs
+// by name. The inputs are shared and keep their names; every other line of a
+// circuit gets an "@a" or "@b" suffix. Each pair of outputs feeds an XOR named
+// after the output with an "@xor" suffix, and the XORs an OR tree whose root,
+// named "miter" unless there is a single output pair, is the miter output. Scan cells are matched by name, their Q lines as inputs
+// and their D lines as outputs.
+func BuildMiter(a, b *Circuit) (*Miter, error) {
+	inputs, err := matchNames(inputNames(a), inputNames(b), "input")
+	if err != nil {
+		return nil, err
+	}
+	outputsA, outputsB := a.ObservedOutputs(), b.ObservedOutputs()
+	names, err := matchNames(sortedKeys(outputsA), sortedKeys(outputsB), "output")
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("circuits %s and %s have no outputs to compare", a.Name, b.Name)
+	}
+
+	m := &Miter{A: a, B: b, Circuit: NewCircuit(fmt.Sprintf("miter(%s, %s)", a.Name, b.Name))}
+	nextLineID, nextGateID := 0, 0
+	newLine := func(name string) *Line {
+		l := NewLine(nextLineID, name, Normal)
+		m.Circuit.Lines[l.ID] = l
+		nextLineID++
+		return l
+	}
+	newGate := func(name string, gateType GateType, output *Line, ins ...*Line) *Gate {
+		g := NewGate(nextGateID, name, gateType)
+		for _, input := range ins {
+			g.AddInput(input)
+		}
+		g.SetOutput(output)
+		m.Circuit.AddGate(g)
+		nextGateID++
+		return g
+	}
+
+	shared := make(map[string]*Line, len(inputs))
+	for _, name := range inputs {
+		l := newLine(name)
+		l.Type = PrimaryInput
+		m.Circuit.Inputs = append(m.Circuit.Inputs, l)
+		shared[name] = l
+	}
+
+	// Copy both circuits, connecting their inputs to the shared ones. A gate of
+	// the same type as an earlier gate with the same inputs is not copied, its
+	// output is that of the earlier gate, so the logic both circuits share
+	// appears once. The inputs of a cell are in order, since its function
+	// need not be symmetric.
+	copies := make([]map[*Line]*Line, 2)
+	hashed := make(map[string]*Line)
+	for k, c := range []*Circuit{a, b} {
+		suffix := "@" + string(rune('a'+k))
+		lines := make(map[*Line]*Line, len(c.Lines))
+		lineOf := func(line *Line) *Line {
+			if l, ok := lines[line]; ok {
+				return l
+			}
+			if line.Type == PrimaryInput {
+				lines[line] = shared[line.Name]
+			} else {
+				lines[line] = newLine(line.Name + suffix)
+			}
+			return lines[line]
+		}
+
+		topo := NewTopology(c)
+		topo.ComputeLevels()
+		for _, gate := range topo.LevelizedGates() {
+			ins := make([]*Line, len(gate.Inputs))
+			ids := make([]int, len(gate.Inputs))
+			for i, input := range gate.Inputs {
+				ins[i] = lineOf(input)
+				ids[i] = ins[i].ID
+			}
+			if !gate.Type.HasTruthTable() {
+				sort.Ints(ids)
+			}
+			key := fmt.Sprint(gate.Type, gate.Table, ids)
+			if l, ok := hashed[key]; ok {
+				if _, driven := lines[gate.Output]; !driven {
+					lines[gate.Output] = l
+					continue
+				}
+			}
+			out := lineOf(gate.Output)
+			newGate(gate.Name+suffix, gate.Type, out, ins...).Table = gate.Table
+			hashed[key] = out
+		}
+		copies[k] = lines
+	}
+
+	// Compare each pair of outputs, then OR the comparisons pairwise into a tree
+	used := make(map[string]bool, len(m.Circuit.Lines))
+	for _, line := range m.Circuit.Lines {
+		used[line.Name] = true
+	}
+	unique := func(name string) string {
+		for used[name] {
+			name += "_"
+		}
+		used[name] = true
+		return name
+	}
+	for _, name := range names {
+		outA, outB := copies[0][outputsA[name]], copies[1][outputsB[name]]
+		if outA == outB {
+			m.Shared = append(m.Shared, name)
+			continue
+		}
+		diff := newLine(unique(name + "@xor"))
+		newGate(diff.Name, XOR, diff, outA, outB)
+		m.Outputs = append(m.Outputs, diff)
+		m.Names = append(m.Names, name)
+	}
+	if len(m.Outputs) == 0 {
+		m.Circuit.AnalyzeTopology()
+		return m, nil
+	}
+	level := m.Outputs
+	for len(level) > 1 {
+		var next []*Line
+		for i := 0; i+1 < len(level); i += 2 {
+			name := fmt.Sprintf("miter_or%d", nextGateID)
+			if len(level) == 2 {
+				name = "miter"
+			}
+			or := newLine(unique(name))
+			newGate(or.Name, OR, or, level[i], level[i+1])
+			next = append(next, or)
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		level = next
+	}
+	m.Output = level[0]
+	m.Output.Type = PrimaryOutput
+	m.Circuit.Outputs = []*Line{m.Output}
+
+	m.Circuit.AnalyzeTopology()
+	return m, nil
+}
+
+// inputNames returns the names of the inputs of a circuit, scan cells included
+func inputNames(c *Circuit) []string {
+	names := make([]string, len(c.Inputs))
+	for i, input := range c.Inputs {
+		names[i] = input.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ObservedOutputs returns the observed lines of a circuit by name: the primary
+// outputs by their own name and the D line of each scan cell by the cell name
+func (c *Circuit) ObservedOutputs() map[string]*Line {
+	outputs := make(map[string]*Line)
+	for _, output := range c.PrimaryOutputs() {
+		outputs[output.Name] = output
+	}
+	for _, cell := range c.ScanCells {
+		outputs[cell.Name] = cell.D
+	}
+	return outputs
+}
+
+// matchNames checks that two sorted lists of names are equal and returns them
+func matchNames(a, b []string, kind string) ([]string, error) {
+	inB := make(map[string]bool, len(b))
+	for _, name := range b {
+		inB[name] = true
+	}
+	inA := make(map[string]bool, len(a))
+	var onlyA, onlyB []string
+	for _, name := range a {
+		inA[name] = true
+		if !inB[name] {
+			onlyA = append(onlyA, name)
+		}
+	}
+	for _, name := range b {
+		if !inA[name] {
+			onlyB = append(onlyB, name)
+		}
+	}
+	if len(onlyA) > 0 || len(onlyB) > 0 {
+		return nil, fmt.Errorf("%ss do not match: only in the first circuit [%s], only in the second [%s]",
+			kind, strings.Join(onlyA, " "), strings.Join(onlyB, " "))
+	}
+	return a, nil
+}
+
+// sortedKeys returns the names of a line map in sorted order
+func sortedKeys(lines map[string]*Line) []string {
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
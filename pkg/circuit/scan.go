@@ -0,0 +1,101 @@
+package circuit
+
+// ScanCell is a flip-flop of a full-scan design. Its output Q is loaded by the
+// scan chain and acts as a pseudo-primary input, its data input D is captured
+// and unloaded and acts as a pseudo-primary output.
+type ScanCell struct {
+	Name string // Name of the flip-flop, the name of its Q line
+	Q    *Line  // Pseudo-primary input
+	D    *Line  // Pseudo-primary output
+
+	observed bool // D is also a primary output
+}
+
+// AddScanCell turns a flip-flop into a pseudo-primary input and output.
+// The Q line is appended to the inputs and the D line to the outputs, so that
+// test generation and fault simulation treat both like primary ones.
+func (c *Circuit) AddScanCell(q, d *Line) *ScanCell {
+	cell := &ScanCell{Name: q.Name, Q: q, D: d, observed: c.isOutput(d)}
+	for _, other := range c.ScanCells {
+		if other.D == d {
+			// Several flip-flops capture the same line
+			cell.observed = other.observed
+		}
+	}
+	c.ScanCells = append(c.ScanCells, cell)
+
+	q.Type = PrimaryInput
+	c.Inputs = append(c.Inputs, q)
+
+	if !c.isOutput(d) {
+		d.Type = PrimaryOutput
+		c.Outputs = append(c.Outputs, d)
+	}
+	return cell
+}
+
+// IsScanInput reports whether a line is the Q output of a scan cell
+func (c *Circuit) IsScanInput(line *Line) bool {
+	for _, cell := range c.ScanCells {
+		if cell.Q == line {
+			return true
+		}
+	}
+	return false
+}
+
+// PrimaryInputs returns the inputs that are not scan cells
+func (c *Circuit) PrimaryInputs() []*Line {
+	inputs := make([]*Line, 0, len(c.Inputs))
+	for _, input := range c.Inputs {
+		if !c.IsScanInput(input) {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+// PrimaryOutputs returns the outputs that are not only captured by scan cells
+func (c *Circuit) PrimaryOutputs() []*Line {
+	pseudo := make(map[*Line]bool, len(c.ScanCells))
+	for _, cell := range c.ScanCells {
+		if !cell.observed {
+			pseudo[cell.D] = true
+		}
+	}
+	outputs := make([]*Line, 0, len(c.Outputs))
+	for _, output := range c.Outputs {
+		if !pseudo[output] {
+			outputs = append(outputs, output)
+		}
+	}
+	return outputs
+}
+
+// SplitTest separates the primary input values of a test from the values
+// loaded into the scan cells
+func (c *Circuit) SplitTest(test map[string]LogicValue) (inputs, scan map[string]LogicValue) {
+	inputs = make(map[string]LogicValue)
+	scan = make(map[string]LogicValue)
+	for _, input := range c.PrimaryInputs() {
+		if value, ok := test[input.Name]; ok {
+			inputs[input.Name] = value
+		}
+	}
+	for _, cell := range c.ScanCells {
+		if value, ok := test[cell.Q.Name]; ok {
+			scan[cell.Q.Name] = value
+		}
+	}
+	return inputs, scan
+}
+
+// isOutput reports whether a line is already observed as an output
+func (c *Circuit) isOutput(line *Line) bool {
+	for _, output := range c.Outputs {
+		if output == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package circuit
+
+import "fmt"
+
+// Simplify returns a simplified copy of the circuit in which the given faults
+// are made permanent: the line of a stem fault, or the single branch of a branch
+// fault, is tied to the stuck value. Constants are propagated through the gates,
+// a gate with a single input, or left with one by the constants, becomes a BUF
+// or a NOT, and gates no output depends on are removed. A cell left with fewer
+// inputs becomes a LUT of those its function still depends on. Lines and gates
+// keep their names, and the inputs are kept even if nothing reads them any
+// more. Since only a tie cell of the netlist can drive a constant, it fails if
+// any other output or data input of a scan cell would become constant.
+func (c *Circuit) Simplify(ties []Fault) (*Circuit, error) {
+	constant := make(map[*Line]LogicValue)
+	branch := make(map[*Gate]map[*Line]LogicValue)
+	for _, f := range ties {
+		if f.Branch == nil {
+			constant[f.Line] = f.Type
+			continue
+		}
+		if branch[f.Branch] == nil {
+			branch[f.Branch] = make(map[*Line]LogicValue)
+		}
+		branch[f.Branch][f.Line] = f.Type
+	}
+
+	// Fold the constants into every gate in topological order
+	gates := make(map[*Gate]*foldedGate)
+	topo := NewTopology(c)
+	topo.ComputeLevels()
+	for _, gate := range topo.LevelizedGates() {
+		if _, tied := constant[gate.Output]; tied {
+			continue
+		}
+		var inputs []*Line
+		var values []LogicValue
+		tiedValues := make([]LogicValue, len(gate.Inputs))
+		for i, input := range gate.Inputs {
+			if v, ok := branch[gate][input]; ok {
+				values = append(values, v)
+				tiedValues[i] = v
+			} else if v, ok := constant[input]; ok {
+				values = append(values, v)
+				tiedValues[i] = v
+			} else {
+				inputs = append(inputs, input)
+			}
+		}
+
+		if gate.Type.HasTruthTable() {
+			folded, value := foldTable(gate, tiedValues)
+			if value != X {
+				constant[gate.Output] = value
+				if len(gate.Inputs) == 0 {
+					gates[gate] = folded // A tie cell is kept for the outputs it drives
+				}
+				continue
+			}
+			gates[gate] = folded
+			continue
+		}
+		gateType, value := foldConstants(gate.Type, len(inputs), values)
+		if value != X {
+			constant[gate.Output] = value
+			continue
+		}
+		gates[gate] = &foldedGate{gateType: gateType, inputs: inputs}
+	}
+
+	observed := c.PrimaryOutputs()
+	for _, cell := range c.ScanCells {
+		observed = append(observed, cell.D)
+	}
+	for _, line := range observed {
+		if v, ok := constant[line]; ok && gates[line.InputGate] == nil {
+			return nil, fmt.Errorf("output %s would be constant %v", line.Name, v)
+		}
+	}
+
+	// Keep the gates some output depends on
+	live := make(map[*Line]bool)
+	stack := append([]*Line(nil), observed...)
+	for len(stack) > 0 {
+		line := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if live[line] {
+			continue
+		}
+		live[line] = true
+		if g, ok := gates[line.InputGate]; ok {
+			stack = append(stack, g.inputs...)
+		}
+	}
+	for _, input := range c.Inputs {
+		live[input] = true
+	}
+
+	s := NewCircuit(c.Name)
+	lines := make(map[*Line]*Line)
+	for _, line := range c.SortedLines() {
+		if live[line] {
+			lines[line] = NewLine(line.ID, line.Name, Normal)
+			s.Lines[line.ID] = lines[line]
+		}
+	}
+	for _, gate := range c.SortedGates() {
+		g, ok := gates[gate]
+		if !ok || !live[gate.Output] {
+			continue
+		}
+		copied := NewGate(gate.ID, gate.Name, g.gateType)
+		if g.gateType.HasTruthTable() {
+			copied.Table = g.table
+		}
+		for _, input := range g.inputs {
+			copied.AddInput(lines[input])
+		}
+		copied.SetOutput(lines[gate.Output])
+		s.AddGate(copied)
+	}
+
+	for _, input := range c.PrimaryInputs() {
+		lines[input].Type = PrimaryInput
+		s.Inputs = append(s.Inputs, lines[input])
+	}
+	for _, output := range c.PrimaryOutputs() {
+		if lines[output].Type != PrimaryInput {
+			lines[output].Type = PrimaryOutput
+		}
+		s.Outputs = append(s.Outputs, lines[output])
+	}
+	for _, cell := range c.ScanCells {
+		s.AddScanCell(lines[cell.Q], lines[cell.D])
+	}
+
+	s.AnalyzeTopology()
+	return s, nil
+}
+
+// foldedGate is a gate once the constants on its inputs are folded into it
+type foldedGate struct {
+	gateType GateType
+	table    uint64 // Truth table of a cell
+	inputs   []*Line
+}
+
+// foldTable simplifies a gate with a truth table whose inputs are tied to the
+// given values, X for an input that is not constant. The table is restricted to
+// the inputs that are not constant and the function still depends on. It
+// returns the constant value of the output, or X and the folded gate: the same
+// cell if no input dropped out, a BUF or a NOT if one input is left, and a LUT
+// otherwise.
+func foldTable(gate *Gate, tied []LogicValue) (*foldedGate, LogicValue) {
+	n := min(gate.tableInputs(), len(gate.Inputs))
+	var ones uint64
+	var free []int
+	for i := 0; i < n; i++ {
+		switch tied[i] {
+		case Zero:
+		case One:
+			ones |= 1 << uint(i)
+		default:
+			free = append(free, i)
+		}
+	}
+
+	// table returns the cofactor over the given inputs
+	table := func(inputs []int) uint64 {
+		var t uint64
+		for m := uint64(0); m < 1<<uint(len(inputs)); m++ {
+			full := ones
+			for j, i := range inputs {
+				if bit(m, j) {
+					full |= 1 << uint(i)
+				}
+			}
+			if bit(gate.Table, int(full)) {
+				t |= 1 << m
+			}
+		}
+		return t
+	}
+
+	// Drop the inputs the cofactor does not depend on
+	for j := 0; j < len(free); {
+		cofactor := table(free)
+		depends := false
+		for m := uint64(0); m < 1<<uint(len(free)) && !depends; m++ {
+			depends = bit(cofactor, int(m)) != bit(cofactor, int(m^1<<uint(j)))
+		}
+		if depends {
+			j++
+			continue
+		}
+		free = append(free[:j], free[j+1:]...)
+	}
+
+	f := &foldedGate{gateType: gate.Type, table: table(free)}
+	for _, i := range free {
+		f.inputs = append(f.inputs, gate.Inputs[i])
+	}
+	switch {
+	case len(free) == 0:
+		if f.table&1 == 1 {
+			return f, One
+		}
+		return f, Zero
+	case len(free) == 1 && f.table == 0b10:
+		f.gateType = BUF
+	case len(free) == 1:
+		f.gateType = NOT
+	case len(free) < len(gate.Inputs):
+		f.gateType = LUT
+	}
+	return f, X
+}
+
+// foldConstants simplifies a gate with n non-constant inputs and inputs of the
+// given constant values. It returns the constant value of the output, or X and
+// the type of the gate that computes it from the n inputs.
+func foldConstants(gateType GateType, n int, values []LogicValue) (GateType, LogicValue) {
+	switch gateType {
+	case AND, NAND, OR, NOR:
+		controlling, inverted := Zero, gateType == NAND || gateType == NOR
+		if gateType == OR || gateType == NOR {
+			controlling = One
+		}
+		for _, v := range values {
+			if v == controlling {
+				return gateType, invertIf(controlling, inverted)
+			}
+		}
+		// Only non-controlling constants, which drop out
+		switch {
+		case n == 0:
+			return gateType, invertIf(invertBinary(controlling), inverted)
+		case n == 1 && inverted:
+			return NOT, X
+		case n == 1:
+			return BUF, X
+		}
+		return gateType, X
+
+	case XOR, XNOR:
+		// A constant 1 inverts the parity of the other inputs
+		for _, v := range values {
+			if v == One && gateType == XOR {
+				gateType = XNOR
+			} else if v == One {
+				gateType = XOR
+			}
+		}
+		switch {
+		case n == 0:
+			return gateType, invertIf(Zero, gateType == XNOR)
+		case n == 1 && gateType == XNOR:
+			return NOT, X
+		case n == 1:
+			return BUF, X
+		}
+		return gateType, X
+
+	case NOT:
+		if len(values) == 0 {
+			return gateType, X
+		}
+		return gateType, invertBinary(values[0])
+	default:
+		if len(values) == 0 {
+			return gateType, X
+		}
+		return gateType, values[0]
+	}
+}
+
+// invertIf returns the inverse of a binary value if inverted is set
+func invertIf(v LogicValue, inverted bool) LogicValue {
+	if inverted {
+		return invertBinary(v)
+	}
+	return v
+}
@@ -48,6 +48,12 @@ func (t *Topology) ComputeLevels() {
 	for _, input := range t.Circuit.Inputs {
 		t.LevelMap[input] = 0
 	}
+	// An undriven line, whose value is unknown, is a source like an input
+	for _, line := range t.Circuit.Lines {
+		if line.InputGate == nil {
+			t.LevelMap[line] = 0
+		}
+	}
 
 	// Keep processing gates until all lines have levels
 	changed := true
@@ -87,6 +93,31 @@ func (t *Topology) ComputeLevels() {
 	}
 }
 
+// LevelizedGates returns the gates ordered by the level of their output line,
+// so that every gate comes after the gates driving its inputs. Gates without
+// a level (undriven inputs or loops) are placed last.
+func (t *Topology) LevelizedGates() []*Gate {
+	gates := make([]*Gate, 0, len(t.Circuit.Gates))
+	for _, gate := range t.Circuit.Gates {
+		gates = append(gates, gate)
+	}
+
+	level := func(g *Gate) int {
+		if l, ok := t.LevelMap[g.Output]; ok {
+			return l
+		}
+		return t.MaxLevel + 1
+	}
+	sort.Slice(gates, func(i, j int) bool {
+		li, lj := level(gates[i]), level(gates[j])
+		if li != lj {
+			return li < lj
+		}
+		return gates[i].ID < gates[j].ID
+	})
+	return gates
+}
+
 // IdentifyFanoutPoints identifies all fanout points in the circuit
 func (t *Topology) IdentifyFanoutPoints() {
 	t.FanoutPoints = make([]*Line, 0)
@@ -228,6 +259,40 @@ func (t *Topology) FindUniquePathsToOutputs(gate *Gate) [][]*Line {
 	return paths
 }
 
+// Dominators returns the lines that every path from a line to a primary output
+// passes through, in level order and starting with the line itself. The lines
+// reachable from the start are swept level by level, and a line is a dominator
+// when it is the only line left in the cut between the start and the outputs.
+// The sweep ends at the first primary output, where a path may end. A line
+// that reaches no primary output has no dominators.
+func (t *Topology) Dominators(line *Line) []*Line {
+	var dominators []*Line
+	cut := map[*Line]bool{line: true}
+
+	for len(cut) > 0 {
+		// Take the line of the lowest level from the cut
+		var next *Line
+		for l := range cut {
+			if next == nil || t.LevelMap[l] < t.LevelMap[next] ||
+				(t.LevelMap[l] == t.LevelMap[next] && l.ID < next.ID) {
+				next = l
+			}
+		}
+		if len(cut) == 1 {
+			dominators = append(dominators, next)
+		}
+		delete(cut, next)
+
+		if next.Type == PrimaryOutput {
+			return dominators
+		}
+		for _, gate := range next.OutputGates {
+			cut[gate.Output] = true
+		}
+	}
+	return nil
+}
+
 // Helper function to recursively find paths to primary outputs
 func (t *Topology) findPathsToPO(line *Line, currentPath []*Line, allPaths *[][]*Line) {
 	// If we've reached a primary output, add the path
@@ -0,0 +1,206 @@
+package circuit
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TransitionType is the direction of a transition fault
+type TransitionType int
+
+const (
+	SlowToRise TransitionType = iota // A 0-to-1 transition arrives too late
+	SlowToFall                       // A 1-to-0 transition arrives too late
+)
+
+// String returns a string representation of the transition type
+func (t TransitionType) String() string {
+	switch t {
+	case SlowToRise:
+		return "STR"
+	case SlowToFall:
+		return "STF"
+	default:
+		return "?"
+	}
+}
+
+// TransitionFault is a slow-to-rise or slow-to-fall fault on a stem or on one fanout branch
+type TransitionFault struct {
+	Line   *Line          // Faulty line (the stem for a branch fault)
+	Type   TransitionType // Direction of the delayed transition
+	Branch *Gate          // Gate fed by the faulty fanout branch, nil for a stem fault
+}
+
+// String returns the fault in "name/STR" or "stem->gate/STF" notation
+func (f TransitionFault) String() string {
+	if f.Branch != nil {
+		return fmt.Sprintf("%s->%s/%v", f.Line.Name, f.Branch.Name, f.Type)
+	}
+	return fmt.Sprintf("%s/%v", f.Line.Name, f.Type)
+}
+
+// InitialValue returns the value the line must hold before the transition is launched
+func (f TransitionFault) InitialValue() LogicValue {
+	if f.Type == SlowToRise {
+		return Zero
+	}
+	return One
+}
+
+// StuckAt returns the stuck-at fault that the launch vector must detect: a
+// slow-to-rise line still holds 0 when the response is captured
+func (f TransitionFault) StuckAt() Fault {
+	return Fault{Line: f.Line, Type: f.InitialValue(), Branch: f.Branch}
+}
+
+// NewTransitionFaults returns a slow-to-rise and a slow-to-fall fault for every
+// stuck-at fault site of the circuit, in fault universe order
+func NewTransitionFaults(c *Circuit) []TransitionFault {
+	faults := make([]TransitionFault, 0)
+	for _, f := range NewFaultList(c, false).All {
+		t := SlowToRise
+		if f.Type == One {
+			t = SlowToFall
+		}
+		faults = append(faults, TransitionFault{Line: f.Line, Type: t, Branch: f.Branch})
+	}
+	return faults
+}
+
+// TwoPatternTest is a transition test. V1 holds the primary input values and
+// scan loads of the initialization vector, V2 the primary input values of the
+// launch/capture vector.
+type TwoPatternTest struct {
+	V1 map[string]LogicValue
+	V2 map[string]LogicValue
+}
+
+// TimeFrames is the two time-frame expansion of a circuit used for
+// launch-on-capture tests. Frame 1 applies V1 and its scan load. Under full
+// scan the cells capture the response of frame 1, which launches the
+// transition into frame 2; frame 2 applies the primary inputs of V2, and its
+// primary outputs and scan cells are observed. The outputs of frame 1 are not.
+type TimeFrames struct {
+	Original *Circuit // Circuit that was expanded
+	Circuit  *Circuit // Combinational circuit of both time frames
+
+	lines [2]map[*Line]*Line // Copy of every original line in each frame
+	gates [2]map[*Gate]*Gate // Copy of every original gate in each frame
+}
+
+// ExpandLaunchOnCapture builds the two time-frame circuit of a circuit. Lines
+// and gates of frame k are named after the original with an "@k" suffix. The Q
+// line of a scan cell in frame 2 is a buffer of its D line in frame 1.
+func ExpandLaunchOnCapture(c *Circuit) *TimeFrames {
+	tf := &TimeFrames{
+		Original: c,
+		Circuit:  NewCircuit(c.Name),
+	}
+
+	lines := make([]*Line, 0, len(c.Lines))
+	for _, line := range c.Lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+
+	nextLineID, nextGateID := 0, 0
+	for frame := range tf.lines {
+		tf.lines[frame] = make(map[*Line]*Line, len(lines))
+		tf.gates[frame] = make(map[*Gate]*Gate, len(c.Gates))
+
+		for _, line := range lines {
+			l := NewLine(nextLineID, fmt.Sprintf("%s@%d", line.Name, frame+1), Normal)
+			tf.Circuit.Lines[l.ID] = l
+			tf.lines[frame][line] = l
+			nextLineID++
+		}
+		for _, gate := range c.SortedGates() {
+			g := NewGate(nextGateID, fmt.Sprintf("%s@%d", gate.Name, frame+1), gate.Type)
+			g.Table = gate.Table
+			g.ControlID = gate.ControlID
+			for _, input := range gate.Inputs {
+				g.AddInput(tf.lines[frame][input])
+			}
+			g.SetOutput(tf.lines[frame][gate.Output])
+			tf.Circuit.AddGate(g)
+			tf.gates[frame][gate] = g
+			nextGateID++
+		}
+	}
+
+	// The scan cells capture frame 1 and launch it into frame 2
+	for _, cell := range c.ScanCells {
+		g := NewGate(nextGateID, fmt.Sprintf("%s@capture", cell.Name), BUF)
+		g.AddInput(tf.lines[0][cell.D])
+		g.SetOutput(tf.lines[1][cell.Q])
+		tf.Circuit.AddGate(g)
+		nextGateID++
+	}
+
+	for _, input := range c.Inputs {
+		tf.addInput(tf.lines[0][input])
+	}
+	for _, input := range c.PrimaryInputs() {
+		tf.addInput(tf.lines[1][input])
+	}
+	for _, output := range c.Outputs {
+		l := tf.lines[1][output]
+		if l.Type != PrimaryInput {
+			l.Type = PrimaryOutput
+		}
+		tf.Circuit.Outputs = append(tf.Circuit.Outputs, l)
+	}
+
+	tf.Circuit.AnalyzeTopology()
+	return tf
+}
+
+// addInput makes a line of the expanded circuit an input
+func (tf *TimeFrames) addInput(line *Line) {
+	line.Type = PrimaryInput
+	tf.Circuit.Inputs = append(tf.Circuit.Inputs, line)
+}
+
+// Line returns the copy of an original line in frame 1 or 2
+func (tf *TimeFrames) Line(line *Line, frame int) *Line {
+	return tf.lines[frame-1][line]
+}
+
+// Target returns the stuck-at fault of the expanded circuit whose tests detect
+// a transition fault, together with the initial value required in frame 1
+func (tf *TimeFrames) Target(f TransitionFault) (Fault, Constraint) {
+	stuck := f.StuckAt()
+	fault := Fault{Line: tf.lines[1][stuck.Line], Type: stuck.Type}
+	if stuck.Branch != nil {
+		fault.Branch = tf.gates[1][stuck.Branch]
+	}
+	return fault, Constraint{Line: tf.lines[0][f.Line], Value: f.InitialValue()}
+}
+
+// TwoPatternTest converts a test of the expanded circuit into V1 and V2
+func (tf *TimeFrames) TwoPatternTest(test map[string]LogicValue) TwoPatternTest {
+	t := TwoPatternTest{
+		V1: make(map[string]LogicValue),
+		V2: make(map[string]LogicValue),
+	}
+	for _, input := range tf.Original.Inputs {
+		t.V1[input.Name] = test[tf.lines[0][input].Name]
+	}
+	for _, input := range tf.Original.PrimaryInputs() {
+		t.V2[input.Name] = test[tf.lines[1][input].Name]
+	}
+	return t
+}
+
+// ExpandedTest converts a two-pattern test into the test of the expanded circuit
+func (tf *TimeFrames) ExpandedTest(test TwoPatternTest) map[string]LogicValue {
+	expanded := make(map[string]LogicValue)
+	for _, input := range tf.Original.Inputs {
+		expanded[tf.lines[0][input].Name] = test.V1[input.Name]
+	}
+	for _, input := range tf.Original.PrimaryInputs() {
+		expanded[tf.lines[1][input].Name] = test.V2[input.Name]
+	}
+	return expanded
+}
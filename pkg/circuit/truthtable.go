@@ -0,0 +1,361 @@
+package circuit
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// MaxTableInputs is the largest number of inputs of a gate with a truth table
+const MaxTableInputs = 6
+
+// Cube is a product term over the inputs of a gate: input i is specified if
+// bit i of Mask is set, to the value of bit i of Values
+type Cube struct {
+	Mask   uint64
+	Values uint64
+}
+
+// Literal returns the value a cube requires of input i, or X if the input is free
+func (c Cube) Literal(i int) LogicValue {
+	switch {
+	case c.Mask>>uint(i)&1 == 0:
+		return X
+	case c.Values>>uint(i)&1 == 1:
+		return One
+	default:
+		return Zero
+	}
+}
+
+// Size returns the number of inputs a cube specifies
+func (c Cube) Size() int {
+	return bits.OnesCount64(c.Mask)
+}
+
+// HasTruthTable reports whether a gate type is a cell that computes the
+// function given by the Table of the gate. Bit m of the table is the output
+// for the input values given by the bits of m, the first input being the
+// lowest bit, so that a 2-input AND is the LUT 0x8.
+func (gt GateType) HasTruthTable() bool {
+	switch gt {
+	case MUX, TIE0, TIE1, AOI21, AOI22, OAI21, OAI22, LUT:
+		return true
+	}
+	return false
+}
+
+// Arity returns the number of inputs a gate of the type takes, or -1 if it
+// takes any number: at least one for AND to XNOR, up to MaxTableInputs for a LUT
+func (gt GateType) Arity() int {
+	switch gt {
+	case NOT, BUF:
+		return 1
+	case TIE0, TIE1:
+		return 0
+	case MUX, AOI21, OAI21:
+		return 3
+	case AOI22, OAI22:
+		return 4
+	}
+	return -1
+}
+
+// cellFunctions gives the function of every cell of fixed function, for the
+// input values given by the bits of m
+var cellFunctions = map[GateType]func(m uint64) bool{
+	MUX:   func(m uint64) bool { return bit(m, 2) && bit(m, 1) || !bit(m, 2) && bit(m, 0) },
+	TIE0:  func(m uint64) bool { return false },
+	TIE1:  func(m uint64) bool { return true },
+	AOI21: func(m uint64) bool { return !(bit(m, 0) && bit(m, 1) || bit(m, 2)) },
+	AOI22: func(m uint64) bool { return !(bit(m, 0) && bit(m, 1) || bit(m, 2) && bit(m, 3)) },
+	OAI21: func(m uint64) bool { return !((bit(m, 0) || bit(m, 1)) && bit(m, 2)) },
+	OAI22: func(m uint64) bool { return !((bit(m, 0) || bit(m, 1)) && (bit(m, 2) || bit(m, 3))) },
+}
+
+// bit reports whether bit i of m is set
+func bit(m uint64, i int) bool {
+	return m>>uint(i)&1 == 1
+}
+
+// truthTable returns the truth table of a cell of fixed function, 0 for any
+// other gate type
+func (gt GateType) truthTable() uint64 {
+	f, ok := cellFunctions[gt]
+	if !ok {
+		return 0
+	}
+	var table uint64
+	for m := uint64(0); m < 1<<uint(gt.Arity()); m++ {
+		if f(m) {
+			table |= 1 << m
+		}
+	}
+	return table
+}
+
+// tableKey identifies a truth table over a number of inputs
+type tableKey struct {
+	table uint64
+	n     int
+}
+
+// cubeCache holds the prime cubes of every truth table seen so far, shared by
+// all gates and goroutines
+var cubeCache sync.Map
+
+// primeCubes returns the prime implicants of the off-set and of the on-set of a
+// truth table over n inputs, with the fewest literals first. These are the
+// largest cubes of input values that decide the output, from which evaluation,
+// implication and backtrace all follow.
+func primeCubes(table uint64, n int) *[2][]Cube {
+	key := tableKey{table: table, n: n}
+	if cubes, ok := cubeCache.Load(key); ok {
+		return cubes.(*[2][]Cube)
+	}
+
+	size := uint64(1) << uint(n)
+	implicant := func(c Cube, v uint64) bool {
+		for m := uint64(0); m < size; m++ {
+			if m&c.Mask == c.Values && table>>m&1 != v {
+				return false
+			}
+		}
+		return true
+	}
+
+	cubes := new([2][]Cube)
+	for v := uint64(0); v < 2; v++ {
+		for mask := uint64(0); mask < size; mask++ {
+			for values := mask; ; values = (values - 1) & mask {
+				c := Cube{Mask: mask, Values: values}
+				if implicant(c, v) {
+					prime := true
+					for i := 0; i < n && prime; i++ {
+						if b := uint64(1) << uint(i); mask&b != 0 {
+							prime = !implicant(Cube{Mask: mask &^ b, Values: values &^ b}, v)
+						}
+					}
+					if prime {
+						cubes[v] = append(cubes[v], c)
+					}
+				}
+				if values == 0 {
+					break
+				}
+			}
+		}
+		sort.Slice(cubes[v], func(i, j int) bool {
+			a, b := cubes[v][i], cubes[v][j]
+			if a.Size() != b.Size() {
+				return a.Size() < b.Size()
+			}
+			if a.Mask != b.Mask {
+				return a.Mask < b.Mask
+			}
+			return a.Values < b.Values
+		})
+	}
+
+	actual, _ := cubeCache.LoadOrStore(key, cubes)
+	return actual.(*[2][]Cube)
+}
+
+// tableInputs returns the number of inputs the truth table of a gate is over
+func (g *Gate) tableInputs() int {
+	if n := g.Type.Arity(); n >= 0 {
+		return n
+	}
+	return min(len(g.Inputs), MaxTableInputs)
+}
+
+// Cubes returns the prime cubes of the input values for which a gate with a
+// truth table outputs a binary value, with the fewest literals first
+func (g *Gate) Cubes(value LogicValue) []Cube {
+	cubes := primeCubes(g.Table, g.tableInputs())
+	switch value {
+	case Zero:
+		return cubes[0]
+	case One:
+		return cubes[1]
+	default:
+		return nil
+	}
+}
+
+// inputMasks returns the inputs of a gate with a truth table that are binary in
+// both machines, and those of them that are 1 in the good and in the faulty
+// machine. A missing input reads X.
+func (g *Gate) inputMasks() (known, good, faulty uint64) {
+	for i := 0; i < g.tableInputs() && i < len(g.Inputs); i++ {
+		b := uint64(1) << uint(i)
+		switch g.InputValue(g.Inputs[i]) {
+		case Zero:
+			known |= b
+		case One:
+			known, good, faulty = known|b, good|b, faulty|b
+		case D:
+			known, faulty = known|b, faulty|b
+		case Dnot:
+			known, good = known|b, good|b
+		}
+	}
+	return known, good, faulty
+}
+
+// tableValue returns the output of a gate with a truth table for inputs of
+// which those in known have the values in ones, or X if the other inputs
+// decide it
+func (g *Gate) tableValue(known, ones uint64) LogicValue {
+	cubes := primeCubes(g.Table, g.tableInputs())
+	for v, value := range []LogicValue{Zero, One} {
+		for _, c := range cubes[v] {
+			if c.Mask&^known == 0 && ones&c.Mask == c.Values {
+				return value
+			}
+		}
+	}
+	return X
+}
+
+// evaluateTable evaluates the truth table for the good and the faulty machine
+func (g *Gate) evaluateTable() LogicValue {
+	known, good, faulty := g.inputMasks()
+	goodValue, faultyValue := g.tableValue(known, good), g.tableValue(known, faulty)
+	switch {
+	case goodValue == X || faultyValue == X:
+		return X
+	case goodValue == faultyValue:
+		return goodValue
+	case goodValue == Zero:
+		return D
+	default:
+		return Dnot
+	}
+}
+
+// TableValue returns the output of a gate with a truth table for three-valued
+// input values, given in the order of its inputs
+func (g *Gate) TableValue(values []LogicValue) LogicValue {
+	var known, ones uint64
+	for i, v := range values {
+		if i >= g.tableInputs() {
+			break
+		}
+		switch v {
+		case Zero:
+			known |= 1 << uint(i)
+		case One:
+			known, ones = known|1<<uint(i), ones|1<<uint(i)
+		}
+	}
+	return g.tableValue(known, ones)
+}
+
+// cofactor returns the constant output of a gate with a truth table once input
+// i is set to a value, or X if the output still depends on other inputs
+func (g *Gate) cofactor(i int, value LogicValue) LogicValue {
+	var ones uint64
+	if value == One {
+		ones = 1 << uint(i)
+	}
+	return g.tableValue(1<<uint(i), ones)
+}
+
+// ConsistentCubes returns the cubes for an output value of a gate with a truth
+// table that the good values of its assigned inputs do not contradict
+func (g *Gate) ConsistentCubes(value LogicValue) []Cube {
+	known, good, _ := g.inputMasks()
+	var cubes []Cube
+	for _, c := range g.Cubes(value) {
+		if (c.Values^good)&c.Mask&known == 0 {
+			cubes = append(cubes, c)
+		}
+	}
+	return cubes
+}
+
+// ImpliedInputs returns the values the inputs of a gate with a truth table
+// must take for the good machine to output a value. An unassigned input is
+// implied if every consistent cube gives it the same value, otherwise it and
+// every assigned input are X. It returns false if no cube is consistent with
+// the assigned inputs, so that the output value cannot be justified.
+func (g *Gate) ImpliedInputs(value LogicValue) ([]LogicValue, bool) {
+	cubes := g.ConsistentCubes(value)
+	if len(cubes) == 0 {
+		return nil, false
+	}
+
+	implied := make([]LogicValue, len(g.Inputs))
+	for i, input := range g.Inputs {
+		implied[i] = X
+		if input.IsAssigned() {
+			continue
+		}
+		literal := cubes[0].Literal(i)
+		for _, c := range cubes[1:] {
+			if c.Literal(i) != literal {
+				literal = X
+				break
+			}
+		}
+		implied[i] = literal
+	}
+	return implied, true
+}
+
+// PropagationCube returns a cube over the unassigned inputs of a gate with a
+// truth table under which the fault effects on its inputs reach its output,
+// that is the good and the faulty machine output opposite values. The cube
+// with the fewest literals is returned, and false if no values of the
+// unassigned inputs propagate the fault effects.
+func (g *Gate) PropagationCube() (Cube, bool) {
+	known, good, faulty := g.inputMasks()
+	var free uint64
+	for i := 0; i < g.tableInputs() && i < len(g.Inputs); i++ {
+		if !g.Inputs[i].IsAssigned() {
+			free |= 1 << uint(i)
+		}
+	}
+	propagates := func(c Cube) bool {
+		goodValue := g.tableValue(known|c.Mask, good|c.Values)
+		faultyValue := g.tableValue(known|c.Mask, faulty|c.Values)
+		return goodValue != X && faultyValue != X && goodValue != faultyValue
+	}
+
+	best, found := Cube{}, false
+	for mask := free; ; mask = (mask - 1) & free {
+		if !found || bits.OnesCount64(mask) < best.Size() {
+			for values := mask; ; values = (values - 1) & mask {
+				if c := (Cube{Mask: mask, Values: values}); propagates(c) {
+					best, found = c, true
+					break
+				}
+				if values == 0 {
+					break
+				}
+			}
+		}
+		if mask == 0 {
+			break
+		}
+	}
+	return best, found
+}
+
+// SensitizingCubes returns the prime cubes of the other inputs under which
+// input i of a gate with a truth table decides the output, which are the
+// cubes of the Boolean difference of the table with respect to the input
+func (g *Gate) SensitizingCubes(i int) []Cube {
+	n := g.tableInputs()
+	if i >= n {
+		return nil
+	}
+	var difference uint64
+	for m := uint64(0); m < 1<<uint(n); m++ {
+		if (g.Table>>m^g.Table>>(m^1<<uint(i)))&1 == 1 {
+			difference |= 1 << m
+		}
+	}
+	return primeCubes(difference, n)[1]
+}
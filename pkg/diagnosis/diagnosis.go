@@ -0,0 +1,197 @@
+package diagnosis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// Mode selects how the candidate faults of a fail log are found
+type Mode int
+
+const (
+	PassFail     Mode = iota // Dictionary of the failing patterns of every fault
+	FullResponse             // Dictionary of the failing outputs of every pattern and fault
+	EffectCause              // No dictionary: trace back from the failures and simulate the suspects
+)
+
+// String returns a string representation of the mode
+func (m Mode) String() string {
+	switch m {
+	case PassFail:
+		return "pass-fail"
+	case FullResponse:
+		return "full-response"
+	case EffectCause:
+		return "effect-cause"
+	default:
+		return "?"
+	}
+}
+
+// ParseMode returns the diagnosis mode with the given name
+func ParseMode(name string) (Mode, error) {
+	for _, m := range []Mode{PassFail, FullResponse, EffectCause} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return FullResponse, fmt.Errorf("unknown diagnosis mode %q (expected pass-fail, full-response or effect-cause)", name)
+}
+
+// Candidate is a stuck-at fault that explains some of the observed failures.
+// Failures are counted per pattern with PassFail and per pattern and output
+// otherwise.
+type Candidate struct {
+	Fault   circuit.Fault
+	Score   float64 // Matched failures over the failures observed or predicted, 1 for a perfect match
+	Matched int     // Observed failures the fault predicts
+	Missed  int     // Observed failures the fault does not predict
+	Extra   int     // Predicted failures that were not observed
+}
+
+// String returns the candidate with its score and failure counts
+func (c Candidate) String() string {
+	return fmt.Sprintf("%s (score %.3f, %d matched, %d missed, %d extra)", c.Fault, c.Score, c.Matched, c.Missed, c.Extra)
+}
+
+// Dictionary holds the failures every fault of a fault universe causes under a
+// pattern set, as computed by fault simulation
+type Dictionary struct {
+	Mode    Mode // PassFail or FullResponse
+	Faults  []circuit.Fault
+	Entries [][]simulation.Failure // Failures of each fault, without outputs in a pass/fail dictionary
+}
+
+// BuildDictionary fault-simulates the patterns against the faults and records
+// the failures of every fault at the granularity of the mode
+func BuildDictionary(sim *simulation.FaultSimulator, patterns []map[string]circuit.LogicValue, faults []circuit.Fault, mode Mode) *Dictionary {
+	d := &Dictionary{Mode: mode, Faults: faults, Entries: sim.Failures(patterns, faults)}
+	if mode == PassFail {
+		for i, failures := range d.Entries {
+			d.Entries[i] = failingPatterns(failures)
+		}
+	}
+	return d
+}
+
+// Lookup ranks the faults of the dictionary against the observed failures
+func (d *Dictionary) Lookup(observed []simulation.Failure) []Candidate {
+	if d.Mode == PassFail {
+		observed = failingPatterns(observed)
+	}
+	return rank(d.Faults, d.Entries, observed)
+}
+
+// Diagnoser explains the failures a tester observes with a pattern set by the
+// single stuck-at faults of a circuit. The dictionaries are built on first use
+// and kept for later fail logs of the same pattern set.
+type Diagnoser struct {
+	Circuit   *circuit.Circuit
+	Logger    *utils.Logger
+	Simulator *simulation.FaultSimulator
+	Patterns  []map[string]circuit.LogicValue
+	Faults    []circuit.Fault // Fault universe the candidates are taken from
+
+	dictionaries map[Mode]*Dictionary
+}
+
+// NewDiagnoser creates a diagnoser for a pattern set applied to a circuit. The
+// candidates are every stuck-at fault of the circuit, since faults that are
+// equivalent for test generation are reported side by side.
+func NewDiagnoser(c *circuit.Circuit, patterns []map[string]circuit.LogicValue, logger *utils.Logger) *Diagnoser {
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	return &Diagnoser{
+		Circuit:      c,
+		Logger:       logger,
+		Simulator:    simulation.NewFaultSimulator(c, topo, logger),
+		Patterns:     patterns,
+		Faults:       circuit.NewFaultList(c, false).All,
+		dictionaries: make(map[Mode]*Dictionary),
+	}
+}
+
+// Diagnose ranks the candidate faults of the observed failures, best first. A
+// candidate must explain at least one observed failure. Full-response and
+// effect-cause diagnosis need the failing outputs of every failing pattern.
+func (d *Diagnoser) Diagnose(observed []simulation.Failure, mode Mode) ([]Candidate, error) {
+	for _, failure := range observed {
+		if failure.Pattern < 0 || failure.Pattern >= len(d.Patterns) {
+			return nil, fmt.Errorf("failure of pattern %d, but the pattern set has %d patterns", failure.Pattern+1, len(d.Patterns))
+		}
+		if failure.Output == "" && mode != PassFail {
+			return nil, fmt.Errorf("%v diagnosis needs the failing outputs of pattern %d", mode, failure.Pattern+1)
+		}
+	}
+
+	if mode == EffectCause {
+		return d.effectCause(observed), nil
+	}
+	dictionary, ok := d.dictionaries[mode]
+	if !ok {
+		d.Logger.Info("Building %v dictionary of %d faults for %d patterns", mode, len(d.Faults), len(d.Patterns))
+		dictionary = BuildDictionary(d.Simulator, d.Patterns, d.Faults, mode)
+		d.dictionaries[mode] = dictionary
+	}
+	return dictionary.Lookup(observed), nil
+}
+
+// rank scores every fault whose predicted failures match an observed one and
+// sorts them by score, then by matched failures, then by extra failures. Ties
+// keep the order of the faults.
+func rank(faults []circuit.Fault, predicted [][]simulation.Failure, observed []simulation.Failure) []Candidate {
+	seen := make(map[simulation.Failure]bool, len(observed))
+	for _, failure := range observed {
+		seen[failure] = true
+	}
+
+	candidates := make([]Candidate, 0)
+	for i, fault := range faults {
+		matched := 0
+		for _, failure := range predicted[i] {
+			if seen[failure] {
+				matched++
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+		candidate := Candidate{
+			Fault:   fault,
+			Matched: matched,
+			Missed:  len(seen) - matched,
+			Extra:   len(predicted[i]) - matched,
+		}
+		candidate.Score = float64(matched) / float64(matched+candidate.Missed+candidate.Extra)
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Matched != b.Matched {
+			return a.Matched > b.Matched
+		}
+		return a.Extra < b.Extra
+	})
+	return candidates
+}
+
+// failingPatterns reduces failures to one output-less failure per failing pattern
+func failingPatterns(failures []simulation.Failure) []simulation.Failure {
+	patterns := make([]simulation.Failure, 0, len(failures))
+	seen := make(map[int]bool)
+	for _, failure := range failures {
+		if !seen[failure.Pattern] {
+			seen[failure.Pattern] = true
+			patterns = append(patterns, simulation.Failure{Pattern: failure.Pattern})
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,103 @@
+package diagnosis
+
+import (
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+)
+
+// effectCause diagnoses without a dictionary. The suspects are found by
+// reasoning back from the failures: a single stuck-at fault must lie in the
+// fan-in cone of every failing output and be activated by every failing
+// pattern. Only the suspects are then fault-simulated and ranked by their full
+// response. If none of them matches perfectly, as with several defects or a
+// defect that is no stuck-at fault, every fault in the cone of some failing
+// output that some failing pattern activates is a suspect.
+func (d *Diagnoser) effectCause(observed []simulation.Failure) []Candidate {
+	var patterns []int
+	var cones []map[*circuit.Line]bool
+	seenPattern := make(map[int]bool)
+	seenOutput := make(map[string]bool)
+	lines := make(map[string]*circuit.Line, len(d.Circuit.Lines))
+	for _, line := range d.Circuit.Lines {
+		lines[line.Name] = line
+	}
+	for _, failure := range observed {
+		if !seenPattern[failure.Pattern] {
+			seenPattern[failure.Pattern] = true
+			patterns = append(patterns, failure.Pattern)
+		}
+		if !seenOutput[failure.Output] {
+			seenOutput[failure.Output] = true
+			cones = append(cones, faninCone(lines[failure.Output]))
+		}
+	}
+
+	failing := make([]map[string]circuit.LogicValue, len(patterns))
+	for i, p := range patterns {
+		failing[i] = d.Patterns[p]
+	}
+	values := d.Simulator.GoodValues(failing)
+
+	suspects := d.suspects(cones, values, true)
+	d.Logger.Info("Effect-cause analysis kept %d of %d faults as suspects", len(suspects), len(d.Faults))
+	candidates := rank(suspects, d.Simulator.Failures(d.Patterns, suspects), observed)
+	if len(candidates) > 0 && candidates[0].Score == 1 {
+		return candidates
+	}
+
+	suspects = d.suspects(cones, values, false)
+	d.Logger.Info("No single stuck-at fault explains the failures, widening the suspects to %d faults", len(suspects))
+	return rank(suspects, d.Simulator.Failures(d.Patterns, suspects), observed)
+}
+
+// suspects returns the faults in the fan-in cones of the failing outputs that
+// the failing patterns activate, in every cone and by every pattern if all is
+// set and in some cone and by some pattern otherwise. values holds the good
+// machine values of each failing pattern; a fault on an X line may be activated.
+func (d *Diagnoser) suspects(cones []map[*circuit.Line]bool, values []map[*circuit.Line]circuit.LogicValue, all bool) []circuit.Fault {
+	suspects := make([]circuit.Fault, 0)
+	for _, fault := range d.Faults {
+		// The error of a branch fault first appears on the output of its gate
+		site := fault.Line
+		if fault.Branch != nil {
+			site = fault.Branch.Output
+		}
+
+		inCone := 0
+		for _, cone := range cones {
+			if cone[site] {
+				inCone++
+			}
+		}
+		activated := 0
+		for _, v := range values {
+			if v[fault.Line] != fault.Type {
+				activated++
+			}
+		}
+
+		if all && inCone == len(cones) && activated == len(values) ||
+			!all && inCone > 0 && activated > 0 {
+			suspects = append(suspects, fault)
+		}
+	}
+	return suspects
+}
+
+// faninCone returns the lines from which a line can be reached, the line included
+func faninCone(line *circuit.Line) map[*circuit.Line]bool {
+	cone := make(map[*circuit.Line]bool)
+	stack := []*circuit.Line{line}
+	for len(stack) > 0 {
+		l := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if l == nil || cone[l] {
+			continue
+		}
+		cone[l] = true
+		if l.InputGate != nil {
+			stack = append(stack, l.InputGate.Inputs...)
+		}
+	}
+	return cone
+}
@@ -0,0 +1,71 @@
+package diagnosis
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+)
+
+// ParseFailLog reads a tester fail log, one failing pattern per line as the
+// pattern number followed by the outputs that failed, e.g. "3 G17 G22". Patterns
+// are numbered from 1 as in the pattern file. A scan cell stands for the output
+// it captures. A pattern listed without outputs only records that it failed,
+// which is enough for pass/fail diagnosis. Empty lines and lines starting with
+// # are skipped.
+func ParseFailLog(filename string, c *circuit.Circuit) ([]simulation.Failure, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	outputs := make(map[string]string, len(c.Outputs)+len(c.ScanCells))
+	for _, output := range c.Outputs {
+		outputs[output.Name] = output.Name
+	}
+	for _, cell := range c.ScanCells {
+		outputs[cell.Name] = cell.D.Name
+	}
+
+	failures := make([]simulation.Failure, 0)
+	seen := make(map[simulation.Failure]bool)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		pattern, err := strconv.Atoi(fields[0])
+		if err != nil || pattern < 1 {
+			return nil, fmt.Errorf("%s:%d: invalid pattern number %q", filename, lineNo, fields[0])
+		}
+
+		names := fields[1:]
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, name := range names {
+			output, ok := outputs[name]
+			if name != "" && !ok {
+				return nil, fmt.Errorf("%s:%d: output not found: %s", filename, lineNo, name)
+			}
+			failure := simulation.Failure{Pattern: pattern - 1, Output: output}
+			if !seen[failure] {
+				seen[failure] = true
+				failures = append(failures, failure)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	return failures, nil
+}
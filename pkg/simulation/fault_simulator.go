@@ -0,0 +1,529 @@
+package simulation
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// PatternsPerWord is the number of patterns simulated in parallel in one machine word
+const PatternsPerWord = 64
+
+// word holds the values of one line for up to 64 patterns in two-rail form.
+// Bit i of V0 is set when the line is 0 in pattern i, bit i of V1 when it is 1.
+// A bit that is clear in both rails means X.
+type word struct {
+	V0 uint64
+	V1 uint64
+}
+
+// FaultSimulator performs parallel-pattern single-fault propagation (PPSFP):
+// the good machine is simulated for 64 patterns at once, and each fault is then
+// propagated event-driven through its fanout cone only
+type FaultSimulator struct {
+	Circuit  *circuit.Circuit
+	Logger   *utils.Logger
+	Topology *circuit.Topology
+
+	lines     []*circuit.Line       // Lines ordered by ID
+	lineIndex map[*circuit.Line]int // Dense index of every line
+	gateIndex map[*circuit.Gate]int // Dense index of every gate
+	lineLevel []int                 // Level of every line, indexed by lineIndex
+	gates     []*circuit.Gate       // Gates in levelized order
+	maxLevel  int
+
+	good    []word // Good machine values for the current block
+	faulty  []word // Faulty machine values, valid where stamp matches current
+	stamp   []int  // Per-line stamp marking values in faulty as valid
+	queued  []int  // Per-gate stamp marking gates already scheduled
+	current int    // Current stamp
+	buckets [][]*circuit.Gate
+}
+
+// NewFaultSimulator creates a fault simulator for the given circuit.
+// The topology must have its levels computed.
+func NewFaultSimulator(c *circuit.Circuit, topo *circuit.Topology, logger *utils.Logger) *FaultSimulator {
+	s := &FaultSimulator{
+		Circuit:   c,
+		Logger:    logger,
+		Topology:  topo,
+		lineIndex: make(map[*circuit.Line]int),
+		gateIndex: make(map[*circuit.Gate]int),
+	}
+	s.levelize()
+	return s
+}
+
+// levelize assigns dense indices to lines and gates and orders the gates by level
+func (s *FaultSimulator) levelize() {
+	lines := make([]*circuit.Line, 0, len(s.Circuit.Lines))
+	for _, line := range s.Circuit.Lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+
+	s.lines = lines
+	s.maxLevel = s.Topology.MaxLevel
+	s.lineLevel = make([]int, len(lines))
+	for idx, line := range lines {
+		s.lineIndex[line] = idx
+		level, ok := s.Topology.LevelMap[line]
+		if !ok {
+			// Lines without a level (undriven or on a loop) are evaluated last
+			level = s.Topology.MaxLevel + 1
+		}
+		s.lineLevel[idx] = level
+		if level > s.maxLevel {
+			s.maxLevel = level
+		}
+	}
+
+	s.gates = make([]*circuit.Gate, 0, len(s.Circuit.Gates))
+	for _, gate := range s.Circuit.Gates {
+		if gate.Output != nil {
+			s.gates = append(s.gates, gate)
+		}
+	}
+	sort.Slice(s.gates, func(i, j int) bool {
+		li := s.lineLevel[s.lineIndex[s.gates[i].Output]]
+		lj := s.lineLevel[s.lineIndex[s.gates[j].Output]]
+		if li != lj {
+			return li < lj
+		}
+		return s.gates[i].ID < s.gates[j].ID
+	})
+	for idx, gate := range s.gates {
+		s.gateIndex[gate] = idx
+	}
+
+	s.good = make([]word, len(lines))
+	s.faulty = make([]word, len(lines))
+	s.stamp = make([]int, len(lines))
+	s.queued = make([]int, len(s.gates))
+	s.buckets = make([][]*circuit.Gate, s.maxLevel+2)
+}
+
+// Detect fault-simulates the patterns against the faults and returns, for each
+// fault, the index of the first pattern that detects it, or -1 if none does
+func (s *FaultSimulator) Detect(patterns []map[string]circuit.LogicValue, faults []circuit.Fault) []int {
+	return s.detect(patterns, faults, nil)
+}
+
+// DetectConstrained is Detect for faults that a pattern only detects if a line
+// also holds a good value, such as the initial value of a transition fault.
+// constraints[i] is the requirement of faults[i].
+func (s *FaultSimulator) DetectConstrained(patterns []map[string]circuit.LogicValue, faults []circuit.Fault, constraints []circuit.Constraint) []int {
+	return s.detect(patterns, faults, constraints)
+}
+
+// DetectBridges fault-simulates the patterns against bridging faults and returns,
+// for each bridge, the index of the first pattern that detects it, or -1
+func (s *FaultSimulator) DetectBridges(patterns []map[string]circuit.LogicValue, bridges []circuit.Bridge) []int {
+	var faults []circuit.Fault
+	var constraints []circuit.Constraint
+	var owner []int
+	for i, bridge := range bridges {
+		for _, target := range bridge.Targets() {
+			faults = append(faults, target.Fault)
+			constraints = append(constraints, target.Condition)
+			owner = append(owner, i)
+		}
+	}
+
+	// A bridge is detected by the first pattern that detects any of its targets
+	first := make([]int, len(bridges))
+	for i := range first {
+		first[i] = -1
+	}
+	for i, idx := range s.detect(patterns, faults, constraints) {
+		if idx >= 0 && (first[owner[i]] < 0 || idx < first[owner[i]]) {
+			first[owner[i]] = idx
+		}
+	}
+	return first
+}
+
+// detect fault-simulates the patterns against the faults, with an optional constraint per fault
+func (s *FaultSimulator) detect(patterns []map[string]circuit.LogicValue, faults []circuit.Fault, constraints []circuit.Constraint) []int {
+	first := make([]int, len(faults))
+	for i := range first {
+		first[i] = -1
+	}
+
+	for start := 0; start < len(patterns); start += PatternsPerWord {
+		end := start + PatternsPerWord
+		if end > len(patterns) {
+			end = len(patterns)
+		}
+		mask := blockMask(end - start)
+		s.simulateGood(patterns[start:end])
+
+		for i, fault := range faults {
+			if first[i] >= 0 {
+				continue // Already detected by an earlier block
+			}
+			detected := s.propagateFault(fault) & mask
+			if constraints != nil {
+				detected &= s.holds(constraints[i])
+			}
+			if detected != 0 {
+				first[i] = start + bits.TrailingZeros64(detected)
+			}
+		}
+	}
+
+	return first
+}
+
+// DropDetected fault-simulates the patterns and splits the faults into those
+// that remain undetected and those detected (and therefore dropped)
+func (s *FaultSimulator) DropDetected(patterns []map[string]circuit.LogicValue, faults []circuit.Fault) ([]circuit.Fault, []circuit.Fault) {
+	remaining := make([]circuit.Fault, 0, len(faults))
+	dropped := make([]circuit.Fault, 0)
+
+	for i, idx := range s.Detect(patterns, faults) {
+		if idx >= 0 {
+			dropped = append(dropped, faults[i])
+		} else {
+			remaining = append(remaining, faults[i])
+		}
+	}
+
+	s.Logger.Debug("Fault simulation of %d patterns dropped %d of %d faults",
+		len(patterns), len(dropped), len(faults))
+	return remaining, dropped
+}
+
+// Responses simulates the good machine and returns, for each pattern, the
+// values of the outputs keyed by line name. An output that depends on an
+// unassigned input may be X.
+func (s *FaultSimulator) Responses(patterns []map[string]circuit.LogicValue) []map[string]circuit.LogicValue {
+	responses := make([]map[string]circuit.LogicValue, len(patterns))
+	for start := 0; start < len(patterns); start += PatternsPerWord {
+		end := min(start+PatternsPerWord, len(patterns))
+		s.simulateGood(patterns[start:end])
+
+		for i := start; i < end; i++ {
+			bit := uint64(1) << uint(i-start)
+			response := make(map[string]circuit.LogicValue, len(s.Circuit.Outputs))
+			for _, output := range s.Circuit.Outputs {
+				w := s.good[s.lineIndex[output]]
+				switch {
+				case w.V0&bit != 0:
+					response[output.Name] = circuit.Zero
+				case w.V1&bit != 0:
+					response[output.Name] = circuit.One
+				default:
+					response[output.Name] = circuit.X
+				}
+			}
+			responses[i] = response
+		}
+	}
+	return responses
+}
+
+// Failure is an output at which a pattern observes a fault: the good and the
+// faulty machine have opposite binary values there
+type Failure struct {
+	Pattern int    // Index of the pattern
+	Output  string // Name of the output line
+}
+
+// Failures fault-simulates the patterns against the faults and returns, for
+// each fault, every pattern/output pair at which it is observed, ordered by
+// pattern and then in the order of the circuit outputs
+func (s *FaultSimulator) Failures(patterns []map[string]circuit.LogicValue, faults []circuit.Fault) [][]Failure {
+	failures := make([][]Failure, len(faults))
+	diffs := make([]uint64, len(s.Circuit.Outputs))
+	for start := 0; start < len(patterns); start += PatternsPerWord {
+		end := min(start+PatternsPerWord, len(patterns))
+		mask := blockMask(end - start)
+		s.simulateGood(patterns[start:end])
+
+		for i, fault := range faults {
+			if s.propagateFault(fault)&mask == 0 {
+				continue
+			}
+			for j, output := range s.Circuit.Outputs {
+				diffs[j] = s.difference(s.lineIndex[output]) & mask
+			}
+			for bit := 0; bit < end-start; bit++ {
+				for j, output := range s.Circuit.Outputs {
+					if diffs[j]&(1<<uint(bit)) != 0 {
+						failures[i] = append(failures[i], Failure{Pattern: start + bit, Output: output.Name})
+					}
+				}
+			}
+		}
+	}
+	return failures
+}
+
+// GoodValues simulates the good machine and returns, for each pattern, the
+// value of every line. A line that depends on an unassigned input may be X.
+func (s *FaultSimulator) GoodValues(patterns []map[string]circuit.LogicValue) []map[*circuit.Line]circuit.LogicValue {
+	values := make([]map[*circuit.Line]circuit.LogicValue, len(patterns))
+	for start := 0; start < len(patterns); start += PatternsPerWord {
+		end := min(start+PatternsPerWord, len(patterns))
+		s.simulateGood(patterns[start:end])
+
+		for i := start; i < end; i++ {
+			bit := uint64(1) << uint(i-start)
+			lineValues := make(map[*circuit.Line]circuit.LogicValue, len(s.lines))
+			for idx, line := range s.lines {
+				switch w := s.good[idx]; {
+				case w.V0&bit != 0:
+					lineValues[line] = circuit.Zero
+				case w.V1&bit != 0:
+					lineValues[line] = circuit.One
+				default:
+					lineValues[line] = circuit.X
+				}
+			}
+			values[i] = lineValues
+		}
+	}
+	return values
+}
+
+// holds returns the mask of patterns of the current block that meet a constraint
+func (s *FaultSimulator) holds(cons circuit.Constraint) uint64 {
+	w := s.good[s.lineIndex[cons.Line]]
+	if cons.Value == circuit.Zero {
+		return w.V0
+	}
+	return w.V1
+}
+
+// simulateGood loads up to 64 patterns into the primary inputs and simulates the good machine
+func (s *FaultSimulator) simulateGood(patterns []map[string]circuit.LogicValue) {
+	for i := range s.good {
+		s.good[i] = word{}
+	}
+
+	for _, input := range s.Circuit.Inputs {
+		var w word
+		for bit, pattern := range patterns {
+			switch pattern[input.Name] {
+			case circuit.Zero:
+				w.V0 |= 1 << uint(bit)
+			case circuit.One:
+				w.V1 |= 1 << uint(bit)
+			}
+		}
+		s.good[s.lineIndex[input]] = w
+	}
+
+	ins := make([]word, 0, 8)
+	for _, gate := range s.gates {
+		ins = ins[:0]
+		for _, input := range gate.Inputs {
+			ins = append(ins, s.good[s.lineIndex[input]])
+		}
+		s.good[s.lineIndex[gate.Output]] = evalWord(gate, ins)
+	}
+}
+
+// propagateFault injects a stuck-at fault into the current block and propagates
+// it through the fanout cone. It returns the mask of patterns that detect the fault
+// at some primary output.
+func (s *FaultSimulator) propagateFault(fault circuit.Fault) uint64 {
+	s.current++
+	site := s.lineIndex[fault.Line]
+
+	stuck := word{V1: ^uint64(0)}
+	if fault.Type == circuit.Zero {
+		stuck = word{V0: ^uint64(0)}
+	}
+	if stuck == s.good[site] {
+		return 0 // Fault is not activated by any pattern
+	}
+	if fault.Branch != nil {
+		// The stem keeps its good value, only the branch gate sees the stuck value
+		s.schedule(fault.Branch)
+	} else {
+		s.setFaulty(site, stuck)
+	}
+
+	ins := make([]word, 0, 8)
+	for level := s.lineLevel[site]; level < len(s.buckets); level++ {
+		for i := 0; i < len(s.buckets[level]); i++ {
+			gate := s.buckets[level][i]
+			ins = ins[:0]
+			for _, input := range gate.Inputs {
+				if gate == fault.Branch && input == fault.Line {
+					ins = append(ins, stuck)
+					continue
+				}
+				ins = append(ins, s.value(s.lineIndex[input]))
+			}
+			out := s.lineIndex[gate.Output]
+			if out == site {
+				continue // The fault site keeps its stuck value
+			}
+			value := evalWord(gate, ins)
+			if value != s.value(out) {
+				s.setFaulty(out, value)
+			}
+		}
+		s.buckets[level] = s.buckets[level][:0]
+	}
+
+	var detected uint64
+	for _, output := range s.Circuit.Outputs {
+		detected |= s.difference(s.lineIndex[output])
+	}
+	return detected
+}
+
+// difference returns the mask of patterns of the current block for which the
+// good and the faulty machine have opposite binary values on a line
+func (s *FaultSimulator) difference(idx int) uint64 {
+	if s.stamp[idx] != s.current {
+		return 0
+	}
+	g, f := s.good[idx], s.faulty[idx]
+	return (g.V0 & f.V1) | (g.V1 & f.V0)
+}
+
+// setFaulty records a faulty value for a line and schedules its fanout gates
+func (s *FaultSimulator) setFaulty(idx int, value word) {
+	s.faulty[idx] = value
+	s.stamp[idx] = s.current
+
+	for _, gate := range s.lines[idx].OutputGates {
+		s.schedule(gate)
+	}
+}
+
+// schedule queues a gate for evaluation at its level, at most once per fault
+func (s *FaultSimulator) schedule(gate *circuit.Gate) {
+	gi, ok := s.gateIndex[gate]
+	if !ok || s.queued[gi] == s.current {
+		return
+	}
+	s.queued[gi] = s.current
+	level := s.lineLevel[s.lineIndex[gate.Output]]
+	s.buckets[level] = append(s.buckets[level], gate)
+}
+
+// value returns the faulty machine value of a line, falling back to the good value
+func (s *FaultSimulator) value(idx int) word {
+	if s.stamp[idx] == s.current {
+		return s.faulty[idx]
+	}
+	return s.good[idx]
+}
+
+// evalWord evaluates a gate for 64 patterns in two-rail three-valued logic
+func evalWord(gate *circuit.Gate, ins []word) word {
+	switch gate.Type {
+	case circuit.AND:
+		return andWords(ins)
+	case circuit.NAND:
+		return invertWord(andWords(ins))
+	case circuit.OR:
+		return orWords(ins)
+	case circuit.NOR:
+		return invertWord(orWords(ins))
+	case circuit.XOR:
+		return xorWords(ins)
+	case circuit.XNOR:
+		return invertWord(xorWords(ins))
+	case circuit.NOT:
+		if len(ins) != 1 {
+			return word{}
+		}
+		return invertWord(ins[0])
+	case circuit.BUF:
+		if len(ins) != 1 {
+			return word{}
+		}
+		return ins[0]
+	default:
+		if gate.Type.HasTruthTable() {
+			return tableWords(gate, ins)
+		}
+		return word{}
+	}
+}
+
+// tableWords evaluates a cell as the sum of the cubes of each output value
+func tableWords(gate *circuit.Gate, ins []word) word {
+	var result word
+	for _, rail := range []struct {
+		value circuit.LogicValue
+		out   *uint64
+	}{{circuit.Zero, &result.V0}, {circuit.One, &result.V1}} {
+		for _, cube := range gate.Cubes(rail.value) {
+			term := ^uint64(0)
+			for i := 0; i < circuit.MaxTableInputs; i++ {
+				var in word // A missing input is X
+				if i < len(ins) {
+					in = ins[i]
+				}
+				switch cube.Literal(i) {
+				case circuit.Zero:
+					term &= in.V0
+				case circuit.One:
+					term &= in.V1
+				}
+			}
+			*rail.out |= term
+		}
+	}
+	return result
+}
+
+func andWords(ins []word) word {
+	if len(ins) == 0 {
+		return word{}
+	}
+	result := word{V1: ^uint64(0)}
+	for _, in := range ins {
+		result.V0 |= in.V0
+		result.V1 &= in.V1
+	}
+	return result
+}
+
+func orWords(ins []word) word {
+	if len(ins) == 0 {
+		return word{}
+	}
+	result := word{V0: ^uint64(0)}
+	for _, in := range ins {
+		result.V0 &= in.V0
+		result.V1 |= in.V1
+	}
+	return result
+}
+
+func xorWords(ins []word) word {
+	if len(ins) == 0 {
+		return word{}
+	}
+	result := ins[0]
+	for _, in := range ins[1:] {
+		result = word{
+			V0: (result.V0 & in.V0) | (result.V1 & in.V1),
+			V1: (result.V0 & in.V1) | (result.V1 & in.V0),
+		}
+	}
+	return result
+}
+
+func invertWord(w word) word {
+	return word{V0: w.V1, V1: w.V0}
+}
+
+// blockMask returns a mask with the lowest n bits set
+func blockMask(n int) uint64 {
+	if n >= PatternsPerWord {
+		return ^uint64(0)
+	}
+	return (uint64(1) << uint(n)) - 1
+}
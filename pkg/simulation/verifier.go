@@ -0,0 +1,219 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// ErrNotDetected is wrapped by every VerificationError
+var ErrNotDetected = errors.New("test does not detect the fault")
+
+// VerificationError reports a test that does not detect a fault it is claimed to detect
+type VerificationError struct {
+	Fault  circuit.Fault
+	Test   map[string]circuit.LogicValue
+	Reason string
+}
+
+// Error returns a description of the mismatch
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("test %v does not detect %s: %s", e.Test, e.Fault, e.Reason)
+}
+
+// Unwrap returns ErrNotDetected
+func (e *VerificationError) Unwrap() error {
+	return ErrNotDetected
+}
+
+// Verifier checks tests independently of test generation and of the fault
+// simulator. It simulates the good and the faulty machine separately in
+// three-valued logic, from the inputs of the test alone, with unassigned inputs
+// left X, and only accepts a test if an output has a binary value in both
+// machines and the values differ. It keeps no state in the lines of the circuit.
+type Verifier struct {
+	Circuit *circuit.Circuit
+	order   []*circuit.Gate // Gates in topological order
+}
+
+// NewVerifier creates a verifier for a combinational circuit
+func NewVerifier(c *circuit.Circuit) *Verifier {
+	v := &Verifier{Circuit: c}
+	v.sortGates()
+	return v
+}
+
+// sortGates orders the gates so that every gate comes after the gates driving its inputs
+func (v *Verifier) sortGates() {
+	pending := make(map[*circuit.Gate]int, len(v.Circuit.Gates))
+	var ready []*circuit.Gate
+	for _, gate := range v.Circuit.SortedGates() {
+		for _, input := range gate.Inputs {
+			if input.InputGate != nil {
+				pending[gate]++
+			}
+		}
+		if pending[gate] == 0 {
+			ready = append(ready, gate)
+		}
+	}
+
+	for len(ready) > 0 {
+		gate := ready[0]
+		ready = ready[1:]
+		v.order = append(v.order, gate)
+		// A gate is listed once for every input pin the output drives
+		for _, next := range gate.Output.OutputGates {
+			pending[next]--
+			if pending[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+}
+
+// Verify returns nil if the test detects the fault, and a *VerificationError
+// otherwise. A test only detects a constrained fault, such as the stuck-at
+// target of a transition fault, if every constraint holds in the good machine.
+func (v *Verifier) Verify(test map[string]circuit.LogicValue, fault circuit.Fault, constraints ...circuit.Constraint) error {
+	fail := func(format string, args ...interface{}) error {
+		return &VerificationError{Fault: fault, Test: test, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	good := v.simulate(test, nil)
+	for _, cons := range constraints {
+		if good[cons.Line] != cons.Value {
+			return fail("%s is %v instead of %v", cons.Line.Name, good[cons.Line], cons.Value)
+		}
+	}
+	if good[fault.Line] != invert(fault.Type) {
+		return fail("%s is %v, the fault is not activated", fault.Line.Name, good[fault.Line])
+	}
+
+	faulty := v.simulate(test, &fault)
+	for _, output := range v.Circuit.Outputs {
+		g, f := good[output], faulty[output]
+		if g != circuit.X && f != circuit.X && g != f {
+			return nil
+		}
+	}
+	return fail("no output differs between the good and the faulty machine")
+}
+
+// Detects reports whether the test detects the fault, see Verify
+func (v *Verifier) Detects(test map[string]circuit.LogicValue, fault circuit.Fault, constraints ...circuit.Constraint) bool {
+	return v.Verify(test, fault, constraints...) == nil
+}
+
+// simulate returns the value of every line for a test, in the good machine if
+// fault is nil and in the machine with that fault otherwise
+func (v *Verifier) simulate(test map[string]circuit.LogicValue, fault *circuit.Fault) map[*circuit.Line]circuit.LogicValue {
+	values := make(map[*circuit.Line]circuit.LogicValue, len(v.Circuit.Lines))
+	for _, input := range v.Circuit.Inputs {
+		values[input] = binary(test[input.Name])
+	}
+	stem := fault != nil && fault.Branch == nil
+	if stem {
+		values[fault.Line] = fault.Type
+	}
+
+	ins := make([]circuit.LogicValue, 0, 8)
+	for _, gate := range v.order {
+		if stem && gate.Output == fault.Line {
+			continue // The fault site keeps its stuck value
+		}
+		ins = ins[:0]
+		for _, input := range gate.Inputs {
+			value, ok := values[input]
+			if !ok {
+				value = circuit.X // Undriven line
+			}
+			if fault != nil && gate == fault.Branch && input == fault.Line {
+				value = fault.Type
+			}
+			ins = append(ins, value)
+		}
+		values[gate.Output] = evaluate(gate, ins)
+	}
+	return values
+}
+
+// evaluate computes the output of a gate in three-valued logic
+func evaluate(gate *circuit.Gate, ins []circuit.LogicValue) circuit.LogicValue {
+	switch gateType := gate.Type; gateType {
+	case circuit.AND, circuit.NAND:
+		out := circuit.One
+		for _, in := range ins {
+			if in == circuit.Zero {
+				out = circuit.Zero
+				break
+			}
+			if in == circuit.X {
+				out = circuit.X
+			}
+		}
+		if gateType == circuit.NAND {
+			return invert(out)
+		}
+		return out
+	case circuit.OR, circuit.NOR:
+		out := circuit.Zero
+		for _, in := range ins {
+			if in == circuit.One {
+				out = circuit.One
+				break
+			}
+			if in == circuit.X {
+				out = circuit.X
+			}
+		}
+		if gateType == circuit.NOR {
+			return invert(out)
+		}
+		return out
+	case circuit.XOR, circuit.XNOR:
+		out := circuit.Zero
+		for _, in := range ins {
+			if in == circuit.X {
+				return circuit.X
+			}
+			if in == circuit.One {
+				out = invert(out)
+			}
+		}
+		if gateType == circuit.XNOR {
+			return invert(out)
+		}
+		return out
+	case circuit.NOT:
+		return invert(ins[0])
+	case circuit.BUF:
+		return ins[0]
+	default:
+		if gateType.HasTruthTable() {
+			return gate.TableValue(ins)
+		}
+		return circuit.X
+	}
+}
+
+// binary maps a test value to 0, 1 or X
+func binary(value circuit.LogicValue) circuit.LogicValue {
+	if value == circuit.Zero || value == circuit.One {
+		return value
+	}
+	return circuit.X
+}
+
+// invert returns the complement of 0 or 1, and X for X
+func invert(value circuit.LogicValue) circuit.LogicValue {
+	switch value {
+	case circuit.Zero:
+		return circuit.One
+	case circuit.One:
+		return circuit.Zero
+	default:
+		return circuit.X
+	}
+}
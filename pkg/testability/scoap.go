@@ -0,0 +1,186 @@
+package testability
+
+import (
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// Unreachable is the measure of a line that cannot be controlled or observed,
+// such as an undriven line or a line without a path to a primary output
+const Unreachable = 1 << 30
+
+// Measures holds the SCOAP testability measures of every line of a circuit.
+// CC0 and CC1 count the effort to set a line to 0 or 1, CO the effort to
+// observe it at a primary output. Lower values are easier.
+type Measures struct {
+	CC0 map[*circuit.Line]int
+	CC1 map[*circuit.Line]int
+	CO  map[*circuit.Line]int
+}
+
+// Compute calculates the SCOAP measures of a circuit. Controllability is
+// computed from the primary inputs in level order, observability from the
+// primary outputs in reverse level order.
+func Compute(c *circuit.Circuit, topo *circuit.Topology) *Measures {
+	m := &Measures{
+		CC0: make(map[*circuit.Line]int),
+		CC1: make(map[*circuit.Line]int),
+		CO:  make(map[*circuit.Line]int),
+	}
+
+	for _, line := range c.Lines {
+		m.CC0[line] = Unreachable
+		m.CC1[line] = Unreachable
+		m.CO[line] = Unreachable
+	}
+	for _, input := range c.Inputs {
+		m.CC0[input] = 1
+		m.CC1[input] = 1
+	}
+
+	gates := topo.LevelizedGates()
+	for _, gate := range gates {
+		if gate.Output != nil && (len(gate.Inputs) > 0 || gate.Type.HasTruthTable()) {
+			m.CC0[gate.Output], m.CC1[gate.Output] = m.gateControllability(gate)
+		}
+	}
+
+	for _, output := range c.Outputs {
+		m.CO[output] = 0
+	}
+	for i := len(gates) - 1; i >= 0; i-- {
+		gate := gates[i]
+		for _, input := range gate.Inputs {
+			// A stem is as observable as its most observable branch
+			if co := m.InputObservability(gate, input); co < m.CO[input] {
+				m.CO[input] = co
+			}
+		}
+	}
+
+	// Cache the input that is easiest to set to the controlling value
+	for _, gate := range c.Gates {
+		gate.FindEasiestControlInput(m.Controllability)
+	}
+
+	return m
+}
+
+// Controllability returns the effort to set a line to a value
+func (m *Measures) Controllability(line *circuit.Line, value circuit.LogicValue) int {
+	if value == circuit.Zero {
+		return m.CC0[line]
+	}
+	return m.CC1[line]
+}
+
+// Observability returns the effort to observe a line at a primary output
+func (m *Measures) Observability(line *circuit.Line) int {
+	return m.CO[line]
+}
+
+// InputObservability returns the effort to observe one input of a gate, that is
+// the fanout branch of the input line into this gate
+func (m *Measures) InputObservability(gate *circuit.Gate, input *circuit.Line) int {
+	co := m.CO[gate.Output]
+	if gate.Type.HasTruthTable() {
+		// A cell needs the side inputs of its easiest sensitizing cube
+		for i := range gate.Inputs {
+			if gate.Inputs[i] == input {
+				return add(add(co, m.cubeControllability(gate, gate.SensitizingCubes(i))), 1)
+			}
+		}
+	}
+	for _, side := range gate.Inputs {
+		if side == input {
+			continue
+		}
+		switch gate.Type {
+		case circuit.AND, circuit.NAND:
+			co = add(co, m.CC1[side])
+		case circuit.OR, circuit.NOR:
+			co = add(co, m.CC0[side])
+		case circuit.XOR, circuit.XNOR:
+			co = add(co, min(m.CC0[side], m.CC1[side]))
+		}
+	}
+	return add(co, 1)
+}
+
+// gateControllability returns CC0 and CC1 of a gate output
+func (m *Measures) gateControllability(gate *circuit.Gate) (int, int) {
+	switch gate.Type {
+	case circuit.AND, circuit.NAND:
+		cc0, cc1 := Unreachable, 0
+		for _, input := range gate.Inputs {
+			cc0 = min(cc0, m.CC0[input])
+			cc1 = add(cc1, m.CC1[input])
+		}
+		if gate.Type == circuit.NAND {
+			cc0, cc1 = cc1, cc0
+		}
+		return add(cc0, 1), add(cc1, 1)
+
+	case circuit.OR, circuit.NOR:
+		cc0, cc1 := 0, Unreachable
+		for _, input := range gate.Inputs {
+			cc0 = add(cc0, m.CC0[input])
+			cc1 = min(cc1, m.CC1[input])
+		}
+		if gate.Type == circuit.NOR {
+			cc0, cc1 = cc1, cc0
+		}
+		return add(cc0, 1), add(cc1, 1)
+
+	case circuit.XOR, circuit.XNOR:
+		// Fold the inputs pairwise: even parity gives 0, odd parity gives 1
+		cc0, cc1 := m.CC0[gate.Inputs[0]], m.CC1[gate.Inputs[0]]
+		for _, input := range gate.Inputs[1:] {
+			in0, in1 := m.CC0[input], m.CC1[input]
+			cc0, cc1 = min(add(cc0, in0), add(cc1, in1)), min(add(cc0, in1), add(cc1, in0))
+		}
+		if gate.Type == circuit.XNOR {
+			cc0, cc1 = cc1, cc0
+		}
+		return add(cc0, 1), add(cc1, 1)
+
+	case circuit.NOT:
+		return add(m.CC1[gate.Inputs[0]], 1), add(m.CC0[gate.Inputs[0]], 1)
+
+	case circuit.MUX, circuit.TIE0, circuit.TIE1, circuit.AOI21, circuit.AOI22,
+		circuit.OAI21, circuit.OAI22, circuit.LUT:
+		// The easiest cube that gives the value, a tie cell costs nothing
+		cc0 := m.cubeControllability(gate, gate.Cubes(circuit.Zero))
+		cc1 := m.cubeControllability(gate, gate.Cubes(circuit.One))
+		return add(cc0, 1), add(cc1, 1)
+
+	default: // BUF
+		return add(m.CC0[gate.Inputs[0]], 1), add(m.CC1[gate.Inputs[0]], 1)
+	}
+}
+
+// cubeControllability returns the effort to set the inputs of a cell to the
+// easiest of some cubes, Unreachable if there is none
+func (m *Measures) cubeControllability(gate *circuit.Gate, cubes []circuit.Cube) int {
+	best := Unreachable
+	for _, cube := range cubes {
+		cost := 0
+		for i, input := range gate.Inputs {
+			switch cube.Literal(i) {
+			case circuit.Zero:
+				cost = add(cost, m.CC0[input])
+			case circuit.One:
+				cost = add(cost, m.CC1[input])
+			}
+		}
+		best = min(best, cost)
+	}
+	return best
+}
+
+// add sums two measures, saturating at Unreachable
+func add(a, b int) int {
+	if a >= Unreachable || b >= Unreachable {
+		return Unreachable
+	}
+	return min(a+b, Unreachable)
+}
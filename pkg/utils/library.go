@@ -0,0 +1,605 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// pinRegex matches a cell or pin name of a cell library
+var pinRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CellLibrary maps the cells of a technology library to the gates they are
+// made of, so that netlists written in the cells of a foundry library can be
+// read. It is loaded from a JSON file by LoadCellLibrary.
+type CellLibrary struct {
+	Name  string
+	Cells map[string]*Cell
+}
+
+// Cell is a cell of a library: its pins and the gates its instances are
+// expanded into
+type Cell struct {
+	Name    string
+	Inputs  []string
+	Outputs []string
+
+	gates []cellGate // Logic over the pins and internal nets, in topological order
+	nets  []string   // Internal nets, driven by the gates or the flip-flop
+	flop  [2]string  // Q and D of a flip-flop cell, empty for a combinational cell
+}
+
+// cellGate is a gate of a cell or of an expanded instance
+type cellGate struct {
+	name   string // Gate name, only set once expanded
+	typ    circuit.GateType
+	table  uint64 // Truth table of a LUT
+	output string
+	inputs []string
+}
+
+// cellSpec is a cell as the library file describes it. Exactly one of
+// Functions, Netlist and FlipFlop gives its logic.
+type cellSpec struct {
+	Name      string            `json:"name"`
+	Inputs    []string          `json:"inputs"`
+	Outputs   []string          `json:"outputs"`
+	Functions map[string]string `json:"functions"` // Liberty function of each output pin
+	Netlist   []string          `json:"netlist"`   // BENCH gate statements over the pins and internal nets
+	FlipFlop  *struct {
+		D  string `json:"d"`
+		Q  string `json:"q"`
+		QN string `json:"qn"`
+	} `json:"flipflop"`
+}
+
+// IsFlipFlop reports whether the cell is a flip-flop, read as a scan cell
+func (cell *Cell) IsFlipFlop() bool {
+	return cell.flop != [2]string{}
+}
+
+// Cell returns the cell of the given name, or nil if the library, which may be
+// nil, has none
+func (lib *CellLibrary) Cell(name string) *Cell {
+	if lib == nil {
+		return nil
+	}
+	return lib.Cells[name]
+}
+
+// LoadCellLibrary reads a cell library in JSON format:
+//
+//	{"name": "lib", "cells": [
+//	  {"name": "NAND2X1", "inputs": ["A", "B"], "outputs": ["Y"], "functions": {"Y": "!(A & B)"}},
+//	  {"name": "MX2X1", "inputs": ["A", "B", "S0"], "outputs": ["Y"],
+//	   "netlist": ["t0 = AND(A, sn)", "t1 = AND(B, S0)", "sn = NOT(S0)", "Y = OR(t0, t1)"]},
+//	  {"name": "DFFRX1", "inputs": ["D", "CK", "RN"], "outputs": ["Q", "QN"],
+//	   "flipflop": {"d": "D", "q": "Q", "qn": "QN"}}]}
+//
+// A function uses the Liberty operators: ! or a trailing ' for NOT, ^ for XOR,
+// & or * or a space for AND, | or + for OR, in decreasing precedence, and the
+// constants 0 and 1. An output whose function depends on at most
+// circuit.MaxTableInputs inputs becomes one gate, a primitive if the function
+// is one over the inputs in order and a LUT otherwise; a larger function needs
+// a netlist. A netlist takes the gate statements of a BENCH file. A flip-flop
+// is a scan cell capturing its D input, the other inputs such as the clock are
+// left unused.
+func LoadCellLibrary(filename string) (*CellLibrary, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	name := filepath.Base(filename)
+
+	var file struct {
+		Name  string     `json:"name"`
+		Cells []cellSpec `json:"cells"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return nil, fmt.Errorf("%s:%d: %v", name, 1+bytes.Count(content[:syntaxErr.Offset], []byte("\n")), err)
+		case errors.As(err, &typeErr):
+			return nil, fmt.Errorf("%s:%d: %v", name, 1+bytes.Count(content[:typeErr.Offset], []byte("\n")), err)
+		}
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	lib := &CellLibrary{Name: file.Name, Cells: make(map[string]*Cell, len(file.Cells))}
+	for _, spec := range file.Cells {
+		if _, exists := lib.Cells[spec.Name]; exists {
+			return nil, fmt.Errorf("%s: cell %s is defined twice", name, spec.Name)
+		}
+		cell, err := newCell(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cell %s: %v", name, spec.Name, err)
+		}
+		lib.Cells[spec.Name] = cell
+	}
+	return lib, nil
+}
+
+// newCell checks the pins of a cell and builds its gates
+func newCell(spec cellSpec) (*Cell, error) {
+	if !pinRegex.MatchString(spec.Name) {
+		return nil, fmt.Errorf("invalid cell name %q", spec.Name)
+	}
+	if len(spec.Outputs) == 0 {
+		return nil, fmt.Errorf("no output pins")
+	}
+	pins := make(map[string]bool)
+	for _, pin := range append(append([]string{}, spec.Inputs...), spec.Outputs...) {
+		if !pinRegex.MatchString(pin) {
+			return nil, fmt.Errorf("invalid pin name %q", pin)
+		}
+		if pins[pin] {
+			return nil, fmt.Errorf("pin %s is declared twice", pin)
+		}
+		pins[pin] = true
+	}
+
+	cell := &Cell{Name: spec.Name, Inputs: spec.Inputs, Outputs: spec.Outputs}
+	given := 0
+	for _, set := range []bool{spec.Functions != nil, spec.Netlist != nil, spec.FlipFlop != nil} {
+		if set {
+			given++
+		}
+	}
+	var err error
+	switch {
+	case given != 1:
+		return nil, fmt.Errorf("give exactly one of functions, netlist and flipflop")
+	case spec.Functions != nil:
+		err = cell.compileFunctions(spec.Functions)
+	case spec.Netlist != nil:
+		err = cell.compileNetlist(spec.Netlist)
+	default:
+		err = cell.compileFlipFlop(spec.FlipFlop.D, spec.FlipFlop.Q, spec.FlipFlop.QN)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cell, nil
+}
+
+// compileFunctions builds a gate per output from its function
+func (cell *Cell) compileFunctions(functions map[string]string) error {
+	for pin := range functions {
+		if indexOf(cell.Outputs, pin) < 0 {
+			return fmt.Errorf("function of %s, which is not an output pin", pin)
+		}
+	}
+	for _, pin := range cell.Outputs {
+		function, ok := functions[pin]
+		if !ok {
+			return fmt.Errorf("output %s has no function", pin)
+		}
+		f, support, err := parseFunction(function, cell.Inputs)
+		if err != nil {
+			return fmt.Errorf("function of %s: %v", pin, err)
+		}
+		if len(support) > circuit.MaxTableInputs {
+			return fmt.Errorf("function of %s depends on %d inputs, more than %d; give the cell a netlist",
+				pin, len(support), circuit.MaxTableInputs)
+		}
+		cell.gates = append(cell.gates, functionGate(pin, f, support, cell.Inputs))
+	}
+	return nil
+}
+
+// compileNetlist builds the gates of a netlist, checking that every net is
+// driven once and that the gates can be ordered
+func (cell *Cell) compileNetlist(netlist []string) error {
+	drivers := make(map[string]*benchGate)
+	var statements []*benchGate
+	for i, statement := range netlist {
+		g, err := parseGateStatement(i+1, strings.TrimSpace(statement), nil)
+		if err != nil {
+			return fmt.Errorf("netlist statement %d: %v", i+1, err)
+		}
+		if g.typeName == "DFF" {
+			return fmt.Errorf("netlist statement %d: a flip-flop cell needs a flipflop instead of a netlist", i+1)
+		}
+		if _, err := parseGateType(g.typeName); err != nil {
+			return fmt.Errorf("netlist statement %d: %v", i+1, err)
+		}
+		if problem := g.arityProblem(); problem != "" {
+			return fmt.Errorf("netlist statement %d: %s", i+1, problem)
+		}
+		switch {
+		case indexOf(cell.Inputs, g.output) >= 0:
+			return fmt.Errorf("netlist statement %d drives input pin %s", i+1, g.output)
+		case drivers[g.output] != nil:
+			return fmt.Errorf("netlist statement %d drives %s, already driven by statement %d", i+1, g.output, drivers[g.output].srcLine)
+		}
+		drivers[g.output] = g
+		statements = append(statements, g)
+	}
+	for _, g := range statements {
+		for _, input := range g.inputs {
+			if drivers[input] == nil && indexOf(cell.Inputs, input) < 0 {
+				return fmt.Errorf("netlist statement %d reads %s, which is neither an input pin nor driven", g.srcLine, input)
+			}
+		}
+	}
+	for _, pin := range cell.Outputs {
+		if drivers[pin] == nil {
+			return fmt.Errorf("output %s is not driven by the netlist", pin)
+		}
+	}
+
+	// Order the gates so that each comes after the gates driving its inputs
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*benchGate]int)
+	var visit func(g *benchGate) error
+	visit = func(g *benchGate) error {
+		switch state[g] {
+		case visiting:
+			return fmt.Errorf("netlist has a combinational loop through %s", g.output)
+		case done:
+			return nil
+		}
+		state[g] = visiting
+		for _, input := range g.inputs {
+			if driver := drivers[input]; driver != nil {
+				if err := visit(driver); err != nil {
+					return err
+				}
+			}
+		}
+		state[g] = done
+
+		gateType, _ := parseGateType(g.typeName)
+		cell.gates = append(cell.gates, cellGate{typ: gateType, table: g.table, output: g.output, inputs: g.inputs})
+		if indexOf(cell.Outputs, g.output) < 0 {
+			cell.nets = append(cell.nets, g.output)
+		}
+		return nil
+	}
+	for _, g := range statements {
+		if err := visit(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileFlipFlop sets the pins of a flip-flop. Q is internal if only QN is an
+// output, which is driven by an inverter from Q.
+func (cell *Cell) compileFlipFlop(d, q, qn string) error {
+	switch {
+	case indexOf(cell.Inputs, d) < 0:
+		return fmt.Errorf("flip-flop input %q is not an input pin", d)
+	case q == "" && qn == "":
+		return fmt.Errorf("flip-flop has neither q nor qn")
+	case q != "" && indexOf(cell.Outputs, q) < 0:
+		return fmt.Errorf("flip-flop output %q is not an output pin", q)
+	case qn != "" && indexOf(cell.Outputs, qn) < 0:
+		return fmt.Errorf("flip-flop output %q is not an output pin", qn)
+	}
+	for _, pin := range cell.Outputs {
+		if pin != q && pin != qn {
+			return fmt.Errorf("output %s is neither q nor qn of the flip-flop", pin)
+		}
+	}
+
+	if q == "" {
+		q = qn + "$Q"
+		cell.nets = append(cell.nets, q)
+	}
+	cell.flop = [2]string{q, d}
+	if qn != "" {
+		cell.gates = append(cell.gates, cellGate{typ: circuit.NOT, output: qn, inputs: []string{q}})
+	}
+	return nil
+}
+
+// cellInstance is an instance of a cell expanded into the gates of a netlist
+type cellInstance struct {
+	gates []cellGate  // Gates over the nets of the netlist, in topological order
+	nets  []string    // Nets created for the internal nets and the unconnected pins
+	flops [][2]string // Q and D net of the flip-flop of the cell
+}
+
+// expand returns the gates of an instance whose pins connect the nets given by
+// pins. An internal net or an unconnected pin P of instance u becomes the net
+// "u/P", so that faults inside a cell are reported under the instance name. A
+// cell of one gate gives it the instance name, otherwise each gate is named
+// after the instance and the net it drives.
+func (cell *Cell) expand(instance string, pins map[string]string) *cellInstance {
+	inst := &cellInstance{}
+	net := func(name string) string {
+		if connected, ok := pins[name]; ok {
+			return connected
+		}
+		return instance + "/" + name
+	}
+	for _, pin := range append(append([]string{}, cell.Inputs...), cell.Outputs...) {
+		if _, ok := pins[pin]; !ok {
+			inst.nets = append(inst.nets, net(pin))
+		}
+	}
+	for _, name := range cell.nets {
+		inst.nets = append(inst.nets, net(name))
+	}
+
+	for _, g := range cell.gates {
+		expanded := cellGate{name: instance, typ: g.typ, table: g.table, output: net(g.output)}
+		if len(cell.gates) > 1 {
+			expanded.name = instance + "/" + g.output
+		}
+		for _, input := range g.inputs {
+			expanded.inputs = append(expanded.inputs, net(input))
+		}
+		inst.gates = append(inst.gates, expanded)
+	}
+	if cell.IsFlipFlop() {
+		inst.flops = append(inst.flops, [2]string{net(cell.flop[0]), net(cell.flop[1])})
+	}
+	return inst
+}
+
+// functionGate returns the gate computing a function over the cell inputs it
+// depends on, which are given by their index in inputs
+func functionGate(output string, f func(values uint64) bool, support []int, inputs []string) cellGate {
+	// Inputs the function only mentions are left out
+	table := supportTable(f, support)
+	var used []int
+	for i, input := range support {
+		dependent := false
+		for m := uint64(0); m < 1<<uint(len(support)) && !dependent; m++ {
+			dependent = table>>m&1 != table>>(m^1<<uint(i))&1
+		}
+		if dependent {
+			used = append(used, input)
+		}
+	}
+	table = supportTable(f, used)
+
+	g := cellGate{typ: tableGateType(table, len(used)), output: output}
+	if g.typ == circuit.LUT {
+		g.table = table
+	}
+	for _, input := range used {
+		g.inputs = append(g.inputs, inputs[input])
+	}
+	return g
+}
+
+// supportTable returns the truth table of a function over the inputs of the
+// given indexes, the first being the lowest bit
+func supportTable(f func(values uint64) bool, support []int) uint64 {
+	var table uint64
+	for m := uint64(0); m < 1<<uint(len(support)); m++ {
+		var values uint64
+		for i, input := range support {
+			values |= (m >> uint(i) & 1) << uint(input)
+		}
+		if f(values) {
+			table |= 1 << m
+		}
+	}
+	return table
+}
+
+// tableGateType returns the gate type that computes a truth table over n
+// inputs in order, LUT if no other type does
+func tableGateType(table uint64, n int) circuit.GateType {
+	size := uint(1) << uint(n)
+	all := ^uint64(0) >> (64 - size)
+	switch {
+	case n == 0 && table == 0:
+		return circuit.TIE0
+	case n == 0:
+		return circuit.TIE1
+	case n == 1 && table == 0b10:
+		return circuit.BUF
+	case n == 1 && table == 0b01:
+		return circuit.NOT
+	case n == 1:
+		return circuit.LUT
+	case table == 1<<(size-1):
+		return circuit.AND
+	case table == all^1<<(size-1):
+		return circuit.NAND
+	case table == all^1:
+		return circuit.OR
+	case table == 1:
+		return circuit.NOR
+	case n == 2 && table == 0b0110:
+		return circuit.XOR
+	case n == 2 && table == 0b1001:
+		return circuit.XNOR
+	}
+	for _, gateType := range []circuit.GateType{circuit.MUX, circuit.AOI21, circuit.AOI22, circuit.OAI21, circuit.OAI22} {
+		if gateType.Arity() == n && circuit.NewGate(0, "", gateType).Table == table {
+			return gateType
+		}
+	}
+	return circuit.LUT
+}
+
+// indexOf returns the index of a name in a list, or -1
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// functionParser is a recursive-descent parser for Liberty function strings.
+// Each expression is a function of the input values, bit i being the value of
+// input i.
+type functionParser struct {
+	tokens  []string
+	pos     int
+	inputs  []string
+	support map[int]bool // Inputs the function mentions
+}
+
+// functionTokenRegex splits a Liberty function into names, constants and operators
+var functionTokenRegex = regexp.MustCompile(`\s*([A-Za-z_][A-Za-z0-9_]*|[01]|[!'&*|+^()]|\S)`)
+
+// parseFunction parses a Liberty function over the given inputs. It returns
+// the function and the indexes of the inputs it mentions, in order.
+func parseFunction(function string, inputs []string) (func(values uint64) bool, []int, error) {
+	p := &functionParser{inputs: inputs, support: make(map[int]bool)}
+	for _, match := range functionTokenRegex.FindAllStringSubmatch(function, -1) {
+		p.tokens = append(p.tokens, match[1])
+	}
+	if len(p.tokens) == 0 {
+		return nil, nil, fmt.Errorf("empty function")
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], function)
+	}
+
+	var support []int
+	for i := range inputs {
+		if p.support[i] {
+			support = append(support, i)
+		}
+	}
+	return f, support, nil
+}
+
+// peek returns the current token, or "" at the end of the function
+func (p *functionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parseOr parses "a | b" and "a + b" chains, the lowest-precedence operator
+func (p *functionParser) parseOr() (func(uint64) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "|" || p.peek() == "+" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		a := left
+		left = func(v uint64) bool { return a(v) || right(v) }
+	}
+	return left, nil
+}
+
+// parseAnd parses "a & b", "a * b" and "a b" chains
+func (p *functionParser) parseAnd() (func(uint64) bool, error) {
+	left, err := p.parseXor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch next := p.peek(); {
+		case next == "&" || next == "*":
+			p.pos++
+		case next == "" || strings.Contains("|+)^'", next):
+			return left, nil
+		}
+		right, err := p.parseXor()
+		if err != nil {
+			return nil, err
+		}
+		a := left
+		left = func(v uint64) bool { return a(v) && right(v) }
+	}
+}
+
+// parseXor parses "a ^ b" chains
+func (p *functionParser) parseXor() (func(uint64) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "^" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		a := left
+		left = func(v uint64) bool { return a(v) != right(v) }
+	}
+	return left, nil
+}
+
+// parseUnary parses negations, parentheses, constants and input pins
+func (p *functionParser) parseUnary() (func(uint64) bool, error) {
+	var f func(uint64) bool
+	switch token := p.peek(); {
+	case token == "!":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v uint64) bool { return !operand(v) }, nil
+
+	case token == "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		f = inner
+
+	case token == "0" || token == "1":
+		p.pos++
+		value := token == "1"
+		f = func(uint64) bool { return value }
+
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of function")
+
+	default:
+		input := indexOf(p.inputs, token)
+		if input < 0 {
+			if pinRegex.MatchString(token) {
+				return nil, fmt.Errorf("%s is not an input pin", token)
+			}
+			return nil, fmt.Errorf("unexpected %q", token)
+		}
+		p.pos++
+		p.support[input] = true
+		f = func(v uint64) bool { return v>>uint(input)&1 == 1 }
+	}
+
+	// A trailing ' inverts the operand before it
+	for p.peek() == "'" {
+		p.pos++
+		operand := f
+		f = func(v uint64) bool { return !operand(v) }
+	}
+	return f, nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -44,7 +45,8 @@ type Logger struct {
 	ShowTime   bool
 	Prefix     string
 	IndentSize int
-	indent     int // Current indentation level
+	indent     int         // Current indentation level
+	mu         *sync.Mutex // Serializes writes of loggers sharing an output
 }
 
 // NewLogger creates a new logger with the specified verbosity level
@@ -55,6 +57,7 @@ func NewLogger(level LogLevel) *Logger {
 		ShowTime:   true,
 		IndentSize: 2,
 		indent:     0,
+		mu:         &sync.Mutex{},
 	}
 }
 
@@ -71,6 +74,7 @@ func NewFileLogger(level LogLevel, filename string) (*Logger, error) {
 		ShowTime:   true,
 		IndentSize: 2,
 		indent:     0,
+		mu:         &sync.Mutex{},
 	}, nil
 }
 
@@ -79,6 +83,22 @@ func (l *Logger) SetOutput(w io.Writer) {
 	l.Output = w
 }
 
+// Fork returns a logger with its own prefix and indentation that writes to the
+// same output. Forked loggers can be used from different goroutines.
+func (l *Logger) Fork(prefix string) *Logger {
+	if l.mu == nil {
+		l.mu = &sync.Mutex{}
+	}
+	return &Logger{
+		Level:      l.Level,
+		Output:     l.Output,
+		ShowTime:   l.ShowTime,
+		Prefix:     prefix,
+		IndentSize: l.IndentSize,
+		mu:         l.mu,
+	}
+}
+
 // SetPrefix sets a prefix for all log messages
 func (l *Logger) SetPrefix(prefix string) {
 	l.Prefix = prefix
@@ -131,6 +151,10 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	builder.WriteString(fmt.Sprintf(format, args...))
 	builder.WriteString("\n")
 
+	if l.mu != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
 	fmt.Fprint(l.Output, builder.String())
 }
 
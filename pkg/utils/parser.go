@@ -6,39 +6,80 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/fyerfyer/fan-atpg/pkg/circuit"
 )
 
-// Regular expressions for parsing BENCH format
+// Regular expressions for parsing BENCH format. A net name is any run of
+// characters other than white space, parentheses, commas and equal signs, so
+// that generated names such as "22@xor" or "a[3]" read back. A LUT gives its
+// truth table in hex after the type, as in "z = LUT 0x8 (a, b)", and a tie
+// cell has no inputs, as in "z = TIE1()".
 var (
-	inputRegex  = regexp.MustCompile(`^INPUT\((\w+)\)$`)
-	outputRegex = regexp.MustCompile(`^OUTPUT\((\w+)\)$`)
-	gateRegex   = regexp.MustCompile(`^(\w+)\s*=\s*(\w+)\((.+)\)$`)
+	inputRegex  = regexp.MustCompile(`^INPUT\s*\(\s*([^\s(),=]+)\s*\)$`)
+	outputRegex = regexp.MustCompile(`^OUTPUT\s*\(\s*([^\s(),=]+)\s*\)$`)
+	gateRegex   = regexp.MustCompile(`^([^\s(),=]+)\s*=\s*(\w+)(?:\s+(0[xX][0-9A-Fa-f]+))?\s*\((.*)\)$`)
+	netRegex    = regexp.MustCompile(`^[^\s(),=]+$`)
 )
 
-// ParseBenchFile reads a circuit description in BENCH format and returns a Circuit object
+// ParseBenchFile reads a circuit description in BENCH format and returns a
+// Circuit object. The netlist is validated with the default policy, so any
+// problem but an unused net is an error.
 func ParseBenchFile(filename string) (*circuit.Circuit, error) {
+	c, _, err := ParseBenchFileWithPolicy(filename, DefaultValidationPolicy())
+	return c, err
+}
+
+// ParseBenchFileWithPolicy reads a circuit description in BENCH format,
+// validating it with the given policy. It returns every problem reported,
+// warnings included, and a *ValidationError listing those of Error severity if
+// there are any.
+func ParseBenchFileWithPolicy(filename string, policy ValidationPolicy) (*circuit.Circuit, []Diagnostic, error) {
+	return ParseBenchFileWithLibrary(filename, policy, nil)
+}
+
+// ParseBenchFileWithLibrary is ParseBenchFileWithPolicy for a netlist that
+// instantiates the cells of a library, as in "z = NAND2X1(a, b)". The inputs
+// connect the input pins of the cell in order and the output net its first
+// output; any other output is left unconnected. Every instance is expanded
+// into gates, named after the output net as the instance.
+func ParseBenchFileWithLibrary(filename string, policy ValidationPolicy, library *CellLibrary) (*circuit.Circuit, []Diagnostic, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
 	// Extract circuit name from filename
 	circuitName := strings.TrimSuffix(filepath.Base(filename), ".bench")
 	c := circuit.NewCircuit(circuitName)
+	n := &benchNetlist{
+		filename: filepath.Base(filename),
+		declared: make(map[string]int),
+		policy:   policy,
+	}
 
 	// Maps to store line names to their IDs for easy lookup
 	lineMap := make(map[string]*circuit.Line)
-	gateMap := make(map[string]*circuit.Gate)
 	nextLineID := 0
 	nextGateID := 0
+	addLine := func(name string, lineType circuit.LineType) *circuit.Line {
+		l := circuit.NewLine(nextLineID, name, lineType)
+		lineMap[name] = l
+		c.AddLine(l)
+		nextLineID++
+		return l
+	}
 
-	// First pass: identify all lines (inputs, outputs, and internal wires)
+	// First pass: collect the statements and identify all lines (inputs,
+	// outputs, and internal wires)
 	scanner := bufio.NewScanner(file)
+	srcLine := 0
 	for scanner.Scan() {
+		srcLine++
 		line := strings.TrimSpace(scanner.Text())
 
 		// Skip comments and empty lines
@@ -50,10 +91,9 @@ func ParseBenchFile(filename string) (*circuit.Circuit, error) {
 		if matches := inputRegex.FindStringSubmatch(line); matches != nil {
 			lineName := matches[1]
 			if _, exists := lineMap[lineName]; !exists {
-				l := circuit.NewLine(nextLineID, lineName, circuit.PrimaryInput)
-				lineMap[lineName] = l
-				c.AddLine(l)
-				nextLineID++
+				addLine(lineName, circuit.PrimaryInput)
+				n.inputs = append(n.inputs, lineName)
+				n.declared[lineName] = srcLine
 			}
 			continue
 		}
@@ -62,154 +102,414 @@ func ParseBenchFile(filename string) (*circuit.Circuit, error) {
 		if matches := outputRegex.FindStringSubmatch(line); matches != nil {
 			lineName := matches[1]
 			if l, exists := lineMap[lineName]; exists {
+				if l.Type != circuit.PrimaryOutput {
+					n.outputs = append(n.outputs, lineName)
+					if l.Type != circuit.PrimaryInput {
+						n.declared[lineName] = srcLine
+					}
+				}
 				l.Type = circuit.PrimaryOutput
 			} else {
-				l := circuit.NewLine(nextLineID, lineName, circuit.PrimaryOutput)
-				lineMap[lineName] = l
-				c.AddLine(l)
-				nextLineID++
+				addLine(lineName, circuit.PrimaryOutput)
+				n.outputs = append(n.outputs, lineName)
+				n.declared[lineName] = srcLine
 			}
 			continue
 		}
 
-		// Handle gate declaration - just extract output line for now
-		if matches := gateRegex.FindStringSubmatch(line); matches != nil {
-			outputName := matches[1]
-			if _, exists := lineMap[outputName]; !exists {
-				l := circuit.NewLine(nextLineID, outputName, circuit.Normal)
-				lineMap[outputName] = l
-				c.AddLine(l)
-				nextLineID++
+		// Handle gate declaration - just extract the lines for now
+		g, err := parseGateStatement(srcLine, line, library)
+		if err != nil {
+			net := ""
+			if g != nil {
+				net = g.output
 			}
+			n.report(srcLine, net, SyntaxProblem, "%v", err)
+			continue
+		}
+		n.gates = append(n.gates, g)
 
-			// Identify input lines
-			inputs := strings.Split(matches[3], ",")
-			for _, inputName := range inputs {
-				inputName = strings.TrimSpace(inputName)
-				if _, exists := lineMap[inputName]; !exists {
-					l := circuit.NewLine(nextLineID, inputName, circuit.Normal)
-					lineMap[inputName] = l
-					c.AddLine(l)
-					nextLineID++
-				}
+		if _, exists := lineMap[g.output]; !exists {
+			addLine(g.output, circuit.Normal)
+		}
+		for _, inputName := range g.inputs {
+			if _, exists := lineMap[inputName]; !exists {
+				addLine(inputName, circuit.Normal)
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+		return nil, nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	n.validate()
+	var errs []Diagnostic
+	for _, d := range n.diagnostics {
+		if d.Severity == Error {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, n.diagnostics, &ValidationError{Diagnostics: errs}
 	}
 
 	// Second pass: create gates and connect them
-	file.Seek(0, 0) // Reset to beginning of file
-	scanner = bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	var flops [][2]string // Q and D line names of each flip-flop
+	for _, g := range n.gates {
+		if g.skip {
+			continue
+		}
 
-		// Skip comments, empty lines, and input/output declarations
-		if line == "" || strings.HasPrefix(line, "#") ||
-			inputRegex.MatchString(line) || outputRegex.MatchString(line) {
+		// Flip-flops are handled under the full-scan assumption once all gates
+		// exist, one without an input leaves its output undriven
+		if g.typeName == "DFF" {
+			if len(g.inputs) == 1 {
+				flops = append(flops, [2]string{g.output, g.inputs[0]})
+			}
 			continue
 		}
 
-		// Process gate declaration
-		if matches := gateRegex.FindStringSubmatch(line); matches != nil {
-			outputName := matches[1]
-			gateTypeName := strings.ToUpper(matches[2])
-			inputNames := strings.Split(matches[3], ",")
+		// A cell instance is expanded, its unconnected pins and internal nets
+		// becoming nets of its own
+		if g.cell != nil {
+			pins := map[string]string{g.cell.Outputs[0]: g.output}
+			for i, inputName := range g.inputs {
+				pins[g.cell.Inputs[i]] = inputName
+			}
+			instance := g.cell.expand(g.output, pins)
+			for _, name := range instance.nets {
+				if _, exists := lineMap[name]; !exists {
+					addLine(name, circuit.Normal)
+				}
+			}
+			for _, cg := range instance.gates {
+				gate := circuit.NewGate(nextGateID, cg.name, cg.typ)
+				gate.Table = cg.table
+				nextGateID++
+				gate.SetOutput(lineMap[cg.output])
+				for _, inputName := range cg.inputs {
+					gate.AddInput(lineMap[inputName])
+				}
+				c.AddGate(gate)
+			}
+			flops = append(flops, instance.flops...)
+			continue
+		}
 
-			// Create gate and connect it
-			gate := circuit.NewGate(nextGateID, fmt.Sprintf("g%d", nextGateID), parseGateType(gateTypeName))
-			gateMap[outputName] = gate
-			nextGateID++
+		// An unknown gate type that is only a warning is read as a buffer
+		gateType, _ := parseGateType(g.typeName)
 
-			// Connect output
-			outputLine := lineMap[outputName]
-			gate.SetOutput(outputLine)
+		// Create gate and connect it
+		gate := circuit.NewGate(nextGateID, fmt.Sprintf("g%d", nextGateID), gateType)
+		if gateType == circuit.LUT {
+			gate.Table = g.table
+		}
+		nextGateID++
 
-			// Connect inputs
-			for _, inputName := range inputNames {
-				inputName = strings.TrimSpace(inputName)
-				inputLine := lineMap[inputName]
-				gate.AddInput(inputLine)
-			}
+		// Connect output
+		gate.SetOutput(lineMap[g.output])
 
-			c.AddGate(gate)
+		// Connect inputs, creating the nets that cut combinational loops
+		for _, inputName := range g.inputs {
+			if _, exists := lineMap[inputName]; !exists {
+				addLine(inputName, circuit.Normal)
+			}
+			gate.AddInput(lineMap[inputName])
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+		c.AddGate(gate)
 	}
 
+	// Full scan: each flip-flop becomes a pseudo-primary input (Q) and output (D)
+	addScanCells(c, flops, lineMap)
+
 	// Analyze circuit topology
 	c.AnalyzeTopology()
 
-	return c, nil
+	return c, n.diagnostics, nil
+}
+
+// parseGateStatement parses a gate statement such as "z = AND(a, b)" or
+// "z = LUT 0x8 (a, b)". A type that names a cell of the library, which may be
+// nil, instantiates the cell; any other type is read in upper case. On a syntax
+// error the statement is still returned if its output net could be read.
+func parseGateStatement(srcLine int, line string, library *CellLibrary) (*benchGate, error) {
+	matches := gateRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("cannot parse %q", line)
+	}
+	g := &benchGate{srcLine: srcLine, output: matches[1], typeName: strings.ToUpper(matches[2])}
+	if cell := library.Cell(matches[2]); cell != nil {
+		g.typeName, g.cell = matches[2], cell
+	}
+	if strings.TrimSpace(matches[4]) != "" {
+		for _, inputName := range strings.Split(matches[4], ",") {
+			g.inputs = append(g.inputs, strings.TrimSpace(inputName))
+		}
+	}
+
+	switch {
+	case g.typeName == "LUT" && matches[3] == "":
+		return g, fmt.Errorf("LUT driving %s has no truth table, as in LUT 0x8 (a, b)", g.output)
+	case g.typeName != "LUT" && matches[3] != "":
+		return g, fmt.Errorf("%s gate driving %s takes no truth table", g.typeName, g.output)
+	case matches[3] != "":
+		table, err := strconv.ParseUint(matches[3], 0, 64)
+		if err != nil {
+			return g, fmt.Errorf("truth table %s of the LUT driving %s has more than 64 bits", matches[3], g.output)
+		}
+		g.table = table
+	}
+	for _, inputName := range g.inputs {
+		if !netRegex.MatchString(inputName) {
+			return g, fmt.Errorf("invalid input %q of net %s", inputName, g.output)
+		}
+	}
+	return g, nil
+}
+
+// addScanCells converts flip-flops, given by the names of their Q and D lines,
+// into scan cells under the full-scan assumption: each Q becomes a
+// pseudo-primary input and each D a pseudo-primary output
+func addScanCells(c *circuit.Circuit, flops [][2]string, lineMap map[string]*circuit.Line) {
+	isScanInput := make(map[string]bool, len(flops))
+	for _, flop := range flops {
+		isScanInput[flop[0]] = true
+	}
+	for _, flop := range flops {
+		q, d := lineMap[flop[0]], lineMap[flop[1]]
+
+		// A line cannot be typed as both input and output, so an input captured
+		// by a flip-flop is observed through a buffer
+		if d.Type == circuit.PrimaryInput || isScanInput[d.Name] {
+			captured := circuit.NewLine(len(c.Lines), q.Name+"$D", circuit.Normal)
+			c.AddLine(captured)
+
+			gate := circuit.NewGate(len(c.Gates), fmt.Sprintf("g%d", len(c.Gates)), circuit.BUF)
+			gate.SetOutput(captured)
+			gate.AddInput(d)
+			c.AddGate(gate)
+			d = captured
+		}
+		c.AddScanCell(q, d)
+	}
 }
 
 // parseGateType converts string gate type to GateType enum
-func parseGateType(typeString string) circuit.GateType {
+func parseGateType(typeString string) (circuit.GateType, error) {
 	switch typeString {
 	case "AND":
-		return circuit.AND
+		return circuit.AND, nil
 	case "OR":
-		return circuit.OR
+		return circuit.OR, nil
 	case "NOT", "INV":
-		return circuit.NOT
+		return circuit.NOT, nil
 	case "NAND":
-		return circuit.NAND
+		return circuit.NAND, nil
 	case "NOR":
-		return circuit.NOR
+		return circuit.NOR, nil
 	case "XOR":
-		return circuit.XOR
+		return circuit.XOR, nil
 	case "XNOR":
-		return circuit.XNOR
-	case "BUF":
-		return circuit.BUF
+		return circuit.XNOR, nil
+	case "BUF", "BUFF":
+		return circuit.BUF, nil
+	case "MUX":
+		return circuit.MUX, nil
+	case "TIE0":
+		return circuit.TIE0, nil
+	case "TIE1":
+		return circuit.TIE1, nil
+	case "AOI21":
+		return circuit.AOI21, nil
+	case "AOI22":
+		return circuit.AOI22, nil
+	case "OAI21":
+		return circuit.OAI21, nil
+	case "OAI22":
+		return circuit.OAI22, nil
+	case "LUT":
+		return circuit.LUT, nil
 	default:
-		// Default to buffer for unsupported gate types
-		return circuit.BUF
+		return circuit.BUF, fmt.Errorf("unsupported gate type %s", typeString)
 	}
 }
 
-// ParseFaultString parses a fault string like "a/0" or "net34/1"
+// ParseFaultString parses a stem fault string like "a/0" or "net34/1"
 func ParseFaultString(faultStr string, c *circuit.Circuit) (*circuit.Line, circuit.LogicValue, error) {
-	parts := strings.Split(faultStr, "/")
-	if len(parts) != 2 {
-		return nil, circuit.X, fmt.Errorf("invalid fault string format: %s", faultStr)
+	fault, err := ParseFault(faultStr, c)
+	if err != nil {
+		return nil, circuit.X, err
 	}
+	if fault.IsBranch() {
+		return nil, circuit.X, fmt.Errorf("not a stem fault: %s", faultStr)
+	}
+	return fault.Line, fault.Type, nil
+}
 
-	lineName := parts[0]
-	var line *circuit.Line
+// ParseFault parses a stem fault like "net34/1" or a fanout-branch fault like "n12->g7/0".
+// The branch is named by its gate, as printed by Fault.String.
+func ParseFault(faultStr string, c *circuit.Circuit) (circuit.Fault, error) {
+	idx := strings.LastIndex(faultStr, "/")
+	if idx < 0 {
+		return circuit.Fault{}, fmt.Errorf("invalid fault string format: %s", faultStr)
+	}
+	site, typeStr := faultStr[:idx], faultStr[idx+1:]
+
+	var faultType circuit.LogicValue
+	switch typeStr {
+	case "0":
+		faultType = circuit.Zero
+	case "1":
+		faultType = circuit.One
+	default:
+		return circuit.Fault{}, fmt.Errorf("invalid fault type: %s", typeStr)
+	}
 
-	// Search for line by name
+	stemName, branchName, isBranch := strings.Cut(site, "->")
+	var stem *circuit.Line
 	for _, l := range c.Lines {
-		if l.Name == lineName {
-			line = l
+		if l.Name == stemName {
+			stem = l
 			break
 		}
 	}
+	if stem == nil {
+		return circuit.Fault{}, fmt.Errorf("line not found: %s", stemName)
+	}
 
-	if line == nil {
-		return nil, circuit.X, fmt.Errorf("line not found: %s", lineName)
+	fault := circuit.Fault{Line: stem, Type: faultType}
+	if !isBranch {
+		return fault, nil
 	}
 
-	// Parse fault type (0 or 1)
-	var faultType circuit.LogicValue
-	if parts[1] == "0" {
-		faultType = circuit.Zero
-	} else if parts[1] == "1" {
-		faultType = circuit.One
-	} else {
-		return nil, circuit.X, fmt.Errorf("invalid fault type: %s", parts[1])
+	for _, gate := range stem.OutputGates {
+		if gate.Name != branchName || gate == fault.Branch {
+			continue
+		}
+		if fault.Branch != nil {
+			return circuit.Fault{}, fmt.Errorf("ambiguous branch %s of %s", branchName, stemName)
+		}
+		fault.Branch = gate
+	}
+	if fault.Branch == nil {
+		return circuit.Fault{}, fmt.Errorf("%s does not fan out to gate %s", stemName, branchName)
+	}
+	return fault, nil
+}
+
+// ParseTransitionFault parses a transition fault like "net34/STR" (slow-to-rise)
+// or "n12->g7/STF" (slow-to-fall on the n12 branch into g7)
+func ParseTransitionFault(faultStr string, c *circuit.Circuit) (circuit.TransitionFault, error) {
+	idx := strings.LastIndex(faultStr, "/")
+	if idx < 0 {
+		return circuit.TransitionFault{}, fmt.Errorf("invalid fault string format: %s", faultStr)
+	}
+
+	// Parse the site as the stuck-at fault the transition reduces to
+	var transition circuit.TransitionType
+	stuck := faultStr[:idx]
+	switch strings.ToUpper(faultStr[idx+1:]) {
+	case "STR":
+		transition, stuck = circuit.SlowToRise, stuck+"/0"
+	case "STF":
+		transition, stuck = circuit.SlowToFall, stuck+"/1"
+	default:
+		return circuit.TransitionFault{}, fmt.Errorf("invalid transition type: %s", faultStr[idx+1:])
 	}
 
-	return line, faultType, nil
+	fault, err := ParseFault(stuck, c)
+	if err != nil {
+		return circuit.TransitionFault{}, err
+	}
+	return circuit.TransitionFault{Line: fault.Line, Type: transition, Branch: fault.Branch}, nil
+}
+
+// ParseInputWeights parses weighted-random input probabilities like
+// "G0=0.9,G3=0.25", giving for each listed input the probability of a 1
+func ParseInputWeights(spec string, c *circuit.Circuit) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	if strings.TrimSpace(spec) == "" {
+		return weights, nil
+	}
+
+	inputs := make(map[string]bool, len(c.Inputs))
+	for _, input := range c.Inputs {
+		inputs[input.Name] = true
+	}
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q (expected input=probability)", item)
+		}
+		if !inputs[name] {
+			return nil, fmt.Errorf("input not found: %s", name)
+		}
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid probability %q for %s (expected 0 to 1)", value, name)
+		}
+		weights[name] = p
+	}
+	return weights, nil
 }
 
-// WriteTestVectors writes test vectors to a file
-func WriteTestVectors(filename string, testVectors []map[string]circuit.LogicValue) error {
+// ParseBridgeFile reads a list of bridging faults, one per line as "a b" or
+// "a b model" with model wand, wor or dom (a dominates b). Lines without a
+// model use defaultModel. Empty lines and lines starting with # are skipped.
+func ParseBridgeFile(filename string, c *circuit.Circuit, defaultModel circuit.BridgeModel) ([]circuit.Bridge, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	lines := make(map[string]*circuit.Line, len(c.Lines))
+	for _, l := range c.Lines {
+		lines[l.Name] = l
+	}
+
+	var bridges []circuit.Bridge
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected two line names and an optional model", filename, lineNo)
+		}
+		bridge := circuit.Bridge{Model: defaultModel}
+		if len(fields) == 3 {
+			if bridge.Model, err = circuit.ParseBridgeModel(fields[2]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", filename, lineNo, err)
+			}
+		}
+		var ok bool
+		if bridge.A, ok = lines[fields[0]]; !ok {
+			return nil, fmt.Errorf("%s:%d: line not found: %s", filename, lineNo, fields[0])
+		}
+		if bridge.B, ok = lines[fields[1]]; !ok {
+			return nil, fmt.Errorf("%s:%d: line not found: %s", filename, lineNo, fields[1])
+		}
+		if err := c.ValidateBridge(bridge); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineNo, err)
+		}
+		bridges = append(bridges, bridge)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	return bridges, nil
+}
+
+// WriteTestVectors writes test vectors to a file. fill is recorded in the header
+// as the policy the unassigned inputs were filled with.
+func WriteTestVectors(filename string, testVectors []map[string]circuit.LogicValue, fill circuit.FillPolicy) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
@@ -219,16 +519,23 @@ func WriteTestVectors(filename string, testVectors []map[string]circuit.LogicVal
 	writer := bufio.NewWriter(file)
 	defer writer.Flush()
 
-	// Get all input names from the first test vector
+	// Collect the input names of all test vectors in sorted order, so that
+	// the columns do not change between runs
+	seen := make(map[string]bool)
 	var inputNames []string
-	if len(testVectors) > 0 {
-		for name := range testVectors[0] {
-			inputNames = append(inputNames, name)
+	for _, vector := range testVectors {
+		for name := range vector {
+			if !seen[name] {
+				seen[name] = true
+				inputNames = append(inputNames, name)
+			}
 		}
 	}
+	sort.Strings(inputNames)
 
 	// Write header
 	writer.WriteString("# Test vectors generated by FAN-ATPG\n")
+	writer.WriteString(fmt.Sprintf("# Fill: %v\n", fill))
 	writer.WriteString("# Format: ")
 	for _, name := range inputNames {
 		writer.WriteString(name + " ")
@@ -239,16 +546,182 @@ func WriteTestVectors(filename string, testVectors []map[string]circuit.LogicVal
 	for i, vector := range testVectors {
 		writer.WriteString(fmt.Sprintf("# Test vector %d\n", i+1))
 		for _, name := range inputNames {
-			value := vector[name]
-			switch value {
-			case circuit.Zero:
-				writer.WriteString("0 ")
-			case circuit.One:
-				writer.WriteString("1 ")
+			writer.WriteString(formatValue(vector[name]) + " ")
+		}
+		writer.WriteString("\n")
+	}
+
+	return nil
+}
+
+// WriteScanTestVectors writes test vectors of a full-scan circuit to a file.
+// Each vector lists the primary input values, then the values loaded into the scan cells.
+func WriteScanTestVectors(filename string, c *circuit.Circuit, testVectors []map[string]circuit.LogicValue, fill circuit.FillPolicy) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	inputs := c.PrimaryInputs()
+
+	// Write header
+	writer.WriteString("# Test vectors generated by FAN-ATPG\n")
+	writer.WriteString(fmt.Sprintf("# Fill: %v\n", fill))
+	writer.WriteString("# Format: ")
+	for _, input := range inputs {
+		writer.WriteString(input.Name + " ")
+	}
+	writer.WriteString("| ")
+	for _, cell := range c.ScanCells {
+		writer.WriteString(cell.Name + " ")
+	}
+	writer.WriteString("\n")
+
+	// Write each test vector, primary inputs first and the scan load after the separator
+	for i, vector := range testVectors {
+		pi, scan := c.SplitTest(vector)
+		writer.WriteString(fmt.Sprintf("# Test vector %d\n", i+1))
+		for _, input := range inputs {
+			writer.WriteString(formatValue(pi[input.Name]) + " ")
+		}
+		writer.WriteString("| ")
+		for _, cell := range c.ScanCells {
+			writer.WriteString(formatValue(scan[cell.Name]) + " ")
+		}
+		writer.WriteString("\n")
+	}
+
+	return nil
+}
+
+// formatValue returns the character written for an input value, X when unassigned
+func formatValue(value circuit.LogicValue) string {
+	switch value {
+	case circuit.Zero:
+		return "0"
+	case circuit.One:
+		return "1"
+	default:
+		return "X"
+	}
+}
+
+// ReadTestVectors reads test vectors written by WriteTestVectors or
+// WriteScanTestVectors. The "# Format:" header names the input of each column,
+// with the scan cells after the "|" separator, and every other line that is
+// not a comment holds one test vector. An X leaves the input unassigned.
+func ReadTestVectors(filename string, c *circuit.Circuit) ([]map[string]circuit.LogicValue, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	inputs := make(map[string]bool, len(c.Inputs))
+	for _, input := range c.Inputs {
+		inputs[input.Name] = true
+	}
+
+	var names []string
+	tests := make([]map[string]circuit.LogicValue, 0)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		text := strings.TrimSpace(scanner.Text())
+		if format, ok := strings.CutPrefix(text, "# Format:"); ok {
+			names = []string{}
+			for _, name := range strings.Fields(format) {
+				if name == "|" {
+					continue
+				}
+				if !inputs[name] {
+					return nil, fmt.Errorf("%s:%d: input not found: %s", filename, lineNo, name)
+				}
+				names = append(names, name)
+			}
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if names == nil {
+			return nil, fmt.Errorf("%s:%d: test vector before the # Format: header", filename, lineNo)
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(text, "|", " "))
+		if len(fields) != len(names) {
+			return nil, fmt.Errorf("%s:%d: expected %d values, got %d", filename, lineNo, len(names), len(fields))
+		}
+		test := make(map[string]circuit.LogicValue, len(names))
+		for i, field := range fields {
+			switch field {
+			case "0":
+				test[names[i]] = circuit.Zero
+			case "1":
+				test[names[i]] = circuit.One
+			case "X", "x":
+				test[names[i]] = circuit.X
 			default:
-				writer.WriteString("X ")
+				return nil, fmt.Errorf("%s:%d: invalid value %q for %s", filename, lineNo, field, names[i])
+			}
+		}
+		tests = append(tests, test)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	return tests, nil
+}
+
+// WriteTwoPatternTests writes transition tests to a file. V1 lists the primary
+// input values and, after a separator, the scan load; V2 the primary input values.
+func WriteTwoPatternTests(filename string, c *circuit.Circuit, tests []circuit.TwoPatternTest, fill circuit.FillPolicy) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	inputs := c.PrimaryInputs()
+
+	// Write header
+	writer.WriteString("# Two-pattern transition tests generated by FAN-ATPG\n")
+	writer.WriteString(fmt.Sprintf("# Fill: %v\n", fill))
+	writer.WriteString("# Format: ")
+	for _, input := range inputs {
+		writer.WriteString(input.Name + " ")
+	}
+	if len(c.ScanCells) > 0 {
+		writer.WriteString("| ")
+		for _, cell := range c.ScanCells {
+			writer.WriteString(cell.Name + " ")
+		}
+	}
+	writer.WriteString("\n")
+
+	for i, test := range tests {
+		writer.WriteString(fmt.Sprintf("# Test %d\n", i+1))
+		writer.WriteString("V1: ")
+		for _, input := range inputs {
+			writer.WriteString(formatValue(test.V1[input.Name]) + " ")
+		}
+		if len(c.ScanCells) > 0 {
+			writer.WriteString("| ")
+			for _, cell := range c.ScanCells {
+				writer.WriteString(formatValue(test.V1[cell.Name]) + " ")
 			}
 		}
+		writer.WriteString("\nV2: ")
+		for _, input := range inputs {
+			writer.WriteString(formatValue(test.V2[input.Name]) + " ")
+		}
 		writer.WriteString("\n")
 	}
 
@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// Names of the signals added to a full-scan design for its scan chain
+const (
+	stilScanClock  = "scan_clk"
+	stilScanEnable = "scan_en"
+	stilScanIn     = "scan_in"
+	stilScanOut    = "scan_out"
+)
+
+// Names of the signal groups, timing and pattern blocks
+const (
+	stilInputs   = "_pi"
+	stilOutputs  = "_po"
+	stilLoad     = "_si"
+	stilUnload   = "_so"
+	stilWaveform = "_default_WFT_"
+	stilBurst    = "_burst_"
+	stilPattern  = "_pattern_"
+)
+
+// WriteSTIL writes test patterns in STIL (IEEE 1450) format. responses holds the
+// expected good-machine output values of each test, keyed by output name, as
+// returned by FaultSimulator.Responses. fill is recorded in the header as the
+// policy the unassigned inputs were filled with.
+//
+// A full-scan circuit gets a single scan chain through its scan cells, in the
+// order of Circuit.ScanCells from scan-in to scan-out. Each test loads the chain,
+// applies the primary inputs, measures the primary outputs and pulses the clock
+// to capture; the captured values are unloaded while the next test is loaded.
+// Scan data is listed in shift order, starting with the cell next to scan-out.
+func WriteSTIL(filename string, c *circuit.Circuit, tests, responses []map[string]circuit.LogicValue, fill circuit.FillPolicy) error {
+	if len(tests) != len(responses) {
+		return fmt.Errorf("%d tests but %d responses", len(tests), len(responses))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	inputs := c.PrimaryInputs()
+	outputs := c.PrimaryOutputs()
+	scan := len(c.ScanCells) > 0
+
+	fmt.Fprintf(writer, "STIL 1.0;\n\n")
+	fmt.Fprintf(writer, "Header {\n  Title %s;\n  Ann {* fill: %v *}\n}\n\n", stilName("FAN-ATPG patterns for "+c.Name), fill)
+
+	// Signals
+	fmt.Fprintf(writer, "Signals {\n")
+	for _, input := range inputs {
+		fmt.Fprintf(writer, "  %s In;\n", stilName(input.Name))
+	}
+	for _, output := range outputs {
+		fmt.Fprintf(writer, "  %s Out;\n", stilName(output.Name))
+	}
+	if scan {
+		fmt.Fprintf(writer, "  %s In;\n", stilName(stilScanClock))
+		fmt.Fprintf(writer, "  %s In;\n", stilName(stilScanEnable))
+		fmt.Fprintf(writer, "  %s In { ScanIn; }\n", stilName(stilScanIn))
+		fmt.Fprintf(writer, "  %s Out { ScanOut; }\n", stilName(stilScanOut))
+	}
+	fmt.Fprintf(writer, "}\n\n")
+
+	// SignalGroups
+	fmt.Fprintf(writer, "SignalGroups {\n")
+	if len(inputs) > 0 {
+		fmt.Fprintf(writer, "  %s = '%s';\n", stilName(stilInputs), stilGroup(inputs))
+	}
+	if len(outputs) > 0 {
+		fmt.Fprintf(writer, "  %s = '%s';\n", stilName(stilOutputs), stilGroup(outputs))
+	}
+	if scan {
+		fmt.Fprintf(writer, "  %s = '%s' { ScanIn %d; }\n", stilName(stilLoad), stilName(stilScanIn), len(c.ScanCells))
+		fmt.Fprintf(writer, "  %s = '%s' { ScanOut %d; }\n", stilName(stilUnload), stilName(stilScanOut), len(c.ScanCells))
+	}
+	fmt.Fprintf(writer, "}\n\n")
+
+	if scan {
+		fmt.Fprintf(writer, "ScanStructures {\n  ScanChain %s {\n", stilName("chain1"))
+		fmt.Fprintf(writer, "    ScanLength %d;\n", len(c.ScanCells))
+		fmt.Fprintf(writer, "    ScanIn %s;\n    ScanOut %s;\n", stilName(stilScanIn), stilName(stilScanOut))
+		fmt.Fprintf(writer, "    ScanEnable %s;\n    ScanMasterClock %s;\n", stilName(stilScanEnable), stilName(stilScanClock))
+		fmt.Fprintf(writer, "    ScanCells")
+		for _, cell := range c.ScanCells {
+			fmt.Fprintf(writer, " %s", stilName(cell.Name))
+		}
+		fmt.Fprintf(writer, ";\n  }\n}\n\n")
+	}
+
+	// Timing: inputs are driven at the start of the period, outputs strobed at 40ns
+	// and the scan clock pulses between 45ns and 55ns
+	fmt.Fprintf(writer, "Timing {\n  WaveformTable %s {\n    Period '100ns';\n    Waveforms {\n", stilName(stilWaveform))
+	if len(inputs) > 0 {
+		fmt.Fprintf(writer, "      %s { 01N { '0ns' D/U/N; } }\n", stilName(stilInputs))
+	}
+	if len(outputs) > 0 {
+		fmt.Fprintf(writer, "      %s { LHX { '0ns' X; '40ns' L/H/X; } }\n", stilName(stilOutputs))
+	}
+	if scan {
+		fmt.Fprintf(writer, "      %s { 0P { '0ns' D; '45ns' D/U; '55ns' D; } }\n", stilName(stilScanClock))
+		fmt.Fprintf(writer, "      %s { 01 { '0ns' D/U; } }\n", stilName(stilScanEnable))
+		fmt.Fprintf(writer, "      %s { 01N { '0ns' D/U/N; } }\n", stilName(stilLoad))
+		fmt.Fprintf(writer, "      %s { LHX { '0ns' X; '40ns' L/H/X; } }\n", stilName(stilUnload))
+	}
+	fmt.Fprintf(writer, "    }\n  }\n}\n\n")
+
+	fmt.Fprintf(writer, "PatternBurst %s {\n  PatList { %s; }\n}\n\n", stilName(stilBurst), stilName(stilPattern))
+	fmt.Fprintf(writer, "PatternExec {\n  PatternBurst %s;\n}\n\n", stilName(stilBurst))
+
+	if scan {
+		// The load/unload procedure shifts one scan bit per clock pulse
+		fmt.Fprintf(writer, "Procedures {\n  %s {\n", stilName("load_unload"))
+		fmt.Fprintf(writer, "    W %s;\n", stilName(stilWaveform))
+		fmt.Fprintf(writer, "    V { %s = 0; %s = 1; }\n", stilName(stilScanClock), stilName(stilScanEnable))
+		fmt.Fprintf(writer, "    Shift { V { %s = #; %s = #; %s = P; } }\n",
+			stilName(stilLoad), stilName(stilUnload), stilName(stilScanClock))
+		fmt.Fprintf(writer, "  }\n}\n\n")
+	}
+
+	// Pattern
+	fmt.Fprintf(writer, "Pattern %s {\n  W %s;\n", stilName(stilPattern), stilName(stilWaveform))
+	for i, test := range tests {
+		fmt.Fprintf(writer, "  %s:", stilName(fmt.Sprintf("pattern %d", i)))
+		if scan {
+			fmt.Fprintf(writer, " Call %s {", stilName("load_unload"))
+			if i > 0 {
+				fmt.Fprintf(writer, " %s = %s;", stilName(stilUnload), stilUnloadData(c, responses[i-1]))
+			}
+			fmt.Fprintf(writer, " %s = %s; }\n ", stilName(stilLoad), stilLoadData(c, test))
+		}
+
+		fmt.Fprintf(writer, " V {")
+		if scan {
+			fmt.Fprintf(writer, " %s = 0;", stilName(stilScanEnable))
+		}
+		if len(inputs) > 0 {
+			fmt.Fprintf(writer, " %s = ", stilName(stilInputs))
+			for _, input := range inputs {
+				writer.WriteString(stilDrive(test[input.Name]))
+			}
+			writer.WriteString(";")
+		}
+		if len(outputs) > 0 {
+			fmt.Fprintf(writer, " %s = ", stilName(stilOutputs))
+			for _, output := range outputs {
+				writer.WriteString(stilExpect(responses[i][output.Name]))
+			}
+			writer.WriteString(";")
+		}
+		fmt.Fprintf(writer, " }\n")
+
+		if scan {
+			// Capture the responses of the scan cells
+			fmt.Fprintf(writer, "  V { %s = P; }\n", stilName(stilScanClock))
+		}
+	}
+	if scan && len(tests) > 0 {
+		fmt.Fprintf(writer, "  Call %s { %s = %s; }\n",
+			stilName("load_unload"), stilName(stilUnload), stilUnloadData(c, responses[len(tests)-1]))
+	}
+	fmt.Fprintf(writer, "}\n")
+
+	return nil
+}
+
+// stilName quotes a name, so that any net name is a valid STIL identifier
+func stilName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+// stilGroup returns the expression of a signal group made of the given lines
+func stilGroup(lines []*circuit.Line) string {
+	names := make([]string, len(lines))
+	for i, line := range lines {
+		names[i] = stilName(line.Name)
+	}
+	return strings.Join(names, " + ")
+}
+
+// stilLoadData returns the values shifted into the scan chain for a test
+func stilLoadData(c *circuit.Circuit, test map[string]circuit.LogicValue) string {
+	var data strings.Builder
+	for i := len(c.ScanCells) - 1; i >= 0; i-- {
+		data.WriteString(stilDrive(test[c.ScanCells[i].Name]))
+	}
+	return data.String()
+}
+
+// stilUnloadData returns the values expected out of the scan chain after a capture
+func stilUnloadData(c *circuit.Circuit, response map[string]circuit.LogicValue) string {
+	var data strings.Builder
+	for i := len(c.ScanCells) - 1; i >= 0; i-- {
+		data.WriteString(stilExpect(response[c.ScanCells[i].D.Name]))
+	}
+	return data.String()
+}
+
+// stilDrive returns the waveform character driving an input value
+func stilDrive(value circuit.LogicValue) string {
+	switch value {
+	case circuit.Zero:
+		return "0"
+	case circuit.One:
+		return "1"
+	default:
+		return "N"
+	}
+}
+
+// stilExpect returns the waveform character comparing an output value
+func stilExpect(value circuit.LogicValue) string {
+	switch value {
+	case circuit.Zero:
+		return "L"
+	case circuit.One:
+		return "H"
+	default:
+		return "X"
+	}
+}
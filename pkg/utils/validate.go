@@ -0,0 +1,418 @@
+package utils
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// NetlistProblem is a kind of problem the netlist validation reports
+type NetlistProblem int
+
+const (
+	SyntaxProblem     NetlistProblem = iota // Statement that is not INPUT, OUTPUT or a gate
+	UnknownGate                             // Gate type the parser does not know
+	WrongArity                              // Gate with a number of inputs its type does not take, e.g. a NOT with two
+	UndrivenNet                             // Net used as a gate input or output but never driven
+	MultipleDrivers                         // Net driven by more than one gate, or a driven primary input
+	CombinationalLoop                       // Gates that depend on their own output without a flip-flop in between
+	UnusedNet                               // Net driven or declared as an input but never used
+)
+
+// netlistProblems lists every netlist problem in order
+var netlistProblems = []NetlistProblem{SyntaxProblem, UnknownGate, WrongArity, UndrivenNet, MultipleDrivers, CombinationalLoop, UnusedNet}
+
+// String returns a string representation of the problem
+func (p NetlistProblem) String() string {
+	switch p {
+	case SyntaxProblem:
+		return "syntax"
+	case UnknownGate:
+		return "unknown-gate"
+	case WrongArity:
+		return "arity"
+	case UndrivenNet:
+		return "undriven"
+	case MultipleDrivers:
+		return "multiple-drivers"
+	case CombinationalLoop:
+		return "loop"
+	case UnusedNet:
+		return "unused"
+	default:
+		return "?"
+	}
+}
+
+// Severity says what a netlist problem does to parsing
+type Severity int
+
+const (
+	Ignore  Severity = iota // Not reported
+	Warning                 // Reported, the circuit is still built
+	Error                   // Reported, parsing fails
+)
+
+// String returns a string representation of the severity
+func (s Severity) String() string {
+	switch s {
+	case Ignore:
+		return "ignore"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "?"
+	}
+}
+
+// ValidationPolicy sets the severity of each netlist problem
+type ValidationPolicy map[NetlistProblem]Severity
+
+// DefaultValidationPolicy returns the policy ParseBenchFile applies: every
+// problem is an error except unused nets, which are warnings
+func DefaultValidationPolicy() ValidationPolicy {
+	policy := make(ValidationPolicy, len(netlistProblems))
+	for _, p := range netlistProblems {
+		policy[p] = Error
+	}
+	policy[UnusedNet] = Warning
+	return policy
+}
+
+// ParseValidationPolicy changes the default policy by a comma-separated list
+// of problem=severity settings, e.g. "unused=error,loop=warning". The problem
+// "all" sets every problem.
+func ParseValidationPolicy(spec string) (ValidationPolicy, error) {
+	policy := DefaultValidationPolicy()
+	for _, setting := range strings.Split(spec, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		parts := strings.Split(setting, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid validation setting %q (expected problem=severity)", setting)
+		}
+		name, severityName := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		severity := Severity(-1)
+		for _, s := range []Severity{Ignore, Warning, Error} {
+			if s.String() == severityName {
+				severity = s
+			}
+		}
+		if severity < 0 {
+			return nil, fmt.Errorf("unknown severity %q (expected ignore, warning or error)", severityName)
+		}
+
+		found := false
+		for _, p := range netlistProblems {
+			if name == "all" || p.String() == name {
+				policy[p] = severity
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown netlist problem %q (expected all, syntax, unknown-gate, arity, undriven, multiple-drivers, loop or unused)", name)
+		}
+	}
+	return policy, nil
+}
+
+// Diagnostic is a netlist problem found at a line of the source file
+type Diagnostic struct {
+	File     string
+	Line     int    // Source line number, 1-based
+	Net      string // Net the problem is about, "" for a syntax problem
+	Problem  NetlistProblem
+	Severity Severity
+	Message  string
+}
+
+// String returns the diagnostic in the file:line: severity: message format
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %v: %s", d.File, d.Line, d.Severity, d.Message)
+}
+
+// ValidationError is returned when a netlist has problems of Error severity
+type ValidationError struct {
+	Diagnostics []Diagnostic // Problems of Error severity, in source order
+}
+
+// Error lists every problem, one per line
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		messages[i] = d.String()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Summary counts the problems in one line, such as "2 problems in c17.bench"
+func (e *ValidationError) Summary(filename string) string {
+	if len(e.Diagnostics) == 1 {
+		return fmt.Sprintf("1 problem in %s", filename)
+	}
+	return fmt.Sprintf("%d problems in %s", len(e.Diagnostics), filename)
+}
+
+// benchGate is a gate or flip-flop statement of a BENCH file
+type benchGate struct {
+	srcLine  int
+	output   string
+	typeName string
+	inputs   []string
+	table    uint64 // Truth table of a LUT
+	cell     *Cell  // Library cell instantiated, nil for a gate type of the parser
+	skip     bool   // Dropped by validation, e.g. a second driver of its output
+}
+
+// isFlipFlop reports whether the statement is a flip-flop, which breaks loops
+// since under full scan its output is an input
+func (g *benchGate) isFlipFlop() bool {
+	return g.typeName == "DFF" || g.cell != nil && g.cell.IsFlipFlop()
+}
+
+// arity returns the number of inputs the statement takes, or -1 if it takes
+// any number or its type is unknown
+func (g *benchGate) arity() int {
+	if g.cell != nil {
+		return len(g.cell.Inputs)
+	}
+	return gateArity(g.typeName)
+}
+
+// arityProblem describes why the statement has a number of inputs its type
+// does not take, or a truth table too long for them, or returns ""
+func (g *benchGate) arityProblem() string {
+	switch arity := g.arity(); {
+	case arity == 1 && len(g.inputs) != 1:
+		return fmt.Sprintf("%s gate driving %s has %d inputs instead of one", g.typeName, g.output, len(g.inputs))
+	case arity >= 0 && len(g.inputs) != arity:
+		return fmt.Sprintf("%s gate driving %s has %d inputs instead of %d", g.typeName, g.output, len(g.inputs), arity)
+	case arity < 0 && len(g.inputs) == 0:
+		return fmt.Sprintf("%s gate driving %s has no inputs", g.typeName, g.output)
+	case g.typeName == "LUT" && len(g.inputs) > circuit.MaxTableInputs:
+		return fmt.Sprintf("LUT driving %s has %d inputs, more than %d", g.output, len(g.inputs), circuit.MaxTableInputs)
+	case g.typeName == "LUT" && bits.Len64(g.table) > 1<<len(g.inputs):
+		return fmt.Sprintf("truth table %#x of the LUT driving %s has more than the %d bits of %d inputs",
+			g.table, g.output, 1<<len(g.inputs), len(g.inputs))
+	}
+	return ""
+}
+
+// benchNetlist is the statements of a BENCH file, before any line is connected
+type benchNetlist struct {
+	filename string
+	inputs   []string
+	outputs  []string
+	declared map[string]int // Source line of the INPUT or OUTPUT declaration of each net
+	gates    []*benchGate
+	policy   ValidationPolicy
+
+	diagnostics []Diagnostic
+}
+
+// report records a problem unless the policy ignores it
+func (n *benchNetlist) report(srcLine int, net string, problem NetlistProblem, format string, args ...interface{}) {
+	severity, ok := n.policy[problem]
+	if !ok {
+		severity = Error
+	}
+	if severity == Ignore {
+		return
+	}
+	n.diagnostics = append(n.diagnostics, Diagnostic{
+		File:     n.filename,
+		Line:     srcLine,
+		Net:      net,
+		Problem:  problem,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// validate checks the statements for the problems the parser cannot build a
+// sound circuit from. A second driver of a net is skipped and the extra
+// inputs of a gate of fixed arity dropped, so that the circuit can still be
+// built when these are only warnings; every other problem is left as is.
+func (n *benchNetlist) validate() {
+	isInput := make(map[string]bool, len(n.inputs))
+	for _, name := range n.inputs {
+		isInput[name] = true
+	}
+
+	drivers := make(map[string]*benchGate)
+	for _, g := range n.gates {
+		if isInput[g.output] {
+			n.report(g.srcLine, g.output, MultipleDrivers, "primary input %s is driven by a %s gate", g.output, g.typeName)
+			g.skip = true
+			continue
+		}
+		if first, ok := drivers[g.output]; ok {
+			n.report(g.srcLine, g.output, MultipleDrivers, "net %s is already driven at line %d", g.output, first.srcLine)
+			g.skip = true
+			continue
+		}
+		drivers[g.output] = g
+
+		if g.typeName != "DFF" && g.cell == nil {
+			if _, err := parseGateType(g.typeName); err != nil {
+				n.report(g.srcLine, g.output, UnknownGate, "net %s: %v", g.output, err)
+				continue
+			}
+		}
+		if problem := g.arityProblem(); problem != "" {
+			n.report(g.srcLine, g.output, WrongArity, "%s", problem)
+		}
+	}
+
+	// Every net read by a gate or declared as an output needs a driver
+	used := make(map[string]bool)
+	for _, g := range n.gates {
+		for _, input := range g.inputs {
+			if !used[input] && drivers[input] == nil && !isInput[input] {
+				n.report(g.srcLine, input, UndrivenNet, "net %s is used but never driven", input)
+			}
+			used[input] = true
+		}
+	}
+	for _, name := range n.outputs {
+		if drivers[name] == nil && !isInput[name] {
+			n.report(n.declared[name], name, UndrivenNet, "output %s is never driven", name)
+		}
+		used[name] = true
+	}
+	for _, name := range n.inputs {
+		if !used[name] {
+			n.report(n.declared[name], name, UnusedNet, "input %s is never used", name)
+		}
+	}
+	for _, g := range n.gates {
+		if !g.skip && !used[g.output] {
+			n.report(g.srcLine, g.output, UnusedNet, "net %s is never used", g.output)
+		}
+	}
+
+	for _, g := range n.gates {
+		arity := g.arity()
+		if g.typeName == "LUT" {
+			arity = circuit.MaxTableInputs
+		}
+		if arity >= 0 && len(g.inputs) > arity {
+			g.inputs = g.inputs[:arity]
+		}
+	}
+	for report := true; n.findLoops(drivers, report) > 0; report = false {
+	}
+
+	sort.SliceStable(n.diagnostics, func(i, j int) bool { return n.diagnostics[i].Line < n.diagnostics[j].Line })
+}
+
+// findLoops finds every strongly connected set of gates with Tarjan's
+// algorithm and reports it if report is set. Flip-flops break loops, since
+// under full scan their output is an input. Each loop is cut at its first gate
+// in the file, whose inputs from within the loop are read from a new undriven
+// net instead, as for an undriven net an unknown value. It returns the number
+// of loops cut, so that the sets with more than one loop are cut again until
+// none is left.
+func (n *benchNetlist) findLoops(drivers map[string]*benchGate, report bool) int {
+	index := make(map[*benchGate]int)
+	low := make(map[*benchGate]int)
+	onStack := make(map[*benchGate]bool)
+	var stack []*benchGate
+	cuts := 0
+
+	var visit func(g *benchGate)
+	visit = func(g *benchGate) {
+		index[g] = len(index)
+		low[g] = index[g]
+		stack = append(stack, g)
+		onStack[g] = true
+
+		for _, input := range g.inputs {
+			next := drivers[input]
+			if next == nil || next.isFlipFlop() {
+				continue
+			}
+			if _, visited := index[next]; !visited {
+				visit(next)
+				low[g] = min(low[g], low[next])
+			} else if onStack[next] {
+				low[g] = min(low[g], index[next])
+			}
+		}
+		if low[g] != index[g] {
+			return
+		}
+
+		inComponent := make(map[string]bool)
+		var component []*benchGate
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			inComponent[top.output] = true
+			if top == g {
+				break
+			}
+		}
+		if len(component) == 1 && firstInput(g, inComponent) == "" {
+			return
+		}
+		sort.Slice(component, func(i, j int) bool { return component[i].srcLine < component[j].srcLine })
+		names := make([]string, len(component))
+		for i, c := range component {
+			names[i] = c.output
+		}
+
+		cut := component[0]
+		broken := firstInput(cut, inComponent)
+		for i, input := range cut.inputs {
+			if inComponent[input] {
+				cut.inputs[i] = input + "$loop"
+			}
+		}
+		cuts++
+		if report {
+			n.report(cut.srcLine, cut.output, CombinationalLoop,
+				"combinational loop through %s, cut at input %s of %s", strings.Join(names, ", "), broken, cut.output)
+		}
+	}
+
+	for _, g := range n.gates {
+		if _, visited := index[g]; !visited && !g.skip && !g.isFlipFlop() {
+			visit(g)
+		}
+	}
+	return cuts
+}
+
+// gateArity returns the number of inputs a gate type takes, or -1 if it takes
+// any number or is unknown
+func gateArity(typeName string) int {
+	if typeName == "DFF" {
+		return 1
+	}
+	gateType, err := parseGateType(typeName)
+	if err != nil {
+		return -1
+	}
+	return gateType.Arity()
+}
+
+// firstInput returns the first input of a gate that is in a set of nets, or ""
+func firstInput(g *benchGate, nets map[string]bool) string {
+	for _, input := range g.inputs {
+		if nets[input] {
+			return input
+		}
+	}
+	return ""
+}
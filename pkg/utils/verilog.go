@@ -0,0 +1,955 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// verilogPrimitives maps Verilog gate primitives to gate types
+var verilogPrimitives = map[string]circuit.GateType{
+	"and":  circuit.AND,
+	"nand": circuit.NAND,
+	"or":   circuit.OR,
+	"nor":  circuit.NOR,
+	"xor":  circuit.XOR,
+	"xnor": circuit.XNOR,
+	"not":  circuit.NOT,
+	"buf":  circuit.BUF,
+}
+
+// verilogToken is a lexical token of a structural Verilog netlist
+type verilogToken struct {
+	text    string
+	line    int
+	escaped bool // Escaped identifier, may contain any character
+}
+
+// verilogGate is a gate collected while parsing, before lines are created
+type verilogGate struct {
+	name    string
+	typ     circuit.GateType
+	table   uint64 // Truth table of a LUT
+	output  string
+	inputs  []string
+	srcLine int
+}
+
+// verilogParser is a recursive-descent parser for gate-level Verilog
+type verilogParser struct {
+	filename string
+	tokens   []verilogToken
+	pos      int
+
+	moduleName string
+	inputs     []string
+	outputs    []string
+	nets       []string        // All nets in order of first appearance
+	declared   map[string]bool // Nets seen so far
+	gates      []verilogGate
+	flops      []verilogGate // Flip-flops, with the Q net as output and the D net as input
+	tempCount  int
+	library    *CellLibrary // Cells instances may use, nil for primitives only
+}
+
+// ParseVerilogFile reads a structural gate-level Verilog netlist and returns a Circuit object.
+// It supports a single module with input/output/wire declarations, gate primitive
+// instances, dff instances read as scan cells and continuous assignments of
+// simple expressions.
+func ParseVerilogFile(filename string) (*circuit.Circuit, error) {
+	return ParseVerilogFileWithLibrary(filename, nil)
+}
+
+// ParseVerilogFileWithLibrary is ParseVerilogFile for a netlist that
+// instantiates the cells of a library. A cell instance connects its pins by
+// name, as in "NAND2X1 u1 (.A(a), .B(b), .Y(z));", or in order, outputs first
+// as for a primitive. Every input pin must be connected, an unconnected output
+// drives a net of its own. Each instance is expanded into gates that keep the
+// instance name.
+func ParseVerilogFileWithLibrary(filename string, library *CellLibrary) (*circuit.Circuit, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	tokens, err := tokenizeVerilog(filepath.Base(filename), string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &verilogParser{
+		filename: filepath.Base(filename),
+		tokens:   tokens,
+		declared: make(map[string]bool),
+		library:  library,
+	}
+	if err := p.parseModule(); err != nil {
+		return nil, err
+	}
+
+	c, err := p.build()
+	if err != nil {
+		return nil, err
+	}
+
+	// Analyze circuit topology
+	c.AnalyzeTopology()
+
+	return c, nil
+}
+
+// ParseNetlistFile reads a circuit in BENCH or Verilog format, chosen by file extension
+func ParseNetlistFile(filename string) (*circuit.Circuit, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".v", ".vg", ".sv":
+		return ParseVerilogFile(filename)
+	default:
+		return ParseBenchFile(filename)
+	}
+}
+
+// ParseNetlistFileWithPolicy is ParseNetlistFile validating BENCH files with
+// the given policy. The Verilog parser rejects the problems it finds itself
+// and reports no diagnostics.
+func ParseNetlistFileWithPolicy(filename string, policy ValidationPolicy) (*circuit.Circuit, []Diagnostic, error) {
+	return ParseNetlistFileWithLibrary(filename, policy, nil)
+}
+
+// ParseNetlistFileWithLibrary is ParseNetlistFileWithPolicy for a netlist that
+// may instantiate the cells of a library, which may be nil
+func ParseNetlistFileWithLibrary(filename string, policy ValidationPolicy, library *CellLibrary) (*circuit.Circuit, []Diagnostic, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".v", ".vg", ".sv":
+		c, err := ParseVerilogFileWithLibrary(filename, library)
+		return c, nil, err
+	default:
+		return ParseBenchFileWithLibrary(filename, policy, library)
+	}
+}
+
+// tokenizeVerilog splits Verilog source into tokens, dropping comments
+func tokenizeVerilog(filename, src string) ([]verilogToken, error) {
+	tokens := make([]verilogToken, 0)
+	line := 1
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case r == '\n':
+			line++
+			i++
+
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			start := line
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				if runes[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("%s:%d: unterminated block comment", filename, start)
+			}
+			i += 2
+
+		case r == '\\':
+			// Escaped identifier, terminated by white space
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, verilogToken{text: string(runes[start+1 : i]), line: line, escaped: true})
+
+		case isVerilogIdentStart(r) || unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (isVerilogIdentPart(runes[i]) || runes[i] == '\'') {
+				i++
+			}
+			tokens = append(tokens, verilogToken{text: string(runes[start:i]), line: line})
+
+		case i+1 < len(runes) && ((r == '~' && strings.ContainsRune("&|^", runes[i+1])) || (r == '^' && runes[i+1] == '~')):
+			tokens = append(tokens, verilogToken{text: string(runes[i : i+2]), line: line})
+			i += 2
+
+		case strings.ContainsRune("()[],;:=~&|^!.", r):
+			tokens = append(tokens, verilogToken{text: string(r), line: line})
+			i++
+
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected character %q", filename, line, r)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isVerilogIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isVerilogIdentPart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
+// errorf returns an error positioned at the current token
+func (p *verilogParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.pos < len(p.tokens) {
+		line = p.tokens[p.pos].line
+	} else if len(p.tokens) > 0 {
+		line = p.tokens[len(p.tokens)-1].line
+	}
+	return fmt.Errorf("%s:%d: %s", p.filename, line, fmt.Sprintf(format, args...))
+}
+
+// peek returns the current token text, or "" at end of input
+func (p *verilogParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+// line returns the source line of the current token
+func (p *verilogParser) line() int {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].line
+	}
+	return 0
+}
+
+// next consumes and returns the current token text
+func (p *verilogParser) next() string {
+	text := p.peek()
+	p.pos++
+	return text
+}
+
+// expect consumes the given token or reports an error
+func (p *verilogParser) expect(text string) error {
+	if p.peek() != text {
+		if p.pos >= len(p.tokens) {
+			return p.errorf("expected %q, found end of file", text)
+		}
+		return p.errorf("expected %q, found %q", text, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// identifier consumes an identifier token
+func (p *verilogParser) identifier() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", p.errorf("expected identifier, found end of file")
+	}
+	tok := p.tokens[p.pos]
+	if !tok.escaped && !isVerilogIdentStart([]rune(tok.text)[0]) {
+		return "", p.errorf("expected identifier, found %q", tok.text)
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+// declare records a net the first time it is seen
+func (p *verilogParser) declare(name string) {
+	if !p.declared[name] {
+		p.declared[name] = true
+		p.nets = append(p.nets, name)
+	}
+}
+
+// parseModule parses a single module definition
+func (p *verilogParser) parseModule() error {
+	if err := p.expect("module"); err != nil {
+		return err
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	p.moduleName = name
+
+	// Port list, either plain names or ANSI-style declarations. In an ANSI list
+	// the range applies to every port up to the next direction keyword.
+	if p.peek() == "(" {
+		p.next()
+		direction := ""
+		msb, lsb, hasRange := 0, 0, false
+		for p.peek() != ")" {
+			switch p.peek() {
+			case "input", "output":
+				direction = p.next()
+				if p.peek() == "wire" {
+					p.next()
+				}
+				hasRange = false
+				if p.peek() == "[" {
+					var err error
+					msb, lsb, err = p.parseRange()
+					if err != nil {
+						return err
+					}
+					hasRange = true
+				}
+				continue
+			case ",":
+				p.next()
+				continue
+			}
+
+			name, err := p.identifier()
+			if err != nil {
+				return err
+			}
+			if direction != "" {
+				p.addPorts(direction, expandBits(name, msb, lsb, hasRange))
+			}
+		}
+		p.next()
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	for {
+		switch keyword := p.peek(); keyword {
+		case "":
+			return p.errorf("missing endmodule")
+
+		case "endmodule":
+			p.next()
+			if p.peek() != "" {
+				return p.errorf("only one module per file is supported, found %q", p.peek())
+			}
+			return nil
+
+		case "input", "output", "wire":
+			p.next()
+			if err := p.parseDeclaration(keyword); err != nil {
+				return err
+			}
+
+		case "assign":
+			p.next()
+			if err := p.parseAssign(); err != nil {
+				return err
+			}
+
+		default:
+			if err := p.parseInstances(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// expandBits returns the bit names of a net, one per bit of its range
+func expandBits(name string, msb, lsb int, hasRange bool) []string {
+	if !hasRange {
+		return []string{name}
+	}
+
+	bits := make([]string, 0)
+	step := 1
+	if msb < lsb {
+		step = -1
+	}
+	for i := msb; ; i -= step {
+		bits = append(bits, fmt.Sprintf("%s[%d]", name, i))
+		if i == lsb {
+			break
+		}
+	}
+	return bits
+}
+
+// parseRange parses a bus range of the form [msb:lsb]
+func (p *verilogParser) parseRange() (int, int, error) {
+	if err := p.expect("["); err != nil {
+		return 0, 0, err
+	}
+	msb, err := p.number()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := p.expect(":"); err != nil {
+		return 0, 0, err
+	}
+	lsb, err := p.number()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := p.expect("]"); err != nil {
+		return 0, 0, err
+	}
+	return msb, lsb, nil
+}
+
+// number consumes an unsized decimal number
+func (p *verilogParser) number() (int, error) {
+	text := p.peek()
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, p.errorf("expected number, found %q", text)
+	}
+	p.pos++
+	return value, nil
+}
+
+// parseDeclaration parses the rest of an input, output or wire declaration
+func (p *verilogParser) parseDeclaration(direction string) error {
+	if direction != "wire" && p.peek() == "wire" {
+		p.next()
+	}
+
+	msb, lsb, hasRange := 0, 0, false
+	if p.peek() == "[" {
+		var err error
+		msb, lsb, err = p.parseRange()
+		if err != nil {
+			return err
+		}
+		hasRange = true
+	}
+
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return err
+		}
+
+		bits := expandBits(name, msb, lsb, hasRange)
+		if direction == "wire" {
+			for _, bit := range bits {
+				p.declare(bit)
+			}
+		} else {
+			p.addPorts(direction, bits)
+		}
+
+		if p.peek() == "," {
+			p.next()
+			continue
+		}
+		return p.expect(";")
+	}
+}
+
+// addPorts records input or output ports
+func (p *verilogParser) addPorts(direction string, bits []string) {
+	for _, bit := range bits {
+		p.declare(bit)
+		if direction == "input" {
+			p.inputs = append(p.inputs, bit)
+		} else {
+			p.outputs = append(p.outputs, bit)
+		}
+	}
+}
+
+// netReference parses a net name with an optional bit select. The net must
+// have been declared before.
+func (p *verilogParser) netReference() (string, error) {
+	srcLine := p.line()
+	name, err := p.identifier()
+	if err != nil {
+		return "", err
+	}
+	if p.peek() == "[" {
+		p.next()
+		index, err := p.number()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s[%d]", name, index)
+	}
+	if !p.declared[name] {
+		return "", fmt.Errorf("%s:%d: undeclared net %s", p.filename, srcLine, name)
+	}
+	return name, nil
+}
+
+// parseInstances parses one or more gate primitive instances of the same type
+func (p *verilogParser) parseInstances() error {
+	srcLine := p.line()
+	typeName := p.next()
+	gateType, ok := verilogPrimitives[typeName]
+	if cell := p.library.Cell(typeName); cell != nil {
+		return p.parseCellInstances(cell, srcLine)
+	}
+	if !ok && typeName != "dff" {
+		p.pos--
+		return p.errorf("unknown primitive or cell %q", typeName)
+	}
+
+	for {
+		instLine := p.line()
+		name := ""
+		if p.peek() != "(" {
+			var err error
+			if name, err = p.identifier(); err != nil {
+				return err
+			}
+		}
+		if err := p.expect("("); err != nil {
+			return err
+		}
+
+		terminals := make([]string, 0)
+		for {
+			net, err := p.netReference()
+			if err != nil {
+				return err
+			}
+			terminals = append(terminals, net)
+			if p.peek() == "," {
+				p.next()
+				continue
+			}
+			break
+		}
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+
+		// A flip-flop connects (Q, D), or (CK, Q, D) as in the ISCAS-89
+		// netlists; the clock is implicit under full scan
+		if typeName == "dff" {
+			if len(terminals) != 2 && len(terminals) != 3 {
+				return fmt.Errorf("%s:%d: dff instance must connect (Q, D) or (CK, Q, D)",
+					p.filename, instLine)
+			}
+			terminals = terminals[len(terminals)-2:]
+			p.flops = append(p.flops, verilogGate{
+				name: name, output: terminals[0], inputs: terminals[1:], srcLine: srcLine,
+			})
+			if p.peek() == "," {
+				p.next()
+				continue
+			}
+			return p.expect(";")
+		}
+
+		if len(terminals) < 2 {
+			return fmt.Errorf("%s:%d: %s instance needs an output and at least one input",
+				p.filename, instLine, typeName)
+		}
+		if (gateType == circuit.NOT || gateType == circuit.BUF) && len(terminals) != 2 {
+			return fmt.Errorf("%s:%d: %s instance must have exactly one input",
+				p.filename, instLine, typeName)
+		}
+
+		// Gates evaluate XOR and XNOR over two inputs, so wider instances
+		// become a chain of 2-input XOR gates, as for "assign y = a ^ b ^ c"
+		inputs := terminals[1:]
+		if (gateType == circuit.XOR || gateType == circuit.XNOR) && len(inputs) > 2 {
+			acc := inputs[0]
+			for _, input := range inputs[1 : len(inputs)-1] {
+				p.tempCount++
+				temp := fmt.Sprintf("%s$%d", terminals[0], p.tempCount)
+				p.declare(temp)
+				p.gates = append(p.gates, verilogGate{
+					typ: circuit.XOR, output: temp, inputs: []string{acc, input}, srcLine: srcLine,
+				})
+				acc = temp
+			}
+			inputs = []string{acc, inputs[len(inputs)-1]}
+		}
+
+		p.gates = append(p.gates, verilogGate{
+			name:    name,
+			typ:     gateType,
+			output:  terminals[0],
+			inputs:  inputs,
+			srcLine: srcLine,
+		})
+
+		if p.peek() == "," {
+			p.next()
+			continue
+		}
+		return p.expect(";")
+	}
+}
+
+// parseCellInstances parses one or more instances of a library cell and
+// expands them into gates and flip-flops
+func (p *verilogParser) parseCellInstances(cell *Cell, srcLine int) error {
+	ordered := append(append([]string{}, cell.Outputs...), cell.Inputs...)
+	for {
+		instLine := p.line()
+		name, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("("); err != nil {
+			return err
+		}
+
+		pins := make(map[string]string)
+		connected := make(map[string]bool)
+		for position := 0; p.peek() != ")"; position++ {
+			if position > 0 {
+				if err := p.expect(","); err != nil {
+					return err
+				}
+			}
+
+			pin := ""
+			named := p.peek() == "."
+			switch {
+			case named:
+				p.next()
+				if pin, err = p.identifier(); err != nil {
+					return err
+				}
+				if indexOf(ordered, pin) < 0 {
+					return fmt.Errorf("%s:%d: cell %s has no pin %s", p.filename, instLine, cell.Name, pin)
+				}
+				if err := p.expect("("); err != nil {
+					return err
+				}
+			case position < len(ordered):
+				pin = ordered[position]
+			default:
+				return fmt.Errorf("%s:%d: %s connects more than the %d pins of cell %s",
+					p.filename, instLine, name, len(ordered), cell.Name)
+			}
+			if connected[pin] {
+				return fmt.Errorf("%s:%d: pin %s of %s is connected twice", p.filename, instLine, pin, name)
+			}
+			connected[pin] = true
+
+			// A named connection may leave the pin unconnected, as in .QN()
+			if !named || p.peek() != ")" {
+				if pins[pin], err = p.netReference(); err != nil {
+					return err
+				}
+			}
+			if named {
+				if err := p.expect(")"); err != nil {
+					return err
+				}
+			}
+		}
+		p.next()
+		for _, pin := range cell.Inputs {
+			if _, ok := pins[pin]; !ok {
+				return fmt.Errorf("%s:%d: input pin %s of %s is not connected", p.filename, instLine, pin, name)
+			}
+		}
+
+		instance := cell.expand(name, pins)
+		for _, net := range instance.nets {
+			p.declare(net)
+		}
+		for _, g := range instance.gates {
+			p.gates = append(p.gates, verilogGate{
+				name: g.name, typ: g.typ, table: g.table, output: g.output, inputs: g.inputs, srcLine: srcLine,
+			})
+		}
+		for _, flop := range instance.flops {
+			p.flops = append(p.flops, verilogGate{
+				name: name, output: flop[0], inputs: []string{flop[1]}, srcLine: srcLine,
+			})
+		}
+
+		if p.peek() == "," {
+			p.next()
+			continue
+		}
+		return p.expect(";")
+	}
+}
+
+// verilogExpr is a parsed assign expression: either a net or an operator over operands
+type verilogExpr struct {
+	net      string
+	op       circuit.GateType
+	operands []*verilogExpr
+}
+
+// parseAssign parses a continuous assignment and lowers it to gates
+func (p *verilogParser) parseAssign() error {
+	srcLine := p.line()
+	lhs, err := p.netReference()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	if expr.net != "" {
+		// Plain connection becomes a buffer
+		p.gates = append(p.gates, verilogGate{
+			typ: circuit.BUF, output: lhs, inputs: []string{expr.net}, srcLine: srcLine,
+		})
+		return nil
+	}
+
+	p.lowerExpr(expr, lhs, srcLine)
+	return nil
+}
+
+// parseOr parses "a | b" chains, the lowest-precedence operator
+func (p *verilogParser) parseOr() (*verilogExpr, error) {
+	return p.parseBinary("|", circuit.OR, p.parseXor)
+}
+
+// parseXor parses "a ^ b" chains
+func (p *verilogParser) parseXor() (*verilogExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "^" || p.peek() == "~^" || p.peek() == "^~" {
+		inverted := p.next() != "^"
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &verilogExpr{op: circuit.XOR, operands: []*verilogExpr{left, right}}
+		if inverted {
+			left.op = circuit.XNOR
+		}
+	}
+	return left, nil
+}
+
+// parseAnd parses "a & b" chains
+func (p *verilogParser) parseAnd() (*verilogExpr, error) {
+	return p.parseBinary("&", circuit.AND, p.parseUnary)
+}
+
+// parseBinary parses a left-associative chain of one associative operator into a single gate
+func (p *verilogParser) parseBinary(operator string, gateType circuit.GateType,
+	operand func() (*verilogExpr, error)) (*verilogExpr, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != operator {
+		return first, nil
+	}
+
+	expr := &verilogExpr{op: gateType, operands: []*verilogExpr{first}}
+	for p.peek() == operator {
+		p.next()
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		expr.operands = append(expr.operands, next)
+	}
+	return expr, nil
+}
+
+// parseUnary parses negation, parentheses and net references
+func (p *verilogParser) parseUnary() (*verilogExpr, error) {
+	switch p.peek() {
+	case "~", "!":
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return invertExpr(operand), nil
+
+	case "~&", "~|", "~^":
+		return nil, p.errorf("reduction operator %q is not supported", p.peek())
+
+	case "(":
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	if text := p.peek(); text != "" && unicode.IsDigit([]rune(text)[0]) {
+		return nil, p.errorf("constant %q is not supported in assign expressions", text)
+	}
+
+	net, err := p.netReference()
+	if err != nil {
+		return nil, err
+	}
+	return &verilogExpr{net: net}, nil
+}
+
+// invertExpr applies a logical inversion, folding it into the operator where possible
+func invertExpr(expr *verilogExpr) *verilogExpr {
+	if expr.net == "" {
+		switch expr.op {
+		case circuit.AND:
+			return &verilogExpr{op: circuit.NAND, operands: expr.operands}
+		case circuit.OR:
+			return &verilogExpr{op: circuit.NOR, operands: expr.operands}
+		case circuit.XOR:
+			return &verilogExpr{op: circuit.XNOR, operands: expr.operands}
+		case circuit.NAND:
+			return &verilogExpr{op: circuit.AND, operands: expr.operands}
+		case circuit.NOR:
+			return &verilogExpr{op: circuit.OR, operands: expr.operands}
+		case circuit.XNOR:
+			return &verilogExpr{op: circuit.XOR, operands: expr.operands}
+		case circuit.NOT:
+			return expr.operands[0]
+		}
+	}
+	return &verilogExpr{op: circuit.NOT, operands: []*verilogExpr{expr}}
+}
+
+// lowerExpr creates gates computing expr onto the output net
+func (p *verilogParser) lowerExpr(expr *verilogExpr, output string, srcLine int) {
+	inputs := make([]string, 0, len(expr.operands))
+	for _, operand := range expr.operands {
+		if operand.net != "" {
+			inputs = append(inputs, operand.net)
+			continue
+		}
+
+		// Sub-expressions drive generated intermediate nets
+		p.tempCount++
+		temp := fmt.Sprintf("%s$%d", output, p.tempCount)
+		p.declare(temp)
+		p.lowerExpr(operand, temp, srcLine)
+		inputs = append(inputs, temp)
+	}
+
+	p.gates = append(p.gates, verilogGate{
+		typ: expr.op, output: output, inputs: inputs, srcLine: srcLine,
+	})
+}
+
+// build creates the circuit from the parsed module
+func (p *verilogParser) build() (*circuit.Circuit, error) {
+	c := circuit.NewCircuit(p.moduleName)
+	lineMap := make(map[string]*circuit.Line)
+	nextLineID := 0
+
+	isInput := make(map[string]bool)
+	for _, name := range p.inputs {
+		isInput[name] = true
+	}
+	isOutput := make(map[string]bool)
+	for _, name := range p.outputs {
+		isOutput[name] = true
+	}
+
+	for _, name := range p.nets {
+		lineType := circuit.Normal
+		if isInput[name] {
+			lineType = circuit.PrimaryInput
+		} else if isOutput[name] {
+			lineType = circuit.PrimaryOutput
+		}
+
+		l := circuit.NewLine(nextLineID, name, lineType)
+		lineMap[name] = l
+		c.AddLine(l)
+		nextLineID++
+	}
+
+	// Unnamed instances and assignments get generated names that avoid instance names
+	usedNames := make(map[string]bool)
+	for _, g := range p.gates {
+		usedNames[g.name] = true
+	}
+	nextName := 0
+
+	drivers := make(map[string]int) // Source line of the driver of each net
+	for _, g := range p.flops {
+		if isInput[g.output] {
+			return nil, fmt.Errorf("%s:%d: primary input %s cannot be driven by a flip-flop",
+				p.filename, g.srcLine, g.output)
+		}
+		if prev, exists := drivers[g.output]; exists {
+			return nil, fmt.Errorf("%s:%d: net %s is already driven at line %d",
+				p.filename, g.srcLine, g.output, prev)
+		}
+		drivers[g.output] = g.srcLine
+	}
+	for i, g := range p.gates {
+		if isInput[g.output] {
+			return nil, fmt.Errorf("%s:%d: primary input %s cannot be driven by a gate",
+				p.filename, g.srcLine, g.output)
+		}
+		if prev, exists := drivers[g.output]; exists {
+			return nil, fmt.Errorf("%s:%d: net %s is already driven at line %d",
+				p.filename, g.srcLine, g.output, prev)
+		}
+		drivers[g.output] = g.srcLine
+
+		name := g.name
+		for name == "" || (g.name == "" && usedNames[name]) {
+			name = fmt.Sprintf("g%d", nextName)
+			nextName++
+		}
+		gate := circuit.NewGate(i, name, g.typ)
+		if g.typ == circuit.LUT {
+			gate.Table = g.table
+		}
+		gate.SetOutput(lineMap[g.output])
+		for _, input := range g.inputs {
+			gate.AddInput(lineMap[input])
+		}
+		c.AddGate(gate)
+	}
+
+	for _, name := range p.outputs {
+		if _, driven := drivers[name]; !driven && !isInput[name] {
+			return nil, fmt.Errorf("%s: output %s is never driven", p.filename, name)
+		}
+	}
+	for _, gates := range [][]verilogGate{p.gates, p.flops} {
+		for _, g := range gates {
+			for _, input := range g.inputs {
+				if _, driven := drivers[input]; !driven && !isInput[input] {
+					return nil, fmt.Errorf("%s:%d: net %s is read but never driven",
+						p.filename, g.srcLine, input)
+				}
+			}
+		}
+	}
+
+	// Full scan: each flip-flop becomes a pseudo-primary input (Q) and output (D)
+	flops := make([][2]string, len(p.flops))
+	for i, g := range p.flops {
+		flops[i] = [2]string{g.output, g.inputs[0]}
+	}
+	addScanCells(c, flops, lineMap)
+
+	return c, nil
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// verilogIdentRegex matches a simple Verilog identifier; other names are escaped
+var verilogIdentRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// verilogKeywords are the keywords a net name must not be written as
+var verilogKeywords = map[string]bool{
+	"module": true, "endmodule": true, "input": true, "output": true, "inout": true,
+	"wire": true, "reg": true, "assign": true, "dff": true,
+	"and": true, "nand": true, "or": true, "nor": true, "xor": true, "xnor": true,
+	"not": true, "buf": true,
+}
+
+// netlist is the structure of a circuit as a netlist file describes it: the
+// gates in topological order and a flip-flop per scan cell. The parsers read a
+// flip-flop that captures an input through a buffer, which is left out here,
+// since the flip-flop of the file restores it.
+type netlist struct {
+	gates []*circuit.Gate
+	flops [][2]*circuit.Line // Q and D line of each flip-flop
+	wires []*circuit.Line    // Lines that are neither primary inputs nor outputs, by ID
+}
+
+// newNetlist returns the netlist of a circuit
+func newNetlist(c *circuit.Circuit) *netlist {
+	n := &netlist{}
+	captureBuffers := make(map[*circuit.Gate]bool)
+	for _, cell := range c.ScanCells {
+		d := cell.D
+		if gate := d.InputGate; gate != nil && gate.Type == circuit.BUF && d.Name == cell.Name+"$D" {
+			captureBuffers[gate] = true
+			d = gate.Inputs[0]
+		}
+		n.flops = append(n.flops, [2]*circuit.Line{cell.Q, d})
+	}
+
+	topo := circuit.NewTopology(c)
+	topo.ComputeLevels()
+	for _, gate := range topo.LevelizedGates() {
+		if !captureBuffers[gate] {
+			n.gates = append(n.gates, gate)
+		}
+	}
+
+	ports := make(map[*circuit.Line]bool)
+	for _, line := range append(c.PrimaryInputs(), c.PrimaryOutputs()...) {
+		ports[line] = true
+	}
+	for _, line := range c.SortedLines() {
+		if !ports[line] && !(line.InputGate != nil && captureBuffers[line.InputGate]) {
+			n.wires = append(n.wires, line)
+		}
+	}
+	return n
+}
+
+// WriteNetlistFile writes a circuit in BENCH or Verilog format, chosen by file
+// extension as for ParseNetlistFile
+func WriteNetlistFile(filename string, c *circuit.Circuit) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".v", ".vg", ".sv":
+		return WriteVerilog(filename, c)
+	default:
+		return WriteBench(filename, c)
+	}
+}
+
+// WriteBench writes a circuit in BENCH format: the primary inputs and outputs,
+// a DFF for every scan cell, then every gate in topological order, a LUT with
+// its truth table in hex. Net names
+// are kept, so reading the file back gives the same circuit, and the output
+// only depends on the circuit. A name BENCH cannot express, such as one with a
+// space or a comma, is an error.
+func WriteBench(filename string, c *circuit.Circuit) error {
+	for _, line := range c.Lines {
+		if !netRegex.MatchString(line.Name) || strings.HasPrefix(line.Name, "#") {
+			return fmt.Errorf("net name %q cannot be written in BENCH format", line.Name)
+		}
+	}
+	n := newNetlist(c)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	fmt.Fprintf(writer, "# %s\n", c.Name)
+	fmt.Fprintf(writer, "# Written by FAN-ATPG\n\n")
+	for _, input := range c.PrimaryInputs() {
+		fmt.Fprintf(writer, "INPUT(%s)\n", input.Name)
+	}
+	writer.WriteString("\n")
+	for _, output := range c.PrimaryOutputs() {
+		fmt.Fprintf(writer, "OUTPUT(%s)\n", output.Name)
+	}
+	writer.WriteString("\n")
+
+	if len(n.flops) > 0 {
+		for _, flop := range n.flops {
+			fmt.Fprintf(writer, "%s = DFF(%s)\n", flop[0].Name, flop[1].Name)
+		}
+		writer.WriteString("\n")
+	}
+
+	for _, gate := range n.gates {
+		names := make([]string, len(gate.Inputs))
+		for i, input := range gate.Inputs {
+			names[i] = input.Name
+		}
+		if gate.Type == circuit.LUT {
+			fmt.Fprintf(writer, "%s = LUT %#x(%s)\n", gate.Output.Name, gate.Table, strings.Join(names, ", "))
+			continue
+		}
+		fmt.Fprintf(writer, "%s = %v(%s)\n", gate.Output.Name, gate.Type, strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// WriteVerilog writes a circuit as a structural Verilog module: a port per
+// primary input and output, a dff instance (Q, D) for every scan cell, then a
+// primitive instance per gate in topological order. Names that are not simple
+// Verilog identifiers, such as the numbered nets of ISCAS netlists, are
+// written as escaped identifiers, so every net keeps its name. A gate keeps its
+// instance name unless a net has the same name.
+func WriteVerilog(filename string, c *circuit.Circuit) error {
+	primitives := make(map[circuit.GateType]string, len(verilogPrimitives))
+	for name, gateType := range verilogPrimitives {
+		primitives[gateType] = name
+	}
+	for _, line := range c.Lines {
+		if strings.IndexFunc(line.Name, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("net name %q cannot be written in Verilog", line.Name)
+		}
+	}
+	n := newNetlist(c)
+	for _, gate := range n.gates {
+		if _, ok := primitives[gate.Type]; !ok {
+			return fmt.Errorf("gate %s of type %v has no Verilog primitive", gate.Name, gate.Type)
+		}
+	}
+	nets := make(map[string]bool, len(c.Lines))
+	for _, line := range c.Lines {
+		nets[line.Name] = true
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	inputs, outputs := c.PrimaryInputs(), c.PrimaryOutputs()
+	ports := make([]string, 0, len(inputs)+len(outputs))
+	for _, line := range append(inputs, outputs...) {
+		ports = append(ports, verilogName(line.Name))
+	}
+
+	fmt.Fprintf(writer, "// %s\n", c.Name)
+	fmt.Fprintf(writer, "// Written by FAN-ATPG\n")
+	fmt.Fprintf(writer, "module %s(%s);\n", verilogModuleName(c.Name), strings.Join(ports, ", "))
+	for _, input := range inputs {
+		fmt.Fprintf(writer, "  input %s;\n", verilogName(input.Name))
+	}
+	for _, output := range outputs {
+		fmt.Fprintf(writer, "  output %s;\n", verilogName(output.Name))
+	}
+	for _, wire := range n.wires {
+		fmt.Fprintf(writer, "  wire %s;\n", verilogName(wire.Name))
+	}
+	writer.WriteString("\n")
+
+	if len(n.flops) > 0 {
+		for _, flop := range n.flops {
+			fmt.Fprintf(writer, "  dff (%s, %s);\n", verilogName(flop[0].Name), verilogName(flop[1].Name))
+		}
+		writer.WriteString("\n")
+	}
+
+	for _, gate := range n.gates {
+		terminals := []string{verilogName(gate.Output.Name)}
+		for _, input := range gate.Inputs {
+			terminals = append(terminals, verilogName(input.Name))
+		}
+		instance := ""
+		if gate.Name != "" && !nets[gate.Name] && strings.IndexFunc(gate.Name, unicode.IsSpace) < 0 {
+			instance = strings.TrimSuffix(verilogName(gate.Name), " ") + " "
+		}
+		fmt.Fprintf(writer, "  %s %s(%s);\n", primitives[gate.Type], instance, strings.Join(terminals, ", "))
+	}
+	writer.WriteString("endmodule\n")
+
+	return nil
+}
+
+// verilogName returns a name as a Verilog identifier, escaping it if needed.
+// An escaped identifier ends at the next white space, which is part of it.
+func verilogName(name string) string {
+	if verilogIdentRegex.MatchString(name) && !verilogKeywords[name] {
+		return name
+	}
+	return `\` + name + " "
+}
+
+// verilogModuleName returns a circuit name as a simple Verilog identifier,
+// replacing the characters an identifier cannot have
+func verilogModuleName(name string) string {
+	runes := []rune(name)
+	for i, r := range runes {
+		if !isVerilogIdentPart(r) {
+			runes[i] = '_'
+		}
+	}
+	if len(runes) == 0 || !isVerilogIdentStart(runes[0]) {
+		runes = append([]rune{'_'}, runes...)
+	}
+	return string(runes)
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestBranchFaultInjection tests that a branch fault is only seen by its branch gate
+func TestBranchFaultInjection(t *testing.T) {
+	// in1, in2 -> AND(g1) -> w1; w1, in2 -> OR(g2) -> out
+	c := createTestCircuit()
+	in2 := findLine(c, "in2")
+	g1 := findGate(c, "g1")
+	g2 := findGate(c, "g2")
+
+	c.InjectBranchFault(in2, g1, circuit.Zero)
+	in2.SetValue(circuit.One)
+
+	// The stem keeps its good value
+	if in2.Value != circuit.One {
+		t.Errorf("Expected stem in2 to keep value 1, got %v", in2.Value)
+	}
+
+	// Only the branch into g1 carries the fault effect
+	if v := g1.InputValue(in2); v != circuit.Dnot {
+		t.Errorf("Expected g1 to see D' on in2, got %v", v)
+	}
+	if v := g2.InputValue(in2); v != circuit.One {
+		t.Errorf("Expected g2 to see 1 on in2, got %v", v)
+	}
+	if !g1.HasFaultyInput() || g2.HasFaultyInput() {
+		t.Errorf("Expected only g1 to have a faulty input")
+	}
+
+	// Reset clears the branch fault
+	c.Reset()
+	if in2.FaultBranch != nil || c.FaultBranch != nil {
+		t.Errorf("Expected branch fault to be cleared by Reset")
+	}
+}
+
+// TestBranchFaultSimulation tests that branch and stem faults are simulated differently
+func TestBranchFaultSimulation(t *testing.T) {
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	sim := simulation.NewFaultSimulator(c, topo, utils.NewLogger(utils.ErrorLevel))
+
+	in2 := findLine(c, "in2")
+	g2 := findGate(c, "g2")
+
+	// in1=1, in2=1: the stem fault in2/0 forces out to 0, but with only the branch
+	// into g2 faulty, w1=1 still drives out to 1
+	patterns := []map[string]circuit.LogicValue{
+		{"in1": circuit.One, "in2": circuit.One},
+		{"in1": circuit.Zero, "in2": circuit.One},
+	}
+	faults := []circuit.Fault{
+		{Line: in2, Type: circuit.Zero},
+		{Line: in2, Type: circuit.Zero, Branch: g2},
+	}
+
+	detected := sim.Detect(patterns, faults)
+	if detected[0] != 0 {
+		t.Errorf("Expected stem fault in2/0 to be detected by pattern 0, got %d", detected[0])
+	}
+	if detected[1] != 1 {
+		t.Errorf("Expected branch fault in2->g2/0 to be detected by pattern 1, got %d", detected[1])
+	}
+}
+
+// TestParseBranchFault tests parsing of the stem->gate/value fault syntax
+func TestParseBranchFault(t *testing.T) {
+	c := createTestCircuit()
+
+	fault, err := utils.ParseFault("in2->g1/0", c)
+	if err != nil {
+		t.Fatalf("Failed to parse branch fault: %v", err)
+	}
+	if fault.Line.Name != "in2" || fault.Branch != findGate(c, "g1") || fault.Type != circuit.Zero {
+		t.Errorf("Expected in2->g1/0, got %s", fault)
+	}
+
+	// The branch is named by its gate, not by the gate's output line
+	if _, err := utils.ParseFault("in2->out/1", c); err == nil {
+		t.Errorf("Expected error for a branch named by an output line")
+	}
+
+	// Stem faults still parse as before
+	fault, err = utils.ParseFault("w1/1", c)
+	if err != nil || fault.IsBranch() || fault.Line.Name != "w1" {
+		t.Errorf("Expected stem fault w1/1, got %v (err %v)", fault, err)
+	}
+
+	// in1 does not feed g2
+	if _, err := utils.ParseFault("in1->g2/0", c); err == nil {
+		t.Errorf("Expected error for a branch that does not exist")
+	}
+}
+
+// TestFindTestForBranchFault tests test generation for a fanout-branch fault
+func TestFindTestForBranchFault(t *testing.T) {
+	c := createTestCircuit()
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	fault := circuit.Fault{Line: findLine(c, "in2"), Type: circuit.Zero, Branch: findGate(c, "g2")}
+	test, err := fan.FindTestForFault(fault)
+	if err != nil {
+		t.Fatalf("Failed to find test for %s: %v", fault, err)
+	}
+
+	// The only test is in1=0, in2=1
+	if test["in1"] != circuit.Zero || test["in2"] != circuit.One {
+		t.Errorf("Expected test in1=0, in2=1 for %s, got %v", fault, test)
+	}
+}
+
+// TestFindTestForC17BranchFaults checks every branch-fault test of c17 with the fault simulator
+func TestFindTestForC17BranchFaults(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	branches := 0
+	for _, fault := range circuit.NewFaultList(c, false).All {
+		if !fault.IsBranch() {
+			continue
+		}
+		branches++
+
+		// c17 has no redundant faults
+		test, err := fan.FindTestForFault(fault)
+		if err != nil {
+			t.Errorf("Failed to find test for %s: %v", fault, err)
+			continue
+		}
+		patterns := []map[string]circuit.LogicValue{test}
+		if fan.Simulator.Detect(patterns, []circuit.Fault{fault})[0] != 0 {
+			t.Errorf("Test %v does not detect %s", test, fault)
+		}
+	}
+
+	// Stems 3, 11 and 16 each fan out to two gates
+	if branches != 12 {
+		t.Errorf("Expected 12 branch faults in c17, got %d", branches)
+	}
+}
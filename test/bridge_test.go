@@ -0,0 +1,236 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// detectsBridge simulates a test on the good circuit and on the bridged circuit,
+// where both bridged lines take the value resolved by the bridge model, and
+// reports whether an output differs
+func detectsBridge(c *circuit.Circuit, bridge circuit.Bridge, test map[string]circuit.LogicValue) bool {
+	good := goodValues(c, test)
+	a, b := good[bridge.A], good[bridge.B]
+	switch bridge.Model {
+	case circuit.WiredAND:
+		if a == circuit.Zero || b == circuit.Zero {
+			a, b = circuit.Zero, circuit.Zero
+		}
+	case circuit.WiredOR:
+		if a == circuit.One || b == circuit.One {
+			a, b = circuit.One, circuit.One
+		}
+	case circuit.Dominant:
+		b = a
+	}
+
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	c.Reset()
+	defer c.Reset()
+	for _, input := range c.Inputs {
+		input.SetValue(test[input.Name])
+	}
+	bridge.A.SetValue(a)
+	bridge.B.SetValue(b)
+	for _, gate := range topo.LevelizedGates() {
+		if gate.Output != bridge.A && gate.Output != bridge.B {
+			gate.Output.SetValue(gate.Evaluate())
+		}
+	}
+
+	for _, output := range c.Outputs {
+		if good[output] != circuit.X && output.Value != circuit.X && good[output] != output.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// TestBridgeTargets tests the reduction of bridges to constrained stuck-at faults
+func TestBridgeTargets(t *testing.T) {
+	c := createC17Circuit(t)
+	a, b := findLine(c, "10"), findLine(c, "19")
+
+	tests := []struct {
+		model    circuit.BridgeModel
+		name     string
+		expected []string
+	}{
+		{circuit.WiredAND, "wand(10, 19)", []string{"10/0 if 19=0", "19/0 if 10=0"}},
+		{circuit.WiredOR, "wor(10, 19)", []string{"10/1 if 19=1", "19/1 if 10=1"}},
+		{circuit.Dominant, "dom(10, 19)", []string{"19/0 if 10=0", "19/1 if 10=1"}},
+	}
+	for _, tt := range tests {
+		bridge := circuit.Bridge{A: a, B: b, Model: tt.model}
+		if bridge.String() != tt.name {
+			t.Errorf("Expected %s, got %s", tt.name, bridge)
+		}
+		targets := bridge.Targets()
+		if len(targets) != len(tt.expected) {
+			t.Fatalf("Expected %d targets for %s, got %d", len(tt.expected), bridge, len(targets))
+		}
+		for i, target := range targets {
+			got := target.Fault.String() + " if " + target.Condition.Line.Name + "=" + target.Condition.Value.String()
+			if got != tt.expected[i] {
+				t.Errorf("Expected target %q for %s, got %q", tt.expected[i], bridge, got)
+			}
+		}
+	}
+}
+
+// TestValidateBridge tests that feedback and self bridges are rejected
+func TestValidateBridge(t *testing.T) {
+	c := createC17Circuit(t)
+
+	valid := circuit.Bridge{A: findLine(c, "10"), B: findLine(c, "19"), Model: circuit.WiredAND}
+	if err := c.ValidateBridge(valid); err != nil {
+		t.Errorf("Expected %s to be valid, got %v", valid, err)
+	}
+	feedback := circuit.Bridge{A: findLine(c, "11"), B: findLine(c, "16"), Model: circuit.WiredOR}
+	if err := c.ValidateBridge(feedback); err == nil || !strings.Contains(err.Error(), "feedback") {
+		t.Errorf("Expected %s to be rejected as a feedback bridge, got %v", feedback, err)
+	}
+	self := circuit.Bridge{A: findLine(c, "10"), B: findLine(c, "10"), Model: circuit.Dominant}
+	if err := c.ValidateBridge(self); err == nil {
+		t.Errorf("Expected %s to be rejected", self)
+	}
+}
+
+// TestParseBridgeFile tests reading a list of bridges
+func TestParseBridgeFile(t *testing.T) {
+	c := createC17Circuit(t)
+	dir := t.TempDir()
+
+	bridgeFile := filepath.Join(dir, "c17.bridges")
+	content := "# c17 bridges\n10 19\n\n1 2 wor\n10 23 dom\n"
+	if err := os.WriteFile(bridgeFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write bridge file: %v", err)
+	}
+	bridges, err := utils.ParseBridgeFile(bridgeFile, c, circuit.WiredAND)
+	if err != nil {
+		t.Fatalf("Failed to parse bridge file: %v", err)
+	}
+	expected := []string{"wand(10, 19)", "wor(1, 2)", "dom(10, 23)"}
+	if len(bridges) != len(expected) {
+		t.Fatalf("Expected %d bridges, got %d", len(expected), len(bridges))
+	}
+	for i, bridge := range bridges {
+		if bridge.String() != expected[i] {
+			t.Errorf("Expected bridge %s, got %s", expected[i], bridge)
+		}
+	}
+
+	invalid := map[string]string{
+		"10 99\n":        "line not found: 99",
+		"10 19 short\n":  "unknown bridge model",
+		"# ok\n11 16\n":  ":2: bridge wand(11, 16) is a feedback bridge",
+		"10 19 wand x\n": "expected two line names",
+	}
+	for content, message := range invalid {
+		if err := os.WriteFile(bridgeFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write bridge file: %v", err)
+		}
+		if _, err := utils.ParseBridgeFile(bridgeFile, c, circuit.WiredAND); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected error containing %q for %q, got %v", message, content, err)
+		}
+	}
+}
+
+// TestBridgeATPGC17 tests that every test generated for the c17 bridges detects its bridge
+func TestBridgeATPGC17(t *testing.T) {
+	c := createC17Circuit(t)
+	logger := utils.NewLogger(utils.ErrorLevel)
+	atpg := algorithm.NewBridgeATPG(c, logger)
+
+	names := [][2]string{{"10", "19"}, {"1", "2"}, {"10", "11"}, {"22", "23"}, {"3", "7"}, {"16", "19"}}
+	var bridges []circuit.Bridge
+	for _, model := range []circuit.BridgeModel{circuit.WiredAND, circuit.WiredOR, circuit.Dominant} {
+		for _, pair := range names {
+			bridges = append(bridges, circuit.Bridge{A: findLine(c, pair[0]), B: findLine(c, pair[1]), Model: model})
+		}
+	}
+
+	for _, bridge := range bridges {
+		result := atpg.GenerateTest(bridge)
+		if result.Status != algorithm.Detected {
+			t.Errorf("Expected a test for %s, got %v: %v", bridge, result.Status, result.Err())
+			continue
+		}
+		if !detectsBridge(c, bridge, result.Test) {
+			t.Errorf("Test %v does not detect %s", result.Test, bridge)
+		}
+	}
+
+	tests, err := atpg.GenerateTestsForAllFaults(bridges)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atpg.Stats.UndetectedFaults != 0 {
+		t.Errorf("Expected every bridge to be detected, %d are not", atpg.Stats.UndetectedFaults)
+	}
+	if len(tests) >= len(bridges) {
+		t.Errorf("Expected fault dropping to need fewer than %d tests, got %d", len(bridges), len(tests))
+	}
+	for _, bridge := range bridges {
+		detected := false
+		for _, test := range tests {
+			if detectsBridge(c, bridge, test) {
+				detected = true
+				break
+			}
+		}
+		if !detected {
+			t.Errorf("No generated test detects %s", bridge)
+		}
+	}
+
+	first := atpg.Fan.Simulator.DetectBridges(tests, bridges)
+	for i, bridge := range bridges {
+		if first[i] < 0 || !detectsBridge(c, bridge, tests[first[i]]) {
+			t.Errorf("Fault simulation reports test %d for %s", first[i], bridge)
+		}
+	}
+
+	if err := atpg.VerifyTests(bridges, tests); err != nil {
+		t.Errorf("Expected the tests to pass verification, got %v", err)
+	}
+	if err := atpg.VerifyTests(bridges, nil); !errors.Is(err, simulation.ErrNotDetected) {
+		t.Errorf("Expected no tests to fail verification, got %v", err)
+	}
+}
+
+// TestBridgeRedundant tests a wired-AND bridge between the inputs of an AND gate,
+// which never changes its output
+func TestBridgeRedundant(t *testing.T) {
+	c, err := parseBench(t, "INPUT(a)\nINPUT(b)\nOUTPUT(z)\nz = AND(a, b)\n")
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	atpg := algorithm.NewBridgeATPG(c, utils.NewLogger(utils.ErrorLevel))
+	a, b := findLine(c, "a"), findLine(c, "b")
+
+	wand := atpg.GenerateTest(circuit.Bridge{A: a, B: b, Model: circuit.WiredAND})
+	if wand.Status != algorithm.Redundant {
+		t.Errorf("Expected %s to be redundant, got %v", wand.Fault, wand.Status)
+	}
+	if wand.Err() == nil {
+		t.Errorf("Expected an error for a redundant bridge")
+	}
+
+	wor := atpg.GenerateTest(circuit.Bridge{A: a, B: b, Model: circuit.WiredOR})
+	if wor.Status != algorithm.Detected || !detectsBridge(c, wor.Fault, wor.Test) {
+		t.Errorf("Expected a test for %s, got %v with %v", wor.Fault, wor.Status, wor.Test)
+	}
+	if len(c.Constraints) != 0 {
+		t.Errorf("Expected the constraints to be cleared, got %v", c.Constraints)
+	}
+}
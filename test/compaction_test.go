@@ -0,0 +1,178 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestFixedInputs tests that the search keeps the fixed inputs of dynamic compaction
+func TestFixedInputs(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	fan.Decision.Fixed = map[*circuit.Line]circuit.LogicValue{
+		findLine(c, "1"): circuit.One,
+		findLine(c, "7"): circuit.Zero,
+	}
+
+	fault := circuit.Fault{Line: findLine(c, "16"), Type: circuit.One}
+	result := fan.GenerateTest(fault)
+	if result.Status != algorithm.Detected {
+		t.Fatalf("Expected a test for %s, got %v", fault, result.Err())
+	}
+	if result.Test["1"] != circuit.One || result.Test["7"] != circuit.Zero {
+		t.Errorf("Expected the fixed inputs to be kept, got %v", result.Test)
+	}
+	if fan.Simulator.Detect([]map[string]circuit.LogicValue{result.Test}, []circuit.Fault{fault})[0] != 0 {
+		t.Errorf("Test %v does not detect %s", result.Test, fault)
+	}
+
+	// Input 1 stuck-at-1 needs input 1 at 0
+	fault = circuit.Fault{Line: findLine(c, "1"), Type: circuit.One}
+	if result := fan.GenerateTest(fault); result.Status != algorithm.Redundant {
+		t.Errorf("Expected %s to have no test with input 1 fixed to 1, got %v", fault, result.Status)
+	}
+}
+
+// TestDynamicCompaction tests that dynamic compaction gives fewer tests with the
+// same coverage
+func TestDynamicCompaction(t *testing.T) {
+	run := func(enabled bool) (*algorithm.Fan, []map[string]circuit.LogicValue) {
+		c := createC17Circuit(t)
+		fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+		fan.Compaction.Enabled = enabled
+		tests, err := fan.GenerateTestsForAllFaults()
+		if err != nil {
+			t.Fatalf("Failed to generate tests: %v", err)
+		}
+		return fan, fan.CompactTests(tests)
+	}
+
+	// Dynamic compaction changes the tests generated, so it is opt-in
+	if algorithm.NewFan(createC17Circuit(t), utils.NewLogger(utils.ErrorLevel)).Compaction.Enabled {
+		t.Errorf("Expected dynamic compaction to be off by default")
+	}
+
+	plain, plainTests := run(false)
+	compact, compactTests := run(true)
+
+	if len(compactTests) >= len(plainTests) {
+		t.Errorf("Expected fewer than %d tests with dynamic compaction, got %d", len(plainTests), len(compactTests))
+	}
+	if compact.Stats.FaultCoverage() != plain.Stats.FaultCoverage() {
+		t.Errorf("Expected coverage %.2f%%, got %.2f%%", plain.Stats.FaultCoverage()*100, compact.Stats.FaultCoverage()*100)
+	}
+
+	// The compacted tests still detect every fault
+	remaining, _ := compact.Simulator.DropDetected(compactTests, compact.FaultList.Faults)
+	if len(remaining) != 0 {
+		t.Errorf("Expected the compacted tests to detect every fault, %v left", remaining)
+	}
+	if compact.Decision.Fixed != nil {
+		t.Errorf("Expected the fixed inputs to be cleared after the run")
+	}
+}
+
+// detectedFaults returns the faults of the fault universe a test set detects
+func detectedFaults(fan *algorithm.Fan, tests []map[string]circuit.LogicValue) map[circuit.Fault]bool {
+	_, detected := fan.Simulator.DropDetected(tests, circuit.NewFaultList(fan.Circuit, false).All)
+	set := make(map[circuit.Fault]bool)
+	for _, fault := range detected {
+		set[fault] = true
+	}
+	return set
+}
+
+// TestCompactTestsCoverage tests that static compaction keeps the coverage of the test set
+func TestCompactTestsCoverage(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	fan.Compaction.Enabled = false
+	testVectors, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	var original []map[string]circuit.LogicValue
+	for _, test := range testVectors {
+		original = append(original, test)
+	}
+	want := detectedFaults(fan, original)
+
+	for _, merge := range []bool{false, true} {
+		for _, cover := range []bool{false, true} {
+			fan.Compaction.Merge = merge
+			fan.Compaction.SetCover = cover
+			tests := fan.CompactTests(testVectors)
+
+			if got := detectedFaults(fan, tests); len(got) != len(want) {
+				t.Errorf("merge=%v cover=%v: expected %d detected faults, got %d", merge, cover, len(want), len(got))
+			}
+			if len(tests) >= len(original) {
+				t.Errorf("merge=%v cover=%v: expected fewer than %d tests, got %d", merge, cover, len(original), len(tests))
+			}
+
+			// Every test is needed: dropping it loses coverage (set cover), or it
+			// detects a fault no later test detects (reverse order)
+			for i := range tests {
+				others := append(append([]map[string]circuit.LogicValue{}, tests[:i]...), tests[i+1:]...)
+				if !cover {
+					others = tests[i+1:]
+				}
+				rest := detectedFaults(fan, others)
+				own := detectedFaults(fan, tests[i:i+1])
+				needed := false
+				for fault := range own {
+					if !rest[fault] {
+						needed = true
+						break
+					}
+				}
+				if !needed {
+					t.Errorf("merge=%v cover=%v: test %v is not needed", merge, cover, tests[i])
+				}
+			}
+		}
+	}
+}
+
+// TestCompactTestsMerge tests that tests whose specified inputs do not conflict are merged
+func TestCompactTestsMerge(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	x := circuit.X
+	one, zero := circuit.One, circuit.Zero
+	testVectors := map[string]map[string]circuit.LogicValue{
+		// Detects 10/1 and 1/0 through output 22, listed once
+		"10/1": {"1": one, "2": zero, "3": one, "6": x, "7": x},
+		"1/0":  {"1": one, "2": zero, "3": one, "6": x, "7": x},
+		// Detects 11/1 through output 23 and is compatible with the test above
+		"11/1": {"1": x, "2": x, "3": one, "6": one, "7": one},
+	}
+	fan.Compaction.Merge = false
+	if tests := fan.CompactTests(testVectors); len(tests) != 2 {
+		t.Fatalf("Expected 2 tests without merging, got %v", tests)
+	}
+
+	fan.Compaction.Merge = true
+	tests := fan.CompactTests(testVectors)
+	if len(tests) != 1 {
+		t.Fatalf("Expected the compatible tests to merge into one, got %v", tests)
+	}
+	for name, expected := range map[string]circuit.LogicValue{"1": one, "2": zero, "3": one, "6": one, "7": one} {
+		if tests[0][name] != expected {
+			t.Errorf("Expected input %s = %v in the merged test, got %v", name, expected, tests[0][name])
+		}
+	}
+	for fault, test := range testVectors {
+		f, err := utils.ParseFault(fault, c)
+		if err != nil {
+			t.Fatalf("Failed to parse fault %s: %v", fault, err)
+		}
+		if fan.Simulator.Detect([]map[string]circuit.LogicValue{test}, []circuit.Fault{f})[0] == 0 &&
+			fan.Simulator.Detect(tests, []circuit.Fault{f})[0] != 0 {
+			t.Errorf("Merged test %v no longer detects %s", tests[0], fault)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/diagnosis"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// c17Patterns returns the filled, compacted tests of every c17 fault
+func c17Patterns(t *testing.T, c *circuit.Circuit) []map[string]circuit.LogicValue {
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	testVectors, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	return c.FillTests(fan.CompactTests(testVectors), circuit.FillRandom, 3)
+}
+
+// TestFailures tests the failing outputs reported by fault simulation
+func TestFailures(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	// 16 stuck-at-1 with 16 = 0, 10 = 1 and 19 = 1 flips both outputs
+	pattern := map[string]circuit.LogicValue{"1": circuit.Zero, "2": circuit.One, "3": circuit.One, "6": circuit.Zero, "7": circuit.Zero}
+	patterns := []map[string]circuit.LogicValue{{"1": circuit.One}, pattern}
+	fault := circuit.Fault{Line: findLine(c, "16"), Type: circuit.One}
+	got := fan.Simulator.Failures(patterns, []circuit.Fault{fault})[0]
+	want := []simulation.Failure{{Pattern: 1, Output: "22"}, {Pattern: 1, Output: "23"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected failures %v, got %v", want, got)
+	}
+
+	// Every fault fails first at the pattern that detects it
+	patterns = c17Patterns(t, c)
+	faults := circuit.NewFaultList(c, false).All
+	first := fan.Simulator.Detect(patterns, faults)
+	for i, failures := range fan.Simulator.Failures(patterns, faults) {
+		if len(failures) == 0 && first[i] >= 0 || len(failures) > 0 && failures[0].Pattern != first[i] {
+			t.Errorf("Fault %s first detected by pattern %d, but failures are %v", faults[i], first[i], failures)
+		}
+	}
+}
+
+// TestReadTestVectors tests reading back written test vectors
+func TestReadTestVectors(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	tests := []map[string]circuit.LogicValue{
+		{"G0": circuit.Zero, "G1": circuit.One, "G2": circuit.X, "G3": circuit.One, "G5": circuit.One, "G6": circuit.X, "G7": circuit.Zero},
+		{"G0": circuit.X, "G1": circuit.X, "G2": circuit.Zero, "G3": circuit.Zero, "G5": circuit.Zero, "G6": circuit.One, "G7": circuit.X},
+	}
+
+	file := filepath.Join(t.TempDir(), "tests.txt")
+	if err := utils.WriteScanTestVectors(file, c, tests, circuit.FillNone); err != nil {
+		t.Fatalf("Failed to write tests: %v", err)
+	}
+	read, err := utils.ReadTestVectors(file, c)
+	if err != nil {
+		t.Fatalf("Failed to read tests: %v", err)
+	}
+	if !reflect.DeepEqual(read, tests) {
+		t.Errorf("Expected %v, got %v", tests, read)
+	}
+
+	for _, content := range []string{
+		"# Format: G0 G1 G2 G3 | G5 G6 G9\n",
+		"# Format: G0 G1 G2 G3 | G5 G6 G7\n0 1 0 1 | 1 1\n",
+		"# Format: G0 G1 G2 G3 | G5 G6 G7\n0 1 0 1 | 1 1 2\n",
+		"0 1 0 1 | 1 1 0\n",
+	} {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if _, err := utils.ReadTestVectors(file, c); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+// TestParseFailLog tests reading a tester fail log
+func TestParseFailLog(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "fail.log")
+	content := "# pattern outputs\n2 G17 G5\n\n4\n2 G17\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write fail log: %v", err)
+	}
+
+	failures, err := diagnosis.ParseFailLog(file, c)
+	if err != nil {
+		t.Fatalf("Failed to parse fail log: %v", err)
+	}
+	// The scan cell G5 stands for the line G10 it captures
+	want := []simulation.Failure{{Pattern: 1, Output: "G17"}, {Pattern: 1, Output: "G10"}, {Pattern: 3}}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("Expected %v, got %v", want, failures)
+	}
+
+	for _, content := range []string{"0 G17\n", "x G17\n", "1 G14\n"} {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write fail log: %v", err)
+		}
+		if _, err := diagnosis.ParseFailLog(file, c); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+// TestDiagnose tests that every mode ranks the fault behind a fail log first
+func TestDiagnose(t *testing.T) {
+	c := createC17Circuit(t)
+	patterns := c17Patterns(t, c)
+	d := diagnosis.NewDiagnoser(c, patterns, utils.NewLogger(utils.ErrorLevel))
+
+	for _, fault := range d.Faults {
+		observed := d.Simulator.Failures(patterns, []circuit.Fault{fault})[0]
+		for _, mode := range []diagnosis.Mode{diagnosis.PassFail, diagnosis.FullResponse, diagnosis.EffectCause} {
+			candidates, err := d.Diagnose(observed, mode)
+			if err != nil {
+				t.Fatalf("%v diagnosis of %s failed: %v", mode, fault, err)
+			}
+
+			// Faults the patterns cannot tell apart share the top score
+			found := false
+			for _, candidate := range candidates {
+				if candidate.Score < 1 {
+					break
+				}
+				found = found || candidate.Fault == fault
+			}
+			if !found {
+				t.Errorf("%v diagnosis does not rank %s among the perfect matches: %v", mode, fault, candidates)
+			}
+		}
+	}
+}
+
+// TestDiagnoseModes tests how the modes treat fail logs
+func TestDiagnoseModes(t *testing.T) {
+	c := createC17Circuit(t)
+	patterns := c17Patterns(t, c)
+	d := diagnosis.NewDiagnoser(c, patterns, utils.NewLogger(utils.ErrorLevel))
+
+	// A pass/fail log only supports pass/fail diagnosis
+	fault := circuit.Fault{Line: findLine(c, "16"), Type: circuit.One}
+	var observed []simulation.Failure
+	for _, failure := range d.Simulator.Failures(patterns, []circuit.Fault{fault})[0] {
+		observed = append(observed, simulation.Failure{Pattern: failure.Pattern})
+	}
+	if candidates, err := d.Diagnose(observed, diagnosis.PassFail); err != nil || len(candidates) == 0 || candidates[0].Score != 1 {
+		t.Errorf("Expected a perfect pass/fail match, got %v, %v", candidates, err)
+	}
+	for _, mode := range []diagnosis.Mode{diagnosis.FullResponse, diagnosis.EffectCause} {
+		if _, err := d.Diagnose(observed, mode); err == nil || !strings.Contains(err.Error(), "failing outputs") {
+			t.Errorf("Expected %v diagnosis to need the failing outputs, got %v", mode, err)
+		}
+	}
+	if _, err := d.Diagnose([]simulation.Failure{{Pattern: len(patterns), Output: "22"}}, diagnosis.FullResponse); err == nil {
+		t.Errorf("Expected an error for a pattern outside the pattern set")
+	}
+
+	// Two faults in separate cones: no single fault explains both outputs,
+	// so effect-cause analysis falls back to the faults explaining some of them
+	faults := []circuit.Fault{{Line: findLine(c, "10"), Type: circuit.One}, {Line: findLine(c, "19"), Type: circuit.One}}
+	observed = nil
+	for _, failures := range d.Simulator.Failures(patterns, faults) {
+		observed = append(observed, failures...)
+	}
+	candidates, err := d.Diagnose(observed, diagnosis.EffectCause)
+	if err != nil {
+		t.Fatalf("Effect-cause diagnosis failed: %v", err)
+	}
+	for _, fault := range faults {
+		found := false
+		for _, candidate := range candidates {
+			found = found || candidate.Fault == fault && candidate.Extra == 0
+		}
+		if !found {
+			t.Errorf("Expected %s among the candidates of the double fault, got %v", fault, candidates)
+		}
+	}
+
+	for _, mode := range []diagnosis.Mode{diagnosis.PassFail, diagnosis.FullResponse, diagnosis.EffectCause} {
+		if parsed, err := diagnosis.ParseMode(mode.String()); err != nil || parsed != mode {
+			t.Errorf("Expected ParseMode(%q) to return %v, got %v, %v", mode.String(), mode, parsed, err)
+		}
+	}
+	if _, err := diagnosis.ParseMode("dictionary"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// c17Rewritten is c17 with 23 = NAND(16, 19) rewritten as an OR of inverted inputs
+var c17Rewritten = strings.Replace(c17Bench, "23 = NAND(16, 19)", "n16 = NOT(16)\nn19 = NOT(19)\n23 = OR(n16, n19)", 1)
+
+// c17Changed is c17 with 22 computed by an AND instead of a NAND
+var c17Changed = strings.Replace(c17Bench, "22 = NAND(10, 16)", "22 = AND(10, 16)", 1)
+
+// TestBuildMiter tests the miter of two circuits
+func TestBuildMiter(t *testing.T) {
+	a := createC17Circuit(t)
+	b, err := parseBench(t, c17Changed)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+
+	m, err := circuit.BuildMiter(a, b)
+	if err != nil {
+		t.Fatalf("Failed to build miter: %v", err)
+	}
+	if !reflect.DeepEqual(m.Names, []string{"22"}) || !reflect.DeepEqual(m.Shared, []string{"23"}) {
+		t.Errorf("Expected 22 to be compared and 23 shared, got %v and %v", m.Names, m.Shared)
+	}
+	if len(m.Circuit.Inputs) != 5 || findLine(m.Circuit, "1") == nil || findLine(m.Circuit, "1").Type != circuit.PrimaryInput {
+		t.Errorf("Expected the five inputs to be shared under their own names, got %v", m.Circuit.Inputs)
+	}
+	if len(m.Circuit.Outputs) != 1 || m.Output != m.Circuit.Outputs[0] || m.Output.Name != "22@xor" {
+		t.Errorf("Expected the comparison of 22 as the only output, got %v", m.Circuit.Outputs)
+	}
+	// Only the gates of 22 are copied twice
+	if len(m.Circuit.Gates) != 6+1+1 {
+		t.Errorf("Expected 8 gates after structural hashing, got %d", len(m.Circuit.Gates))
+	}
+
+	// Structurally equal circuits leave nothing to compare
+	if m, err = circuit.BuildMiter(a, createC17Circuit(t)); err != nil || m.Output != nil || len(m.Shared) != 2 {
+		t.Errorf("Expected every output of c17 to be shared with itself, got %v, %v", m, err)
+	}
+
+	// Several compared outputs are ORed into the miter output
+	c, err := parseBench(t, strings.Replace(c17Bench, "11 = NAND(3, 6)", "11 = NOR(3, 6)", 1))
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	if m, err = circuit.BuildMiter(a, c); err != nil || m.Output == nil || m.Output.Name != "miter" || len(m.Outputs) != 2 {
+		t.Errorf("Expected an OR of two comparisons named miter, got %v, %v", m, err)
+	}
+
+	for _, content := range []string{
+		strings.Replace(c17Bench, "INPUT(7)", "INPUT(7)\nINPUT(8)", 1),
+		strings.Replace(c17Bench, "OUTPUT(23)", "OUTPUT(19)", 1),
+	} {
+		b, err := parseBench(t, content)
+		if err != nil {
+			t.Fatalf("Failed to parse circuit: %v", err)
+		}
+		if _, err := circuit.BuildMiter(a, b); err == nil || !strings.Contains(err.Error(), "do not match") {
+			t.Errorf("Expected unmatched inputs or outputs to be rejected, got %v", err)
+		}
+	}
+}
+
+// TestEquivalenceChecker tests equivalence checking with FAN on the miter
+func TestEquivalenceChecker(t *testing.T) {
+	a := createC17Circuit(t)
+	logger := utils.NewLogger(utils.ErrorLevel)
+
+	rewritten, err := parseBench(t, c17Rewritten)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	checker, err := algorithm.NewEquivalenceChecker(a, rewritten, logger)
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	if result := checker.Check(); result.Status != algorithm.Equivalent {
+		t.Errorf("Expected the rewritten c17 to be equivalent, got %v: %s", result.Status, result.Reason)
+	}
+
+	// The proof needs backtracking, so a budget of one backtrack leaves it undecided
+	checker.Fan.Options.MaxBacktracks = 1
+	if result := checker.Check(); result.Status != algorithm.Undecided || result.Reason == "" {
+		t.Errorf("Expected the check to be undecided, got %v", result.Status)
+	}
+
+	changed, err := parseBench(t, c17Changed)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	checker, err = algorithm.NewEquivalenceChecker(a, changed, logger)
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	result := checker.Check()
+	if result.Status != algorithm.NotEquivalent || !reflect.DeepEqual(result.Outputs, []string{"22"}) {
+		t.Fatalf("Expected a counterexample on 22, got %v on %v", result.Status, result.Outputs)
+	}
+	for _, input := range a.Inputs {
+		if value := result.Counterexample[input.Name]; value != circuit.Zero && value != circuit.One {
+			t.Errorf("Expected a binary value for %s in the counterexample, got %v", input.Name, value)
+		}
+	}
+	good, bad := goodValues(a, result.Counterexample), goodValues(changed, result.Counterexample)
+	if good[findLine(a, "22")] == bad[findLine(changed, "22")] {
+		t.Errorf("Counterexample %v does not tell the circuits apart", result.Counterexample)
+	}
+}
+
+// TestEquivalenceScan tests that scan cells are compared as inputs and outputs
+func TestEquivalenceScan(t *testing.T) {
+	a, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	logger := utils.NewLogger(utils.ErrorLevel)
+
+	// G10 is only observed through the scan cell G5
+	b, err := parseBench(t, strings.Replace(s27Bench, "G10 = NOR(G14, G11)", "G10 = OR(G14, G11)", 1))
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	checker, err := algorithm.NewEquivalenceChecker(a, b, logger)
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	if result := checker.Check(); result.Status != algorithm.NotEquivalent || !reflect.DeepEqual(result.Outputs, []string{"G5"}) {
+		t.Errorf("Expected a counterexample on the scan cell G5, got %v on %v", result.Status, result.Outputs)
+	}
+
+	// G8 = AND(G14, G6) as NOR(G0, NOT(G6)), with G14 = NOT(G0)
+	b, err = parseBench(t, strings.Replace(s27Bench, "G8 = AND(G14, G6)", "G6n = NOT(G6)\nG8 = NOR(G0, G6n)", 1))
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	if checker, err = algorithm.NewEquivalenceChecker(a, b, logger); err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	if result := checker.Check(); result.Status != algorithm.Equivalent {
+		t.Errorf("Expected the rewritten s27 to be equivalent, got %v on %v", result.Status, result.Outputs)
+	}
+}
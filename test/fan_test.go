@@ -1,6 +1,7 @@
 package test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
@@ -106,60 +107,80 @@ func TestGenerateTestsForAllFaults(t *testing.T) {
 	}
 }
 
-// TestCompactTests tests the test compaction functionality
-func TestCompactTests(t *testing.T) {
-	// Create test vectors that have significant overlap
-	testVectors := make(map[string]map[string]circuit.LogicValue)
+// TestGenerateTestsForAllFaultsVerified tests that every reported test detects its fault
+func TestGenerateTestsForAllFaultsVerified(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
 
-	// Test for fault1: in1=1, in2=1, in3=0
-	testVectors["fault1"] = map[string]circuit.LogicValue{
-		"in1": circuit.One,
-		"in2": circuit.One,
-		"in3": circuit.Zero,
+	tests, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
 	}
 
-	// Test for fault2: in1=1, in2=1, in3=1
-	// This can be merged with fault1 since they differ only in in3
-	testVectors["fault2"] = map[string]circuit.LogicValue{
-		"in1": circuit.One,
-		"in2": circuit.One,
-		"in3": circuit.One,
+	// c17 has no redundant faults, so every collapsed fault gets a test
+	if len(tests) != fan.FaultList.Size() || fan.Stats.UndetectedFaults != 0 {
+		t.Errorf("Expected tests for all %d collapsed faults, got %d (%d undetected)",
+			fan.FaultList.Size(), len(tests), fan.Stats.UndetectedFaults)
 	}
 
-	// Test for fault3: in1=0, in2=0, in3=0
-	// This is distinct and can't be merged with others
-	testVectors["fault3"] = map[string]circuit.LogicValue{
-		"in1": circuit.Zero,
-		"in2": circuit.Zero,
-		"in3": circuit.Zero,
+	for faultStr, test := range tests {
+		fault, err := utils.ParseFault(faultStr, c)
+		if err != nil {
+			t.Fatalf("Failed to parse fault %s: %v", faultStr, err)
+		}
+		patterns := []map[string]circuit.LogicValue{test}
+		if fan.Simulator.Detect(patterns, []circuit.Fault{fault})[0] != 0 {
+			t.Errorf("Test %v does not detect %s", test, fault)
+		}
 	}
+}
 
-	// Test for fault4: in1=0, in2=0, in3=1
-	// This can be merged with fault3 since they differ only in in3
-	testVectors["fault4"] = map[string]circuit.LogicValue{
-		"in1": circuit.Zero,
-		"in2": circuit.Zero,
-		"in3": circuit.One,
+// TestCompactTests tests that tests shared by several faults are listed once,
+// that compatible tests are merged and that conflicting tests are kept
+func TestCompactTests(t *testing.T) {
+	shared := map[string]circuit.LogicValue{"in1": circuit.One, "in2": circuit.One, "in3": circuit.Zero}
+	testVectors := map[string]map[string]circuit.LogicValue{
+		"fault1": shared,
+		"fault2": shared,
+		// Same values as the shared test, in a separate map
+		"fault3": {"in1": circuit.One, "in2": circuit.One, "in3": circuit.Zero},
+		// Differs from the shared test only in in3, so it must be kept
+		"fault4": {"in1": circuit.One, "in2": circuit.One, "in3": circuit.One},
+		// These two specify different inputs and are merged
+		"fault5": {"in1": circuit.Zero, "in2": circuit.X, "in3": circuit.Zero},
+		"fault6": {"in1": circuit.X, "in2": circuit.Zero, "in3": circuit.X},
 	}
 
-	// Create FAN instance
-	logger := utils.NewLogger(utils.InfoLevel)
-	c := createFanTestCircuit()
-	fan := algorithm.NewFan(c, logger)
-
-	// Compact the test vectors
+	fan := algorithm.NewFan(createFanTestCircuit(), utils.NewLogger(utils.ErrorLevel))
 	compactedTests := fan.CompactTests(testVectors)
 
-	// The compacted set should be smaller than the original
-	if len(compactedTests) >= len(testVectors) {
-		t.Errorf("Expected compacted tests (%d) to be fewer than original tests (%d)",
-			len(compactedTests), len(testVectors))
+	if len(compactedTests) != 3 {
+		t.Fatalf("Expected 3 tests, got %d: %v", len(compactedTests), compactedTests)
+	}
+	for name, vector := range testVectors {
+		found := false
+		for _, test := range compactedTests {
+			found = found || covers(test, vector)
+		}
+		if !found {
+			t.Errorf("Expected the test of %s, %v, to be kept", name, vector)
+		}
 	}
 
-	// But it should still be at least 1
-	if len(compactedTests) < 1 {
-		t.Error("Compacted test set is empty")
+	again := fan.CompactTests(testVectors)
+	if !reflect.DeepEqual(again, compactedTests) {
+		t.Errorf("Expected the same order on every call, got %v and %v", again, compactedTests)
+	}
+}
+
+// covers reports whether a test assigns every input a vector specifies to the same value
+func covers(test, vector map[string]circuit.LogicValue) bool {
+	for name, value := range vector {
+		if value != circuit.X && test[name] != value {
+			return false
+		}
 	}
+	return true
 }
 
 // Helper function to create a test circuit for FAN algorithm testing
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+)
+
+// TestFaultListEquivalenceCollapsing tests structural equivalence collapsing
+func TestFaultListEquivalenceCollapsing(t *testing.T) {
+	// in1, in2 -> AND(g1) -> w1; w1, in2 -> OR(g2) -> out
+	c := createTestCircuit()
+	fl := circuit.NewFaultList(c, false)
+
+	// 5 lines with 2 faults each, plus 2 faults on each of the 2 branches of in2
+	if len(fl.All) != 14 {
+		t.Fatalf("Expected 14 faults in the universe, got %d", len(fl.All))
+	}
+
+	// in1/0 == in2->g1/0 == w1/0 (AND input faults), w1/1 == in2->g2/1 == out/1 (OR input faults).
+	// in2 fans out, so its stem faults are not equivalent to any gate output fault.
+	if fl.Size() != 10 {
+		t.Errorf("Expected 10 collapsed faults, got %d: %v", fl.Size(), fl.Faults)
+	}
+	if fl.EquivalentCollapsed != 4 {
+		t.Errorf("Expected 4 faults removed by equivalence, got %d", fl.EquivalentCollapsed)
+	}
+
+	in1 := findLine(c, "in1")
+	w1 := findLine(c, "w1")
+	out := findLine(c, "out")
+	in2 := findLine(c, "in2")
+
+	rep := fl.RepresentativeOf(circuit.Fault{Line: w1, Type: circuit.Zero})
+	if rep != (circuit.Fault{Line: in1, Type: circuit.Zero}) {
+		t.Errorf("Expected w1/0 to be represented by in1/0, got %s", rep)
+	}
+
+	// The branch fault sits at a lower level than w1, so it represents the class
+	g2 := findGate(c, "g2")
+	rep = fl.RepresentativeOf(circuit.Fault{Line: out, Type: circuit.One})
+	if rep != (circuit.Fault{Line: in2, Type: circuit.One, Branch: g2}) {
+		t.Errorf("Expected out/1 to be represented by in2->g2/1, got %s", rep)
+	}
+	rep = fl.RepresentativeOf(circuit.Fault{Line: w1, Type: circuit.One})
+	if rep != (circuit.Fault{Line: in2, Type: circuit.One, Branch: g2}) {
+		t.Errorf("Expected w1/1 to be represented by in2->g2/1, got %s", rep)
+	}
+
+	rep = fl.RepresentativeOf(circuit.Fault{Line: in2, Type: circuit.Zero})
+	if rep != (circuit.Fault{Line: in2, Type: circuit.Zero}) {
+		t.Errorf("Expected fanout stem fault in2/0 to represent itself, got %s", rep)
+	}
+
+	members := fl.Members(circuit.Fault{Line: in1, Type: circuit.Zero})
+	if len(members) != 3 {
+		t.Errorf("Expected in1/0 to represent 2 faults, got %v", members)
+	}
+}
+
+// TestFaultListInverterChain tests collapsing across NOT and BUF gates
+func TestFaultListInverterChain(t *testing.T) {
+	c := circuit.NewCircuit("chain")
+
+	a := circuit.NewLine(0, "a", circuit.PrimaryInput)
+	b := circuit.NewLine(1, "b", circuit.Normal)
+	y := circuit.NewLine(2, "y", circuit.PrimaryOutput)
+	c.AddLine(a)
+	c.AddLine(b)
+	c.AddLine(y)
+
+	g1 := circuit.NewGate(0, "g1", circuit.NOT)
+	g1.AddInput(a)
+	g1.SetOutput(b)
+	g2 := circuit.NewGate(1, "g2", circuit.BUF)
+	g2.AddInput(b)
+	g2.SetOutput(y)
+	c.AddGate(g1)
+	c.AddGate(g2)
+
+	fl := circuit.NewFaultList(c, false)
+
+	// a/0 == b/1 == y/1 and a/1 == b/0 == y/0
+	if fl.Size() != 2 {
+		t.Fatalf("Expected 2 collapsed faults, got %d: %v", fl.Size(), fl.Faults)
+	}
+	rep := fl.RepresentativeOf(circuit.Fault{Line: y, Type: circuit.One})
+	if rep != (circuit.Fault{Line: a, Type: circuit.Zero}) {
+		t.Errorf("Expected y/1 to be represented by a/0, got %s", rep)
+	}
+}
+
+// TestFaultListDominanceCollapsing tests optional dominance collapsing
+func TestFaultListDominanceCollapsing(t *testing.T) {
+	c := createTestCircuit()
+	fl := circuit.NewFaultList(c, true)
+
+	// AND: {w1/1, out/1} dominates in1/1; OR: out/0 dominates {in1/0, w1/0}
+	if fl.DominanceCollapsed != 2 {
+		t.Errorf("Expected 2 classes removed by dominance, got %d", fl.DominanceCollapsed)
+	}
+	if fl.Size() != 8 {
+		t.Errorf("Expected 8 collapsed faults, got %d: %v", fl.Size(), fl.Faults)
+	}
+
+	in1 := findLine(c, "in1")
+	out := findLine(c, "out")
+
+	rep := fl.RepresentativeOf(circuit.Fault{Line: out, Type: circuit.One})
+	if rep != (circuit.Fault{Line: in1, Type: circuit.One}) {
+		t.Errorf("Expected out/1 to be covered by in1/1, got %s", rep)
+	}
+	rep = fl.RepresentativeOf(circuit.Fault{Line: out, Type: circuit.Zero})
+	if rep != (circuit.Fault{Line: in1, Type: circuit.Zero}) {
+		t.Errorf("Expected out/0 to be covered by in1/0, got %s", rep)
+	}
+
+	// Every fault of the universe must map to a fault that is actually targeted
+	targeted := make(map[circuit.Fault]bool)
+	for _, f := range fl.Faults {
+		targeted[f] = true
+	}
+	for _, f := range fl.All {
+		if !targeted[fl.RepresentativeOf(f)] {
+			t.Errorf("Fault %s maps to untargeted fault %s", f, fl.RepresentativeOf(f))
+		}
+	}
+}
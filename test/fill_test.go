@@ -0,0 +1,156 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestFillPolicies tests the values each fill policy gives to unassigned inputs
+func TestFillPolicies(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	zero, one, x := circuit.Zero, circuit.One, circuit.X
+	tests := []map[string]circuit.LogicValue{
+		{"G0": zero, "G1": x, "G3": one, "G5": one, "G7": zero},
+		{"G3": one},
+		{},
+	}
+
+	// Inputs in order: G0 G1 G2 G3 | G5 G6 G7
+	expected := map[circuit.FillPolicy][][]circuit.LogicValue{
+		circuit.FillZero:     {{zero, zero, zero, one, one, zero, zero}, {zero, zero, zero, one, zero, zero, zero}, {zero, zero, zero, zero, zero, zero, zero}},
+		circuit.FillOne:      {{zero, one, one, one, one, one, zero}, {one, one, one, one, one, one, one}, {one, one, one, one, one, one, one}},
+		circuit.FillAdjacent: {{zero, zero, zero, one, one, one, zero}, {one, one, one, one, one, one, one}, {zero, zero, zero, zero, zero, zero, zero}},
+	}
+	for policy, vectors := range expected {
+		filled := c.FillTests(tests, policy, 1)
+		for i, vector := range vectors {
+			for j, input := range c.Inputs {
+				if filled[i][input.Name] != vector[j] {
+					t.Errorf("%v fill of test %d: expected %s = %v, got %v", policy, i, input.Name, vector[j], filled[i][input.Name])
+				}
+			}
+		}
+	}
+
+	if tests[0]["G1"] != x || len(tests[1]) != 1 {
+		t.Errorf("Expected the original tests to be unchanged, got %v", tests)
+	}
+	if none := c.FillTests(tests, circuit.FillNone, 1); none[0]["G1"] != x || none[1]["G0"] == zero || none[1]["G0"] == one {
+		t.Errorf("Expected no fill to keep X, got %v", none)
+	}
+
+	random := c.FillTests(tests, circuit.FillRandom, 7)
+	for i, test := range random {
+		for _, input := range c.Inputs {
+			value := test[input.Name]
+			if value != zero && value != one {
+				t.Errorf("Random fill of test %d left %s = %v", i, input.Name, value)
+			}
+			if assigned := tests[i][input.Name]; (assigned == zero || assigned == one) && value != assigned {
+				t.Errorf("Random fill of test %d changed %s from %v to %v", i, input.Name, assigned, value)
+			}
+		}
+	}
+	if !reflect.DeepEqual(random, c.FillTests(tests, circuit.FillRandom, 7)) {
+		t.Errorf("Expected the same random fill for the same seed")
+	}
+
+	if _, err := circuit.ParseFillPolicy("minimum"); err == nil {
+		t.Errorf("Expected an error for an unknown fill policy")
+	}
+	for _, policy := range []circuit.FillPolicy{circuit.FillNone, circuit.FillZero, circuit.FillOne, circuit.FillRandom, circuit.FillAdjacent} {
+		if parsed, err := circuit.ParseFillPolicy(policy.String()); err != nil || parsed != policy {
+			t.Errorf("Expected %v to parse back, got %v (%v)", policy, parsed, err)
+		}
+	}
+}
+
+// TestFillKeepsDetection tests that filled tests still detect every fault
+func TestFillKeepsDetection(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	testVectors, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	tests := fan.CompactTests(testVectors)
+	want := detectedFaults(fan, tests)
+
+	for _, policy := range []circuit.FillPolicy{circuit.FillZero, circuit.FillOne, circuit.FillRandom, circuit.FillAdjacent} {
+		filled := c.FillTests(tests, policy, 3)
+		got := detectedFaults(fan, filled)
+		for fault := range want {
+			if !got[fault] {
+				t.Errorf("%v fill lost the detection of %s", policy, fault)
+			}
+		}
+	}
+}
+
+// TestFillTwoPatternTests tests that adjacent fill keeps unassigned V2 inputs at their V1 value
+func TestFillTwoPatternTests(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	tests := []circuit.TwoPatternTest{{
+		V1: map[string]circuit.LogicValue{"G1": circuit.One, "G6": circuit.Zero},
+		V2: map[string]circuit.LogicValue{"G0": circuit.Zero},
+	}}
+
+	filled := c.FillTwoPatternTests(tests, circuit.FillAdjacent, 1)[0]
+	for _, input := range c.PrimaryInputs() {
+		if input.Name == "G0" {
+			continue
+		}
+		if filled.V2[input.Name] != filled.V1[input.Name] {
+			t.Errorf("Expected V2 %s to keep its V1 value %v, got %v", input.Name, filled.V1[input.Name], filled.V2[input.Name])
+		}
+	}
+	if filled.V2["G0"] != circuit.Zero || filled.V1["G0"] != circuit.One {
+		t.Errorf("Expected G0 to go from 1 to 0, got %v and %v", filled.V1["G0"], filled.V2["G0"])
+	}
+}
+
+// TestFillRecorded tests that the fill policy is written to the output files
+func TestFillRecorded(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	tests := c.FillTests([]map[string]circuit.LogicValue{{"G0": circuit.One}}, circuit.FillAdjacent, 1)
+	outFile := filepath.Join(t.TempDir(), "tests.txt")
+	if err := utils.WriteScanTestVectors(outFile, c, tests, circuit.FillAdjacent); err != nil {
+		t.Fatalf("Failed to write test vectors: %v", err)
+	}
+	content, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Failed to read test vectors: %v", err)
+	}
+	if !strings.Contains(string(content), "# Fill: adjacent\n") || !strings.Contains(string(content), "1 1 1 1 | 1 1 1 \n") {
+		t.Errorf("Expected the fill policy and filled values in\n%s", content)
+	}
+
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	stilFile := filepath.Join(t.TempDir(), "tests.stil")
+	if err := utils.WriteSTIL(stilFile, c, tests, fan.Simulator.Responses(tests), circuit.FillAdjacent); err != nil {
+		t.Fatalf("Failed to write STIL: %v", err)
+	}
+	stil, err := os.ReadFile(stilFile)
+	if err != nil {
+		t.Fatalf("Failed to read STIL: %v", err)
+	}
+	if !strings.Contains(string(stil), "Ann {* fill: adjacent *}") {
+		t.Errorf("Expected the fill policy in the STIL header, got\n%s", stil)
+	}
+}
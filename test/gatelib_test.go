@@ -0,0 +1,416 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// cellBench uses every cell of the gate library, a tie cell to force a side
+// input of a complex gate and a LUT computing the majority of three nets
+const cellBench = `# cells
+INPUT(a)
+INPUT(b)
+INPUT(c)
+INPUT(d)
+INPUT(s)
+OUTPUT(v)
+OUTPUT(w)
+one = TIE1()
+zero = TIE0()
+m = MUX(a, b, s)
+p = AOI21(a, b, c)
+q = OAI22(a, b, c, d)
+r = AOI22(m, p, q, one)
+u = OAI21(p, q, s)
+l = LUT 0x96 (a, b, c)
+v = LUT 0xe8 (m, u, l)
+w = OR(zero, r)
+`
+
+// cellFunctions are the functions of the cells of fixed function, over their
+// inputs in order
+var cellFunctions = map[circuit.GateType]func(in []bool) bool{
+	circuit.MUX:   func(in []bool) bool { return in[2] && in[1] || !in[2] && in[0] },
+	circuit.TIE0:  func(in []bool) bool { return false },
+	circuit.TIE1:  func(in []bool) bool { return true },
+	circuit.AOI21: func(in []bool) bool { return !(in[0] && in[1] || in[2]) },
+	circuit.AOI22: func(in []bool) bool { return !(in[0] && in[1] || in[2] && in[3]) },
+	circuit.OAI21: func(in []bool) bool { return !((in[0] || in[1]) && in[2]) },
+	circuit.OAI22: func(in []bool) bool { return !((in[0] || in[1]) && (in[2] || in[3])) },
+}
+
+// createCell returns a cell with an input line per input of its type
+func createCell(gateType circuit.GateType, arity int) *circuit.Gate {
+	gate := circuit.NewGate(0, gateType.String(), gateType)
+	for i := 0; i < arity; i++ {
+		gate.AddInput(circuit.NewLine(i, string(rune('a'+i)), circuit.Normal))
+	}
+	gate.SetOutput(circuit.NewLine(arity, "z", circuit.Normal))
+	return gate
+}
+
+// setInputs assigns values to the inputs of a gate
+func setInputs(gate *circuit.Gate, values ...circuit.LogicValue) {
+	for i, value := range values {
+		gate.Inputs[i].Value = value
+	}
+}
+
+// TestCellEvaluation tests the cells against their functions and the
+// five-valued semantics of a truth table
+func TestCellEvaluation(t *testing.T) {
+	for gateType, f := range cellFunctions {
+		gate := createCell(gateType, gateType.Arity())
+		for m := 0; m < 1<<gateType.Arity(); m++ {
+			in := make([]bool, gateType.Arity())
+			for i := range in {
+				in[i] = m>>i&1 == 1
+				gate.Inputs[i].Value = circuit.Zero
+				if in[i] {
+					gate.Inputs[i].Value = circuit.One
+				}
+			}
+			expected := circuit.Zero
+			if f(in) {
+				expected = circuit.One
+			}
+			if got := gate.Evaluate(); got != expected {
+				t.Errorf("%v with inputs %v: expected %v, got %v", gateType, in, expected, got)
+			}
+		}
+	}
+
+	mux := createCell(circuit.MUX, 3)
+	for _, tc := range []struct {
+		inputs   []circuit.LogicValue
+		expected circuit.LogicValue
+	}{
+		{[]circuit.LogicValue{circuit.D, circuit.One, circuit.Zero}, circuit.D},
+		{[]circuit.LogicValue{circuit.D, circuit.One, circuit.One}, circuit.One},
+		{[]circuit.LogicValue{circuit.D, circuit.One, circuit.X}, circuit.X},
+		{[]circuit.LogicValue{circuit.One, circuit.One, circuit.X}, circuit.One}, // Both data inputs agree
+		{[]circuit.LogicValue{circuit.D, circuit.D, circuit.X}, circuit.D},
+		{[]circuit.LogicValue{circuit.Zero, circuit.One, circuit.Dnot}, circuit.Dnot},
+	} {
+		setInputs(mux, tc.inputs...)
+		if got := mux.Evaluate(); got != tc.expected {
+			t.Errorf("MUX with inputs %v: expected %v, got %v", tc.inputs, tc.expected, got)
+		}
+	}
+
+	// A side input at 0 decides the AND term of an AOI21 even with X on the other
+	aoi := createCell(circuit.AOI21, 3)
+	setInputs(aoi, circuit.X, circuit.Zero, circuit.Zero)
+	if got := aoi.Evaluate(); got != circuit.One {
+		t.Errorf("Expected AOI21(X, 0, 0) = 1, got %v", got)
+	}
+
+	// LUT 0x8 is an AND, the first input being the lowest bit of the table
+	lut := createCell(circuit.LUT, 2)
+	lut.Table = 0x8
+	for _, tc := range []struct {
+		inputs   []circuit.LogicValue
+		expected circuit.LogicValue
+	}{
+		{[]circuit.LogicValue{circuit.One, circuit.One}, circuit.One},
+		{[]circuit.LogicValue{circuit.Zero, circuit.X}, circuit.Zero},
+		{[]circuit.LogicValue{circuit.Dnot, circuit.One}, circuit.Dnot},
+	} {
+		setInputs(lut, tc.inputs...)
+		if got := lut.Evaluate(); got != tc.expected {
+			t.Errorf("LUT 0x8 with inputs %v: expected %v, got %v", tc.inputs, tc.expected, got)
+		}
+	}
+
+	if got := createCell(circuit.TIE1, 0).Evaluate(); got != circuit.One {
+		t.Errorf("Expected TIE1 to output 1, got %v", got)
+	}
+}
+
+// TestCellImplication tests backward implication and the D-frontier through cells
+func TestCellImplication(t *testing.T) {
+	c, err := parseBench(t, cellBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	logger := utils.NewLogger(utils.ErrorLevel)
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	frontier := algorithm.NewFrontier(c, logger)
+	implication := algorithm.NewImplication(c, frontier, topo, logger)
+
+	// m = 1 with s = 0 needs a = 1, p = 1 needs c = 0
+	findLine(c, "s").SetValue(circuit.Zero)
+	findLine(c, "m").SetValue(circuit.One)
+	findLine(c, "p").SetValue(circuit.One)
+	if _, err := implication.ImplyValues(); err != nil {
+		t.Fatalf("Implication failed: %v", err)
+	}
+	if a := findLine(c, "a"); a.Value != circuit.One {
+		t.Errorf("Expected a = 1 implied by the MUX, got %v", a.Value)
+	}
+	if cc := findLine(c, "c"); cc.Value != circuit.Zero {
+		t.Errorf("Expected c = 0 implied by the AOI21, got %v", cc.Value)
+	}
+	if b := findLine(c, "b"); b.Value != circuit.Zero {
+		t.Errorf("Expected b = 0 implied by p = 1 once a = 1, got %v", b.Value)
+	}
+
+	// An output value no cube gives is a conflict
+	c.Reset()
+	findLine(c, "a").SetValue(circuit.Zero)
+	findLine(c, "b").SetValue(circuit.Zero)
+	findLine(c, "m").SetValue(circuit.One)
+	if _, err := implication.ImplyValues(); err == nil {
+		t.Errorf("Expected MUX(0, 0, s) = 1 to be a conflict")
+	}
+
+	// A fault effect on the data input of a MUX propagates only with s = 0
+	c.Reset()
+	c.InjectFault(findLine(c, "a"), circuit.Zero)
+	findLine(c, "a").SetValue(circuit.One)
+	c.SimulateForward()
+	frontier.UpdateDFrontier()
+	mux := findLine(c, "m").InputGate
+	found := false
+	for _, gate := range frontier.DFrontier {
+		found = found || gate == mux
+	}
+	if !found {
+		t.Fatalf("Expected the MUX in the D-frontier, got %v", frontier.DFrontier)
+	}
+	cube, ok := mux.PropagationCube()
+	if !ok || cube.Literal(2) != circuit.Zero || cube.Size() != 1 {
+		t.Errorf("Expected s = 0 to propagate a through the MUX, got %v", cube)
+	}
+	findLine(c, "s").SetValue(circuit.One)
+	if _, ok := mux.PropagationCube(); ok {
+		t.Errorf("Expected s = 1 to block a")
+	}
+}
+
+// TestCellATPG tests test generation on a circuit of cells against exhaustive
+// simulation: every detected fault has a verified test and no test exists for
+// any fault proven redundant
+func TestCellATPG(t *testing.T) {
+	c, err := parseBench(t, cellBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	logger := utils.NewLogger(utils.ErrorLevel)
+
+	for _, heuristic := range []algorithm.Heuristic{algorithm.HeuristicStructural, algorithm.HeuristicSCOAP} {
+		fan := algorithm.NewFan(c, logger)
+		fan.Options.Heuristic = heuristic
+		if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+			t.Fatalf("Test generation failed: %v", err)
+		}
+		if fan.Stats.AbortedFaults != 0 {
+			t.Errorf("%v: expected no aborted faults, got %d", heuristic, fan.Stats.AbortedFaults)
+		}
+
+		verifier := simulation.NewVerifier(c)
+		for fault, result := range fan.Results {
+			if result.Status != algorithm.Redundant {
+				continue
+			}
+			for m := 0; m < 1<<len(c.Inputs); m++ {
+				test := make(map[string]circuit.LogicValue)
+				for i, input := range c.Inputs {
+					test[input.Name] = circuit.LogicValue(1 + m>>i&1)
+				}
+				if verifier.Detects(test, fault) {
+					t.Errorf("%v: fault %s proven redundant is detected by %v", heuristic, fault, test)
+					break
+				}
+			}
+		}
+
+		one := findLine(c, "one")
+		if result := fan.GenerateTest(circuit.Fault{Line: one, Type: circuit.One}); result.Status != algorithm.Redundant {
+			t.Errorf("%v: expected one/1 on a tie cell to be redundant, got %v", heuristic, result.Status)
+		}
+		if result := fan.GenerateTest(circuit.Fault{Line: one, Type: circuit.Zero}); result.Status != algorithm.Detected {
+			t.Errorf("%v: expected one/0 to be detected, got %v", heuristic, result.Status)
+		}
+	}
+
+	// The tests detect the same faults under bit-parallel fault simulation
+	fan := algorithm.NewFan(c, logger)
+	tests, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Test generation failed: %v", err)
+	}
+	var patterns []map[string]circuit.LogicValue
+	for _, test := range tests {
+		patterns = append(patterns, test)
+	}
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	sim := simulation.NewFaultSimulator(c, topo, logger)
+	detected := sim.Detect(patterns, fan.FaultList.Faults)
+	for i, fault := range fan.FaultList.Faults {
+		if status := fan.Results[fault].Status; (status == algorithm.Detected) != (detected[i] >= 0) {
+			t.Errorf("Fault %s is %v but detected by pattern %d in fault simulation", fault, status, detected[i])
+		}
+	}
+}
+
+// TestCellFaultCollapsing tests that an input fault that makes a cell constant
+// is equivalent to the output fault
+func TestCellFaultCollapsing(t *testing.T) {
+	c, err := parseBench(t, `INPUT(a)
+INPUT(b)
+INPUT(c)
+INPUT(s)
+OUTPUT(p)
+OUTPUT(m)
+p = AOI21(a, b, c)
+m = MUX(a, c, s)
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	fl := circuit.NewFaultList(c, false)
+	p, cc := findLine(c, "p"), findLine(c, "c")
+	rep := fl.RepresentativeOf(circuit.Fault{Line: p, Type: circuit.Zero})
+	if rep != (circuit.Fault{Line: cc, Type: circuit.One, Branch: p.InputGate}) {
+		t.Errorf("Expected p/0 to be represented by c->%s/1, got %s", p.InputGate.Name, rep)
+	}
+
+	// No input fault of a MUX makes it constant
+	m := findLine(c, "m")
+	for _, v := range []circuit.LogicValue{circuit.Zero, circuit.One} {
+		if members := fl.Members(circuit.Fault{Line: m, Type: v}); len(members) != 1 {
+			t.Errorf("Expected m/%v to be alone in its class, got %v", v, members)
+		}
+	}
+}
+
+// TestSimplifyCells tests folding constants into cells
+func TestSimplifyCells(t *testing.T) {
+	c, err := parseBench(t, cellBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+
+	// s = 0 selects a, c = 0 leaves the AOI21 a NAND of a and b as a LUT
+	s, err := c.Simplify([]circuit.Fault{
+		{Line: findLine(c, "s"), Type: circuit.Zero},
+		{Line: findLine(c, "c"), Type: circuit.Zero},
+	})
+	if err != nil {
+		t.Fatalf("Failed to simplify: %v", err)
+	}
+	if m := findLine(s, "m"); m.InputGate.Type != circuit.BUF || m.InputGate.Inputs[0].Name != "a" {
+		t.Errorf("Expected m = BUF(a), got %v", m.InputGate)
+	}
+	if p := findLine(s, "p").InputGate; p.Type != circuit.LUT || p.Table != 0x7 || len(p.Inputs) != 2 {
+		t.Errorf("Expected p = LUT 0x7 (a, b), got %v with table %#x", p, p.Table)
+	}
+
+	// The tie cells fold into the gates they drive
+	if r := findLine(s, "r").InputGate; r.Type != circuit.LUT || len(r.Inputs) != 3 {
+		t.Errorf("Expected r to be a LUT of m, p and q, got %v", r)
+	}
+	if w := findLine(s, "w").InputGate; w.Type != circuit.BUF {
+		t.Errorf("Expected w = BUF(r), got %v", w)
+	}
+	if findLine(s, "one") != nil || findLine(s, "zero") != nil {
+		t.Errorf("Expected the tie cells to be removed")
+	}
+
+	checker, err := algorithm.NewEquivalenceChecker(s, s.Clone(), utils.NewLogger(utils.ErrorLevel))
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	if result := checker.Check(); result.Status != algorithm.Equivalent {
+		t.Errorf("Expected a circuit of cells to be equivalent to its copy, got %v", result.Status)
+	}
+}
+
+// TestWriteCells tests that cells round-trip through BENCH
+func TestWriteCells(t *testing.T) {
+	c, err := parseBench(t, cellBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	benchFile := writeNetlist(t, c, "cells.bench")
+	content, err := os.ReadFile(benchFile)
+	if err != nil {
+		t.Fatalf("Failed to read written netlist: %v", err)
+	}
+	for _, statement := range []string{"one = TIE1()", "m = MUX(a, b, s)", "v = LUT 0xe8(m, u, l)"} {
+		if !strings.Contains(string(content), statement) {
+			t.Errorf("Expected %q in the netlist, got:\n%s", statement, content)
+		}
+	}
+
+	written, err := utils.ParseBenchFile(benchFile)
+	if err != nil {
+		t.Fatalf("Failed to read written netlist: %v", err)
+	}
+	checkRoundTrip(t, c, written)
+	if l := findLine(written, "l").InputGate; l.Type != circuit.LUT || l.Table != 0x96 {
+		t.Errorf("Expected l = LUT 0x96, got %v", l)
+	}
+
+	// Verilog has no primitive for a cell
+	err = utils.WriteVerilog(filepath.Join(t.TempDir(), "cells.v"), c)
+	if err == nil || !strings.Contains(err.Error(), "has no Verilog primitive") {
+		t.Errorf("Expected cells to be rejected in Verilog, got %v", err)
+	}
+}
+
+// TestValidateCells tests the arity and truth table checks of cells
+func TestValidateCells(t *testing.T) {
+	_, diagnostics, err := parseBenchWithPolicy(t, `INPUT(a)
+INPUT(b)
+INPUT(c)
+OUTPUT(z)
+z = AND(m, n, k, l, o, t)
+m = MUX(a, b)
+n = LUT(a, b)
+k = AND 0x8 (a, b)
+l = LUT 0x1ff (a, b, c)
+o = AND()
+t = TIE0(a)
+`, utils.DefaultValidationPolicy())
+	if err == nil {
+		t.Fatalf("Expected a validation error")
+	}
+
+	expected := []struct {
+		line    int
+		problem utils.NetlistProblem
+		message string
+	}{
+		{6, utils.WrongArity, "MUX gate driving m has 2 inputs instead of 3"},
+		{7, utils.SyntaxProblem, "LUT driving n has no truth table"},
+		{8, utils.SyntaxProblem, "AND gate driving k takes no truth table"},
+		{9, utils.WrongArity, "truth table 0x1ff of the LUT driving l has more than the 8 bits of 3 inputs"},
+		{10, utils.WrongArity, "AND gate driving o has no inputs"},
+		{11, utils.WrongArity, "TIE0 gate driving t has 1 inputs instead of 0"},
+	}
+	var found []utils.Diagnostic
+	for _, d := range diagnostics {
+		if d.Problem == utils.SyntaxProblem || d.Problem == utils.WrongArity {
+			found = append(found, d)
+		}
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), found)
+	}
+	for i, e := range expected {
+		if found[i].Line != e.line || found[i].Problem != e.problem || !strings.Contains(found[i].Message, e.message) {
+			t.Errorf("Expected %v %q at line %d, got %v", e.problem, e.message, e.line, found[i])
+		}
+	}
+}
@@ -1,6 +1,73 @@
 package test
 
-import "github.com/fyerfyer/fan-atpg/pkg/circuit"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// c17Bench is the ISCAS-85 c17 benchmark, a small circuit with reconvergent fanout
+const c17Bench = `# c17
+INPUT(1)
+INPUT(2)
+INPUT(3)
+INPUT(6)
+INPUT(7)
+OUTPUT(22)
+OUTPUT(23)
+10 = NAND(1, 3)
+11 = NAND(3, 6)
+16 = NAND(2, 11)
+19 = NAND(11, 7)
+22 = NAND(10, 16)
+23 = NAND(16, 19)
+`
+
+// s27Bench is the ISCAS-89 s27 benchmark
+const s27Bench = `# s27
+INPUT(G0)
+INPUT(G1)
+INPUT(G2)
+INPUT(G3)
+OUTPUT(G17)
+G5 = DFF(G10)
+G6 = DFF(G11)
+G7 = DFF(G13)
+G14 = NOT(G0)
+G17 = NOT(G11)
+G8 = AND(G14, G6)
+G15 = OR(G12, G8)
+G16 = OR(G3, G8)
+G9 = NAND(G16, G15)
+G10 = NOR(G14, G11)
+G11 = NOR(G5, G9)
+G12 = NOR(G1, G7)
+G13 = NOR(G2, G12)
+`
+
+// parseBench writes a BENCH description to a temporary file and parses it
+func parseBench(t *testing.T, content string) (*circuit.Circuit, error) {
+	t.Helper()
+	benchFile := filepath.Join(t.TempDir(), "circuit.bench")
+	if err := os.WriteFile(benchFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test BENCH file: %v", err)
+	}
+	return utils.ParseBenchFile(benchFile)
+}
+
+// Helper function to parse the c17 benchmark
+func createC17Circuit(t *testing.T) *circuit.Circuit {
+	t.Helper()
+	c, err := parseBench(t, c17Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse c17: %v", err)
+	}
+	c.Name = "c17"
+	return c
+}
 
 // Helper function to find a line by name (already implemented elsewhere)
 func findLine(c *circuit.Circuit, name string) *circuit.Line {
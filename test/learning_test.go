@@ -0,0 +1,227 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// learningBench reconverges a on f, so that f = 1 implies a = 1 although
+// neither input of the OR gate is implied by it. z = f AND NOT a is constant 0.
+const learningBench = `
+INPUT(a)
+INPUT(b)
+INPUT(c)
+OUTPUT(f)
+OUTPUT(z)
+d = AND(a, b)
+e = AND(a, c)
+f = OR(d, e)
+na = NOT(a)
+z = AND(f, na)
+`
+
+// TestStaticLearning tests the indirect implications and constant lines learned
+func TestStaticLearning(t *testing.T) {
+	c, err := parseBench(t, learningBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	learning := fan.Implication.Learn()
+
+	a, f, z := findLine(c, "a"), findLine(c, "f"), findLine(c, "z")
+	want := algorithm.Assignment{Line: a, Value: circuit.One}
+	found := false
+	for _, implied := range learning.Implications[algorithm.Assignment{Line: f, Value: circuit.One}] {
+		found = found || implied == want
+	}
+	if !found {
+		t.Errorf("Expected f = 1 to imply a = 1, got %v",
+			learning.Implications[algorithm.Assignment{Line: f, Value: circuit.One}])
+	}
+
+	// A direct implication is not learned again: a = 0 implies na = 1 directly
+	na := findLine(c, "na")
+	if implied := learning.Implications[algorithm.Assignment{Line: na, Value: circuit.One}]; len(implied) > 0 {
+		t.Errorf("Expected nothing learned for na = 1, got %v", implied)
+	}
+
+	if value, ok := learning.Constants[z]; !ok || value != circuit.Zero {
+		t.Errorf("Expected z to be learned constant 0, got %v (%v)", value, ok)
+	}
+	if len(learning.Constants) != 1 {
+		t.Errorf("Expected only z to be constant, got %d constant lines", len(learning.Constants))
+	}
+
+	for _, line := range c.Lines {
+		if line.Value != circuit.X {
+			t.Errorf("Expected learning to leave %s reset, got %v", line.Name, line.Value)
+		}
+	}
+
+	// The learned implications carry over to a clone by line ID
+	clone := c.Clone()
+	translated := learning.Translate(clone)
+	if translated.Count() != learning.Count() || translated.Constants[findLine(clone, "z")] != circuit.Zero {
+		t.Errorf("Expected the translated learning to match, got %d implications", translated.Count())
+	}
+}
+
+// TestImplyLearned tests that ImplyValues applies the learned implications
+func TestImplyLearned(t *testing.T) {
+	c, err := parseBench(t, learningBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	// Direct implication of f = 1 stops at the OR gate
+	findLine(c, "f").SetValue(circuit.One)
+	if _, err := fan.Implication.ImplyValues(); err != nil {
+		t.Fatalf("Unexpected conflict: %v", err)
+	}
+	if a := findLine(c, "a"); a.Value != circuit.X {
+		t.Fatalf("Expected a to stay X without learning, got %v", a.Value)
+	}
+
+	fan.Implication.Learn()
+	findLine(c, "f").SetValue(circuit.One)
+	if _, err := fan.Implication.ImplyValues(); err != nil {
+		t.Fatalf("Unexpected conflict: %v", err)
+	}
+	for name, want := range map[string]circuit.LogicValue{"a": circuit.One, "na": circuit.Zero, "z": circuit.Zero} {
+		if got := findLine(c, name).Value; got != want {
+			t.Errorf("Expected %s = %v with learning, got %v", name, want, got)
+		}
+	}
+
+	// A value that contradicts a learned implication is a conflict
+	c.Reset()
+	findLine(c, "f").SetValue(circuit.One)
+	findLine(c, "na").SetValue(circuit.One)
+	if _, err := fan.Implication.ImplyValues(); err == nil {
+		t.Error("Expected f = 1 and na = 1 to conflict")
+	}
+}
+
+// TestLearningATPG tests that learning keeps the result of every fault and
+// does not need more backtracks
+func TestLearningATPG(t *testing.T) {
+	circuits := map[string]string{"c17": c17Bench, "s27": s27Bench, "learning": learningBench}
+	for name, content := range circuits {
+		t.Run(name, func(t *testing.T) {
+			c, err := parseBench(t, content)
+			if err != nil {
+				t.Fatalf("Failed to parse circuit: %v", err)
+			}
+			plain := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+			learned := algorithm.NewFan(c.Clone(), utils.NewLogger(utils.ErrorLevel))
+			learned.Options.Learning = true
+
+			plainBacktracks, learnedBacktracks := 0, 0
+			for _, fault := range circuit.NewFaultList(c, false).Faults {
+				want := plain.GenerateTest(fault)
+				plainBacktracks += plain.Stats.Backtracks
+				got := learned.GenerateTest(learned.Circuit.TranslateFault(fault))
+				learnedBacktracks += learned.Stats.Backtracks
+
+				if got.Status != want.Status {
+					t.Errorf("Expected %s to be %v with learning, got %v", fault, want.Status, got.Status)
+				}
+			}
+			if learnedBacktracks > plainBacktracks {
+				t.Errorf("Expected at most %d backtracks with learning, got %d", plainBacktracks, learnedBacktracks)
+			}
+		})
+	}
+}
+
+// TestLearningRedundantFault tests that a fault on a line learned constant is
+// proven redundant without searching
+func TestLearningRedundantFault(t *testing.T) {
+	c, err := parseBench(t, learningBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	fan.Options.Learning = true
+	fault := circuit.Fault{Line: findLine(c, "z"), Type: circuit.Zero}
+
+	result := fan.GenerateTest(fault)
+	if result.Status != algorithm.Redundant {
+		t.Fatalf("Expected z/0 to be redundant, got %v", result.Status)
+	}
+	if fan.Stats.Decisions != 0 || fan.Stats.Backtracks != 0 {
+		t.Errorf("Expected learning to end the search at once, got %d decisions and %d backtracks",
+			fan.Stats.Decisions, fan.Stats.Backtracks)
+	}
+}
+
+// backtrackBench has e = NAND(a, a OR b), which is NOT a, so e = 1 implies
+// a = 0 only by learning
+const backtrackBench = `
+INPUT(a)
+INPUT(b)
+OUTPUT(y)
+OUTPUT(z)
+d = OR(a, b)
+e = NAND(a, d)
+y = NAND(d, e)
+z = NOR(b, e)
+`
+
+// TestLearningSavesBacktracks tests that the search applies learned
+// implications: activating e/0 implies a = 0, which the search otherwise has
+// to find by backtracking
+func TestLearningSavesBacktracks(t *testing.T) {
+	c, err := parseBench(t, backtrackBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	fault := circuit.Fault{Line: findLine(c, "e"), Type: circuit.Zero}
+
+	plain := fan.GenerateTest(fault)
+	plainBacktracks := fan.Stats.Backtracks
+
+	fan.Options.Learning = true
+	result := fan.GenerateTest(fault)
+	if plain.Status != algorithm.Detected || result.Status != algorithm.Detected {
+		t.Fatalf("Expected e/0 to be detected, got %v and %v with learning", plain.Status, result.Status)
+	}
+	if plainBacktracks == 0 || fan.Stats.Backtracks >= plainBacktracks {
+		t.Errorf("Expected learning to save backtracks, got %d (%d without)", fan.Stats.Backtracks, plainBacktracks)
+	}
+	if result.Test["a"] != circuit.Zero {
+		t.Errorf("Expected the test to set a = 0, got %v", result.Test)
+	}
+}
+
+// TestLearningParallel tests that workers use the learned implications of the circuit
+func TestLearningParallel(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	sequential := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	sequential.Options.Learning = true
+	if _, err := sequential.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+
+	parallel := algorithm.NewFan(c.Clone(), utils.NewLogger(utils.ErrorLevel))
+	parallel.Options.Learning = true
+	parallel.Jobs = 4
+	if _, err := parallel.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Failed to generate tests in parallel: %v", err)
+	}
+	if parallel.Learning == nil || parallel.Learning.Count() != sequential.Learning.Count() {
+		t.Fatalf("Expected the same learned implications in both runs")
+	}
+	if got, want := parallel.Stats.FaultCoverage(), sequential.Stats.FaultCoverage(); got != want {
+		t.Errorf("Expected fault coverage %.3f in parallel, got %.3f", want, got)
+	}
+}
@@ -0,0 +1,268 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// demoLibrary has a cell of every kind: functions that map to primitives, to a
+// fixed cell and to LUTs, a cell of two outputs, a netlist, a tie cell and a
+// flip-flop
+const demoLibrary = `{
+  "name": "demo",
+  "cells": [
+    {"name": "NAND2X1", "inputs": ["A", "B"], "outputs": ["Y"], "functions": {"Y": "!(A & B)"}},
+    {"name": "INVX1", "inputs": ["A"], "outputs": ["Y"], "functions": {"Y": "A'"}},
+    {"name": "AOI21X1", "inputs": ["A0", "A1", "B0"], "outputs": ["Y"], "functions": {"Y": "!(A0 A1 + B0)"}},
+    {"name": "XOR3X1", "inputs": ["A", "B", "C"], "outputs": ["Y"], "functions": {"Y": "A ^ B ^ C"}},
+    {"name": "ADDFX1", "inputs": ["A", "B", "CI"], "outputs": ["S", "CO"],
+     "functions": {"S": "A ^ B ^ CI", "CO": "(A * B) + (CI * (A + B))"}},
+    {"name": "MX2X1", "inputs": ["A", "B", "S0"], "outputs": ["Y"],
+     "netlist": ["t0 = AND(A, sn)", "t1 = AND(B, S0)", "sn = NOT(S0)", "Y = OR(t0, t1)"]},
+    {"name": "TIEHI", "inputs": [], "outputs": ["Y"], "functions": {"Y": "1"}},
+    {"name": "DFFRX1", "inputs": ["D", "CK", "RN"], "outputs": ["Q", "QN"],
+     "flipflop": {"d": "D", "q": "Q", "qn": "QN"}}
+  ]
+}
+`
+
+// cellNetlist instantiates the demo library with named and ordered connections
+const cellNetlist = `module top(a, b, c, s, z, co);
+  input a, b, c, s;
+  output z, co;
+  wire n1, n2, n3, sum, d, q;
+  NAND2X1 u1 (.A(a), .B(b), .Y(n1));
+  AOI21X1 u2 (n2, n1, c, s);
+  ADDFX1 u3 (.A(n1), .B(n2), .CI(c), .S(sum), .CO(co));
+  MX2X1 u4 (.A(sum), .B(q), .S0(s), .Y(d));
+  DFFRX1 r1 (.D(d), .CK(c), .RN(a), .Q(q), .QN());
+  XOR3X1 u5 (.A(d), .B(n2), .C(b), .Y(n3));
+  INVX1 u6 (.A(n3), .Y(z));
+endmodule
+`
+
+// cellNetlistReference is cellNetlist in primitives
+const cellNetlistReference = `INPUT(a)
+INPUT(b)
+INPUT(c)
+INPUT(s)
+OUTPUT(z)
+OUTPUT(co)
+q = DFF(d)
+n1 = NAND(a, b)
+x = AND(n1, c)
+n2 = NOR(x, s)
+p = XOR(n1, n2)
+sum = XOR(p, c)
+g = AND(n1, n2)
+h = OR(n1, n2)
+k = AND(c, h)
+co = OR(g, k)
+sn = NOT(s)
+t0 = AND(sum, sn)
+t1 = AND(q, s)
+d = OR(t0, t1)
+e = XOR(d, n2)
+n3 = XOR(e, b)
+z = NOT(n3)
+`
+
+// writeFile writes content to a file of a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create %s: %v", name, err)
+	}
+	return filename
+}
+
+// loadDemoLibrary loads demoLibrary
+func loadDemoLibrary(t *testing.T) *utils.CellLibrary {
+	t.Helper()
+	library, err := utils.LoadCellLibrary(writeFile(t, "demo.json", demoLibrary))
+	if err != nil {
+		t.Fatalf("Failed to load library: %v", err)
+	}
+	return library
+}
+
+// TestCellLibraryVerilog tests expanding the cell instances of a Verilog netlist
+func TestCellLibraryVerilog(t *testing.T) {
+	library := loadDemoLibrary(t)
+	if library.Name != "demo" || len(library.Cells) != 8 {
+		t.Fatalf("Expected the 8 cells of demo, got %d of %s", len(library.Cells), library.Name)
+	}
+	c, err := utils.ParseVerilogFileWithLibrary(writeFile(t, "top.v", cellNetlist), library)
+	if err != nil {
+		t.Fatalf("Failed to parse netlist: %v", err)
+	}
+
+	// Functions become a primitive or a fixed cell where one computes them
+	for _, e := range []struct {
+		gate     string
+		gateType circuit.GateType
+		table    uint64
+	}{
+		{"u1", circuit.NAND, 0},
+		{"u2", circuit.AOI21, 0},
+		{"u3/S", circuit.LUT, 0x96},
+		{"u3/CO", circuit.LUT, 0xe8},
+		{"u5", circuit.LUT, 0x96},
+		{"u6", circuit.NOT, 0},
+		{"r1", circuit.NOT, 0},
+	} {
+		gate := findGate(c, e.gate)
+		if gate == nil {
+			t.Errorf("Expected a gate %s, got none", e.gate)
+			continue
+		}
+		if gate.Type != e.gateType || e.gateType == circuit.LUT && gate.Table != e.table {
+			t.Errorf("Expected %s to be %v %#x, got %v %#x", e.gate, e.gateType, e.table, gate.Type, gate.Table)
+		}
+	}
+
+	// The nets inside an instance and its unconnected pins are named after it
+	for _, net := range []string{"u4/sn", "u4/t0", "u4/t1", "r1/QN"} {
+		if findLine(c, net) == nil {
+			t.Errorf("Expected a net %s", net)
+		}
+	}
+	if len(c.ScanCells) != 1 || c.ScanCells[0].Q.Name != "q" || c.ScanCells[0].D.Name != "d" {
+		t.Errorf("Expected the flip-flop to be a scan cell from d to q, got %v", c.ScanCells)
+	}
+	fault, err := utils.ParseFault("n1->u3/CO/1", c)
+	if err != nil || fault.Branch != findGate(c, "u3/CO") {
+		t.Errorf("Expected a fault on the n1 pin of u3/CO, got %v: %v", fault, err)
+	}
+
+	reference, err := parseBench(t, cellNetlistReference)
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	checker, err := algorithm.NewEquivalenceChecker(c, reference, utils.NewLogger(utils.ErrorLevel))
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	if result := checker.Check(); result.Status != algorithm.Equivalent {
+		t.Errorf("Expected the expanded cells to compute the reference, got %v on %v", result.Status, result.Outputs)
+	}
+}
+
+// TestCellLibraryBench tests cell instances in a BENCH netlist
+func TestCellLibraryBench(t *testing.T) {
+	library := loadDemoLibrary(t)
+
+	// The flip-flop breaks the loop through q
+	c, diagnostics, err := utils.ParseBenchFileWithLibrary(writeFile(t, "cells.bench", `INPUT(a)
+INPUT(b)
+INPUT(s)
+OUTPUT(z)
+q = DFFRX1(d, a, b)
+n1 = NAND2X1(a, q)
+d = MX2X1(n1, b, s)
+one = TIEHI()
+z = AOI21X1(d, one, q)
+`), utils.DefaultValidationPolicy(), library)
+	if err != nil || len(diagnostics) != 0 {
+		t.Fatalf("Failed to parse netlist: %v %v", diagnostics, err)
+	}
+	if len(c.ScanCells) != 1 || c.ScanCells[0].Q.Name != "q" {
+		t.Errorf("Expected a scan cell q, got %v", c.ScanCells)
+	}
+	if gate := findLine(c, "d").InputGate; gate.Name != "d/Y" || gate.Type != circuit.OR {
+		t.Errorf("Expected d to be driven by the OR of the multiplexer, got %v", gate)
+	}
+	if gate := findLine(c, "one").InputGate; gate.Type != circuit.TIE1 {
+		t.Errorf("Expected the tie cell to be a TIE1, got %v", gate.Type)
+	}
+	if findLine(c, "q/QN") == nil || findLine(c, "d/sn") == nil {
+		t.Errorf("Expected the nets inside the instances to be named after their output")
+	}
+
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Test generation failed: %v", err)
+	}
+	if fan.Stats.AbortedFaults != 0 {
+		t.Errorf("Expected no aborted faults, got %d", fan.Stats.AbortedFaults)
+	}
+
+	// An instance is validated against the pins of its cell
+	_, diagnostics, err = utils.ParseBenchFileWithLibrary(writeFile(t, "arity.bench", `INPUT(a)
+OUTPUT(z)
+z = NAND2X1(a)
+`), utils.DefaultValidationPolicy(), library)
+	if err == nil || len(diagnostics) != 1 || diagnostics[0].Problem != utils.WrongArity ||
+		!strings.Contains(diagnostics[0].Message, "NAND2X1 gate driving z has 1 inputs instead of 2") {
+		t.Errorf("Expected a NAND2X1 with one input to be reported, got %v", diagnostics)
+	}
+
+	// Without the library the cells are unknown
+	if _, _, err = utils.ParseBenchFileWithPolicy(writeFile(t, "nolib.bench", `INPUT(a)
+INPUT(b)
+OUTPUT(z)
+z = NAND2X1(a, b)
+`), utils.DefaultValidationPolicy()); err == nil || !strings.Contains(err.Error(), "unsupported gate type NAND2X1") {
+		t.Errorf("Expected NAND2X1 to be unknown without a library, got %v", err)
+	}
+}
+
+// TestCellLibraryErrors tests the problems reported in a library or an instance
+func TestCellLibraryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		cells   string
+		message string
+	}{
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "functions": {"Y": "A & C"}}`, "cell X: function of Y: C is not an input pin"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "functions": {"Y": "!(A"}}`, "missing )"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "functions": {}}`, "output Y has no function"},
+		{`{"name": "X", "inputs": ["A", "B", "C", "D", "E", "F", "G"], "outputs": ["Y"], "functions": {"Y": "A B C D E F G"}}`,
+			"depends on 7 inputs, more than 6; give the cell a netlist"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "functions": {"Y": "A"}, "netlist": ["Y = BUF(A)"]}`, "exactly one of"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "netlist": ["n = AND(A, m)", "m = NOT(n)", "Y = BUF(n)"]}`, "combinational loop"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "netlist": ["Y = AND(A, m)"]}`, "reads m, which is neither an input pin nor driven"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "netlist": ["Y = NOT(A, A)"]}`, "has 2 inputs instead of one"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Q"], "flipflop": {"d": "B", "q": "Q"}}`, "flip-flop input \"B\" is not an input pin"},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "function": {"Y": "A"}}`, "unknown field \"function\""},
+		{`{"name": "X", "inputs": ["A"], "outputs": ["Y"], "functions": {"Y": "A"}}, {"name": "X", "inputs": ["A"], "outputs": ["Y"], "functions": {"Y": "A"}}`,
+			"cell X is defined twice"},
+	} {
+		_, err := utils.LoadCellLibrary(writeFile(t, "lib.json", `{"cells": [`+tc.cells+`]}`))
+		if err == nil || !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("Expected %q for %s, got %v", tc.message, tc.cells, err)
+		}
+	}
+
+	// A JSON error is reported at its line
+	if _, err := utils.LoadCellLibrary(writeFile(t, "lib.json", "{\n\"cells\": [\n}\n")); err == nil || !strings.HasPrefix(err.Error(), "lib.json:3:") {
+		t.Errorf("Expected a syntax error at line 3, got %v", err)
+	}
+
+	library := loadDemoLibrary(t)
+	for _, tc := range []struct {
+		instance string
+		message  string
+	}{
+		{"NAND2X1 u1 (.A(a), .Z(b), .Y(z));", "top.v:4: cell NAND2X1 has no pin Z"},
+		{"NAND2X1 u1 (.A(a), .Y(z));", "input pin B of u1 is not connected"},
+		{"NAND2X1 u1 (.A(a), .A(b), .Y(z));", "pin A of u1 is connected twice"},
+		{"NAND2X1 u1 (z, a, b, b);", "u1 connects more than the 3 pins of cell NAND2X1"},
+	} {
+		_, err := utils.ParseVerilogFileWithLibrary(writeFile(t, "top.v", `module top(a, b, z);
+  input a, b;
+  output z;
+  `+tc.instance+`
+endmodule
+`), library)
+		if err == nil || !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("Expected %q for %s, got %v", tc.message, tc.instance, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestDecisionLimitAborts tests that a fault is aborted once the decision limit is reached
+func TestDecisionLimitAborts(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	fan.Options.MaxDecisions = 1
+
+	result := fan.GenerateTest(circuit.Fault{Line: findLine(c, "22"), Type: circuit.Zero})
+	if result.Status != algorithm.Aborted {
+		t.Fatalf("Expected 22/0 to be aborted, got %v", result.Status)
+	}
+	if !strings.Contains(result.Reason, "decision limit") {
+		t.Errorf("Expected the decision limit as reason, got %q", result.Reason)
+	}
+
+	// Without the limit the same fault is detected
+	fan.Options = algorithm.DefaultOptions()
+	if result := fan.GenerateTest(result.Fault); result.Status != algorithm.Detected {
+		t.Errorf("Expected 22/0 to be detected with default options, got %v", result.Status)
+	}
+}
+
+// TestImplicationLimitAborts tests that a fault is aborted once an implication
+// pass reaches the implication limit
+func TestImplicationLimitAborts(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	fan.Options.MaxImplications = 1
+
+	result := fan.GenerateTest(circuit.Fault{Line: findLine(c, "22"), Type: circuit.Zero})
+	if result.Status != algorithm.Aborted {
+		t.Fatalf("Expected 22/0 to be aborted, got %v", result.Status)
+	}
+	if !strings.Contains(result.Reason, "implication limit (1)") {
+		t.Errorf("Expected the implication limit as reason, got %q", result.Reason)
+	}
+
+	// Without the limit the same fault is detected, counting every implication pass
+	fan.Options = algorithm.DefaultOptions()
+	if result := fan.GenerateTest(result.Fault); result.Status != algorithm.Detected {
+		t.Errorf("Expected 22/0 to be detected with default options, got %v", result.Status)
+	}
+	if fan.Stats.Implications <= fan.Stats.Decisions {
+		t.Errorf("Expected more implication passes than the %d decisions, got %d",
+			fan.Stats.Decisions, fan.Stats.Implications)
+	}
+}
+
+// TestGenerateTestsForAllFaultsCancelled tests that a cancelled run aborts the remaining faults
+func TestGenerateTestsForAllFaultsCancelled(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests, err := fan.GenerateTestsForAllFaultsContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(tests) != 0 {
+		t.Errorf("Expected no tests from a cancelled run, got %d", len(tests))
+	}
+	if fan.Stats.AbortedFaults != fan.FaultList.Size() || len(fan.Results) != fan.FaultList.Size() {
+		t.Errorf("Expected all %d faults to be aborted, got %+v", fan.FaultList.Size(), fan.Stats)
+	}
+}
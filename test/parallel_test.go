@@ -0,0 +1,76 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestCircuitClone tests that a clone has the same structure but no shared state
+func TestCircuitClone(t *testing.T) {
+	c := createC17Circuit(t)
+	clone := c.Clone()
+
+	if len(clone.Lines) != len(c.Lines) || len(clone.Gates) != len(c.Gates) {
+		t.Fatalf("Expected %d lines and %d gates, got %d and %d",
+			len(c.Lines), len(c.Gates), len(clone.Lines), len(clone.Gates))
+	}
+	for i, input := range c.Inputs {
+		if clone.Inputs[i].Name != input.Name || clone.Inputs[i] == input {
+			t.Errorf("Expected a copy of input %s at position %d", input.Name, i)
+		}
+	}
+
+	line := findLine(c, "11")
+	cloned := findLine(clone, "11")
+	if cloned == line || len(cloned.OutputGates) != len(line.OutputGates) {
+		t.Fatalf("Expected a copy of line 11 with %d fanouts", len(line.OutputGates))
+	}
+	for i, gate := range cloned.OutputGates {
+		if gate.Name != line.OutputGates[i].Name || gate.Output.InputGate != gate {
+			t.Errorf("Expected fanout %d of line 11 to be a connected copy of %s", i, line.OutputGates[i].Name)
+		}
+	}
+
+	// Values set on the clone do not leak into the original
+	cloned.SetValue(circuit.One)
+	if line.Value != circuit.X {
+		t.Errorf("Expected original line 11 to stay X, got %v", line.Value)
+	}
+
+	fault := circuit.Fault{Line: line, Type: circuit.Zero, Branch: line.OutputGates[1]}
+	if got := clone.TranslateFault(fault); got.Line != cloned || got.Branch != cloned.OutputGates[1] {
+		t.Errorf("Expected %s to translate to the clone, got %s", fault, got)
+	}
+}
+
+// TestParallelMatchesSequential tests that parallel test generation gives the same results
+func TestParallelMatchesSequential(t *testing.T) {
+	c := createC17Circuit(t)
+	sequential := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	want, err := sequential.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+
+	for _, jobs := range []int{2, 4, 16} {
+		parallel := algorithm.NewFan(createC17Circuit(t), utils.NewLogger(utils.ErrorLevel))
+		parallel.Jobs = jobs
+		got, err := parallel.GenerateTestsForAllFaults()
+		if err != nil {
+			t.Fatalf("Failed to generate tests with %d jobs: %v", jobs, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected the same tests with %d jobs, got %v, want %v", jobs, got, want)
+		}
+		if parallel.Stats.TestsFound != sequential.Stats.TestsFound ||
+			parallel.Stats.DroppedFaults != sequential.Stats.DroppedFaults {
+			t.Errorf("Expected the same statistics with %d jobs, got %+v, want %+v",
+				jobs, parallel.Stats, sequential.Stats)
+		}
+	}
+}
@@ -269,7 +269,7 @@ func TestWriteTestVectors(t *testing.T) {
 
 	// Write to temporary file
 	tempFile := filepath.Join(t.TempDir(), "test_vectors.txt")
-	err := utils.WriteTestVectors(tempFile, testVectors)
+	err := utils.WriteTestVectors(tempFile, testVectors, circuit.FillNone)
 	if err != nil {
 		t.Fatalf("Failed to write test vectors: %v", err)
 	}
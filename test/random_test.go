@@ -0,0 +1,119 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// newRandomFan returns a FAN instance on c17 with the random pattern phase enabled
+func newRandomFan(t *testing.T, seed int64) *algorithm.Fan {
+	t.Helper()
+	fan := algorithm.NewFan(createC17Circuit(t), utils.NewLogger(utils.ErrorLevel))
+	fan.Random.MaxPatterns = 64
+	fan.Random.BlockSize = 8
+	fan.Random.MinGain = 0
+	fan.Random.Seed = seed
+	return fan
+}
+
+// TestRandomPhase tests that random patterns detect faults before FAN and that
+// every test still detects its faults
+func TestRandomPhase(t *testing.T) {
+	fan := newRandomFan(t, 7)
+	tests, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+
+	if fan.Stats.RandomDetected == 0 || fan.Stats.RandomPatterns == 0 {
+		t.Fatalf("Expected the random phase to detect faults, got %d with %d patterns",
+			fan.Stats.RandomDetected, fan.Stats.RandomPatterns)
+	}
+	if fan.Stats.FaultCoverage() != 1 {
+		t.Errorf("Expected full coverage of c17, got %.2f%%", fan.Stats.FaultCoverage()*100)
+	}
+	if detected := fan.Stats.RandomDetected + fan.Stats.TestsFound + fan.Stats.DroppedFaults; detected != fan.FaultList.Size() {
+		t.Errorf("Expected %d detected faults, got %d", fan.FaultList.Size(), detected)
+	}
+
+	for fault, result := range fan.Results {
+		if result.Status != algorithm.Detected {
+			continue
+		}
+		patterns := []map[string]circuit.LogicValue{result.Test}
+		if fan.Simulator.Detect(patterns, []circuit.Fault{fault})[0] != 0 {
+			t.Errorf("Test %v does not detect %s", result.Test, fault)
+		}
+		if !reflect.DeepEqual(tests[fault.String()], result.Test) {
+			t.Errorf("Expected the test of %s to be %v, got %v", fault, result.Test, tests[fault.String()])
+		}
+	}
+}
+
+// TestRandomPhaseReproducible tests that the same seed gives the same tests
+func TestRandomPhaseReproducible(t *testing.T) {
+	first, err := newRandomFan(t, 42).GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	second, err := newRandomFan(t, 42).GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same tests for the same seed")
+	}
+}
+
+// TestRandomPhaseMinGain tests that the phase ends after the first block that
+// falls below the coverage gain threshold
+func TestRandomPhaseMinGain(t *testing.T) {
+	fan := newRandomFan(t, 1)
+	fan.Random.MinGain = 1
+	if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	if fan.Stats.RandomPatterns > fan.Random.BlockSize {
+		t.Errorf("Expected at most one block of %d patterns, kept %d", fan.Random.BlockSize, fan.Stats.RandomPatterns)
+	}
+	if fan.Stats.FaultCoverage() != 1 {
+		t.Errorf("Expected FAN to cover the remaining faults, got %.2f%%", fan.Stats.FaultCoverage()*100)
+	}
+}
+
+// TestWeightedRandomPatterns tests that input weights bias the random patterns
+func TestWeightedRandomPatterns(t *testing.T) {
+	fan := newRandomFan(t, 1)
+	weights, err := utils.ParseInputWeights("1=1, 2=1,3=1,6=1,7=0", fan.Circuit)
+	if err != nil {
+		t.Fatalf("Failed to parse weights: %v", err)
+	}
+	fan.Random.Weights = weights
+	if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	if fan.Stats.RandomPatterns != 1 {
+		t.Errorf("Expected a single distinct random pattern, kept %d", fan.Stats.RandomPatterns)
+	}
+
+	expected := map[string]circuit.LogicValue{"1": circuit.One, "2": circuit.One, "3": circuit.One, "6": circuit.One, "7": circuit.Zero}
+	random := 0
+	for _, result := range fan.Results {
+		if reflect.DeepEqual(result.Test, expected) {
+			random++
+		}
+	}
+	if random < fan.Stats.RandomDetected {
+		t.Errorf("Expected %d faults detected by %v, found %d", fan.Stats.RandomDetected, expected, random)
+	}
+
+	for _, spec := range []string{"1=2", "9=0.5", "1", "1=x"} {
+		if _, err := utils.ParseInputWeights(spec, fan.Circuit); err == nil {
+			t.Errorf("Expected an error for weights %q", spec)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// redundantBench computes z = a + ab, in which the AND gate is redundant
+const redundantBench = `# redundant
+INPUT(a)
+INPUT(b)
+INPUT(c)
+OUTPUT(z)
+OUTPUT(y)
+x = AND(a, b)
+z = OR(a, x)
+w = NAND(b, c)
+y = XOR(w, a)
+`
+
+// TestSimplify tests tying lines to constants and propagating them
+func TestSimplify(t *testing.T) {
+	c, err := parseBench(t, redundantBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+
+	// x stuck-at-0 leaves z = OR(a), a buffer, and removes the AND gate
+	s, err := c.Simplify([]circuit.Fault{{Line: findLine(c, "x"), Type: circuit.Zero}})
+	if err != nil {
+		t.Fatalf("Failed to simplify: %v", err)
+	}
+	if len(s.Gates) != 3 || findLine(s, "x") != nil {
+		t.Errorf("Expected the AND gate to be removed, got %d gates", len(s.Gates))
+	}
+	if z := findLine(s, "z"); z == nil || z.InputGate.Type != circuit.BUF || z.InputGate.Inputs[0].Name != "a" {
+		t.Errorf("Expected z = BUF(a), got %v", z)
+	}
+	if len(s.Inputs) != 3 || findLine(s, "b").Type != circuit.PrimaryInput {
+		t.Errorf("Expected the inputs to be kept, got %v", s.Inputs)
+	}
+
+	// c stuck-at-1 turns the NAND into a NOT of b
+	s, err = c.Simplify([]circuit.Fault{{Line: findLine(c, "c"), Type: circuit.One}})
+	if err != nil {
+		t.Fatalf("Failed to simplify: %v", err)
+	}
+	if w := findLine(s, "w"); w.InputGate.Type != circuit.NOT || len(w.InputGate.Inputs) != 1 {
+		t.Errorf("Expected w = NOT(b), got %v", w.InputGate.Type)
+	}
+	if y := findLine(s, "y"); y.InputGate.Type != circuit.XOR {
+		t.Errorf("Expected y = XOR(w, a), got %v", y.InputGate.Type)
+	}
+
+	// c stuck-at-0 makes the NAND a constant 1 and y = XNOR(a), a NOT
+	s, err = c.Simplify([]circuit.Fault{{Line: findLine(c, "c"), Type: circuit.Zero}})
+	if err != nil {
+		t.Fatalf("Failed to simplify: %v", err)
+	}
+	if y := findLine(s, "y"); y.InputGate.Type != circuit.NOT || y.InputGate.Inputs[0].Name != "a" {
+		t.Errorf("Expected y = NOT(a), got %v", y.InputGate.Type)
+	}
+	if findLine(s, "w") != nil {
+		t.Errorf("Expected the constant NAND to be removed")
+	}
+
+	// A constant output cannot be written as a netlist
+	_, err = c.Simplify([]circuit.Fault{{Line: findLine(c, "a"), Type: circuit.One, Branch: findLine(c, "z").InputGate}})
+	if err == nil || !strings.Contains(err.Error(), "output z would be constant 1") {
+		t.Errorf("Expected a constant output to be rejected, got %v", err)
+	}
+}
+
+// TestRedundancyRemover tests that removal leaves an equivalent irredundant circuit
+func TestRedundancyRemover(t *testing.T) {
+	c, err := parseBench(t, redundantBench)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	logger := utils.NewLogger(utils.ErrorLevel)
+
+	remover := algorithm.NewRedundancyRemover(c, logger)
+	s, err := remover.Run()
+	if err != nil {
+		t.Fatalf("Redundancy removal failed: %v", err)
+	}
+	if len(remover.Removed) == 0 || len(s.Gates) != 3 {
+		t.Errorf("Expected the AND gate to be removed, got %d gates after removing %v", len(s.Gates), remover.Removed)
+	}
+	if z := findLine(s, "z"); z.InputGate.Type != circuit.BUF {
+		t.Errorf("Expected z = BUF(a), got %v", z.InputGate.Type)
+	}
+
+	fan := algorithm.NewFan(s, logger)
+	if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Test generation failed: %v", err)
+	}
+	if fan.Stats.RedundantFaults != 0 || fan.Stats.AbortedFaults != 0 {
+		t.Errorf("Expected no redundant faults to be left, got %d redundant and %d aborted",
+			fan.Stats.RedundantFaults, fan.Stats.AbortedFaults)
+	}
+
+	checker, err := algorithm.NewEquivalenceChecker(c, s, logger)
+	if err != nil {
+		t.Fatalf("Failed to create checker: %v", err)
+	}
+	if result := checker.Check(); result.Status != algorithm.Equivalent {
+		t.Errorf("Expected the simplified circuit to be equivalent, got %v on %v", result.Status, result.Outputs)
+	}
+
+	// c17 has no redundancy
+	remover = algorithm.NewRedundancyRemover(createC17Circuit(t), logger)
+	if s, err = remover.Run(); err != nil || len(remover.Removed) != 0 || len(s.Gates) != 6 {
+		t.Errorf("Expected c17 to be left unchanged, got %d gates after removing %v: %v", len(s.Gates), remover.Removed, err)
+	}
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestGenerateTestDetected tests that a testable fault is reported as detected
+func TestGenerateTestDetected(t *testing.T) {
+	c := createTestCircuit()
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	result := fan.GenerateTest(circuit.Fault{Line: findLine(c, "in2"), Type: circuit.Zero})
+	if result.Status != algorithm.Detected {
+		t.Fatalf("Expected in2/0 to be detected, got %v (%s)", result.Status, result.Reason)
+	}
+	if result.Test == nil || result.Err() != nil {
+		t.Errorf("Expected a test and no error for a detected fault, got %v, %v", result.Test, result.Err())
+	}
+}
+
+// TestGenerateTestC17 tests that no fault of the irredundant c17 circuit is
+// reported redundant or aborted, and that every test detects its fault
+func TestGenerateTestC17(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	for _, fault := range circuit.NewFaultList(c, false).All {
+		result := fan.GenerateTest(fault)
+		if result.Status != algorithm.Detected {
+			t.Errorf("Expected %s to be detected, got %v (%s)", fault, result.Status, result.Reason)
+			continue
+		}
+		patterns := []map[string]circuit.LogicValue{result.Test}
+		if fan.Simulator.Detect(patterns, []circuit.Fault{fault})[0] != 0 {
+			t.Errorf("Test %v does not detect %s", result.Test, fault)
+		}
+	}
+}
+
+// TestGenerateTestRedundant tests that an untestable fault is reported as redundant
+func TestGenerateTestRedundant(t *testing.T) {
+	c := createRedundantCircuit()
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	// w = a AND NOT(a) is constantly 0, so w stuck-at-0 cannot be activated
+	result := fan.GenerateTest(circuit.Fault{Line: findLine(c, "w"), Type: circuit.Zero})
+	if result.Status != algorithm.Redundant {
+		t.Fatalf("Expected w/0 to be redundant, got %v (%s)", result.Status, result.Reason)
+	}
+	if result.Test != nil {
+		t.Errorf("Expected no test for a redundant fault, got %v", result.Test)
+	}
+	if result.Reason == "" || result.Err() == nil {
+		t.Errorf("Expected a reason and an error for a redundant fault")
+	}
+}
+
+// TestCoverageAndEfficiency tests that redundant faults count against fault coverage
+// but not against test efficiency
+func TestCoverageAndEfficiency(t *testing.T) {
+	c := createRedundantCircuit()
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+
+	w := findLine(c, "w")
+	rep := fan.FaultList.RepresentativeOf(circuit.Fault{Line: w, Type: circuit.Zero})
+	if result := fan.Results[rep]; result == nil || result.Status != algorithm.Redundant {
+		t.Errorf("Expected %s to be redundant, got %v", rep, result)
+	}
+
+	if fan.Stats.RedundantFaults == 0 {
+		t.Errorf("Expected at least one redundant fault")
+	}
+	if fan.Stats.UndetectedFaults != fan.Stats.RedundantFaults+fan.Stats.AbortedFaults {
+		t.Errorf("Expected undetected faults to be redundant plus aborted, got %+v", fan.Stats)
+	}
+	if fan.Stats.FaultCoverage() >= 1 {
+		t.Errorf("Expected fault coverage below 100%%, got %.2f", fan.Stats.FaultCoverage())
+	}
+	if fan.Stats.AbortedFaults == 0 && fan.Stats.TestEfficiency() != 1 {
+		t.Errorf("Expected 100%% test efficiency without aborted faults, got %.2f", fan.Stats.TestEfficiency())
+	}
+
+	// Every targeted fault has a result
+	if len(fan.Results) != fan.FaultList.Size() {
+		t.Errorf("Expected %d results, got %d", fan.FaultList.Size(), len(fan.Results))
+	}
+}
+
+// createRedundantCircuit creates a circuit with a constant-0 line:
+// na = NOT(a); w = AND(a, na); out = OR(w, b)
+func createRedundantCircuit() *circuit.Circuit {
+	c := circuit.NewCircuit("redundant_circuit")
+
+	a := circuit.NewLine(1, "a", circuit.PrimaryInput)
+	b := circuit.NewLine(2, "b", circuit.PrimaryInput)
+	na := circuit.NewLine(3, "na", circuit.Normal)
+	w := circuit.NewLine(4, "w", circuit.Normal)
+	out := circuit.NewLine(5, "out", circuit.PrimaryOutput)
+
+	c.AddLine(a)
+	c.AddLine(b)
+	c.AddLine(na)
+	c.AddLine(w)
+	c.AddLine(out)
+
+	g1 := circuit.NewGate(1, "g1", circuit.NOT)
+	g1.AddInput(a)
+	g1.SetOutput(na)
+
+	g2 := circuit.NewGate(2, "g2", circuit.AND)
+	g2.AddInput(a)
+	g2.AddInput(na)
+	g2.SetOutput(w)
+
+	g3 := circuit.NewGate(3, "g3", circuit.OR)
+	g3.AddInput(w)
+	g3.AddInput(b)
+	g3.SetOutput(out)
+
+	c.AddGate(g1)
+	c.AddGate(g2)
+	c.AddGate(g3)
+
+	c.AnalyzeTopology()
+	return c
+}
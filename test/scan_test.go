@@ -0,0 +1,130 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestParseScanCells tests that flip-flops become pseudo-primary inputs and outputs
+func TestParseScanCells(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+
+	if len(c.Gates) != 10 {
+		t.Errorf("Expected 10 combinational gates, got %d", len(c.Gates))
+	}
+	if len(c.ScanCells) != 3 {
+		t.Fatalf("Expected 3 scan cells, got %d", len(c.ScanCells))
+	}
+	if len(c.Inputs) != 7 || len(c.PrimaryInputs()) != 4 {
+		t.Errorf("Expected 4 primary and 7 total inputs, got %d and %d", len(c.PrimaryInputs()), len(c.Inputs))
+	}
+	if len(c.Outputs) != 4 || len(c.PrimaryOutputs()) != 1 {
+		t.Errorf("Expected 1 primary and 4 total outputs, got %d and %d", len(c.PrimaryOutputs()), len(c.Outputs))
+	}
+
+	cell := c.ScanCells[0]
+	if cell.Name != "G5" || cell.Q.Name != "G5" || cell.D.Name != "G10" {
+		t.Errorf("Expected scan cell G5 capturing G10, got %s capturing %s", cell.Q.Name, cell.D.Name)
+	}
+	if cell.Q.Type != circuit.PrimaryInput || cell.Q.InputGate != nil {
+		t.Errorf("Expected G5 to be an undriven pseudo-primary input")
+	}
+	if cell.D.Type != circuit.PrimaryOutput {
+		t.Errorf("Expected G10 to be a pseudo-primary output")
+	}
+}
+
+// TestParseScanCellCapturingInput tests a flip-flop whose data input is an input itself
+func TestParseScanCellCapturingInput(t *testing.T) {
+	c, err := parseBench(t, `INPUT(a)
+OUTPUT(z)
+q1 = DFF(a)
+q2 = DFF(q1)
+z = AND(q2, a)
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+
+	// Both captured lines are inputs, so they are observed through buffers
+	for _, cell := range c.ScanCells {
+		if cell.D.Name != cell.Name+"$D" || cell.D.Type != circuit.PrimaryOutput {
+			t.Errorf("Expected %s to capture a buffered pseudo-primary output, got %s", cell.Name, cell.D.Name)
+		}
+	}
+	if q1 := findLine(c, "q1"); q1.Type != circuit.PrimaryInput {
+		t.Errorf("Expected q1 to stay a pseudo-primary input")
+	}
+}
+
+// TestParseUnsupportedGate tests that unknown gate types are rejected
+func TestParseUnsupportedGate(t *testing.T) {
+	_, err := parseBench(t, `INPUT(a)
+OUTPUT(z)
+z = LATCH(a)
+`)
+	if err == nil || !strings.Contains(err.Error(), "unsupported gate type LATCH") {
+		t.Errorf("Expected an unsupported gate type error, got %v", err)
+	}
+}
+
+// TestScanATPG tests that every fault of a full-scan circuit gets a valid test
+func TestScanATPG(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	if _, err := fan.GenerateTestsForAllFaults(); err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	if fan.Stats.FaultCoverage() != 1 {
+		t.Errorf("Expected full fault coverage on s27, got %.2f", fan.Stats.FaultCoverage())
+	}
+
+	// Scan-cell values are kept apart from primary input values
+	for _, result := range fan.Results {
+		pi, scan := c.SplitTest(result.Test)
+		if len(pi)+len(scan) != len(result.Test) {
+			t.Errorf("Test %v does not split into inputs and scan cells", result.Test)
+		}
+		if _, ok := scan["G0"]; ok {
+			t.Errorf("Expected primary input G0 not to be loaded by scan")
+		}
+	}
+}
+
+// TestWriteScanTestVectors tests the scan-aware test vector format
+func TestWriteScanTestVectors(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+
+	tests := []map[string]circuit.LogicValue{
+		{"G0": circuit.Zero, "G3": circuit.One, "G5": circuit.One, "G7": circuit.Zero},
+	}
+	outFile := filepath.Join(t.TempDir(), "tests.txt")
+	if err := utils.WriteScanTestVectors(outFile, c, tests, circuit.FillNone); err != nil {
+		t.Fatalf("Failed to write test vectors: %v", err)
+	}
+
+	content, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Failed to read test vectors: %v", err)
+	}
+	expected := "# Format: G0 G1 G2 G3 | G5 G6 G7 \n# Test vector 1\n0 X X 1 | 1 X 0 \n"
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("Expected output to contain\n%s\ngot\n%s", expected, content)
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestFaultSimulatorDetect tests PPSFP fault simulation on a small circuit
+func TestFaultSimulatorDetect(t *testing.T) {
+	// in1, in2 -> AND(g1) -> w1; w1, in2 -> OR(g2) -> out
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+
+	logger := utils.NewLogger(utils.ErrorLevel)
+	sim := simulation.NewFaultSimulator(c, topo, logger)
+
+	patterns := []map[string]circuit.LogicValue{
+		{"in1": circuit.One, "in2": circuit.One},
+		{"in1": circuit.Zero, "in2": circuit.Zero},
+		{"in1": circuit.One, "in2": circuit.Zero},
+	}
+
+	faults := []circuit.Fault{
+		{Line: findLine(c, "w1"), Type: circuit.One},   // out = 1 when in2=0: detected by pattern 1
+		{Line: findLine(c, "in2"), Type: circuit.Zero}, // out = in1&in2|in2: detected by pattern 0
+		{Line: findLine(c, "w1"), Type: circuit.Zero},  // masked by in2=1, undetectable
+		{Line: findLine(c, "out"), Type: circuit.Zero}, // detected by pattern 0
+		{Line: findLine(c, "out"), Type: circuit.One},  // detected by pattern 1
+	}
+	expected := []int{1, 0, -1, 0, 1}
+
+	detected := sim.Detect(patterns, faults)
+	for i, fault := range faults {
+		if detected[i] != expected[i] {
+			t.Errorf("Fault %s: expected first detecting pattern %d, got %d",
+				fault, expected[i], detected[i])
+		}
+	}
+}
+
+// TestFaultSimulatorUnknownInputs tests that X inputs never produce a detection
+func TestFaultSimulatorUnknownInputs(t *testing.T) {
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+
+	sim := simulation.NewFaultSimulator(c, topo, utils.NewLogger(utils.ErrorLevel))
+
+	// in2 s-a-1 needs in2=0; with in2=X the outcome is unknown
+	patterns := []map[string]circuit.LogicValue{
+		{"in1": circuit.Zero, "in2": circuit.X},
+	}
+	faults := []circuit.Fault{{Line: findLine(c, "in2"), Type: circuit.One}}
+
+	if detected := sim.Detect(patterns, faults); detected[0] != -1 {
+		t.Errorf("Expected fault to be undetected with X input, got pattern %d", detected[0])
+	}
+}
+
+// TestFaultSimulatorMultipleBlocks tests detection beyond the first 64-pattern word
+func TestFaultSimulatorMultipleBlocks(t *testing.T) {
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+
+	sim := simulation.NewFaultSimulator(c, topo, utils.NewLogger(utils.ErrorLevel))
+
+	// Only the last of 70 patterns detects w1 s-a-1
+	patterns := make([]map[string]circuit.LogicValue, 70)
+	for i := range patterns {
+		patterns[i] = map[string]circuit.LogicValue{"in1": circuit.One, "in2": circuit.One}
+	}
+	patterns[69] = map[string]circuit.LogicValue{"in1": circuit.Zero, "in2": circuit.Zero}
+
+	faults := []circuit.Fault{{Line: findLine(c, "w1"), Type: circuit.One}}
+	if detected := sim.Detect(patterns, faults); detected[0] != 69 {
+		t.Errorf("Expected fault to be detected by pattern 69, got %d", detected[0])
+	}
+}
+
+// TestFaultSimulatorDropDetected tests splitting a fault list into remaining and dropped faults
+func TestFaultSimulatorDropDetected(t *testing.T) {
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+
+	sim := simulation.NewFaultSimulator(c, topo, utils.NewLogger(utils.ErrorLevel))
+
+	faults := []circuit.Fault{
+		{Line: findLine(c, "in2"), Type: circuit.Zero},
+		{Line: findLine(c, "in2"), Type: circuit.One},
+		{Line: findLine(c, "out"), Type: circuit.Zero},
+	}
+	patterns := []map[string]circuit.LogicValue{{"in1": circuit.One, "in2": circuit.One}}
+
+	remaining, dropped := sim.DropDetected(patterns, faults)
+	if len(dropped) != 2 {
+		t.Errorf("Expected 2 dropped faults, got %d: %v", len(dropped), dropped)
+	}
+	if len(remaining) != 1 || remaining[0].String() != "in2/1" {
+		t.Errorf("Expected only in2/1 to remain, got %v", remaining)
+	}
+}
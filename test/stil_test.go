@@ -0,0 +1,129 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// writeSTIL writes the tests of a circuit in STIL format and returns the file content
+func writeSTIL(t *testing.T, c *circuit.Circuit, tests []map[string]circuit.LogicValue) string {
+	t.Helper()
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	outFile := filepath.Join(t.TempDir(), "tests.stil")
+	if err := utils.WriteSTIL(outFile, c, tests, fan.Simulator.Responses(tests), circuit.FillNone); err != nil {
+		t.Fatalf("Failed to write STIL: %v", err)
+	}
+	content, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Failed to read STIL: %v", err)
+	}
+	return string(content)
+}
+
+// TestResponses tests the good-machine responses of the fault simulator
+func TestResponses(t *testing.T) {
+	c := createTestCircuit()
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+
+	// out = OR(AND(in1, in2), in2)
+	responses := fan.Simulator.Responses([]map[string]circuit.LogicValue{
+		{"in1": circuit.One, "in2": circuit.Zero},
+		{"in1": circuit.Zero, "in2": circuit.One},
+		{"in1": circuit.One},
+	})
+	expected := []circuit.LogicValue{circuit.Zero, circuit.One, circuit.X}
+	for i, response := range responses {
+		if response["out"] != expected[i] {
+			t.Errorf("Pattern %d: expected out = %v, got %v", i, expected[i], response["out"])
+		}
+	}
+}
+
+// TestWriteSTILCombinational tests the STIL blocks and vectors of a combinational circuit
+func TestWriteSTILCombinational(t *testing.T) {
+	c := createC17Circuit(t)
+	content := writeSTIL(t, c, []map[string]circuit.LogicValue{
+		{"1": circuit.Zero, "2": circuit.Zero, "3": circuit.Zero, "6": circuit.Zero, "7": circuit.Zero},
+		{"1": circuit.One, "3": circuit.One, "6": circuit.Zero},
+	})
+
+	for _, block := range []string{"STIL 1.0;", "Signals {", "SignalGroups {", "Timing {", "PatternBurst ", "PatternExec {", "Pattern \"_pattern_\" {"} {
+		if !strings.Contains(content, block) {
+			t.Errorf("Expected STIL to contain %q", block)
+		}
+	}
+	if !strings.Contains(content, `"_pi" = '"1" + "2" + "3" + "6" + "7"';`) {
+		t.Errorf("Expected the input group in circuit order, got\n%s", content)
+	}
+	if strings.Contains(content, "load_unload") {
+		t.Errorf("Expected no scan procedures for a combinational circuit")
+	}
+
+	// All-zero inputs give 22 = 0 and 23 = 0; 22 = 1 for 1 = 3 = 1, 6 = 0, whatever 2 and 7 are
+	if !strings.Contains(content, `"pattern 0": V { "_pi" = 00000; "_po" = LL; }`) {
+		t.Errorf("Expected the first vector with its responses, got\n%s", content)
+	}
+	if !strings.Contains(content, `"pattern 1": V { "_pi" = 1N10N; "_po" = HX; }`) {
+		t.Errorf("Expected unassigned inputs as N and unknown responses as X, got\n%s", content)
+	}
+}
+
+// TestWriteSTILScan tests the scan structures and load/unload of a full-scan circuit
+func TestWriteSTILScan(t *testing.T) {
+	c, err := parseBench(t, `INPUT(a)
+OUTPUT(z)
+q1 = DFF(n1)
+q2 = DFF(n2)
+n1 = AND(a, q2)
+n2 = NOT(q1)
+z = OR(q1, q2)
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+
+	content := writeSTIL(t, c, []map[string]circuit.LogicValue{
+		{"a": circuit.One, "q1": circuit.Zero, "q2": circuit.One},
+	})
+
+	for _, part := range []string{
+		"ScanCells \"q1\" \"q2\";",
+		`Shift { V { "_si" = #; "_so" = #; "scan_clk" = P; } }`,
+		// Loads are listed from the cell next to scan-out: q2 = 1, q1 = 0
+		`"pattern 0": Call "load_unload" { "_si" = 10; }`,
+		`V { "scan_en" = 0; "_pi" = 1; "_po" = H; }`,
+		`V { "scan_clk" = P; }`,
+		// Captured n2 = 1 into q2 and n1 = 1 into q1
+		`Call "load_unload" { "_so" = HH; }`,
+	} {
+		if !strings.Contains(content, part) {
+			t.Errorf("Expected STIL to contain %q, got\n%s", part, content)
+		}
+	}
+}
+
+// TestWriteTestVectorsColumnOrder tests that the columns do not depend on map order
+func TestWriteTestVectorsColumnOrder(t *testing.T) {
+	tests := []map[string]circuit.LogicValue{
+		{"c": circuit.One, "a": circuit.Zero, "b": circuit.One},
+	}
+	outFile := filepath.Join(t.TempDir(), "tests.txt")
+	for i := 0; i < 5; i++ {
+		if err := utils.WriteTestVectors(outFile, tests, circuit.FillNone); err != nil {
+			t.Fatalf("Failed to write test vectors: %v", err)
+		}
+		content, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("Failed to read test vectors: %v", err)
+		}
+		if !strings.Contains(string(content), "# Format: a b c \n# Test vector 1\n0 1 1 \n") {
+			t.Fatalf("Expected sorted columns, got\n%s", content)
+		}
+	}
+}
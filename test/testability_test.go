@@ -0,0 +1,90 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/testability"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestSCOAPMeasures tests controllability and observability on a small circuit
+func TestSCOAPMeasures(t *testing.T) {
+	// in1, in2 -> AND(g1) -> w1; w1, in2 -> OR(g2) -> out
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	m := testability.Compute(c, topo)
+
+	tests := []struct {
+		line         string
+		cc0, cc1, co int
+	}{
+		{"in1", 1, 1, 4},
+		{"in2", 1, 1, 3}, // Observed best through the OR gate
+		{"w1", 2, 3, 2},
+		{"out", 4, 2, 0},
+	}
+	for _, tt := range tests {
+		line := findLine(c, tt.line)
+		if m.CC0[line] != tt.cc0 || m.CC1[line] != tt.cc1 || m.CO[line] != tt.co {
+			t.Errorf("Expected %s CC0/CC1/CO = %d/%d/%d, got %d/%d/%d", tt.line,
+				tt.cc0, tt.cc1, tt.co, m.CC0[line], m.CC1[line], m.CO[line])
+		}
+	}
+}
+
+// TestSCOAPControlInput tests that the cached control input is the easiest to control
+func TestSCOAPControlInput(t *testing.T) {
+	c := createC17Circuit(t)
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	testability.Compute(c, topo)
+
+	// 19 = NAND(11, 7): the primary input 7 is easier to set to 0 than 11
+	gate := findLine(c, "19").InputGate
+	if gate.ControlID != 1 {
+		t.Errorf("Expected input 7 to be the control input of %s, got input %d", gate.Name, gate.ControlID)
+	}
+}
+
+// TestSCOAPDFrontierSelection tests that the most observable D-frontier gate is chosen
+func TestSCOAPDFrontierSelection(t *testing.T) {
+	c := createTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+
+	frontier := algorithm.NewFrontier(c, utils.NewLogger(utils.ErrorLevel))
+	frontier.DFrontier = []*circuit.Gate{findGate(c, "g1"), findGate(c, "g2")}
+
+	if gate := frontier.GetDFrontierGate(); gate.Name != "g1" {
+		t.Errorf("Expected structural selection to keep g1, got %s", gate.Name)
+	}
+
+	// g2 drives the primary output and is easier to observe
+	frontier.Measures = testability.Compute(c, topo)
+	if gate := frontier.GetDFrontierGate(); gate.Name != "g2" {
+		t.Errorf("Expected SCOAP selection to choose g2, got %s", gate.Name)
+	}
+}
+
+// TestHeuristicsFindValidTests tests both heuristics on every fault of c17
+func TestHeuristicsFindValidTests(t *testing.T) {
+	for _, heuristic := range []algorithm.Heuristic{algorithm.HeuristicStructural, algorithm.HeuristicSCOAP} {
+		c := createC17Circuit(t)
+		fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+		fan.Options.Heuristic = heuristic
+
+		for _, fault := range circuit.NewFaultList(c, false).All {
+			result := fan.GenerateTest(fault)
+			if result.Status != algorithm.Detected {
+				t.Errorf("%v: expected %s to be detected, got %v", heuristic, fault, result.Status)
+				continue
+			}
+			if fan.Simulator.Detect([]map[string]circuit.LogicValue{result.Test}, []circuit.Fault{fault})[0] != 0 {
+				t.Errorf("%v: test %v does not detect %s", heuristic, result.Test, fault)
+			}
+		}
+	}
+}
@@ -640,3 +640,25 @@ func createUniquePathTestCircuit() *circuit.Circuit {
 
 	return c
 }
+
+// TestDominators tests finding the lines every path to an output passes through
+func TestDominators(t *testing.T) {
+	c := createEnhancedSensitizationTestCircuit()
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+
+	tests := map[string][]string{
+		"w2":  {"w2", "w5", "out"}, // The paths over w3 and w4 reconverge on w5
+		"w3":  {"w3", "w5", "out"},
+		"in6": {"in6", "out"},
+	}
+	for start, want := range tests {
+		var got []string
+		for _, line := range topo.Dominators(findLine(c, start)) {
+			got = append(got, line.Name)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("Expected dominators %v of %s, got %v", want, start, got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// goodValues simulates the good machine for an input assignment and returns the line values
+func goodValues(c *circuit.Circuit, inputs map[string]circuit.LogicValue) map[*circuit.Line]circuit.LogicValue {
+	topo := circuit.NewTopology(c)
+	topo.Analyze()
+	c.Reset()
+	for _, input := range c.Inputs {
+		input.SetValue(inputs[input.Name])
+	}
+	for _, gate := range topo.LevelizedGates() {
+		gate.Output.SetValue(gate.Evaluate())
+	}
+
+	values := make(map[*circuit.Line]circuit.LogicValue)
+	for _, line := range c.Lines {
+		values[line] = line.Value
+	}
+	c.Reset()
+	return values
+}
+
+// detectsTransition checks a two-pattern test on the original circuit: V1 must set
+// the initial value, and the launch vector, made of V2 and the captured scan cells,
+// must detect the corresponding stuck-at fault
+func detectsTransition(t *testing.T, c *circuit.Circuit, fault circuit.TransitionFault, test circuit.TwoPatternTest) bool {
+	t.Helper()
+	initial := goodValues(c, test.V1)
+	if initial[fault.Line] != fault.InitialValue() {
+		return false
+	}
+
+	launch := make(map[string]circuit.LogicValue)
+	for name, value := range test.V2 {
+		launch[name] = value
+	}
+	for _, cell := range c.ScanCells {
+		launch[cell.Name] = initial[cell.D]
+	}
+
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	return fan.Simulator.Detect([]map[string]circuit.LogicValue{launch}, []circuit.Fault{fault.StuckAt()})[0] == 0
+}
+
+// TestTransitionFaults tests the transition fault list
+func TestTransitionFaults(t *testing.T) {
+	c := createC17Circuit(t)
+	stuck := circuit.NewFaultList(c, false).All
+	faults := circuit.NewTransitionFaults(c)
+
+	if len(faults) != len(stuck) {
+		t.Fatalf("Expected %d transition faults, got %d", len(stuck), len(faults))
+	}
+	for i, fault := range faults {
+		if fault.StuckAt() != stuck[i] {
+			t.Errorf("Expected %s to reduce to %s, got %s", fault, stuck[i], fault.StuckAt())
+		}
+	}
+	if faults[0].String() != "1/STR" || faults[1].String() != "1/STF" {
+		t.Errorf("Expected 1/STR and 1/STF first, got %s and %s", faults[0], faults[1])
+	}
+}
+
+// TestExpandLaunchOnCapture tests the two time-frame expansion of a full-scan circuit
+func TestExpandLaunchOnCapture(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	frames := circuit.ExpandLaunchOnCapture(c)
+	expanded := frames.Circuit
+
+	// Frame 1 has the primary inputs and scan loads, frame 2 only the primary inputs
+	if len(expanded.Inputs) != 11 {
+		t.Errorf("Expected 11 inputs, got %d", len(expanded.Inputs))
+	}
+	if len(expanded.Outputs) != 4 {
+		t.Errorf("Expected the primary output and 3 scan cells of frame 2 as outputs, got %d", len(expanded.Outputs))
+	}
+	if len(expanded.Gates) != 2*len(c.Gates)+len(c.ScanCells) {
+		t.Errorf("Expected two copies of every gate and a capture buffer per cell, got %d gates", len(expanded.Gates))
+	}
+
+	// G5 = DFF(G10): G5 in frame 2 holds the value G10 had in frame 1
+	q := frames.Line(findLine(c, "G5"), 2)
+	if q.Name != "G5@2" || q.Type == circuit.PrimaryInput {
+		t.Fatalf("Expected G5@2 to be an internal line, got %s", q.Name)
+	}
+	if q.InputGate == nil || q.InputGate.Type != circuit.BUF || q.InputGate.Inputs[0] != frames.Line(findLine(c, "G10"), 1) {
+		t.Errorf("Expected G5@2 to be captured from G10@1")
+	}
+	if frames.Line(findLine(c, "G17"), 1).Type == circuit.PrimaryOutput {
+		t.Errorf("Expected the outputs of frame 1 not to be observed")
+	}
+}
+
+// TestTransitionATPG tests that every transition fault of s27 gets a valid two-pattern test
+func TestTransitionATPG(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+
+	atpg := algorithm.NewTransitionATPG(c, utils.NewLogger(utils.ErrorLevel))
+	tests, err := atpg.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+	if len(tests) == 0 || atpg.Stats.AbortedFaults != 0 {
+		t.Fatalf("Expected tests and no aborted faults, got %d tests and %d aborted", len(tests), atpg.Stats.AbortedFaults)
+	}
+
+	for fault, result := range atpg.Results {
+		switch result.Status {
+		case algorithm.Detected:
+			if !detectsTransition(t, c, fault, *result.Test) {
+				t.Errorf("Test %v does not detect %s", *result.Test, fault)
+			}
+			if _, ok := result.Test.V2["G5"]; ok {
+				t.Errorf("Expected V2 of %s not to load the scan cells", fault)
+			}
+		case algorithm.Aborted:
+			t.Errorf("Expected %s not to be aborted: %s", fault, result.Reason)
+		}
+	}
+
+	if err := atpg.VerifyTests(tests); err != nil {
+		t.Errorf("Expected the tests to pass verification, got %v", err)
+	}
+	if err := atpg.VerifyTests(nil); !errors.Is(err, simulation.ErrNotDetected) {
+		t.Errorf("Expected no tests to fail verification, got %v", err)
+	}
+}
+
+// TestTransitionUntestable tests a transition fault that launch-on-capture cannot launch
+func TestTransitionUntestable(t *testing.T) {
+	// q captures a constant 0, so it can never rise in the second frame
+	c, err := parseBench(t, `INPUT(a)
+OUTPUT(z)
+q = DFF(k)
+n = NOT(a)
+k = AND(a, n)
+z = BUFF(q)
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	atpg := algorithm.NewTransitionATPG(c, utils.NewLogger(utils.ErrorLevel))
+
+	rise := atpg.GenerateTest(circuit.TransitionFault{Line: findLine(c, "q"), Type: circuit.SlowToRise})
+	if rise.Status != algorithm.Redundant {
+		t.Errorf("Expected q/STR to be untestable, got %v", rise.Status)
+	}
+
+	fall := atpg.GenerateTest(circuit.TransitionFault{Line: findLine(c, "q"), Type: circuit.SlowToFall})
+	if fall.Status != algorithm.Detected || !detectsTransition(t, c, fall.Fault, *fall.Test) {
+		t.Errorf("Expected a valid test for q/STF, got %v", fall.Status)
+	}
+}
+
+// TestParseTransitionFault tests parsing transition fault strings
+func TestParseTransitionFault(t *testing.T) {
+	c := createC17Circuit(t)
+
+	fault, err := utils.ParseTransitionFault("11->g2/str", c)
+	if err != nil {
+		t.Fatalf("Failed to parse fault: %v", err)
+	}
+	if fault.Line.Name != "11" || fault.Branch == nil || fault.Branch.Name != "g2" || fault.Type != circuit.SlowToRise {
+		t.Errorf("Expected 11->g2/STR, got %s", fault)
+	}
+
+	if _, err := utils.ParseTransitionFault("11/0", c); err == nil {
+		t.Errorf("Expected an error for a stuck-at fault string")
+	}
+}
+
+// TestXORPropagatesFaultEffect tests that XOR gates pass D and D' on
+func TestXORPropagatesFaultEffect(t *testing.T) {
+	a := circuit.NewLine(0, "a", circuit.PrimaryInput)
+	b := circuit.NewLine(1, "b", circuit.PrimaryInput)
+	d := circuit.NewLine(2, "d", circuit.PrimaryInput)
+	out := circuit.NewLine(3, "out", circuit.PrimaryOutput)
+	gate := circuit.NewGate(0, "g0", circuit.XOR)
+	gate.AddInput(a)
+	gate.AddInput(b)
+	gate.AddInput(d)
+	gate.SetOutput(out)
+
+	tests := []struct {
+		a, b, d  circuit.LogicValue
+		expected circuit.LogicValue
+	}{
+		{circuit.One, circuit.One, circuit.One, circuit.One},
+		{circuit.D, circuit.Zero, circuit.Zero, circuit.D},
+		{circuit.D, circuit.One, circuit.Zero, circuit.Dnot},
+		{circuit.D, circuit.Dnot, circuit.Zero, circuit.One},
+		{circuit.D, circuit.D, circuit.One, circuit.One},
+		{circuit.D, circuit.X, circuit.One, circuit.X},
+	}
+	for _, tt := range tests {
+		a.Value, b.Value, d.Value = tt.a, tt.b, tt.d
+		if got := gate.Evaluate(); got != tt.expected {
+			t.Errorf("XOR(%v, %v, %v): expected %v, got %v", tt.a, tt.b, tt.d, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// brokenBench has one problem of every kind, listed with its source line
+const brokenBench = `# broken
+INPUT(a)
+INPUT(b)
+INPUT(u)
+OUTPUT(z)
+OUTPUT(q)
+x = AND(a, bb)
+z = LATCH(x, y)
+y = OR(z, a)
+x = NOT(a)
+w = NOT(a, b)
+INPUT c
+`
+
+// parseBenchWithPolicy writes a BENCH description to a temporary file and
+// parses it with a validation policy
+func parseBenchWithPolicy(t *testing.T, content string, policy utils.ValidationPolicy) (*circuit.Circuit, []utils.Diagnostic, error) {
+	t.Helper()
+	benchFile := filepath.Join(t.TempDir(), "broken.bench")
+	if err := os.WriteFile(benchFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test BENCH file: %v", err)
+	}
+	return utils.ParseBenchFileWithPolicy(benchFile, policy)
+}
+
+// TestValidationErrors tests that every problem is reported at its source line
+func TestValidationErrors(t *testing.T) {
+	c, diagnostics, err := parseBenchWithPolicy(t, brokenBench, utils.DefaultValidationPolicy())
+	var invalid *utils.ValidationError
+	if c != nil || !errors.As(err, &invalid) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	expected := []struct {
+		line     int
+		net      string
+		problem  utils.NetlistProblem
+		severity utils.Severity
+	}{
+		{4, "u", utils.UnusedNet, utils.Warning},
+		{6, "q", utils.UndrivenNet, utils.Error},
+		{7, "bb", utils.UndrivenNet, utils.Error},
+		{8, "z", utils.UnknownGate, utils.Error},
+		{8, "z", utils.CombinationalLoop, utils.Error},
+		{10, "x", utils.MultipleDrivers, utils.Error},
+		{11, "w", utils.WrongArity, utils.Error},
+		{11, "w", utils.UnusedNet, utils.Warning},
+		{12, "", utils.SyntaxProblem, utils.Error},
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(expected), len(diagnostics), diagnostics)
+	}
+	for i, e := range expected {
+		d := diagnostics[i]
+		if d.Line != e.line || d.Net != e.net || d.Problem != e.problem || d.Severity != e.severity {
+			t.Errorf("Expected %v %v of %q at line %d, got %v", e.severity, e.problem, e.net, e.line, d)
+		}
+	}
+	if len(invalid.Diagnostics) != 7 {
+		t.Errorf("Expected the 7 errors in the validation error, got %d", len(invalid.Diagnostics))
+	}
+
+	for _, message := range []string{
+		"broken.bench:7: error: net bb is used but never driven",
+		"broken.bench:8: error: net z: unsupported gate type LATCH",
+		"broken.bench:8: error: combinational loop through z, y, cut at input y of z",
+		"broken.bench:10: error: net x is already driven at line 7",
+		"broken.bench:12: error: cannot parse \"INPUT c\"",
+	} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected %q in the error, got:\n%v", message, err)
+		}
+	}
+	if strings.Contains(err.Error(), "never used") {
+		t.Errorf("Expected warnings to be left out of the error, got:\n%v", err)
+	}
+
+	if got := invalid.Summary("broken.bench"); got != "7 problems in broken.bench" {
+		t.Errorf("Expected the summary to count 7 problems, got %q", got)
+	}
+	single := &utils.ValidationError{Diagnostics: invalid.Diagnostics[:1]}
+	if got := single.Summary("loop.bench"); got != "1 problem in loop.bench" {
+		t.Errorf("Expected the singular for one problem, got %q", got)
+	}
+}
+
+// TestValidationWarnings tests that a netlist with problems demoted to warnings
+// is still built, with loops cut and the first driver of a net kept
+func TestValidationWarnings(t *testing.T) {
+	policy, err := utils.ParseValidationPolicy("all=warning,unused=ignore")
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	c, diagnostics, err := parseBenchWithPolicy(t, brokenBench, policy)
+	if err != nil {
+		t.Fatalf("Expected warnings only, got %v", err)
+	}
+	if len(diagnostics) != 7 {
+		t.Errorf("Expected 7 warnings, got %d: %v", len(diagnostics), diagnostics)
+	}
+	for _, d := range diagnostics {
+		if d.Severity != utils.Warning || d.Problem == utils.UnusedNet {
+			t.Errorf("Expected only warnings of reported problems, got %v", d)
+		}
+	}
+
+	if x := findLine(c, "x"); x.InputGate == nil || x.InputGate.Type != circuit.AND {
+		t.Errorf("Expected x to keep its first driver, got %v", x.InputGate)
+	}
+	if w := findLine(c, "w"); len(w.InputGate.Inputs) != 1 {
+		t.Errorf("Expected the extra input of w to be dropped, got %d inputs", len(w.InputGate.Inputs))
+	}
+	if y := findLine(c, "y$loop"); y == nil || y.InputGate != nil || findLine(c, "z").InputGate.Inputs[1] != y {
+		t.Errorf("Expected the loop to be cut at input y of z by an undriven net y$loop")
+	}
+	topo := circuit.NewTopology(c)
+	topo.ComputeLevels()
+	for _, line := range c.Lines {
+		if _, ok := topo.LevelMap[line]; !ok {
+			t.Errorf("Expected line %s to be levelized once the loop is cut", line.Name)
+		}
+	}
+
+	// A valid netlist has nothing to report
+	if _, diagnostics, err = parseBenchWithPolicy(t, c17Bench, utils.DefaultValidationPolicy()); err != nil || len(diagnostics) != 0 {
+		t.Errorf("Expected c17 to be valid, got %v: %v", diagnostics, err)
+	}
+}
+
+// TestValidationLoops tests loop detection through flip-flops and nested loops
+func TestValidationLoops(t *testing.T) {
+	// A loop through a flip-flop is sequential, not combinational
+	if _, _, err := parseBenchWithPolicy(t, `INPUT(a)
+OUTPUT(z)
+q = DFF(z)
+z = AND(a, q)
+`, utils.DefaultValidationPolicy()); err != nil {
+		t.Errorf("Expected a loop through a flip-flop to be accepted, got %v", err)
+	}
+
+	// Two loops through x are reported once and both cut
+	policy, _ := utils.ParseValidationPolicy("loop=warning")
+	c, diagnostics, err := parseBenchWithPolicy(t, `INPUT(a)
+INPUT(b)
+OUTPUT(z)
+y = OR(x, b)
+x = AND(a, y, z)
+z = OR(x, b)
+`, policy)
+	if err != nil || len(diagnostics) != 1 || diagnostics[0].Line != 4 || diagnostics[0].Problem != utils.CombinationalLoop {
+		t.Fatalf("Expected one loop at line 4, got %v: %v", diagnostics, err)
+	}
+	topo := circuit.NewTopology(c)
+	topo.ComputeLevels()
+	if len(topo.LevelMap) != len(c.Lines) {
+		t.Errorf("Expected every line to be levelized, got %d of %d", len(topo.LevelMap), len(c.Lines))
+	}
+}
+
+// TestParseValidationPolicy tests the problem=severity settings
+func TestParseValidationPolicy(t *testing.T) {
+	policy, err := utils.ParseValidationPolicy("unused=error, loop=ignore")
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	if policy[utils.UnusedNet] != utils.Error || policy[utils.CombinationalLoop] != utils.Ignore || policy[utils.UndrivenNet] != utils.Error {
+		t.Errorf("Expected the settings on top of the defaults, got %v", policy)
+	}
+
+	for _, spec := range []string{"unused", "unused=fatal", "typo=error"} {
+		if _, err := utils.ParseValidationPolicy(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
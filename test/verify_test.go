@@ -0,0 +1,159 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/simulation"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestVerifier tests the independent good/faulty machine simulation
+func TestVerifier(t *testing.T) {
+	c := createC17Circuit(t)
+	v := simulation.NewVerifier(c)
+	zero, one, x := circuit.Zero, circuit.One, circuit.X
+	stuck1 := circuit.Fault{Line: findLine(c, "16"), Type: one}
+
+	tests := []struct {
+		name   string
+		test   map[string]circuit.LogicValue
+		fault  circuit.Fault
+		reason string // Expected part of the error, empty if the test detects the fault
+	}{
+		// 16 = NAND(2, 11) is 0 and reaches 22 through 10 = 1
+		{"detected", map[string]circuit.LogicValue{"1": zero, "2": one, "3": one, "6": zero, "7": x}, stuck1, ""},
+		{"not activated", map[string]circuit.LogicValue{"1": zero, "2": zero, "3": one, "6": zero, "7": x}, stuck1, "not activated"},
+		// 16 = 0 but 10 = X and 19 = X block both outputs
+		{"X blocks", map[string]circuit.LogicValue{"1": x, "2": one, "3": x, "6": zero, "7": x}, stuck1, "no output differs"},
+		// 11 -> 16 branch stuck-at-0: 16 becomes 1 and reaches 23 through 19 = 1
+		{"branch", map[string]circuit.LogicValue{"1": x, "2": one, "3": one, "6": zero, "7": zero},
+			circuit.Fault{Line: findLine(c, "11"), Type: zero, Branch: findLine(c, "16").InputGate}, ""},
+	}
+	for _, tt := range tests {
+		err := v.Verify(tt.test, tt.fault)
+		switch {
+		case tt.reason == "" && err != nil:
+			t.Errorf("%s: expected %v to detect %s, got %v", tt.name, tt.test, tt.fault, err)
+		case tt.reason != "" && (err == nil || !strings.Contains(err.Error(), tt.reason)):
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.reason, err)
+		case tt.reason != "" && !errors.Is(err, simulation.ErrNotDetected):
+			t.Errorf("%s: expected the error to wrap ErrNotDetected, got %v", tt.name, err)
+		}
+	}
+
+	// A constraint that does not hold rejects the test
+	detected := tests[0].test
+	if err := v.Verify(detected, stuck1, circuit.Constraint{Line: findLine(c, "10"), Value: zero}); err == nil {
+		t.Errorf("Expected a violated constraint to reject the test")
+	}
+	if err := v.Verify(detected, stuck1, circuit.Constraint{Line: findLine(c, "10"), Value: one}); err != nil {
+		t.Errorf("Expected a holding constraint to keep the test, got %v", err)
+	}
+	for _, line := range c.Lines {
+		if line.Value != x {
+			t.Errorf("Expected the verifier to leave line %s unassigned, got %v", line.Name, line.Value)
+		}
+	}
+}
+
+// TestVerifierAgreesWithFaultSimulation tests the verifier against the fault
+// simulator on every input combination of c17
+func TestVerifierAgreesWithFaultSimulation(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	faults := circuit.NewFaultList(c, false).All
+	values := []circuit.LogicValue{circuit.Zero, circuit.One, circuit.X}
+
+	combinations := 1
+	for range c.Inputs {
+		combinations *= len(values)
+	}
+	for n := 0; n < combinations; n++ {
+		test := make(map[string]circuit.LogicValue)
+		for i, k := 0, n; i < len(c.Inputs); i, k = i+1, k/len(values) {
+			test[c.Inputs[i].Name] = values[k%len(values)]
+		}
+		first := fan.Simulator.Detect([]map[string]circuit.LogicValue{test}, faults)
+		for i, fault := range faults {
+			if got := fan.Verifier.Detects(test, fault); got != (first[i] == 0) {
+				t.Fatalf("Test %v on %s: verifier says %v, fault simulation %v", test, fault, got, first[i] == 0)
+			}
+		}
+	}
+}
+
+// TestVerifierRepeatedInput tests a gate that reads the same net on two pins:
+// it must still be simulated after its driver
+func TestVerifierRepeatedInput(t *testing.T) {
+	c, err := parseBench(t, `
+INPUT(a)
+INPUT(c)
+OUTPUT(z)
+b = NOT(a)
+d = NOT(c)
+z = OR(b, d, b)
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	v := simulation.NewVerifier(c)
+	fault := circuit.Fault{Line: findLine(c, "a"), Type: circuit.Zero}
+	if err := v.Verify(map[string]circuit.LogicValue{"a": circuit.One, "c": circuit.One}, fault); err != nil {
+		t.Errorf("Expected a = 1, c = 1 to detect a/0, got %v", err)
+	}
+
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	if result := fan.GenerateTest(fault); result.Status != algorithm.Detected {
+		t.Errorf("Expected a/0 to be detected, got %v (%s)", result.Status, result.Reason)
+	}
+}
+
+// TestVerificationMismatch tests that a test rejected by verification is a hard error
+func TestVerificationMismatch(t *testing.T) {
+	fan := algorithm.NewFan(createC17Circuit(t), utils.NewLogger(utils.ErrorLevel))
+
+	// A verifier for a circuit where output 22 is an AND rejects the tests of c17
+	other, err := parseBench(t, strings.Replace(c17Bench, "22 = NAND(10, 16)", "22 = AND(10, 16)", 1))
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	fan.Verifier = simulation.NewVerifier(other)
+
+	fault := circuit.Fault{Line: findLine(fan.Circuit, "10"), Type: circuit.One}
+	result := fan.GenerateTest(fault)
+	if result.Status != algorithm.Aborted || result.Mismatch == nil || result.Test != nil {
+		t.Fatalf("Expected the test to be rejected, got %v with %v", result.Status, result.Test)
+	}
+	if !errors.Is(result.Err(), simulation.ErrNotDetected) {
+		t.Errorf("Expected the error to wrap ErrNotDetected, got %v", result.Err())
+	}
+
+	if _, err := fan.GenerateTestsForAllFaults(); !errors.Is(err, simulation.ErrNotDetected) {
+		t.Errorf("Expected the run to fail verification, got %v", err)
+	}
+}
+
+// TestVerifyTests tests the verification of a final test set
+func TestVerifyTests(t *testing.T) {
+	c := createC17Circuit(t)
+	fan := algorithm.NewFan(c, utils.NewLogger(utils.ErrorLevel))
+	testVectors, err := fan.GenerateTestsForAllFaults()
+	if err != nil {
+		t.Fatalf("Failed to generate tests: %v", err)
+	}
+
+	compacted := fan.CompactTests(testVectors)
+	tests := c.FillTests(compacted, circuit.FillRandom, 5)
+	if err := fan.VerifyTests(tests); err != nil {
+		t.Errorf("Expected the final tests to pass verification, got %v", err)
+	}
+
+	// After reverse-order compaction the first test detects a fault no other test does
+	if err := fan.VerifyTests(compacted[1:]); !errors.Is(err, simulation.ErrNotDetected) {
+		t.Errorf("Expected a missing test to fail verification, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// TestParseVerilogFile tests parsing a structural Verilog netlist
+func TestParseVerilogFile(t *testing.T) {
+	verilogContent := `// Simple test circuit
+module test_circuit (a, b, f);
+  input a, b;
+  output f;
+  wire d, e;
+
+  and g1 (d, a, b);
+  not (e, b);    /* unnamed instance */
+  or g3 (f, d, e);
+endmodule
+`
+	c := parseVerilogString(t, "test_circuit.v", verilogContent)
+
+	if c.Name != "test_circuit" {
+		t.Errorf("Expected circuit name 'test_circuit', got '%s'", c.Name)
+	}
+	if len(c.Gates) != 3 {
+		t.Errorf("Expected 3 gates, got %d", len(c.Gates))
+	}
+	if len(c.Lines) != 5 {
+		t.Errorf("Expected 5 lines, got %d", len(c.Lines))
+	}
+	if len(c.Inputs) != 2 || len(c.Outputs) != 1 {
+		t.Errorf("Expected 2 inputs and 1 output, got %d and %d", len(c.Inputs), len(c.Outputs))
+	}
+
+	g1 := findGate(c, "g1")
+	if g1 == nil || g1.Type != circuit.AND || g1.Output.Name != "d" || len(g1.Inputs) != 2 {
+		t.Errorf("Expected AND gate g1 driving d, got %v", g1)
+	}
+
+	// b fans out to the AND and the NOT gate
+	b := findLine(c, "b")
+	if b == nil || len(b.OutputGates) != 2 {
+		t.Errorf("Expected b to fan out to 2 gates")
+	}
+}
+
+// TestParseVerilogAssign tests lowering continuous assignments to gates
+func TestParseVerilogAssign(t *testing.T) {
+	verilogContent := `module assigns (
+  input a, b, c,
+  output y, z, w
+);
+  assign y = ~(a & b & c);
+  assign z = (a | b) ^ c;
+  assign w = a;
+endmodule
+`
+	c := parseVerilogString(t, "assigns.v", verilogContent)
+
+	y := findLine(c, "y")
+	if y == nil || y.InputGate == nil || y.InputGate.Type != circuit.NAND || len(y.InputGate.Inputs) != 3 {
+		t.Fatalf("Expected y to be driven by a 3-input NAND")
+	}
+
+	z := findLine(c, "z")
+	if z == nil || z.InputGate == nil || z.InputGate.Type != circuit.XOR {
+		t.Fatalf("Expected z to be driven by an XOR")
+	}
+	orInput := z.InputGate.Inputs[0]
+	if orInput.InputGate == nil || orInput.InputGate.Type != circuit.OR {
+		t.Errorf("Expected the first XOR input to be driven by an OR, got %v", orInput.InputGate)
+	}
+
+	w := findLine(c, "w")
+	if w == nil || w.InputGate == nil || w.InputGate.Type != circuit.BUF {
+		t.Errorf("Expected w to be driven by a buffer")
+	}
+}
+
+// TestParseVerilogBus tests bus declarations and bit selects
+func TestParseVerilogBus(t *testing.T) {
+	verilogContent := `module bus (a, y);
+  input [1:0] a;
+  output y;
+  xor x1 (y, a[1], a[0]);
+endmodule
+`
+	c := parseVerilogString(t, "bus.v", verilogContent)
+
+	if len(c.Inputs) != 2 {
+		t.Fatalf("Expected 2 input bits, got %d", len(c.Inputs))
+	}
+	if findLine(c, "a[1]") == nil || findLine(c, "a[0]") == nil {
+		t.Errorf("Expected bit lines a[1] and a[0]")
+	}
+}
+
+// TestParseVerilogANSIRange tests that an ANSI port range applies up to the next direction
+func TestParseVerilogANSIRange(t *testing.T) {
+	verilogContent := `module m (input [1:0] a, b, output y);
+  assign y = a[1] & a[0] & b[1] & b[0];
+endmodule
+`
+	c := parseVerilogString(t, "ansi.v", verilogContent)
+
+	if len(c.Inputs) != 4 || len(c.Outputs) != 1 {
+		t.Fatalf("Expected 4 input bits and 1 output, got %d and %d", len(c.Inputs), len(c.Outputs))
+	}
+	if findLine(c, "b[1]") == nil || findLine(c, "b[0]") == nil {
+		t.Errorf("Expected b to be a 2-bit port")
+	}
+}
+
+// TestParseVerilogWideXor tests that xor and xnor instances with more than two inputs are decomposed
+func TestParseVerilogWideXor(t *testing.T) {
+	verilogContent := `module m (a, b, c, y, z);
+  input a, b, c;
+  output y, z;
+  xor x1 (y, a, b, c);
+  xnor x2 (z, a, b, c);
+endmodule
+`
+	c := parseVerilogString(t, "xor.v", verilogContent)
+
+	for _, tt := range []struct {
+		output   string
+		gateType circuit.GateType
+	}{{"y", circuit.XOR}, {"z", circuit.XNOR}} {
+		gate := findLine(c, tt.output).InputGate
+		if gate == nil || gate.Type != tt.gateType || len(gate.Inputs) != 2 {
+			t.Fatalf("Expected %s to be driven by a 2-input %v, got %v", tt.output, tt.gateType, gate)
+		}
+		inner := gate.Inputs[0].InputGate
+		if inner == nil || inner.Type != circuit.XOR || len(inner.Inputs) != 2 {
+			t.Errorf("Expected the first input of %s to be driven by a 2-input XOR, got %v", gate.Name, inner)
+		}
+	}
+}
+
+// TestParseVerilogErrors tests that errors report the file and line
+func TestParseVerilogErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name: "unknown cell",
+			content: `module m (a, y);
+  input a;
+  output y;
+  DFFX1 u1 (y, a);
+endmodule
+`,
+			expected: "bad.v:4:",
+		},
+		{
+			name: "multiple drivers",
+			content: `module m (a, b, y);
+  input a, b;
+  output y;
+  and (y, a, b);
+  or (y, a, b);
+endmodule
+`,
+			expected: "bad.v:5: net y is already driven at line 4",
+		},
+		{
+			name: "missing semicolon",
+			content: `module m (a, y);
+  input a
+  output y;
+endmodule
+`,
+			expected: "bad.v:3:",
+		},
+		{
+			name: "undeclared net",
+			content: `module m (a, y);
+  input a;
+  output y;
+  and (y, a, b);
+endmodule
+`,
+			expected: "bad.v:4: undeclared net b",
+		},
+		{
+			name: "undriven net",
+			content: `module m (a, y);
+  input a;
+  output y;
+  wire w;
+  and (y, a, w);
+endmodule
+`,
+			expected: "bad.v:5: net w is read but never driven",
+		},
+		{
+			name: "flip-flop without data input",
+			content: `module m (a, y);
+  input a;
+  output y;
+  wire q;
+  dff (q);
+  and (y, a, q);
+endmodule
+`,
+			expected: "bad.v:5: dff instance must connect (Q, D) or (CK, Q, D)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "bad.v")
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create test Verilog file: %v", err)
+			}
+
+			_, err := utils.ParseVerilogFile(file)
+			if err == nil {
+				t.Fatalf("Expected parse error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %q", tt.expected, err.Error())
+			}
+		})
+	}
+}
+
+// TestParseVerilogDFF tests that flip-flops become scan cells as in BENCH
+func TestParseVerilogDFF(t *testing.T) {
+	c := parseVerilogString(t, "seq.v", `module seq (CK, a, y);
+  input CK, a;
+  output y;
+  wire q0, q1, d0;
+
+  dff r0 (CK, q0, d0);
+  dff (q1, a);
+  and (d0, a, q1);
+  not (y, q0);
+endmodule
+`)
+
+	if len(c.ScanCells) != 2 {
+		t.Fatalf("Expected 2 scan cells, got %d", len(c.ScanCells))
+	}
+	if cell := c.ScanCells[0]; cell.Name != "q0" || cell.D.Name != "d0" || cell.Q.Type != circuit.PrimaryInput {
+		t.Errorf("Expected q0 to capture d0 as a pseudo-primary input, got %s capturing %s", cell.Name, cell.D.Name)
+	}
+	// An input captured by a flip-flop is observed through a buffer
+	if cell := c.ScanCells[1]; cell.D.Name != "q1$D" || cell.D.InputGate.Inputs[0].Name != "a" {
+		t.Errorf("Expected q1 to capture a through a buffer, got %s", cell.D.Name)
+	}
+	if len(c.PrimaryInputs()) != 2 || len(c.PrimaryOutputs()) != 1 {
+		t.Errorf("Expected the clock and a as inputs and y as output, got %v and %v", c.PrimaryInputs(), c.PrimaryOutputs())
+	}
+}
+
+// parseVerilogString writes Verilog source to a temporary file and parses it
+func parseVerilogString(t *testing.T, name, content string) *circuit.Circuit {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test Verilog file: %v", err)
+	}
+
+	c, err := utils.ParseNetlistFile(file)
+	if err != nil {
+		t.Fatalf("Failed to parse Verilog file: %v", err)
+	}
+	return c
+}
@@ -0,0 +1,187 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fyerfyer/fan-atpg/pkg/algorithm"
+	"github.com/fyerfyer/fan-atpg/pkg/circuit"
+	"github.com/fyerfyer/fan-atpg/pkg/utils"
+)
+
+// writeNetlist writes a circuit to a temporary file of the given name, whose
+// extension selects the format, and returns the file
+func writeNetlist(t *testing.T, c *circuit.Circuit, name string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := utils.WriteNetlistFile(file, c); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return file
+}
+
+// lineNames returns the names of a list of lines
+func lineNames(lines []*circuit.Line) []string {
+	names := make([]string, len(lines))
+	for i, line := range lines {
+		names[i] = line.Name
+	}
+	return names
+}
+
+// checkRoundTrip checks that a circuit read back from a netlist file has the
+// same nets, ports, scan cells and gates as the circuit written
+func checkRoundTrip(t *testing.T, c, written *circuit.Circuit) {
+	t.Helper()
+	if !reflect.DeepEqual(lineNames(written.PrimaryInputs()), lineNames(c.PrimaryInputs())) ||
+		!reflect.DeepEqual(lineNames(written.PrimaryOutputs()), lineNames(c.PrimaryOutputs())) {
+		t.Errorf("%s: expected ports %v and %v, got %v and %v", c.Name,
+			lineNames(c.PrimaryInputs()), lineNames(c.PrimaryOutputs()),
+			lineNames(written.PrimaryInputs()), lineNames(written.PrimaryOutputs()))
+	}
+	if len(written.Gates) != len(c.Gates) || len(written.Lines) != len(c.Lines) || len(written.ScanCells) != len(c.ScanCells) {
+		t.Errorf("%s: expected %d gates, %d lines and %d scan cells, got %d, %d and %d", c.Name,
+			len(c.Gates), len(c.Lines), len(c.ScanCells), len(written.Gates), len(written.Lines), len(written.ScanCells))
+	}
+	for i, cell := range c.ScanCells {
+		if i < len(written.ScanCells) && (written.ScanCells[i].Name != cell.Name || written.ScanCells[i].D.Name != cell.D.Name) {
+			t.Errorf("%s: expected scan cell %s capturing %s, got %s capturing %s", c.Name,
+				cell.Name, cell.D.Name, written.ScanCells[i].Name, written.ScanCells[i].D.Name)
+		}
+	}
+	for _, gate := range c.Gates {
+		line := findLine(written, gate.Output.Name)
+		if line == nil || line.InputGate == nil || line.InputGate.Type != gate.Type ||
+			!reflect.DeepEqual(lineNames(line.InputGate.Inputs), lineNames(gate.Inputs)) {
+			t.Errorf("%s: expected %s = %v%v", c.Name, gate.Output.Name, gate.Type, lineNames(gate.Inputs))
+		}
+	}
+}
+
+// TestWriteBench tests that a written BENCH netlist reads back as the same circuit
+func TestWriteBench(t *testing.T) {
+	logger := utils.NewLogger(utils.ErrorLevel)
+	for _, content := range []string{c17Bench, s27Bench} {
+		c, err := parseBench(t, content)
+		if err != nil {
+			t.Fatalf("Failed to parse circuit: %v", err)
+		}
+		written, err := utils.ParseBenchFile(writeNetlist(t, c, c.Name+".bench"))
+		if err != nil {
+			t.Fatalf("Failed to read back %s: %v", c.Name, err)
+		}
+		checkRoundTrip(t, c, written)
+
+		checker, err := algorithm.NewEquivalenceChecker(c, written, logger)
+		if err != nil {
+			t.Fatalf("Failed to compare %s: %v", c.Name, err)
+		}
+		if result := checker.Check(); result.Status != algorithm.Equivalent || len(checker.Miter.Names) != 0 {
+			t.Errorf("%s: expected the written circuit to share every output, got %v on %v", c.Name, result.Status, checker.Miter.Names)
+		}
+	}
+}
+
+// TestWriteVerilog tests that a written Verilog netlist reads back as the same
+// circuit, with the numbered nets of ISCAS netlists as escaped identifiers
+func TestWriteVerilog(t *testing.T) {
+	for _, content := range []string{c17Bench, s27Bench} {
+		c, err := parseBench(t, content)
+		if err != nil {
+			t.Fatalf("Failed to parse circuit: %v", err)
+		}
+		file := writeNetlist(t, c, c.Name+".v")
+		written, err := utils.ParseVerilogFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read back %s: %v", c.Name, err)
+		}
+		checkRoundTrip(t, c, written)
+		for _, gate := range c.Gates {
+			if findGate(written, gate.Name) == nil {
+				t.Errorf("%s: expected gate %s to keep its instance name", c.Name, gate.Name)
+			}
+		}
+	}
+
+	c := createC17Circuit(t)
+	content, err := os.ReadFile(writeNetlist(t, c, "c17.v"))
+	if err != nil {
+		t.Fatalf("Failed to read netlist: %v", err)
+	}
+	for _, line := range []string{"module c17(\\1 , \\2 , \\3 , \\6 , \\7 , \\22 , \\23 );", "  input \\1 ;", "  nand g0 (\\10 , \\1 , \\3 );"} {
+		if !strings.Contains(string(content), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, content)
+		}
+	}
+}
+
+// TestWriteDeterministic tests that the output only depends on the circuit:
+// writing it twice, or writing the circuit read back, gives the same file
+func TestWriteDeterministic(t *testing.T) {
+	c, err := parseBench(t, s27Bench)
+	if err != nil {
+		t.Fatalf("Failed to parse s27: %v", err)
+	}
+	for _, name := range []string{"s27.bench", "s27.v"} {
+		first, _ := os.ReadFile(writeNetlist(t, c, name))
+		second, _ := os.ReadFile(writeNetlist(t, c, name))
+		if !bytes.Equal(first, second) {
+			t.Errorf("%s: expected identical output for the same circuit", name)
+		}
+	}
+
+	first := writeNetlist(t, c, "s27.bench")
+	written, err := utils.ParseBenchFile(first)
+	if err != nil {
+		t.Fatalf("Failed to read back s27: %v", err)
+	}
+	written.Name = c.Name // Read from the file name
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(writeNetlist(t, written, "s27.bench"))
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected writing s27 read back to give the same file:\n%s\n%s", a, b)
+	}
+
+	// Gates come in topological order whatever the order of the source
+	for i, gate := range written.SortedGates() {
+		for _, input := range gate.Inputs {
+			if input.InputGate != nil && input.InputGate.ID >= i {
+				t.Errorf("Gate %s reads %s before it is driven", gate.Name, input.Name)
+			}
+		}
+	}
+}
+
+// TestWriteMiter tests saving a miter, whose generated names BENCH and
+// Verilog must both keep
+func TestWriteMiter(t *testing.T) {
+	changed, err := parseBench(t, c17Changed)
+	if err != nil {
+		t.Fatalf("Failed to parse circuit: %v", err)
+	}
+	m, err := circuit.BuildMiter(createC17Circuit(t), changed)
+	if err != nil {
+		t.Fatalf("Failed to build miter: %v", err)
+	}
+
+	for _, name := range []string{"miter.bench", "miter.v"} {
+		written, err := utils.ParseNetlistFile(writeNetlist(t, m.Circuit, name))
+		if err != nil {
+			t.Fatalf("%s: failed to read back the miter: %v", name, err)
+		}
+		checkRoundTrip(t, m.Circuit, written)
+		if output := findLine(written, "22@xor"); output == nil || output.Type != circuit.PrimaryOutput {
+			t.Errorf("%s: expected the miter output 22@xor", name)
+		}
+	}
+
+	// BENCH has no way to write a name with a comma
+	m.Circuit.Outputs[0].Name = "22,xor"
+	if err := utils.WriteBench(filepath.Join(t.TempDir(), "miter.bench"), m.Circuit); err == nil {
+		t.Errorf("Expected a net name with a comma to be rejected")
+	}
+}